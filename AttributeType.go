@@ -17,6 +17,7 @@ const (
 	AttrReplyMessage         AttributeType = 18
 	AttrState                AttributeType = 24
 	AttrVendorSpecific       AttributeType = 26
+	AttrSessionTimeout       AttributeType = 27
 	AttrCalledStationId      AttributeType = 30
 	AttrCallingStationId     AttributeType = 31
 	AttrNASIdentifier        AttributeType = 32
@@ -85,19 +86,17 @@ const (
 )
 
 func getAttributeTypeDesc(t AttributeType) attributeTypeDesc {
-	defaultDictionaryMu.RLock()
-	d := defaultDictionary
-	defaultDictionaryMu.RUnlock()
-
-	if d != nil {
-		name := d.GetAttributeName(t)
-		if name != "" {
-			typeName := d.GetAttributeType(name)
-			handler := attrTypeHandlers[typeName]
+	if s := defaultSnapshot.Load(); s != nil {
+		if desc, ok := s.typeDesc(t); ok {
+			return desc
+		}
+	} else if d := GetDefaultDictionary(); d != nil {
+		if def, ok := d.GetAttributeDefByID(t); ok {
+			handler := def.handler()
 			if handler == nil {
 				handler = avpBinary
 			}
-			return attributeTypeDesc{name: name, dataType: handler}
+			return attributeTypeDesc{name: def.Name, dataType: handler}
 		}
 	}
 
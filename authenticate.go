@@ -0,0 +1,185 @@
+package radius
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"strconv"
+)
+
+// AuthMethod is the password authentication method of an Access-Request.
+type AuthMethod int
+
+const (
+	AuthMethodNone AuthMethod = iota
+	AuthMethodPAP
+	AuthMethodCHAP
+	AuthMethodMSCHAPv1
+	AuthMethodMSCHAPv2
+)
+
+func (m AuthMethod) String() string {
+	switch m {
+	case AuthMethodNone:
+		return "None"
+	case AuthMethodPAP:
+		return "PAP"
+	case AuthMethodCHAP:
+		return "CHAP"
+	case AuthMethodMSCHAPv1:
+		return "MS-CHAPv1"
+	case AuthMethodMSCHAPv2:
+		return "MS-CHAPv2"
+	default:
+		return "unknown AuthMethod " + strconv.Itoa(int(m))
+	}
+}
+
+// ErrNoCredentials is returned for Access-Requests without User-Password,
+// CHAP-Password or MS-CHAP attributes.
+var ErrNoCredentials = errors.New("no PAP, CHAP or MS-CHAP credentials")
+
+// ErrEmptyPassword is returned when the CredentialProvider returns an empty
+// password, which anyone could answer for.
+var ErrEmptyPassword = errors.New("empty password")
+
+// CredentialProvider returns the credentials of users for an Authenticator.
+// Errors reject the request; an MsChapError sets the MS-CHAP error code. An
+// empty password, as returned by a lookup in a map missing the user, rejects
+// the request with ErrEmptyPassword.
+type CredentialProvider interface {
+	// Password returns the cleartext password of a user.
+	Password(ctx context.Context, username string) (string, error)
+}
+
+// NTHashProvider may be implemented by a CredentialProvider storing NT-Hashes
+// (see MSCHAPv2NTHash) rather than passwords. It is then used for MS-CHAP,
+// while PAP and CHAP still need Password.
+type NTHashProvider interface {
+	NTHash(ctx context.Context, username string) ([]byte, error)
+}
+
+// PasswordFunc adapts a function to the CredentialProvider interface.
+type PasswordFunc func(ctx context.Context, username string) (string, error)
+
+// Password calls f(ctx, username).
+func (f PasswordFunc) Password(ctx context.Context, username string) (string, error) {
+	return f(ctx, username)
+}
+
+// DetectAuthMethod returns the method of the credentials of an
+// Access-Request, AuthMethodNone when it has none (for example with EAP).
+func DetectAuthMethod(p *Packet) AuthMethod {
+	switch {
+	case p.GetVSA(VendorMicrosoft, AttrMSCHAP2Response) != nil:
+		return AuthMethodMSCHAPv2
+	case p.GetVSA(VendorMicrosoft, AttrMSCHAPResponse) != nil:
+		return AuthMethodMSCHAPv1
+	case p.HasAVP(AttrCHAPPassword):
+		return AuthMethodCHAP
+	case p.HasAVP(AttrUserPassword):
+		return AuthMethodPAP
+	}
+	return AuthMethodNone
+}
+
+// Authenticator is a Service verifying the PAP, CHAP or MS-CHAP credentials
+// of Access-Requests against a CredentialProvider.
+type Authenticator struct {
+	Provider CredentialProvider
+}
+
+// NewAuthenticator returns an Authenticator looking up credentials in
+// provider.
+func NewAuthenticator(provider CredentialProvider) *Authenticator {
+	return &Authenticator{Provider: provider}
+}
+
+// Authenticate verifies the credentials of request, detecting the method
+// used by the NAS, and sets reply, created by request.Reply, to Access-Accept
+// or Access-Reject. MS-CHAP replies also carry MS-CHAP2-Success and the
+// MS-MPPE keys, or MS-CHAP-Error. The error tells why credentials could not
+// be verified; wrong credentials are only a reject.
+func (a *Authenticator) Authenticate(ctx context.Context, request, reply *Packet) (AuthMethod, error) {
+	reply.Code = AccessReject
+	method := DetectAuthMethod(request)
+	username := request.GetUsername()
+
+	switch method {
+	case AuthMethodPAP, AuthMethodCHAP:
+		password, err := a.Provider.Password(ctx, username)
+		if err != nil {
+			return method, err
+		}
+		if err := checkPassword(password); err != nil {
+			return method, err
+		}
+		var ok bool
+		if method == AuthMethodCHAP {
+			if ok, err = request.VerifyCHAP(password); err != nil {
+				return method, err
+			}
+		} else {
+			ok = subtle.ConstantTimeCompare([]byte(request.GetPassword()), []byte(password)) == 1
+		}
+		if ok {
+			reply.Code = AccessAccept
+		}
+		return method, nil
+	case AuthMethodMSCHAPv1, AuthMethodMSCHAPv2:
+		return method, a.msChap(ctx, request, reply)
+	}
+	return method, ErrNoCredentials
+}
+
+// msChap verifies MS-CHAP credentials (RFC 2548).
+func (a *Authenticator) msChap(ctx context.Context, request, reply *Packet) error {
+	r, err := request.GetMSCHAPRequest()
+	if err != nil {
+		return err
+	}
+	ntHash, err := a.ntHash(ctx, r.UserName)
+	if err != nil {
+		code := MsChapErrorAuthenticationFailure
+		errors.As(err, &code)
+		r.Reject(reply, code)
+		return err
+	}
+	// Verify fails for an empty password
+	ok, err := r.Verify(ntHash)
+	if err != nil {
+		r.Reject(reply, MsChapErrorAuthenticationFailure)
+		return err
+	}
+	if !ok {
+		r.Reject(reply, MsChapErrorAuthenticationFailure)
+		return nil
+	}
+	if err := r.Accept(reply, ntHash); err != nil {
+		return err
+	}
+	reply.Code = AccessAccept
+	return nil
+}
+
+func (a *Authenticator) ntHash(ctx context.Context, username string) ([]byte, error) {
+	if p, ok := a.Provider.(NTHashProvider); ok {
+		return p.NTHash(ctx, username)
+	}
+	password, err := a.Provider.Password(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	return MSCHAPv2NTHash(password), nil
+}
+
+// RadiusHandle answers an Access-Request with Access-Accept or
+// Access-Reject.
+func (a *Authenticator) RadiusHandle(ctx context.Context, request *Packet) *Packet {
+	reply := request.Reply()
+	if method, err := a.Authenticate(ctx, request, reply); err != nil {
+		log.Printf("%s authentication of %q failed: %v", method, request.GetUsername(), err)
+	}
+	return reply
+}
@@ -0,0 +1,142 @@
+package radius
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+// testCredentials knows alice, whose account may be disabled.
+type testCredentials struct{ ntHash bool }
+
+func (c testCredentials) Password(ctx context.Context, username string) (string, error) {
+	switch username {
+	case "alice":
+		return "secret", nil
+	case "bob":
+		return "", MsChapErrorAccountDisabled
+	}
+	return "", errors.New("unknown user")
+}
+
+// testNTHashCredentials stores NT-Hashes only.
+type testNTHashCredentials struct{ testCredentials }
+
+func (c testNTHashCredentials) NTHash(ctx context.Context, username string) ([]byte, error) {
+	if username != "alice" {
+		return nil, errors.New("unknown user")
+	}
+	return MSCHAPv2NTHash("secret"), nil
+}
+
+func TestAuthenticator(t *testing.T) {
+	challenge := bytes.Repeat([]byte{0x42}, 16)
+	request := func(username string, set func(p *Packet) error) *Packet {
+		p := Request(AccessRequest, "secret")
+		p.AddAVP(AVP{Type: AttrUserName, Value: []byte(username)})
+		if err := set(p); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	pap := func(password string) func(p *Packet) error {
+		return func(p *Packet) error { p.AddPassword(password); return nil }
+	}
+	chap := func(password string) func(p *Packet) error {
+		return func(p *Packet) error { return p.SetCHAPPasswordFromSecret(1, password, challenge[:8]) }
+	}
+	msChapV1 := func(password string) func(p *Packet) error {
+		return func(p *Packet) error { return p.SetMSCHAPv1FromSecret(1, password, challenge[:8]) }
+	}
+	msChapV2 := func(password string) func(p *Packet) error {
+		return func(p *Packet) error { return p.SetMSCHAPv2FromSecret(1, password, challenge) }
+	}
+
+	testCases := []struct {
+		name     string
+		provider CredentialProvider
+		request  *Packet
+		method   AuthMethod
+		want     PacketCode
+		err      bool
+	}{
+		{"PAP", testCredentials{}, request("alice", pap("secret")), AuthMethodPAP, AccessAccept, false},
+		{"PAP wrong password", testCredentials{}, request("alice", pap("secrets")), AuthMethodPAP, AccessReject, false},
+		{"PAP unknown user", testCredentials{}, request("carol", pap("secret")), AuthMethodPAP, AccessReject, true},
+		{"CHAP", testCredentials{}, request("alice", chap("secret")), AuthMethodCHAP, AccessAccept, false},
+		{"CHAP wrong password", testCredentials{}, request("alice", chap("wrong")), AuthMethodCHAP, AccessReject, false},
+		{"MS-CHAPv1", testCredentials{}, request("alice", msChapV1("secret")), AuthMethodMSCHAPv1, AccessAccept, false},
+		{"MS-CHAPv2", testCredentials{}, request("alice", msChapV2("secret")), AuthMethodMSCHAPv2, AccessAccept, false},
+		{"MS-CHAPv2 from NT-Hash", testNTHashCredentials{}, request("alice", msChapV2("secret")), AuthMethodMSCHAPv2, AccessAccept, false},
+		{"MS-CHAPv2 wrong password", testCredentials{}, request("alice", msChapV2("wrong")), AuthMethodMSCHAPv2, AccessReject, false},
+		{"MS-CHAPv2 disabled account", testCredentials{}, request("bob", msChapV2("secret")), AuthMethodMSCHAPv2, AccessReject, true},
+		{"no credentials", testCredentials{}, request("alice", func(*Packet) error { return nil }), AuthMethodNone, AccessReject, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := NewAuthenticator(tc.provider)
+			reply := tc.request.Reply()
+			method, err := a.Authenticate(context.Background(), tc.request, reply)
+			if method != tc.method || reply.Code != tc.want || (err != nil) != tc.err {
+				t.Fatalf("Authenticate() = %v, %v, %v; want %v, %v, error %v", method, reply.Code, err, tc.method, tc.want, tc.err)
+			}
+			if method == AuthMethodMSCHAPv2 && reply.Code == AccessAccept && reply.GetVSA(VendorMicrosoft, AttrMSCHAP2Success) == nil {
+				t.Errorf("MS-CHAPv2 Access-Accept lacks MS-CHAP2-Success")
+			}
+			if method == AuthMethodMSCHAPv2 && reply.Code == AccessReject && reply.GetVSA(VendorMicrosoft, AttrMSCHAPError) == nil {
+				t.Errorf("MS-CHAPv2 Access-Reject lacks MS-CHAP-Error")
+			}
+		})
+	}
+
+	// the disabled account code is reported to the NAS
+	p := request("bob", msChapV2("secret"))
+	reply := NewAuthenticator(testCredentials{}).RadiusHandle(context.Background(), p)
+	if vsa := reply.GetVSA(VendorMicrosoft, AttrMSCHAPError); vsa == nil || !bytes.Contains(vsa.Value, []byte("E=647")) {
+		t.Errorf("MS-CHAP-Error = %v; want E=647", vsa)
+	}
+}
+
+func TestPasswordFunc(t *testing.T) {
+	a := NewAuthenticator(PasswordFunc(func(ctx context.Context, username string) (string, error) {
+		return "pw", nil
+	}))
+	p := Request(AccessRequest, "secret")
+	p.AddAVP(AVP{Type: AttrUserName, Value: []byte("alice")})
+	resp, _ := ComputeCHAPResponse(7, "pw", p.Authenticator[:])
+	p.SetCHAPPassword(7, resp)
+	if reply := a.RadiusHandle(context.Background(), p); reply.Code != AccessAccept {
+		t.Errorf("reply = %v; want Access-Accept", reply.Code)
+	}
+}
+
+func TestAuthenticatorEmptyPassword(t *testing.T) {
+	passwords := map[string]string{"alice": "secret"}
+	a := NewAuthenticator(PasswordFunc(func(ctx context.Context, username string) (string, error) {
+		return passwords[username], nil
+	}))
+	challenge := bytes.Repeat([]byte{0x42}, 16)
+	testCases := []struct {
+		name string
+		set  func(p *Packet) error
+	}{
+		{"PAP", func(p *Packet) error { p.AddPassword(""); return nil }},
+		{"CHAP", func(p *Packet) error { return p.SetCHAPPasswordFromSecret(1, "", challenge[:8]) }},
+		{"MS-CHAPv1", func(p *Packet) error { return p.SetMSCHAPv1FromSecret(1, "", challenge[:8]) }},
+		{"MS-CHAPv2", func(p *Packet) error { return p.SetMSCHAPv2FromSecret(1, "", challenge) }},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := Request(AccessRequest, "secret")
+			p.AddAVP(AVP{Type: AttrUserName, Value: []byte("mallory")})
+			if err := tc.set(p); err != nil {
+				t.Fatal(err)
+			}
+			reply := p.Reply()
+			if _, err := a.Authenticate(context.Background(), p, reply); !errors.Is(err, ErrEmptyPassword) || reply.Code != AccessReject {
+				t.Errorf("Authenticate() = %v, %v; want Access-Reject, ErrEmptyPassword", reply.Code, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package radius
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Ascend binary filter layout (32 bytes):
+// Type (1), Forward (1), Direction (1), Fill (1), then for IP filters:
+// SrcIP (4), DstIP (4), SrcMask (1), DstMask (1), Proto (1), Established (1),
+// SrcPort (2), DstPort (2), SrcPortCmp (1), DstPortCmp (1), Fill (4+4).
+const abinaryFilterSize = 32
+
+const abinaryTypeIP = 1
+
+var abinaryProtoName = map[uint8]string{
+	1:  "icmp",
+	6:  "tcp",
+	17: "udp",
+	47: "gre",
+	50: "esp",
+	51: "ah",
+	89: "ospf",
+}
+
+var abinaryPortCmp = []string{"", "<", "=", ">", "!="}
+
+var avpABinary AvpABinary
+
+// AvpABinary handles the Ascend "abinary" filter data type.
+//
+// IP filters are rendered in FreeRADIUS text form, e.g.
+// "ip in forward srcip 10.0.0.0/8 tcp dstport = 22 est". Other filter types
+// are rendered and parsed as "0x" prefixed hex.
+type AvpABinary struct{}
+
+func (s AvpABinary) Value(p *Packet, a AVP) interface{} {
+	return a.Value
+}
+
+func (s AvpABinary) String(p *Packet, a AVP) string {
+	b := a.Value
+	if len(b) < 28 || b[0] != abinaryTypeIP {
+		return "0x" + hex.EncodeToString(b)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("ip")
+	if b[2] == 1 {
+		sb.WriteString(" in")
+	} else {
+		sb.WriteString(" out")
+	}
+	if b[1] == 1 {
+		sb.WriteString(" forward")
+	} else {
+		sb.WriteString(" drop")
+	}
+
+	ip := b[4:]
+	if !net.IP(ip[0:4]).Equal(net.IPv4zero) || ip[8] != 0 {
+		sb.WriteString(" srcip " + net.IP(ip[0:4]).String() + "/" + strconv.Itoa(int(ip[8])))
+	}
+	if !net.IP(ip[4:8]).Equal(net.IPv4zero) || ip[9] != 0 {
+		sb.WriteString(" dstip " + net.IP(ip[4:8]).String() + "/" + strconv.Itoa(int(ip[9])))
+	}
+	if proto := ip[10]; proto != 0 {
+		if name, ok := abinaryProtoName[proto]; ok {
+			sb.WriteString(" " + name)
+		} else {
+			sb.WriteString(" " + strconv.Itoa(int(proto)))
+		}
+	}
+	if cmp := ip[16]; cmp > 0 && int(cmp) < len(abinaryPortCmp) {
+		sb.WriteString(" srcport " + abinaryPortCmp[cmp] + " " + strconv.Itoa(int(binary.BigEndian.Uint16(ip[12:14]))))
+	}
+	if cmp := ip[17]; cmp > 0 && int(cmp) < len(abinaryPortCmp) {
+		sb.WriteString(" dstport " + abinaryPortCmp[cmp] + " " + strconv.Itoa(int(binary.BigEndian.Uint16(ip[14:16]))))
+	}
+	if ip[11] != 0 {
+		sb.WriteString(" est")
+	}
+	return sb.String()
+}
+
+func (s AvpABinary) FromString(value string) []byte {
+	if strings.HasPrefix(value, "0x") {
+		b, err := hex.DecodeString(value[2:])
+		if err != nil {
+			return nil
+		}
+		return b
+	}
+
+	f := splitLine(value)
+	if len(f) < 3 || f[0] != "ip" {
+		return nil
+	}
+
+	buf := make([]byte, abinaryFilterSize)
+	buf[0] = abinaryTypeIP
+	switch f[1] {
+	case "in":
+		buf[2] = 1
+	case "out":
+	default:
+		return nil
+	}
+	switch f[2] {
+	case "forward":
+		buf[1] = 1
+	case "drop":
+	default:
+		return nil
+	}
+
+	ip := buf[4:]
+	for i := 3; i < len(f); i++ {
+		switch f[i] {
+		case "srcip", "dstip":
+			if i+1 >= len(f) {
+				return nil
+			}
+			addr, mask, ok := parseABinaryIP(f[i+1])
+			if !ok {
+				return nil
+			}
+			if f[i] == "srcip" {
+				copy(ip[0:4], addr)
+				ip[8] = mask
+			} else {
+				copy(ip[4:8], addr)
+				ip[9] = mask
+			}
+			i++
+		case "srcport", "dstport":
+			if i+2 >= len(f) {
+				return nil
+			}
+			cmp := 0
+			for j := 1; j < len(abinaryPortCmp); j++ {
+				if abinaryPortCmp[j] == f[i+1] {
+					cmp = j
+				}
+			}
+			port, err := strconv.ParseUint(f[i+2], 10, 16)
+			if cmp == 0 || err != nil {
+				return nil
+			}
+			if f[i] == "srcport" {
+				binary.BigEndian.PutUint16(ip[12:14], uint16(port))
+				ip[16] = uint8(cmp)
+			} else {
+				binary.BigEndian.PutUint16(ip[14:16], uint16(port))
+				ip[17] = uint8(cmp)
+			}
+			i += 2
+		case "est":
+			ip[11] = 1
+		default:
+			proto, ok := abinaryProtoNumber(f[i])
+			if !ok {
+				return nil
+			}
+			ip[10] = proto
+		}
+	}
+	return buf
+}
+
+func parseABinaryIP(s string) (net.IP, uint8, bool) {
+	addr, bits := s, "32"
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		addr, bits = s[:i], s[i+1:]
+	}
+	ip := net.ParseIP(addr).To4()
+	mask, err := strconv.ParseUint(bits, 10, 8)
+	if ip == nil || err != nil || mask > 32 {
+		return nil, 0, false
+	}
+	return ip, uint8(mask), true
+}
+
+func abinaryProtoNumber(s string) (uint8, bool) {
+	for num, name := range abinaryProtoName {
+		if name == s {
+			return num, true
+		}
+	}
+	n, err := strconv.ParseUint(s, 10, 8)
+	if err != nil {
+		return 0, false
+	}
+	return uint8(n), true
+}
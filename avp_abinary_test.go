@@ -0,0 +1,59 @@
+package radius
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAvpABinary(t *testing.T) {
+	handler := avpABinary
+
+	testCases := []struct {
+		name   string
+		strVal string
+	}{
+		{"Minimal", "ip in forward"},
+		{"Drop", "ip out drop srcip 10.0.0.0/8"},
+		{"Full", "ip in forward srcip 10.1.0.0/16 dstip 192.0.2.1/32 tcp srcport > 1023 dstport = 22 est"},
+		{"ProtoNumber", "ip out forward dstip 192.0.2.0/24 132"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := handler.FromString(tc.strVal)
+			if len(b) != abinaryFilterSize {
+				t.Fatalf("FromString(%s) returned %d bytes; want %d", tc.strVal, len(b), abinaryFilterSize)
+			}
+			if res := handler.String(nil, AVP{Value: b}); res != tc.strVal {
+				t.Errorf("String() = %s; want %s", res, tc.strVal)
+			}
+		})
+	}
+
+	t.Run("Layout", func(t *testing.T) {
+		b := handler.FromString("ip in forward dstip 192.0.2.1/32 tcp dstport = 22")
+		want := make([]byte, abinaryFilterSize)
+		copy(want, []byte{1, 1, 1, 0, 0, 0, 0, 0, 192, 0, 2, 1, 0, 32, 6, 0, 0, 0, 0, 22, 0, 2})
+		if !bytes.Equal(b, want) {
+			t.Errorf("FromString = %v; want %v", b, want)
+		}
+	})
+
+	t.Run("Hex", func(t *testing.T) {
+		raw := []byte{2, 1, 0, 0, 0xde, 0xad}
+		if s := handler.String(nil, AVP{Value: raw}); s != "0x02010000dead" {
+			t.Errorf("String() = %s; want 0x02010000dead", s)
+		}
+		if b := handler.FromString("0x02010000dead"); !bytes.Equal(b, raw) {
+			t.Errorf("FromString = %v; want %v", b, raw)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, in := range []string{"ipx in forward", "ip sideways forward", "ip in forward srcip 10.0.0.1/33", "ip in forward dstport ~ 22"} {
+			if b := handler.FromString(in); b != nil {
+				t.Errorf("FromString(%s) expected nil; got %v", in, b)
+			}
+		}
+	})
+}
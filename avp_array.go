@@ -0,0 +1,87 @@
+package radius
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+// fixed element sizes for attributes flagged "array"
+var arrayElementSize = map[string]int{
+	"byte":      1,
+	"short":     2,
+	"integer":   4,
+	"signed":    4,
+	"date":      4,
+	"ipaddr":    4,
+	"integer64": 8,
+	"ifid":      8,
+	"ether":     6,
+	"ipv6addr":  16,
+}
+
+// AvpArray wraps a data type handler for attributes flagged "array", which
+// carry several values of the same type in one attribute.
+//
+// Fixed-size types are packed back to back. Variable-size types (string,
+// octets) are each prefixed with a 2-byte length.
+type AvpArray struct {
+	elem avpDataType
+	size int
+}
+
+// Elements splits the attribute value into per-element values.
+func (s AvpArray) Elements(b []byte) [][]byte {
+	var out [][]byte
+	for len(b) > 0 {
+		n := s.size
+		if n == 0 {
+			if len(b) < 2 {
+				break
+			}
+			n = int(binary.BigEndian.Uint16(b))
+			b = b[2:]
+		}
+		if n > len(b) {
+			break
+		}
+		out = append(out, b[:n])
+		b = b[n:]
+	}
+	return out
+}
+
+// Value returns the decoded elements as a []interface{}.
+func (s AvpArray) Value(p *Packet, a AVP) interface{} {
+	elems := s.Elements(a.Value)
+	out := make([]interface{}, len(elems))
+	for i, e := range elems {
+		out[i] = s.elem.Value(p, AVP{Type: a.Type, Value: e})
+	}
+	return out
+}
+
+// String returns the elements separated by ", ".
+func (s AvpArray) String(p *Packet, a AVP) string {
+	elems := s.Elements(a.Value)
+	out := make([]string, len(elems))
+	for i, e := range elems {
+		out[i] = s.elem.String(p, AVP{Type: a.Type, Value: e})
+	}
+	return strings.Join(out, ", ")
+}
+
+// FromString encodes a comma separated list of values.
+func (s AvpArray) FromString(v string) []byte {
+	var buf []byte
+	for _, item := range strings.Split(v, ",") {
+		e := s.elem.FromString(strings.TrimSpace(item))
+		if e == nil || (s.size > 0 && len(e) != s.size) {
+			return nil
+		}
+		if s.size == 0 {
+			buf = binary.BigEndian.AppendUint16(buf, uint16(len(e)))
+		}
+		buf = append(buf, e...)
+	}
+	return buf
+}
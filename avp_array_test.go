@@ -0,0 +1,51 @@
+package radius
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestAvpArray(t *testing.T) {
+	t.Run("IPAddr", func(t *testing.T) {
+		handler := AvpArray{elem: avpIP, size: 4}
+		b := handler.FromString("192.0.2.1, 192.0.2.2")
+		if !bytes.Equal(b, []byte{192, 0, 2, 1, 192, 0, 2, 2}) {
+			t.Fatalf("FromString() = %v", b)
+		}
+		v := handler.Value(nil, AVP{Value: b}).([]interface{})
+		if len(v) != 2 || !v[1].(net.IP).Equal(net.ParseIP("192.0.2.2")) {
+			t.Errorf("Value() = %v", v)
+		}
+		if s := handler.String(nil, AVP{Value: b}); s != "192.0.2.1, 192.0.2.2" {
+			t.Errorf("String() = %s", s)
+		}
+		if b := handler.FromString("192.0.2.1, 2001:db8::1"); b != nil {
+			t.Errorf("FromString() expected nil for mixed sizes; got %v", b)
+		}
+	})
+
+	t.Run("Short", func(t *testing.T) {
+		handler := AvpArray{elem: avpUint16, size: 2}
+		b := handler.FromString("1,2,3")
+		v := handler.Value(nil, AVP{Value: b}).([]interface{})
+		if len(v) != 3 || v[2].(uint16) != 3 {
+			t.Errorf("Value() = %v", v)
+		}
+	})
+
+	t.Run("String", func(t *testing.T) {
+		handler := AvpArray{elem: avpString}
+		b := handler.FromString("a,bc")
+		if !bytes.Equal(b, []byte{0, 1, 'a', 0, 2, 'b', 'c'}) {
+			t.Fatalf("FromString() = %v", b)
+		}
+		if s := handler.String(nil, AVP{Value: b}); s != "a, bc" {
+			t.Errorf("String() = %s", s)
+		}
+		// truncated trailing element is ignored
+		if e := handler.Elements(append(b, 0, 9, 'x')); len(e) != 2 {
+			t.Errorf("Elements() = %v", e)
+		}
+	})
+}
@@ -0,0 +1,45 @@
+package radius
+
+import (
+	"encoding/binary"
+	"strconv"
+	"time"
+)
+
+var avpDate AvpDate
+
+// AvpDate handles the "date" data type ("time", RFC 8044 §3.3): seconds since
+// 00:00:00 UTC, January 1, 1970.
+type AvpDate struct{}
+
+func (s AvpDate) Value(p *Packet, a AVP) interface{} {
+	if len(a.Value) < uint32Size {
+		return time.Time{}
+	}
+	return time.Unix(int64(binary.BigEndian.Uint32(a.Value)), 0).UTC()
+}
+
+func (s AvpDate) String(p *Packet, a AVP) string {
+	if len(a.Value) < uint32Size {
+		return "invalid"
+	}
+	return s.Value(p, a).(time.Time).Format(time.RFC3339)
+}
+
+// FromString accepts RFC 3339 timestamps or a plain number of seconds since the epoch.
+func (s AvpDate) FromString(value string) []byte {
+	var sec int64
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		sec = t.Unix()
+	} else if i, err := strconv.ParseUint(value, 0, 32); err == nil {
+		sec = int64(i)
+	} else {
+		return nil
+	}
+	if sec < 0 || sec > 0xffffffff {
+		return nil
+	}
+	buf := make([]byte, uint32Size)
+	binary.BigEndian.PutUint32(buf, uint32(sec))
+	return buf
+}
@@ -0,0 +1,55 @@
+package radius
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestAvpDate(t *testing.T) {
+	handler := avpDate
+
+	bytesVal := []byte{0x5e, 0x0b, 0xe1, 0x00} // 1577836800
+	want := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("FromString", func(t *testing.T) {
+		for _, in := range []string{"2020-01-01T00:00:00Z", "2020-01-01T03:00:00+03:00", "1577836800"} {
+			if res := handler.FromString(in); !bytes.Equal(res, bytesVal) {
+				t.Errorf("FromString(%s) = %v; want %v", in, res, bytesVal)
+			}
+		}
+	})
+
+	t.Run("Value", func(t *testing.T) {
+		res := handler.Value(nil, AVP{Value: bytesVal}).(time.Time)
+		if !res.Equal(want) {
+			t.Errorf("Value() = %v; want %v", res, want)
+		}
+	})
+
+	t.Run("String", func(t *testing.T) {
+		res := handler.String(nil, AVP{Value: bytesVal})
+		if res != "2020-01-01T00:00:00Z" {
+			t.Errorf("String() = %s; want 2020-01-01T00:00:00Z", res)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		if b := handler.FromString("yesterday"); b != nil {
+			t.Errorf("FromString expected nil; got %v", b)
+		}
+		if b := handler.FromString("1900-01-01T00:00:00Z"); b != nil {
+			t.Errorf("FromString expected nil for pre-epoch; got %v", b)
+		}
+		if s := handler.String(nil, AVP{Value: []byte{1}}); s != "invalid" {
+			t.Errorf("String() = %s; want invalid", s)
+		}
+	})
+
+	t.Run("EventTimestamp", func(t *testing.T) {
+		avp := AVP{Type: AttrEventTimestamp, Value: bytesVal}
+		if v, ok := avp.Decode(nil).(time.Time); !ok || !v.Equal(want) {
+			t.Errorf("Decode() = %v; want %v", avp.Decode(nil), want)
+		}
+	})
+}
@@ -0,0 +1,189 @@
+package radius
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"errors"
+)
+
+// Attribute encryption methods selected by the "encrypt=N" dictionary flag.
+const (
+	EncryptMethodNone           = 0
+	EncryptMethodUserPassword   = 1 // RFC 2865 §5.2
+	EncryptMethodTunnelPassword = 2 // RFC 2868 §3.5
+	EncryptMethodAscendSecret   = 3 // Ascend-Send-Secret
+)
+
+const tunnelPasswordSaltSize = 2
+
+var (
+	ErrInvalidEncryptedValue = errors.New("invalid encrypted attribute value")
+	ErrEncryptWithoutPacket  = errors.New("encrypted attribute needs the packet secret and authenticator")
+)
+
+// AvpEncrypted wraps a data type handler for attributes flagged encrypt=N.
+//
+// Value and String decrypt using the packet Secret and Authenticator before
+// decoding with the wrapped handler, which is only right for requests and for
+// replies not yet encoded: decrypt values of a received reply with Decrypt and
+// the Request Authenticator. As with AvpPassword, FromString cannot
+// encrypt because it lacks packet context: use Encrypt (templates do this
+// automatically).
+type AvpEncrypted struct {
+	method int
+	inner  avpDataType
+}
+
+func (s AvpEncrypted) Value(p *Packet, a AVP) interface{} {
+	plain, err := s.decryptPacket(p, a.Value)
+	if err != nil {
+		return s.inner.Value(p, AVP{Type: a.Type})
+	}
+	return s.inner.Value(p, AVP{Type: a.Type, Value: plain})
+}
+
+func (s AvpEncrypted) String(p *Packet, a AVP) string {
+	plain, err := s.decryptPacket(p, a.Value)
+	if err != nil {
+		return "invalid"
+	}
+	return s.inner.String(p, AVP{Type: a.Type, Value: plain})
+}
+
+func (s AvpEncrypted) FromString(v string) []byte {
+	return s.inner.FromString(v)
+}
+
+// Encrypt obfuscates plain using the packet Secret and Authenticator.
+//
+// For replies, p.Authenticator must still hold the request authenticator, as
+// it does for packets built with Packet.Reply before encoding. It fails with
+// ErrEncryptWithoutPacket when p is nil rather than leaving plain in clear.
+func (s AvpEncrypted) Encrypt(p *Packet, plain []byte) ([]byte, error) {
+	if p == nil {
+		return nil, ErrEncryptWithoutPacket
+	}
+	switch s.method {
+	case EncryptMethodUserPassword:
+		return avpPassword.Encode(string(plain), p.Secret, p.Authenticator[:]), nil
+	case EncryptMethodTunnelPassword:
+		return EncryptTunnelPassword(plain, p.Secret, p.Authenticator[:])
+	case EncryptMethodAscendSecret:
+		return cryptAscendSecret(plain, p.Secret, p.Authenticator[:]), nil
+	}
+	return plain, nil
+}
+
+// Decrypt reverses Encrypt using the packet Secret and requestAuth, the
+// Request Authenticator: p.Authenticator for a request, and the Authenticator
+// of the request sent for a received reply, whose own Authenticator is the
+// Response Authenticator.
+func (s AvpEncrypted) Decrypt(p *Packet, requestAuth []byte, b []byte) ([]byte, error) {
+	if p == nil || len(requestAuth) != 16 {
+		return nil, ErrInvalidEncryptedValue
+	}
+	switch s.method {
+	case EncryptMethodUserPassword:
+		if len(b) == 0 || len(b)%blockSize != 0 {
+			return nil, ErrInvalidEncryptedValue
+		}
+		return []byte(avpPassword.decode(b, p.Secret, requestAuth)), nil
+	case EncryptMethodTunnelPassword:
+		return DecryptTunnelPassword(b, p.Secret, requestAuth)
+	case EncryptMethodAscendSecret:
+		if len(b) != blockSize {
+			return nil, ErrInvalidEncryptedValue
+		}
+		return bytes.TrimRight(cryptAscendSecret(b, p.Secret, requestAuth), "\x00"), nil
+	}
+	return b, nil
+}
+
+func (s AvpEncrypted) decryptPacket(p *Packet, b []byte) ([]byte, error) {
+	if p == nil {
+		return nil, ErrInvalidEncryptedValue
+	}
+	return s.Decrypt(p, p.Authenticator[:], b)
+}
+
+// EncryptTunnelPassword obfuscates a Tunnel-Password style value (RFC 2868 §3.5)
+// with a random salt. requestAuth is the Request Authenticator. It fails only
+// when no random salt can be read.
+func EncryptTunnelPassword(password []byte, secret string, requestAuth []byte) ([]byte, error) {
+	// plaintext is length byte + password, padded to a multiple of 16
+	plainLen := 1 + len(password)
+	if plainLen%blockSize != 0 {
+		plainLen += blockSize - plainLen%blockSize
+	}
+	plain := make([]byte, plainLen)
+	plain[0] = uint8(len(password))
+	copy(plain[1:], password)
+
+	out := make([]byte, tunnelPasswordSaltSize+plainLen)
+	if _, err := rand.Read(out[:tunnelPasswordSaltSize]); err != nil {
+		return nil, err
+	}
+	// the most significant bit of the salt must be set
+	out[0] |= 0x80
+
+	hash := crypto.Hash(crypto.MD5).New()
+	last := append(append([]byte(nil), requestAuth...), out[:tunnelPasswordSaltSize]...)
+	c := out[tunnelPasswordSaltSize:]
+	for i := 0; i < plainLen; i += blockSize {
+		hash.Write([]byte(secret))
+		hash.Write(last)
+		digest := hash.Sum(nil)
+		hash.Reset()
+		for j := 0; j < blockSize; j++ {
+			c[i+j] = plain[i+j] ^ digest[j]
+		}
+		last = c[i : i+blockSize]
+	}
+	return out, nil
+}
+
+// DecryptTunnelPassword reverses EncryptTunnelPassword. requestAuth is the
+// Request Authenticator of the request the value was sent in or replied to.
+func DecryptTunnelPassword(b []byte, secret string, requestAuth []byte) ([]byte, error) {
+	if len(b) < tunnelPasswordSaltSize+blockSize || (len(b)-tunnelPasswordSaltSize)%blockSize != 0 {
+		return nil, ErrInvalidEncryptedValue
+	}
+	c := b[tunnelPasswordSaltSize:]
+	plain := make([]byte, len(c))
+
+	hash := crypto.Hash(crypto.MD5).New()
+	last := append(append([]byte(nil), requestAuth...), b[:tunnelPasswordSaltSize]...)
+	for i := 0; i < len(c); i += blockSize {
+		hash.Write([]byte(secret))
+		hash.Write(last)
+		digest := hash.Sum(nil)
+		hash.Reset()
+		for j := 0; j < blockSize; j++ {
+			plain[i+j] = c[i+j] ^ digest[j]
+		}
+		last = c[i : i+blockSize]
+	}
+
+	n := int(plain[0])
+	if n > len(plain)-1 {
+		return nil, ErrInvalidEncryptedValue
+	}
+	return plain[1 : 1+n], nil
+}
+
+// cryptAscendSecret XORs up to 16 bytes with MD5(authenticator || secret).
+// The operation is its own inverse.
+func cryptAscendSecret(value []byte, secret string, authenticator []byte) []byte {
+	hash := crypto.Hash(crypto.MD5).New()
+	hash.Write(authenticator)
+	hash.Write([]byte(secret))
+	digest := hash.Sum(nil)
+
+	out := make([]byte, blockSize)
+	copy(out, value)
+	for i := range out {
+		out[i] ^= digest[i]
+	}
+	return out
+}
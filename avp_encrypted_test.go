@@ -0,0 +1,99 @@
+package radius
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAvpEncrypted(t *testing.T) {
+	p := Request(AccessRequest, "secret")
+
+	testCases := []struct {
+		name   string
+		method int
+		value  string
+	}{
+		{"UserPassword", EncryptMethodUserPassword, "password123"},
+		{"UserPasswordLong", EncryptMethodUserPassword, "a-password-longer-than-sixteen-bytes"},
+		{"TunnelPassword", EncryptMethodTunnelPassword, "tunnel-secret"},
+		{"TunnelPasswordBlock", EncryptMethodTunnelPassword, "fifteen-bytes.."},
+		{"AscendSecret", EncryptMethodAscendSecret, "ascend"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := AvpEncrypted{method: tc.method, inner: avpString}
+
+			plain := handler.FromString(tc.value)
+			if !bytes.Equal(plain, []byte(tc.value)) {
+				t.Errorf("FromString(%s) = %v; want plaintext", tc.value, plain)
+			}
+
+			enc, err := handler.Encrypt(p, plain)
+			if err != nil {
+				t.Fatalf("Encrypt() failed: %v", err)
+			}
+			if bytes.Contains(enc, plain) {
+				t.Errorf("Encrypt() leaked plaintext: %v", enc)
+			}
+
+			avp := AVP{Value: enc}
+			if v := handler.Value(p, avp).(string); v != tc.value {
+				t.Errorf("Value() = %q; want %q", v, tc.value)
+			}
+			if s := handler.String(p, avp); s != tc.value {
+				t.Errorf("String() = %q; want %q", s, tc.value)
+			}
+		})
+	}
+
+	t.Run("TunnelPasswordSalt", func(t *testing.T) {
+		enc, err := EncryptTunnelPassword([]byte("x"), "secret", p.Authenticator[:])
+		if err != nil {
+			t.Fatalf("EncryptTunnelPassword() failed: %v", err)
+		}
+		if len(enc) != 2+16 {
+			t.Errorf("encrypted length = %d; want 18", len(enc))
+		}
+		if enc[0]&0x80 == 0 {
+			t.Error("salt MSB not set")
+		}
+		if _, err := DecryptTunnelPassword(enc[:10], "secret", p.Authenticator[:]); err == nil {
+			t.Error("expected error for truncated value")
+		}
+	})
+
+	t.Run("ReceivedReply", func(t *testing.T) {
+		handler := AvpEncrypted{method: EncryptMethodTunnelPassword, inner: avpString}
+		reply := p.Reply()
+		reply.Code = AccessAccept
+		enc, err := handler.Encrypt(reply, []byte("tunnel-secret"))
+		if err != nil {
+			t.Fatalf("Encrypt() failed: %v", err)
+		}
+		reply.AddAVP(AVP{Type: AttrTunnelPassword, Value: enc})
+		buf, err := reply.Encode()
+		if err != nil {
+			t.Fatalf("Encode() failed: %v", err)
+		}
+		q, err := DecodeReply("secret", buf, p.Authenticator[:])
+		if err != nil {
+			t.Fatalf("DecodeReply() failed: %v", err)
+		}
+		// q.Authenticator is now the Response Authenticator
+		plain, err := handler.Decrypt(q, p.Authenticator[:], q.GetAVP(AttrTunnelPassword).Value)
+		if err != nil || string(plain) != "tunnel-secret" {
+			t.Errorf("Decrypt() = %q, %v; want tunnel-secret", plain, err)
+		}
+	})
+
+	t.Run("NoPacket", func(t *testing.T) {
+		handler := AvpEncrypted{method: EncryptMethodUserPassword, inner: avpString}
+		if s := handler.String(nil, AVP{Value: make([]byte, 16)}); s != "invalid" {
+			t.Errorf("String() = %s; want invalid", s)
+		}
+		if _, err := handler.Encrypt(nil, []byte("x")); err != ErrEncryptWithoutPacket {
+			t.Errorf("Encrypt(nil) error = %v; want ErrEncryptWithoutPacket", err)
+		}
+	})
+}
@@ -0,0 +1,29 @@
+package radius
+
+import (
+	"net"
+)
+
+var avpEther AvpEther
+
+// AvpEther handles the "ether" data type: a 6-byte Ethernet MAC address.
+type AvpEther struct{}
+
+func (s AvpEther) Value(p *Packet, a AVP) interface{} {
+	return net.HardwareAddr(a.Value)
+}
+
+func (s AvpEther) String(p *Packet, a AVP) string {
+	if len(a.Value) != 6 {
+		return "invalid"
+	}
+	return net.HardwareAddr(a.Value).String()
+}
+
+func (s AvpEther) FromString(value string) []byte {
+	mac, err := net.ParseMAC(value)
+	if err != nil || len(mac) != 6 {
+		return nil
+	}
+	return mac
+}
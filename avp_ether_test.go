@@ -0,0 +1,29 @@
+package radius
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestAvpEther(t *testing.T) {
+	handler := avpEther
+
+	strVal := "00:11:22:aa:bb:cc"
+	bytesVal := []byte{0x00, 0x11, 0x22, 0xaa, 0xbb, 0xcc}
+
+	for _, in := range []string{strVal, "00-11-22-AA-BB-CC", "0011.22aa.bbcc"} {
+		if res := handler.FromString(in); !bytes.Equal(res, bytesVal) {
+			t.Errorf("FromString(%s) = %v; want %v", in, res, bytesVal)
+		}
+	}
+	if res := handler.Value(nil, AVP{Value: bytesVal}).(net.HardwareAddr); !bytes.Equal(res, bytesVal) {
+		t.Errorf("Value() = %v; want %v", res, bytesVal)
+	}
+	if res := handler.String(nil, AVP{Value: bytesVal}); res != strVal {
+		t.Errorf("String() = %s; want %s", res, strVal)
+	}
+	if b := handler.FromString("00:11:22:33:44:55:66:77"); b != nil {
+		t.Errorf("FromString expected nil for EUI-64; got %v", b)
+	}
+}
@@ -0,0 +1,100 @@
+package radius
+
+// The RFC 6929 Extended-Attribute types, carrying extended attributes.
+const (
+	AttrExtendedAttribute1 AttributeType = 241
+	AttrExtendedAttribute2 AttributeType = 242
+	AttrExtendedAttribute3 AttributeType = 243
+	AttrExtendedAttribute4 AttributeType = 244
+	AttrExtendedAttribute5 AttributeType = 245
+	AttrExtendedAttribute6 AttributeType = 246
+)
+
+// extendedMore is the "More" flag of long extended attributes: the value
+// continues in the next attribute.
+const extendedMore = 0x80
+
+// ExtendedAttr is an RFC 6929 extended attribute: the value of the
+// Extended-Type ExtType carried by the Extended-Attribute Type (241 to 246).
+type ExtendedAttr struct {
+	Type    AttributeType
+	ExtType uint8
+	Value   []byte
+}
+
+// IsExtendedType reports whether attrType is an RFC 6929 Extended-Attribute
+// (241 to 246).
+func IsExtendedType(attrType AttributeType) bool {
+	return attrType >= AttrExtendedAttribute1 && attrType <= AttrExtendedAttribute6
+}
+
+// IsLongExtendedType reports whether attrType is a Long Extended Type (245
+// or 246), whose values are split over several attributes when too long for
+// one.
+func IsLongExtendedType(attrType AttributeType) bool {
+	return attrType == AttrExtendedAttribute5 || attrType == AttrExtendedAttribute6
+}
+
+// extendedHeaderSize returns the size of the Extended-Type header, with the
+// flags of long extended attributes.
+func extendedHeaderSize(attrType AttributeType) int {
+	if IsLongExtendedType(attrType) {
+		return 2
+	}
+	return 1
+}
+
+// maxValueSize returns the largest value that fits in a single attribute.
+func (e ExtendedAttr) maxValueSize() int {
+	return maxAVPValueSize - extendedHeaderSize(e.Type)
+}
+
+// ToAVPs encodes the extended attribute. Values of long extended attributes
+// too long for one attribute are split over several, with the More flag set
+// on all but the last; other values give a single AVP.
+func (e ExtendedAttr) ToAVPs() []AVP {
+	if !IsLongExtendedType(e.Type) || len(e.Value) <= e.maxValueSize() {
+		return []AVP{e.encodeFragment(e.Value, false)}
+	}
+
+	var avps []AVP
+	value := e.Value
+	for len(value) > e.maxValueSize() {
+		avps = append(avps, e.encodeFragment(value[:e.maxValueSize()], true))
+		value = value[e.maxValueSize():]
+	}
+	avps = append(avps, e.encodeFragment(value, false))
+	return avps
+}
+
+func (e ExtendedAttr) encodeFragment(value []byte, more bool) AVP {
+	hdr := extendedHeaderSize(e.Type)
+	b := make([]byte, hdr+len(value))
+	b[0] = e.ExtType
+	if more {
+		b[1] = extendedMore
+	}
+	copy(b[hdr:], value)
+	return AVP{Type: e.Type, Value: b}
+}
+
+// ToExtendedAttr decodes the extended attribute carried by a, or returns nil
+// when a is not an extended attribute. The value of a long extended
+// attribute is the part carried by a, see Packet.GetExtendedAttr.
+func ToExtendedAttr(a AVP) *ExtendedAttr {
+	e, _ := decodeExtended(a)
+	return e
+}
+
+// decodeExtended decodes an extended attribute and reports whether the More
+// flag is set.
+func decodeExtended(a AVP) (*ExtendedAttr, bool) {
+	hdr := extendedHeaderSize(a.Type)
+	if !IsExtendedType(a.Type) || len(a.Value) < hdr {
+		return nil, false
+	}
+	e := &ExtendedAttr{Type: a.Type, ExtType: a.Value[0]}
+	e.Value = make([]byte, len(a.Value)-hdr)
+	copy(e.Value, a.Value[hdr:])
+	return e, hdr > 1 && a.Value[1]&extendedMore != 0
+}
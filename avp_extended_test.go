@@ -0,0 +1,94 @@
+package radius
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestExtendedAttrRoundTrip(t *testing.T) {
+	testCases := []struct {
+		name string
+		attr ExtendedAttr
+		wire []byte
+	}{
+		{
+			"Extended",
+			ExtendedAttr{Type: AttrExtendedAttribute1, ExtType: 8, Value: []byte("ab")},
+			[]byte{8, 'a', 'b'},
+		},
+		{
+			"LongExtended",
+			ExtendedAttr{Type: AttrExtendedAttribute5, ExtType: 26, Value: []byte("ab")},
+			[]byte{26, 0, 'a', 'b'},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			avps := tc.attr.ToAVPs()
+			if len(avps) != 1 || avps[0].Type != tc.attr.Type || !bytes.Equal(avps[0].Value, tc.wire) {
+				t.Fatalf("ToAVPs() = %v; want %d %x", avps, tc.attr.Type, tc.wire)
+			}
+			if got := ToExtendedAttr(avps[0]); !reflect.DeepEqual(*got, tc.attr) {
+				t.Errorf("ToExtendedAttr() = %+v; want %+v", *got, tc.attr)
+			}
+		})
+	}
+
+	if ToExtendedAttr(AVP{Type: AttrUserName, Value: []byte("ab")}) != nil {
+		t.Error("ToExtendedAttr decoded a standard attribute")
+	}
+	if ToExtendedAttr(AVP{Type: AttrExtendedAttribute5, Value: []byte{26}}) != nil {
+		t.Error("ToExtendedAttr decoded a truncated attribute")
+	}
+}
+
+func TestExtendedAttrFragments(t *testing.T) {
+	value := bytes.Repeat([]byte{0x5a}, 600)
+	e := ExtendedAttr{Type: AttrExtendedAttribute5, ExtType: 26, Value: value}
+
+	avps := e.ToAVPs()
+	if len(avps) != 3 {
+		t.Fatalf("ToAVPs returned %d fragments; want 3", len(avps))
+	}
+	for i, avp := range avps {
+		more := avp.Value[1]&extendedMore != 0
+		if more != (i < len(avps)-1) {
+			t.Errorf("fragment %d More = %v", i, more)
+		}
+		if len(avp.Value)+2 > 255 {
+			t.Errorf("fragment %d too long: %d", i, len(avp.Value)+2)
+		}
+	}
+
+	// extended attributes which are not long are never split
+	short := ExtendedAttr{Type: AttrExtendedAttribute1, ExtType: 8, Value: value}
+	if avps := short.ToAVPs(); len(avps) != 1 {
+		t.Errorf("ToAVPs split an extended attribute in %d", len(avps))
+	}
+
+	p := &Packet{}
+	p.AddAVP(AVP{Type: AttrUserName, Value: []byte("user")})
+	p.AddExtendedAttr(e)
+	p.AddExtendedAttr(ExtendedAttr{Type: AttrExtendedAttribute5, ExtType: 1, Value: []byte("x")})
+	if len(p.AVPs) != 5 {
+		t.Fatalf("AddExtendedAttr added %d attributes; want 4", len(p.AVPs)-1)
+	}
+
+	got := p.GetExtendedAttr(AttrExtendedAttribute5, 26)
+	if got == nil {
+		t.Fatal("GetExtendedAttr returned nil")
+	}
+	if !bytes.Equal(got.Value, value) {
+		t.Errorf("GetExtendedAttr reassembled %d bytes; want %d", len(got.Value), len(value))
+	}
+	if p.GetExtendedAttr(AttrExtendedAttribute6, 26) != nil {
+		t.Error("GetExtendedAttr returned unexpected attribute")
+	}
+
+	p.DeleteExtendedAttr(AttrExtendedAttribute5, 26)
+	if len(p.AVPs) != 2 || p.GetExtendedAttr(AttrExtendedAttribute5, 1) == nil {
+		t.Errorf("DeleteExtendedAttr left %v", p.AVPs)
+	}
+}
@@ -0,0 +1,45 @@
+package radius
+
+import (
+	"encoding/hex"
+	"strings"
+)
+
+const ifidSize = 8
+
+var avpIfID AvpIfID
+
+// AvpIfID handles the "ifid" data type (RFC 8044 §3.7): an 8-byte IPv6
+// interface identifier, written as "0000:0000:0000:0001".
+type AvpIfID struct{}
+
+func (s AvpIfID) Value(p *Packet, a AVP) interface{} {
+	return a.Value
+}
+
+func (s AvpIfID) String(p *Packet, a AVP) string {
+	if len(a.Value) != ifidSize {
+		return "invalid"
+	}
+	h := hex.EncodeToString(a.Value)
+	return h[0:4] + ":" + h[4:8] + ":" + h[8:12] + ":" + h[12:16]
+}
+
+func (s AvpIfID) FromString(value string) []byte {
+	groups := strings.Split(value, ":")
+	if len(groups) != 4 {
+		return nil
+	}
+	buf := make([]byte, 0, ifidSize)
+	for _, g := range groups {
+		if len(g) == 0 || len(g) > 4 {
+			return nil
+		}
+		b, err := hex.DecodeString(strings.Repeat("0", 4-len(g)) + g)
+		if err != nil {
+			return nil
+		}
+		buf = append(buf, b...)
+	}
+	return buf
+}
@@ -0,0 +1,32 @@
+package radius
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAvpIfID(t *testing.T) {
+	handler := avpIfID
+
+	strVal := "0211:22ff:fe33:4455"
+	bytesVal := []byte{0x02, 0x11, 0x22, 0xff, 0xfe, 0x33, 0x44, 0x55}
+
+	if res := handler.FromString(strVal); !bytes.Equal(res, bytesVal) {
+		t.Errorf("FromString(%s) = %v; want %v", strVal, res, bytesVal)
+	}
+	if res := handler.FromString("211:22ff:fe33:4455"); !bytes.Equal(res, bytesVal) {
+		t.Errorf("FromString without leading zeroes = %v; want %v", res, bytesVal)
+	}
+	if res := handler.Value(nil, AVP{Value: bytesVal}).([]byte); !bytes.Equal(res, bytesVal) {
+		t.Errorf("Value() = %v; want %v", res, bytesVal)
+	}
+	if res := handler.String(nil, AVP{Value: bytesVal}); res != strVal {
+		t.Errorf("String() = %s; want %s", res, strVal)
+	}
+
+	for _, in := range []string{"0211:22ff:fe33", "0211:22ff:fe33:44556", "zzzz:0:0:0"} {
+		if b := handler.FromString(in); b != nil {
+			t.Errorf("FromString(%s) expected nil; got %v", in, b)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package radius
+
+import (
+	"encoding/binary"
+	"strconv"
+)
+
+var avpInt32 AvpInt32
+
+// AvpInt32 handles the "signed" data type (two's complement int32).
+type AvpInt32 struct{}
+
+func (s AvpInt32) Value(p *Packet, a AVP) interface{} {
+	if len(a.Value) < uint32Size {
+		return int32(0)
+	}
+	return int32(binary.BigEndian.Uint32(a.Value))
+}
+
+func (s AvpInt32) String(p *Packet, a AVP) string {
+	if len(a.Value) < uint32Size {
+		return "invalid"
+	}
+	return strconv.Itoa(int(int32(binary.BigEndian.Uint32(a.Value))))
+}
+
+func (s AvpInt32) FromString(value string) []byte {
+	i, err := strconv.ParseInt(value, 0, 32)
+	if err != nil {
+		return nil
+	}
+	buf := make([]byte, uint32Size)
+	binary.BigEndian.PutUint32(buf, uint32(int32(i)))
+	return buf
+}
@@ -0,0 +1,45 @@
+package radius
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAvpInt32(t *testing.T) {
+	handler := avpInt32
+
+	testCases := []struct {
+		name     string
+		strVal   string
+		bytesVal []byte
+		intVal   int32
+	}{
+		{"Zero", "0", []byte{0, 0, 0, 0}, 0},
+		{"Positive", "123456789", []byte{7, 91, 205, 21}, 123456789},
+		{"MinusOne", "-1", []byte{255, 255, 255, 255}, -1},
+		{"Min", "-2147483648", []byte{0x80, 0, 0, 0}, -2147483648},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resBytes := handler.FromString(tc.strVal)
+			if !bytes.Equal(resBytes, tc.bytesVal) {
+				t.Errorf("FromString(%s) = %v; want %v", tc.strVal, resBytes, tc.bytesVal)
+			}
+
+			avp := AVP{Value: tc.bytesVal}
+			if resVal := handler.Value(nil, avp).(int32); resVal != tc.intVal {
+				t.Errorf("Value() = %d; want %d", resVal, tc.intVal)
+			}
+			if resStr := handler.String(nil, avp); resStr != tc.strVal {
+				t.Errorf("String() = %s; want %s", resStr, tc.strVal)
+			}
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		if b := handler.FromString("2147483648"); b != nil {
+			t.Errorf("FromString(2147483648) expected nil; got %v", b)
+		}
+	})
+}
@@ -0,0 +1,45 @@
+package radius
+
+import (
+	"net"
+)
+
+var avpIPv4Prefix AvpIPv4Prefix
+
+// AvpIPv4Prefix handles the "ipv4prefix" data type (RFC 8044 §3.11):
+// Reserved (1 byte), Prefix-Length (1 byte), Prefix (4 bytes).
+type AvpIPv4Prefix struct{}
+
+func (s AvpIPv4Prefix) Value(p *Packet, a AVP) interface{} {
+	if len(a.Value) != 6 || a.Value[1]&0x3f > 32 {
+		return (*net.IPNet)(nil)
+	}
+	mask := net.CIDRMask(int(a.Value[1]&0x3f), 32)
+	ip := net.IP(append([]byte(nil), a.Value[2:6]...))
+	return &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+}
+
+func (s AvpIPv4Prefix) String(p *Packet, a AVP) string {
+	n := s.Value(p, a).(*net.IPNet)
+	if n == nil {
+		return "invalid"
+	}
+	return n.String()
+}
+
+// FromString encodes a CIDR such as "192.0.2.0/24".
+func (s AvpIPv4Prefix) FromString(value string) []byte {
+	_, n, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil
+	}
+	ip4 := n.IP.To4()
+	if ip4 == nil {
+		return nil
+	}
+	ones, _ := n.Mask.Size()
+	buf := make([]byte, 6)
+	buf[1] = uint8(ones)
+	copy(buf[2:], ip4)
+	return buf
+}
@@ -0,0 +1,48 @@
+package radius
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestAvpIPv4Prefix(t *testing.T) {
+	handler := avpIPv4Prefix
+
+	testCases := []struct {
+		name     string
+		strVal   string
+		bytesVal []byte
+	}{
+		{"Slash24", "192.0.2.0/24", []byte{0, 24, 192, 0, 2, 0}},
+		{"Host", "198.51.100.7/32", []byte{0, 32, 198, 51, 100, 7}},
+		{"Default", "0.0.0.0/0", []byte{0, 0, 0, 0, 0, 0}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resBytes := handler.FromString(tc.strVal)
+			if !bytes.Equal(resBytes, tc.bytesVal) {
+				t.Errorf("FromString(%s) = %v; want %v", tc.strVal, resBytes, tc.bytesVal)
+			}
+
+			avp := AVP{Value: tc.bytesVal}
+			resVal := handler.Value(nil, avp).(*net.IPNet)
+			if resVal == nil || resVal.String() != tc.strVal {
+				t.Errorf("Value() = %v; want %s", resVal, tc.strVal)
+			}
+			if resStr := handler.String(nil, avp); resStr != tc.strVal {
+				t.Errorf("String() = %s; want %s", resStr, tc.strVal)
+			}
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		if b := handler.FromString("2001:db8::/32"); b != nil {
+			t.Errorf("FromString expected nil for IPv6; got %v", b)
+		}
+		if s := handler.String(nil, AVP{Value: []byte{0, 24, 1}}); s != "invalid" {
+			t.Errorf("String() = %s; want invalid", s)
+		}
+	})
+}
@@ -0,0 +1,44 @@
+package radius
+
+import (
+	"net"
+)
+
+var avpIPv6Prefix AvpIPv6Prefix
+
+// AvpIPv6Prefix handles the "ipv6prefix" data type (RFC 8044 §3.10):
+// Reserved (1 byte), Prefix-Length (1 byte), Prefix (0..16 bytes).
+type AvpIPv6Prefix struct{}
+
+func (s AvpIPv6Prefix) Value(p *Packet, a AVP) interface{} {
+	if len(a.Value) < 2 || len(a.Value) > 18 || a.Value[1] > 128 {
+		return (*net.IPNet)(nil)
+	}
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, a.Value[2:])
+	mask := net.CIDRMask(int(a.Value[1]), 128)
+	return &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+}
+
+func (s AvpIPv6Prefix) String(p *Packet, a AVP) string {
+	n := s.Value(p, a).(*net.IPNet)
+	if n == nil {
+		return "invalid"
+	}
+	return n.String()
+}
+
+// FromString encodes a CIDR such as "2001:db8::/32". Only the octets covered
+// by the prefix length are sent.
+func (s AvpIPv6Prefix) FromString(value string) []byte {
+	ip, n, err := net.ParseCIDR(value)
+	if err != nil || ip.To4() != nil {
+		return nil
+	}
+	ones, _ := n.Mask.Size()
+	size := (ones + 7) / 8
+	buf := make([]byte, 2+size)
+	buf[1] = uint8(ones)
+	copy(buf[2:], n.IP.To16()[:size])
+	return buf
+}
@@ -0,0 +1,58 @@
+package radius
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestAvpIPv6Prefix(t *testing.T) {
+	handler := avpIPv6Prefix
+
+	testCases := []struct {
+		name     string
+		strVal   string
+		bytesVal []byte
+	}{
+		{"Slash32", "2001:db8::/32", []byte{0, 32, 0x20, 0x01, 0x0d, 0xb8}},
+		{"Slash60", "2001:db8:0:10::/60", []byte{0, 60, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0x10}},
+		{"Host", "2001:db8::1/128", []byte{0, 128, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}},
+		{"Default", "::/0", []byte{0, 0}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resBytes := handler.FromString(tc.strVal)
+			if !bytes.Equal(resBytes, tc.bytesVal) {
+				t.Errorf("FromString(%s) = %v; want %v", tc.strVal, resBytes, tc.bytesVal)
+			}
+
+			avp := AVP{Value: tc.bytesVal}
+			_, want, _ := net.ParseCIDR(tc.strVal)
+			resVal := handler.Value(nil, avp).(*net.IPNet)
+			if resVal == nil || resVal.String() != want.String() {
+				t.Errorf("Value() = %v; want %v", resVal, want)
+			}
+			if resStr := handler.String(nil, avp); resStr != want.String() {
+				t.Errorf("String() = %s; want %s", resStr, want.String())
+			}
+		})
+	}
+
+	t.Run("FullWidthPrefix", func(t *testing.T) {
+		// Senders may include all 16 prefix octets.
+		b := append([]byte{0, 64}, net.ParseIP("2001:db8:1:2::")...)
+		if s := handler.String(nil, AVP{Value: b}); s != "2001:db8:1:2::/64" {
+			t.Errorf("String() = %s; want 2001:db8:1:2::/64", s)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		if b := handler.FromString("192.0.2.0/24"); b != nil {
+			t.Errorf("FromString expected nil for IPv4; got %v", b)
+		}
+		if s := handler.String(nil, AVP{Value: []byte{0, 129}}); s != "invalid" {
+			t.Errorf("String() = %s; want invalid", s)
+		}
+	})
+}
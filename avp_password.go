@@ -15,11 +15,14 @@ func (s AvpPassword) Value(p *Packet, a AVP) interface{} {
 	if p == nil {
 		return ""
 	}
+	return s.decode(a.Value, p.Secret, p.Authenticator[:])
+}
 
-	b := a.Value
+// decode reverses Encode, returning "" when b is not a multiple of 16 bytes.
+func (s AvpPassword) decode(b []byte, secret string, authenticator []byte) string {
 	password := make([]byte, len(b))
 	last := make([]byte, blockSize)
-	copy(last, p.Authenticator[:])
+	copy(last, authenticator)
 	hash := crypto.Hash(crypto.MD5).New()
 	blocks := 0
 
@@ -28,7 +31,7 @@ func (s AvpPassword) Value(p *Packet, a AVP) interface{} {
 	}
 
 	for len(b) > 0 {
-		hash.Write(append([]byte(p.Secret), last...))
+		hash.Write(append([]byte(secret), last...))
 		digest := hash.Sum(nil)
 		hash.Reset()
 
@@ -0,0 +1,69 @@
+package radius
+
+// maxTag is the largest valid tag value (RFC 2868 §3.1).
+const maxTag = 0x1f
+
+// AvpTagged wraps a data type handler for attributes flagged has_tag
+// (RFC 2868 tunnel attributes).
+//
+// For integer attributes the tag replaces the most significant byte of the
+// value. For other attributes the tag is an optional leading byte in the
+// range 0x01-0x1F, except for encrypt=2 values where it is always present.
+type AvpTagged struct {
+	inner     avpDataType
+	integer   bool
+	encrypted bool
+}
+
+func (s AvpTagged) Value(p *Packet, a AVP) interface{} {
+	_, value := s.Split(a.Value)
+	return s.inner.Value(p, AVP{Type: a.Type, Value: value})
+}
+
+func (s AvpTagged) String(p *Packet, a AVP) string {
+	_, value := s.Split(a.Value)
+	return s.inner.String(p, AVP{Type: a.Type, Value: value})
+}
+
+// FromString encodes the value with tag 0 (no tag).
+func (s AvpTagged) FromString(v string) []byte {
+	value := s.inner.FromString(v)
+	if value == nil {
+		return nil
+	}
+	return s.Join(0, value)
+}
+
+// Split separates the tag from an on-the-wire value.
+func (s AvpTagged) Split(b []byte) (tag uint8, value []byte) {
+	switch {
+	case len(b) == 0:
+		return 0, b
+	case s.integer:
+		if len(b) != uint32Size {
+			return 0, b
+		}
+		return b[0], []byte{0, b[1], b[2], b[3]}
+	case s.encrypted:
+		return b[0], b[1:]
+	case b[0] <= maxTag:
+		return b[0], b[1:]
+	}
+	return 0, b
+}
+
+// Join adds tag to an encoded value.
+func (s AvpTagged) Join(tag uint8, value []byte) []byte {
+	switch {
+	case s.integer:
+		if len(value) != uint32Size {
+			return value
+		}
+		out := append([]byte(nil), value...)
+		out[0] = tag
+		return out
+	case s.encrypted, tag > 0, len(value) > 0 && value[0] <= maxTag:
+		return append([]byte{tag}, value...)
+	}
+	return value
+}
@@ -0,0 +1,64 @@
+package radius
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAvpTagged(t *testing.T) {
+	t.Run("Integer", func(t *testing.T) {
+		handler := AvpTagged{inner: avpUint32, integer: true}
+		b := handler.Join(3, avpUint32.FromString("13"))
+		if !bytes.Equal(b, []byte{3, 0, 0, 13}) {
+			t.Errorf("Join() = %v; want [3 0 0 13]", b)
+		}
+		tag, value := handler.Split(b)
+		if tag != 3 || !bytes.Equal(value, []byte{0, 0, 0, 13}) {
+			t.Errorf("Split() = %d, %v", tag, value)
+		}
+		if v := handler.Value(nil, AVP{Value: b}).(uint32); v != 13 {
+			t.Errorf("Value() = %d; want 13", v)
+		}
+		if b := handler.FromString("13"); !bytes.Equal(b, []byte{0, 0, 0, 13}) {
+			t.Errorf("FromString() = %v; want [0 0 0 13]", b)
+		}
+	})
+
+	t.Run("String", func(t *testing.T) {
+		handler := AvpTagged{inner: avpString}
+		if b := handler.Join(0, []byte("vlan")); !bytes.Equal(b, []byte("vlan")) {
+			t.Errorf("Join(0) = %v; want untagged", b)
+		}
+		b := handler.Join(1, []byte("vlan"))
+		if !bytes.Equal(b, []byte("\x01vlan")) {
+			t.Errorf("Join(1) = %v", b)
+		}
+		if s := handler.String(nil, AVP{Value: b}); s != "vlan" {
+			t.Errorf("String() = %s; want vlan", s)
+		}
+		if s := handler.String(nil, AVP{Value: []byte("vlan")}); s != "vlan" {
+			t.Errorf("String() untagged = %s; want vlan", s)
+		}
+		// a leading byte in the tag range must be protected by a zero tag
+		if b := handler.Join(0, []byte("\x05x")); !bytes.Equal(b, []byte("\x00\x05x")) {
+			t.Errorf("Join(0) = %v", b)
+		}
+	})
+
+	t.Run("TunnelPassword", func(t *testing.T) {
+		p := Request(AccessRequest, "secret")
+		handler := attrHandler("string", AttributeFlags{HasTag: true, Encrypt: EncryptMethodTunnelPassword})
+		def := AttributeDef{Name: "Tunnel-Password", Type: "string",
+			Flags: AttributeFlags{HasTag: true, Encrypt: EncryptMethodTunnelPassword}}
+		b, err := def.encodeValue(p, 2, "tunnel-secret", nil)
+		if err != nil {
+			t.Fatalf("encodeValue failed: %v", err)
+		}
+		if b[0] != 2 || len(b) != 1+2+16 {
+			t.Errorf("encoded = %v", b)
+		}
+		if s := handler.String(p, AVP{Value: b}); s != "tunnel-secret" {
+			t.Errorf("String() = %s; want tunnel-secret", s)
+		}
+	})
+}
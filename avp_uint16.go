@@ -0,0 +1,37 @@
+package radius
+
+import (
+	"encoding/binary"
+	"strconv"
+)
+
+const uint16Size = 2
+
+var avpUint16 AvpUint16
+
+// AvpUint16 handles the "short" data type (uint16).
+type AvpUint16 struct{}
+
+func (s AvpUint16) Value(p *Packet, a AVP) interface{} {
+	if len(a.Value) < uint16Size {
+		return uint16(0)
+	}
+	return binary.BigEndian.Uint16(a.Value)
+}
+
+func (s AvpUint16) String(p *Packet, a AVP) string {
+	if len(a.Value) < uint16Size {
+		return "invalid"
+	}
+	return strconv.Itoa(int(binary.BigEndian.Uint16(a.Value)))
+}
+
+func (s AvpUint16) FromString(value string) []byte {
+	i, err := strconv.ParseUint(value, 0, 16)
+	if err != nil {
+		return nil
+	}
+	buf := make([]byte, uint16Size)
+	binary.BigEndian.PutUint16(buf, uint16(i))
+	return buf
+}
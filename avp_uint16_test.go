@@ -0,0 +1,47 @@
+package radius
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAvpUint16(t *testing.T) {
+	handler := avpUint16
+
+	testCases := []struct {
+		name     string
+		strVal   string
+		bytesVal []byte
+		uintVal  uint16
+	}{
+		{"Zero", "0", []byte{0, 0}, 0},
+		{"Arbitrary", "4660", []byte{0x12, 0x34}, 0x1234},
+		{"Max", "65535", []byte{255, 255}, 65535},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resBytes := handler.FromString(tc.strVal)
+			if !bytes.Equal(resBytes, tc.bytesVal) {
+				t.Errorf("FromString(%s) = %v; want %v", tc.strVal, resBytes, tc.bytesVal)
+			}
+
+			avp := AVP{Value: tc.bytesVal}
+			if resVal := handler.Value(nil, avp).(uint16); resVal != tc.uintVal {
+				t.Errorf("Value() = %d; want %d", resVal, tc.uintVal)
+			}
+			if resStr := handler.String(nil, avp); resStr != tc.strVal {
+				t.Errorf("String() = %s; want %s", resStr, tc.strVal)
+			}
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		if b := handler.FromString("65536"); b != nil {
+			t.Errorf("FromString(65536) expected nil; got %v", b)
+		}
+		if s := handler.String(nil, AVP{Value: []byte{1}}); s != "invalid" {
+			t.Errorf("String() = %s; want invalid", s)
+		}
+	})
+}
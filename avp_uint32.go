@@ -26,8 +26,11 @@ func (s AvpUint32) String(p *Packet, a AVP) string {
 }
 
 func (s AvpUint32) FromString(value string) []byte {
+	i, err := strconv.ParseUint(value, 0, 32)
+	if err != nil {
+		return nil
+	}
 	buf := make([]byte, uint32Size)
-	i, _ := strconv.ParseUint(value, 0, 32)
 	binary.BigEndian.PutUint32(buf, uint32(i))
 	return buf
 }
@@ -11,21 +11,17 @@ type AvpUint32Enum struct {
 }
 
 func (s AvpUint32Enum) Value(p *Packet, a AVP) interface{} {
-	value := reflect.New(reflect.TypeOf(s.t)).Elem()
 	if len(a.Value) < 4 {
-		return value.Interface()
+		return reflect.Zero(reflect.TypeOf(s.t)).Interface()
 	}
-	value.SetUint(uint64(binary.BigEndian.Uint32(a.Value)))
-	return value.Interface()
+	return s.value(binary.BigEndian.Uint32(a.Value)).Interface()
 }
 func (s AvpUint32Enum) String(p *Packet, a AVP) string {
 	if len(a.Value) < 4 {
 		return "invalid"
 	}
 	number := binary.BigEndian.Uint32(a.Value)
-	value := reflect.New(reflect.TypeOf(s.t)).Elem()
-	value.SetUint(uint64(number))
-	method := value.MethodByName("String")
+	method := s.value(number).MethodByName("String")
 	if !method.IsValid() {
 		return strconv.Itoa(int(number))
 	}
@@ -33,7 +29,20 @@ func (s AvpUint32Enum) String(p *Packet, a AVP) string {
 	return out[0].Interface().(string)
 }
 
-// TODO
+// value returns number as a value of the enum type, signed or unsigned.
+func (s AvpUint32Enum) value(number uint32) reflect.Value {
+	value := reflect.New(reflect.TypeOf(s.t)).Elem()
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value.SetInt(int64(number))
+	default:
+		value.SetUint(uint64(number))
+	}
+	return value
+}
+
+// FromString accepts a number. Value names are resolved from the VALUE
+// definitions of the dictionary before the handler is called.
 func (s AvpUint32Enum) FromString(v string) []byte {
-	return []byte(v)
+	return avpUint32.FromString(v)
 }
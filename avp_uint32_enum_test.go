@@ -7,6 +7,8 @@ import (
 
 type dummyEnum uint32
 
+type signedEnum int32
+
 func (e dummyEnum) String() string {
 	if e == 1 {
 		return "One"
@@ -68,14 +70,36 @@ func TestAvpUint32EnumDetailed(t *testing.T) {
 		}
 	})
 
-	// 4. Test FromString stub
-	t.Run("FromStringStub", func(t *testing.T) {
-		handler := AvpUint32Enum{uint32(0)}
-		input := "123"
-		res := handler.FromString(input)
-		// Current implementation just returns []byte(input)
-		if !bytes.Equal(res, []byte(input)) {
-			t.Errorf("FromString(%s) = %v; want %v", input, res, []byte(input))
+	// 4. Test with a signed enum type
+	t.Run("SignedEnum", func(t *testing.T) {
+		handler := AvpUint32Enum{signedEnum(0)}
+		avp := AVP{Value: []byte{0, 0, 0, 2}}
+		if val := handler.Value(nil, avp).(signedEnum); val != 2 {
+			t.Errorf("Value() = %v; want 2", val)
+		}
+		if str := handler.String(nil, avp); str != "2" {
+			t.Errorf("String() = %s; want 2", str)
+		}
+	})
+
+	// 5. Test FromString with numbers
+	t.Run("FromString", func(t *testing.T) {
+		testCases := []struct {
+			handler AvpUint32Enum
+			input   string
+			want    []byte
+		}{
+			{AvpUint32Enum{uint32(0)}, "123", []byte{0, 0, 0, 123}},
+			{AvpUint32Enum{AcctStatusTypeEnum(0)}, "0x03", []byte{0, 0, 0, 3}},
+			{AvpUint32Enum{signedEnum(0)}, "4096", []byte{0, 0, 0x10, 0}},
+			// names are resolved from the dictionary VALUE definitions
+			{AvpUint32Enum{AcctStatusTypeEnum(0)}, "Stop", nil},
+			{AvpUint32Enum{uint32(0)}, "One", nil},
+		}
+		for _, tc := range testCases {
+			if res := tc.handler.FromString(tc.input); !bytes.Equal(res, tc.want) {
+				t.Errorf("FromString(%s) = %v; want %v", tc.input, res, tc.want)
+			}
 		}
 	})
 }
@@ -0,0 +1,37 @@
+package radius
+
+import (
+	"encoding/binary"
+	"strconv"
+)
+
+const uint64Size = 8
+
+var avpUint64 AvpUint64
+
+// AvpUint64 handles the "integer64" data type (RFC 8044 §3.12).
+type AvpUint64 struct{}
+
+func (s AvpUint64) Value(p *Packet, a AVP) interface{} {
+	if len(a.Value) < uint64Size {
+		return uint64(0)
+	}
+	return binary.BigEndian.Uint64(a.Value)
+}
+
+func (s AvpUint64) String(p *Packet, a AVP) string {
+	if len(a.Value) < uint64Size {
+		return "invalid"
+	}
+	return strconv.FormatUint(binary.BigEndian.Uint64(a.Value), 10)
+}
+
+func (s AvpUint64) FromString(value string) []byte {
+	i, err := strconv.ParseUint(value, 0, 64)
+	if err != nil {
+		return nil
+	}
+	buf := make([]byte, uint64Size)
+	binary.BigEndian.PutUint64(buf, i)
+	return buf
+}
@@ -0,0 +1,47 @@
+package radius
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAvpUint64(t *testing.T) {
+	handler := avpUint64
+
+	testCases := []struct {
+		name     string
+		strVal   string
+		bytesVal []byte
+		uintVal  uint64
+	}{
+		{"Zero", "0", []byte{0, 0, 0, 0, 0, 0, 0, 0}, 0},
+		{"Above32Bit", "4294967296", []byte{0, 0, 0, 1, 0, 0, 0, 0}, 1 << 32},
+		{"Max", "18446744073709551615", []byte{255, 255, 255, 255, 255, 255, 255, 255}, 1<<64 - 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resBytes := handler.FromString(tc.strVal)
+			if !bytes.Equal(resBytes, tc.bytesVal) {
+				t.Errorf("FromString(%s) = %v; want %v", tc.strVal, resBytes, tc.bytesVal)
+			}
+
+			avp := AVP{Value: tc.bytesVal}
+			if resVal := handler.Value(nil, avp).(uint64); resVal != tc.uintVal {
+				t.Errorf("Value() = %d; want %d", resVal, tc.uintVal)
+			}
+			if resStr := handler.String(nil, avp); resStr != tc.strVal {
+				t.Errorf("String() = %s; want %s", resStr, tc.strVal)
+			}
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		if b := handler.FromString("-1"); b != nil {
+			t.Errorf("FromString(-1) expected nil; got %v", b)
+		}
+		if s := handler.String(nil, AVP{Value: []byte{0, 0, 0, 1}}); s != "invalid" {
+			t.Errorf("String() = %s; want invalid", s)
+		}
+	})
+}
@@ -0,0 +1,32 @@
+package radius
+
+import (
+	"strconv"
+)
+
+var avpUint8 AvpUint8
+
+// AvpUint8 handles the "byte" data type (uint8).
+type AvpUint8 struct{}
+
+func (s AvpUint8) Value(p *Packet, a AVP) interface{} {
+	if len(a.Value) < 1 {
+		return uint8(0)
+	}
+	return uint8(a.Value[0])
+}
+
+func (s AvpUint8) String(p *Packet, a AVP) string {
+	if len(a.Value) < 1 {
+		return "invalid"
+	}
+	return strconv.Itoa(int(a.Value[0]))
+}
+
+func (s AvpUint8) FromString(value string) []byte {
+	i, err := strconv.ParseUint(value, 0, 8)
+	if err != nil {
+		return nil
+	}
+	return []byte{uint8(i)}
+}
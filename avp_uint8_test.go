@@ -0,0 +1,47 @@
+package radius
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAvpUint8(t *testing.T) {
+	handler := avpUint8
+
+	testCases := []struct {
+		name     string
+		strVal   string
+		bytesVal []byte
+		uintVal  uint8
+	}{
+		{"Zero", "0", []byte{0}, 0},
+		{"One", "1", []byte{1}, 1},
+		{"Max", "255", []byte{255}, 255},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resBytes := handler.FromString(tc.strVal)
+			if !bytes.Equal(resBytes, tc.bytesVal) {
+				t.Errorf("FromString(%s) = %v; want %v", tc.strVal, resBytes, tc.bytesVal)
+			}
+
+			avp := AVP{Value: tc.bytesVal}
+			if resVal := handler.Value(nil, avp).(uint8); resVal != tc.uintVal {
+				t.Errorf("Value() = %d; want %d", resVal, tc.uintVal)
+			}
+			if resStr := handler.String(nil, avp); resStr != tc.strVal {
+				t.Errorf("String() = %s; want %s", resStr, tc.strVal)
+			}
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		if b := handler.FromString("256"); b != nil {
+			t.Errorf("FromString(256) expected nil; got %v", b)
+		}
+		if s := handler.String(nil, AVP{}); s != "invalid" {
+			t.Errorf("String() = %s; want invalid", s)
+		}
+	})
+}
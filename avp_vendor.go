@@ -2,16 +2,105 @@ package radius
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 )
 
 type VendorID uint32
 
-// TODO some VSA has uint16 type (Lucent)
-type VendorAttr uint8
+// VendorAttr is the vendor attribute type. Most vendors use a 1-byte type,
+// but some (USR, Lucent, Starent) use 2 or 4 bytes, see VendorFormat.
+type VendorAttr uint32
 
 const vsaHeaderSize = 6
 
+// vsaContinuation is the "more fragments follow" bit of the WiMAX
+// continuation byte.
+const vsaContinuation = 0x80
+
+// VendorFormat describes the on-the-wire layout of a vendor's sub-attributes,
+// as declared by "VENDOR name id format=t,l[,c]" in FreeRADIUS dictionaries.
+type VendorFormat struct {
+	// TypeSize is the width of the vendor attribute type: 1, 2 or 4 bytes.
+	TypeSize int
+	// LengthSize is the width of the vendor attribute length: 0, 1 or 2 bytes.
+	// With 0 the value extends to the end of the Vendor-Specific attribute.
+	LengthSize int
+	// Continuation reports whether a WiMAX-style continuation byte follows
+	// the length field. Long values are then split over several attributes.
+	Continuation bool
+}
+
+// DefaultVendorFormat is the RFC 2865 layout: 1-byte type, 1-byte length.
+var DefaultVendorFormat = VendorFormat{TypeSize: 1, LengthSize: 1}
+
+// ParseVendorFormat parses the "format=t,l[,c]" option of a VENDOR line.
+// The "format=" prefix is optional.
+func ParseVendorFormat(s string) (VendorFormat, error) {
+	spec := strings.TrimPrefix(s, "format=")
+	parts := strings.Split(spec, ",")
+	if len(parts) < 2 || len(parts) > 3 {
+		return VendorFormat{}, errors.New("invalid vendor format " + s)
+	}
+
+	t, err := strconv.Atoi(parts[0])
+	if err != nil || (t != 1 && t != 2 && t != 4) {
+		return VendorFormat{}, errors.New("invalid vendor type size in " + s)
+	}
+	l, err := strconv.Atoi(parts[1])
+	if err != nil || l < 0 || l > 2 {
+		return VendorFormat{}, errors.New("invalid vendor length size in " + s)
+	}
+
+	f := VendorFormat{TypeSize: t, LengthSize: l}
+	if len(parts) == 3 {
+		if parts[2] != "c" || t != 1 || l != 1 {
+			// continuation is only defined for the WiMAX 1,1 layout
+			return VendorFormat{}, errors.New("invalid vendor continuation flag in " + s)
+		}
+		f.Continuation = true
+	}
+	return f, nil
+}
+
+// String returns the format in dictionary notation, e.g. "format=2,1".
+func (f VendorFormat) String() string {
+	s := "format=" + strconv.Itoa(f.TypeSize) + "," + strconv.Itoa(f.LengthSize)
+	if f.Continuation {
+		s += ",c"
+	}
+	return s
+}
+
+// headerSize returns the size of the sub-attribute header (without vendor id).
+func (f VendorFormat) headerSize() int {
+	n := f.TypeSize + f.LengthSize
+	if f.Continuation {
+		n++
+	}
+	return n
+}
+
+// maxValueSize returns the largest value that fits in a single Vendor-Specific attribute.
+func (f VendorFormat) maxValueSize() int {
+	return 255 - 2 - 4 - f.headerSize()
+}
+
+// vendorFormat returns the VSA layout of vendorID from the default snapshot
+// or dictionary.
+func vendorFormat(vendorID VendorID) VendorFormat {
+	if s := defaultSnapshot.Load(); s != nil {
+		return s.GetVendorFormat(vendorID)
+	}
+	d := GetDefaultDictionary()
+	if d == nil {
+		return DefaultVendorFormat
+	}
+	return d.GetVendorFormat(vendorID)
+}
+
 // Vendor
 type VSA struct {
 	Vendor VendorID
@@ -40,37 +129,142 @@ func (s AvpVendor) FromString(v string) []byte {
 }
 
 // encode VSA attribute under Vendor-Specific AVP
+//
+// The vendor layout is taken from the default dictionary. Values too long for
+// a single attribute are truncated to the first fragment; use ToAVPs for
+// vendors with continuation support.
 func (vsa VSA) ToAVP() AVP {
-	vsaLen := len(vsa.Value)
-	// vendor id (4) + attr type (1) + attr len (1)
-	// TODO - for WiMAX vendor there is extra byte in VSA header
-	vsaValue := make([]byte, vsaLen+vsaHeaderSize)
+	return vsa.ToAVPWithFormat(vendorFormat(vsa.Vendor))
+}
+
+// ToAVPWithFormat encodes the VSA as a single Vendor-Specific AVP using format f.
+func (vsa VSA) ToAVPWithFormat(f VendorFormat) AVP {
+	value := vsa.Value
+	if f.Continuation && len(value) > f.maxValueSize() {
+		value = value[:f.maxValueSize()]
+	}
+	return vsa.encodeFragment(f, value, false)
+}
+
+// ToAVPs encodes the VSA using the vendor layout from the default dictionary.
+//
+// For vendors with a continuation byte (WiMAX) long values are split over
+// several Vendor-Specific AVPs; otherwise a single AVP is returned.
+func (vsa VSA) ToAVPs() []AVP {
+	return vsa.ToAVPsWithFormat(vendorFormat(vsa.Vendor))
+}
+
+// ToAVPsWithFormat is like ToAVPs but uses the given vendor format.
+func (vsa VSA) ToAVPsWithFormat(f VendorFormat) []AVP {
+	if !f.Continuation || len(vsa.Value) <= f.maxValueSize() {
+		return []AVP{vsa.encodeFragment(f, vsa.Value, false)}
+	}
+
+	var avps []AVP
+	value := vsa.Value
+	for len(value) > f.maxValueSize() {
+		avps = append(avps, vsa.encodeFragment(f, value[:f.maxValueSize()], true))
+		value = value[f.maxValueSize():]
+	}
+	avps = append(avps, vsa.encodeFragment(f, value, false))
+	return avps
+}
+
+func (vsa VSA) encodeFragment(f VendorFormat, value []byte, more bool) AVP {
+	hdr := f.headerSize()
+	// vendor id (4) + attr type + attr len [+ continuation]
+	vsaValue := make([]byte, 4+hdr+len(value))
 	binary.BigEndian.PutUint32(vsaValue[0:4], uint32(vsa.Vendor))
-	// TODO VendorAttr -- 1 bytes or 2?
-	vsaValue[4] = uint8(vsa.Type)
-	vsaValue[5] = uint8(vsaLen + 2)
-	copy(vsaValue[vsaHeaderSize:], vsa.Value)
 
-	avp := AVP{Type: AttrVendorSpecific, Value: vsaValue}
+	b := vsaValue[4:]
+	switch f.TypeSize {
+	case 4:
+		binary.BigEndian.PutUint32(b, uint32(vsa.Type))
+	case 2:
+		binary.BigEndian.PutUint16(b, uint16(vsa.Type))
+	default:
+		b[0] = uint8(vsa.Type)
+	}
+	b = b[f.TypeSize:]
+
+	// vendor length covers the sub-attribute header and value
+	switch f.LengthSize {
+	case 2:
+		binary.BigEndian.PutUint16(b, uint16(hdr+len(value)))
+	case 1:
+		b[0] = uint8(hdr + len(value))
+	}
+	b = b[f.LengthSize:]
 
-	return avp
+	if f.Continuation {
+		if more {
+			b[0] = vsaContinuation
+		}
+		b = b[1:]
+	}
+	copy(b, value)
+
+	return AVP{Type: AttrVendorSpecific, Value: vsaValue}
 }
 
 // decode AVP value to VSA
+//
+// The vendor layout is taken from the default dictionary.
 func ToVSA(a AVP) *VSA {
+	if len(a.Value) < 4 {
+		return new(VSA)
+	}
+	return ToVSAWithFormat(a, vendorFormat(VendorID(binary.BigEndian.Uint32(a.Value[0:4]))))
+}
+
+// ToVSAWithFormat decodes the first sub-attribute of a Vendor-Specific AVP
+// using format f.
+func ToVSAWithFormat(a AVP, f VendorFormat) *VSA {
+	vsa, _ := decodeVSA(a, f)
+	return vsa
+}
+
+// decodeVSA decodes a Vendor-Specific AVP and reports whether the WiMAX
+// continuation bit is set.
+func decodeVSA(a AVP, f VendorFormat) (*VSA, bool) {
 	vsa := new(VSA)
 	value := a.Value
-	if len(value) < vsaHeaderSize {
-		return vsa
+	hdr := f.headerSize()
+	if len(value) < 4+hdr {
+		return vsa, false
 	}
 	vsa.Vendor = VendorID(binary.BigEndian.Uint32(value[0:4]))
-	vsa.Type = VendorAttr(value[4])
-	vsaLen := int(value[5])
-	if vsaLen < 2 || 4+vsaLen > len(value) {
-		return vsa
+
+	b := value[4:]
+	switch f.TypeSize {
+	case 4:
+		vsa.Type = VendorAttr(binary.BigEndian.Uint32(b))
+	case 2:
+		vsa.Type = VendorAttr(binary.BigEndian.Uint16(b))
+	default:
+		vsa.Type = VendorAttr(b[0])
 	}
-	vsa.Value = make([]byte, vsaLen-2)
-	copy(vsa.Value, value[vsaHeaderSize:4+vsaLen])
+	b = b[f.TypeSize:]
 
-	return vsa
+	vsaLen := len(value) - 4
+	switch f.LengthSize {
+	case 2:
+		vsaLen = int(binary.BigEndian.Uint16(b))
+	case 1:
+		vsaLen = int(b[0])
+	}
+	b = b[f.LengthSize:]
+	if vsaLen < hdr || 4+vsaLen > len(value) {
+		return vsa, false
+	}
+
+	more := false
+	if f.Continuation {
+		more = b[0]&vsaContinuation != 0
+	}
+
+	vsa.Value = make([]byte, vsaLen-hdr)
+	copy(vsa.Value, value[4+hdr:4+vsaLen])
+
+	return vsa, more
 }
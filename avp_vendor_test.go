@@ -0,0 +1,206 @@
+package radius
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseVendorFormat(t *testing.T) {
+	testCases := []struct {
+		in      string
+		want    VendorFormat
+		wantErr bool
+	}{
+		{"format=1,1", VendorFormat{TypeSize: 1, LengthSize: 1}, false},
+		{"format=2,1", VendorFormat{TypeSize: 2, LengthSize: 1}, false},
+		{"format=4,0", VendorFormat{TypeSize: 4, LengthSize: 0}, false},
+		{"format=1,1,c", VendorFormat{TypeSize: 1, LengthSize: 1, Continuation: true}, false},
+		{"2,2", VendorFormat{TypeSize: 2, LengthSize: 2}, false},
+		{"format=3,1", VendorFormat{}, true},
+		{"format=1,3", VendorFormat{}, true},
+		{"format=2,1,c", VendorFormat{}, true},
+		{"format=1", VendorFormat{}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.in, func(t *testing.T) {
+			f, err := ParseVendorFormat(tc.in)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("ParseVendorFormat(%s) expected error", tc.in)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseVendorFormat(%s) error: %v", tc.in, err)
+			}
+			if f != tc.want {
+				t.Errorf("ParseVendorFormat(%s) = %+v; want %+v", tc.in, f, tc.want)
+			}
+		})
+	}
+}
+
+func TestVSAFormatRoundTrip(t *testing.T) {
+	testCases := []struct {
+		name   string
+		format VendorFormat
+		vsa    VSA
+		wire   []byte
+	}{
+		{
+			"Default",
+			DefaultVendorFormat,
+			VSA{Vendor: 9, Type: 1, Value: []byte("ab")},
+			[]byte{0, 0, 0, 9, 1, 4, 'a', 'b'},
+		},
+		{
+			"Lucent",
+			VendorFormat{TypeSize: 2, LengthSize: 1},
+			VSA{Vendor: 4846, Type: 0x0102, Value: []byte("ab")},
+			[]byte{0, 0, 0x12, 0xee, 1, 2, 5, 'a', 'b'},
+		},
+		{
+			"USR",
+			VendorFormat{TypeSize: 4, LengthSize: 0},
+			VSA{Vendor: 429, Type: 0x9801, Value: []byte("ab")},
+			[]byte{0, 0, 1, 0xad, 0, 0, 0x98, 1, 'a', 'b'},
+		},
+		{
+			"TwoByteLength",
+			VendorFormat{TypeSize: 2, LengthSize: 2},
+			VSA{Vendor: 8164, Type: 7, Value: []byte("ab")},
+			[]byte{0, 0, 0x1f, 0xe4, 0, 7, 0, 6, 'a', 'b'},
+		},
+		{
+			"WiMAX",
+			VendorFormat{TypeSize: 1, LengthSize: 1, Continuation: true},
+			VSA{Vendor: 24757, Type: 1, Value: []byte("ab")},
+			[]byte{0, 0, 0x60, 0xb5, 1, 5, 0, 'a', 'b'},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			avp := tc.vsa.ToAVPWithFormat(tc.format)
+			if avp.Type != AttrVendorSpecific {
+				t.Fatalf("ToAVP type = %d; want %d", avp.Type, AttrVendorSpecific)
+			}
+			if !bytes.Equal(avp.Value, tc.wire) {
+				t.Errorf("ToAVP = %v; want %v", avp.Value, tc.wire)
+			}
+
+			vsa := ToVSAWithFormat(avp, tc.format)
+			if vsa.Vendor != tc.vsa.Vendor || vsa.Type != tc.vsa.Type || !bytes.Equal(vsa.Value, tc.vsa.Value) {
+				t.Errorf("ToVSA = %+v; want %+v", vsa, tc.vsa)
+			}
+		})
+	}
+}
+
+func TestVSAContinuation(t *testing.T) {
+	f := VendorFormat{TypeSize: 1, LengthSize: 1, Continuation: true}
+	value := bytes.Repeat([]byte{0x5a}, 600)
+	vsa := VSA{Vendor: 24757, Type: 3, Value: value}
+
+	avps := vsa.ToAVPsWithFormat(f)
+	if len(avps) != 3 {
+		t.Fatalf("ToAVPs returned %d fragments; want 3", len(avps))
+	}
+	for i, avp := range avps {
+		more := avp.Value[6]&vsaContinuation != 0
+		if more != (i < len(avps)-1) {
+			t.Errorf("fragment %d continuation = %v", i, more)
+		}
+		if len(avp.Value)+2 > 255 {
+			t.Errorf("fragment %d too long: %d", i, len(avp.Value)+2)
+		}
+	}
+
+	d := NewDictionary()
+	d.vendorFormat[24757] = f
+	old := GetDefaultDictionary()
+	SetDefaultDictionary(d)
+	defer SetDefaultDictionary(old)
+
+	p := &Packet{}
+	p.AddAVP(AVP{Type: AttrUserName, Value: []byte("user")})
+	p.AddVSA(vsa)
+	if len(p.AVPs) != 4 {
+		t.Fatalf("AddVSA added %d attributes; want 3", len(p.AVPs)-1)
+	}
+
+	got := p.GetVSA(24757, 3)
+	if got == nil {
+		t.Fatal("GetVSA returned nil")
+	}
+	if !bytes.Equal(got.Value, value) {
+		t.Errorf("GetVSA reassembled %d bytes; want %d", len(got.Value), len(value))
+	}
+	if p.GetVSA(24757, 4) != nil {
+		t.Error("GetVSA returned unexpected attribute")
+	}
+}
+
+func TestLoadFileVendorFormat(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := `
+VENDOR		USR		429	format=4,0
+VENDOR		Lucent		4846	format=2,1
+VENDOR		WiMAX		24757	format=1,1,c
+
+BEGIN-VENDOR	USR
+ATTRIBUTE	USR-Last-Number-Dialed-Out	0x0066	string
+END-VENDOR	USR
+
+BEGIN-VENDOR	Lucent
+ATTRIBUTE	Lucent-Max-Shared-Users		2	integer
+ATTRIBUTE	Lucent-Large-Id			300	string
+END-VENDOR	Lucent
+`
+	path := filepath.Join(tmpDir, "dictionary")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write dictionary: %v", err)
+	}
+
+	d := NewDictionary()
+	if err := d.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+
+	if f := d.GetVendorFormat(429); f != (VendorFormat{TypeSize: 4}) {
+		t.Errorf("USR format = %+v", f)
+	}
+	if f := d.GetVendorFormat(24757); !f.Continuation {
+		t.Errorf("WiMAX format = %+v", f)
+	}
+	if f := d.GetVendorFormat(9); f != DefaultVendorFormat {
+		t.Errorf("unknown vendor format = %+v", f)
+	}
+	if id := d.GetVSAAttributeID(4846, "Lucent-Large-Id"); id != 300 {
+		t.Errorf("Lucent-Large-Id = %d; want 300", id)
+	}
+
+	vsa := d.NewVSA("Lucent", "Lucent-Large-Id", "hello")
+	tmpl, err := d.GetVSATemplate("Lucent", "Lucent-Large-Id")
+	if err != nil {
+		t.Fatalf("GetVSATemplate failed: %v", err)
+	}
+	p := &Packet{}
+	tmpl.Add(p, "hello")
+	avp := p.GetAVP(AttrVendorSpecific)
+	if avp == nil {
+		t.Fatal("VSA not added")
+	}
+	if !bytes.Equal(avp.Value, vsa.ToAVPWithFormat(d.GetVendorFormat(4846)).Value) {
+		t.Errorf("template encoded %v", avp.Value)
+	}
+
+	s := d.DecodeAVPValue(p, *avp)
+	want := "{Vendor:Lucent #4846, Attr: Lucent-Large-Id #300, Value: hello}"
+	if s != want {
+		t.Errorf("DecodeAVPValue = %s; want %s", s, want)
+	}
+}
@@ -0,0 +1,351 @@
+package radius
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// Access-Challenge conversations (RFC 2865 §4.4): the server answers an
+// Access-Request with a challenge carrying a State attribute, which the NAS
+// echoes in the next Access-Request of the conversation, for example with a
+// one-time password or a new password.
+
+var (
+	ErrNoChallengeState        = errors.New("request without State")
+	ErrUnknownChallengeState   = errors.New("unknown or expired State")
+	ErrChallengeClientMismatch = errors.New("State issued to another RADIUS client")
+)
+
+// DefaultChallengeTimeout is how long a conversation waits for the answer to
+// an Access-Challenge, sent as Session-Timeout.
+const DefaultChallengeTimeout = 60 * time.Second
+
+// challengeReplyTimeout is how long Handler keeps the reply to an answer for
+// the retransmissions of the NAS.
+const challengeReplyTimeout = 30 * time.Second
+
+// ChallengeSession is a conversation waiting for the answer to an
+// Access-Challenge.
+type ChallengeSession struct {
+	// State is the State attribute of the Access-Challenge.
+	State []byte
+	// Client is the address of the RADIUS client the challenge was sent to,
+	// without port.
+	Client string
+	// Expires is when the conversation is dropped.
+	Expires time.Time
+	// Data is the per-conversation data of the handler.
+	Data interface{}
+}
+
+// ChallengeStore keeps the conversations between rounds. Implementations
+// must be safe for concurrent use; stores shared by several servers must be
+// able to serialize the Data of their handlers.
+type ChallengeStore interface {
+	// Put stores a conversation until it expires.
+	Put(s *ChallengeSession) error
+	// Get returns the conversation of a State, or nil when there is none.
+	Get(state []byte) (*ChallengeSession, error)
+	// Take removes and returns the conversation of a State, or nil when
+	// there is none. Removing it ensures a conversation is resumed once.
+	Take(state []byte) (*ChallengeSession, error)
+	// Sweep removes the conversations expired at now.
+	Sweep(now time.Time) error
+}
+
+// MemoryChallengeStore is an in-memory ChallengeStore.
+type MemoryChallengeStore struct {
+	mu       sync.Mutex
+	sessions map[string]*ChallengeSession
+}
+
+// NewMemoryChallengeStore returns an empty in-memory store.
+func NewMemoryChallengeStore() *MemoryChallengeStore {
+	return &MemoryChallengeStore{sessions: map[string]*ChallengeSession{}}
+}
+
+func (m *MemoryChallengeStore) Put(s *ChallengeSession) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[string(s.State)] = s
+	return nil
+}
+
+func (m *MemoryChallengeStore) Get(state []byte) (*ChallengeSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sessions[string(state)], nil
+}
+
+func (m *MemoryChallengeStore) Take(state []byte) (*ChallengeSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.sessions[string(state)]
+	delete(m.sessions, string(state))
+	return s, nil
+}
+
+func (m *MemoryChallengeStore) Sweep(now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for state, s := range m.sessions {
+		if now.After(s.Expires) {
+			delete(m.sessions, state)
+		}
+	}
+	return nil
+}
+
+// Len returns the number of stored conversations, including expired ones
+// not swept yet.
+func (m *MemoryChallengeStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sessions)
+}
+
+// ChallengeManager runs Access-Challenge conversations for Server handlers.
+//
+// Challenge turns a reply into an Access-Challenge with an unguessable State
+// and keeps the handler data until the NAS answers or the Session-Timeout
+// elapses; Resume returns that data for the Access-Request echoing the
+// State, once and only from the RADIUS client the challenge was sent to.
+// Expired conversations are swept periodically. Handler answers the
+// retransmissions of an answer with the same reply.
+type ChallengeManager struct {
+	store ChallengeStore
+
+	mu      sync.Mutex
+	timeout time.Duration
+	swept   time.Time
+	// replies to answers by State, for retransmissions
+	replies map[string]*challengeReply
+}
+
+// challengeReply is the reply to the Access-Request answering a challenge.
+type challengeReply struct {
+	client        string
+	identifier    uint8
+	authenticator [16]byte
+	code          PacketCode
+	avps          []AVP
+	// done is false while the request is handled
+	done    bool
+	expires time.Time
+}
+
+// NewChallengeManager returns a manager keeping conversations in store, or
+// in memory when store is nil.
+func NewChallengeManager(store ChallengeStore) *ChallengeManager {
+	if store == nil {
+		store = NewMemoryChallengeStore()
+	}
+	return &ChallengeManager{
+		store:   store,
+		timeout: DefaultChallengeTimeout,
+		replies: map[string]*challengeReply{},
+	}
+}
+
+// SetTimeout sets how long a conversation waits for an answer when the
+// reply has no Session-Timeout.
+func (m *ChallengeManager) SetTimeout(t time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.timeout = t
+}
+
+// Challenge makes reply an Access-Challenge continuing the conversation of
+// request with data. It adds a new State and, unless reply already carries
+// one, a Session-Timeout of the manager timeout; the conversation expires
+// after that Session-Timeout. Use SetReplyMessage and SetPrompt to ask the
+// user for an answer.
+func (m *ChallengeManager) Challenge(request, reply *Packet, data interface{}) error {
+	state := make([]byte, stateSize)
+	if _, err := rand.Read(state); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	ttl := m.timeout
+	m.mu.Unlock()
+	if avp := reply.GetAVP(AttrSessionTimeout); avp != nil && len(avp.Value) == 4 {
+		ttl = time.Duration(binary.BigEndian.Uint32(avp.Value)) * time.Second
+	} else {
+		reply.SetAVP(AVP{Type: AttrSessionTimeout, Value: binary.BigEndian.AppendUint32(nil, uint32(ttl/time.Second))})
+	}
+
+	now := time.Now()
+	s := &ChallengeSession{
+		State:   state,
+		Client:  challengeClient(request),
+		Expires: now.Add(ttl),
+		Data:    data,
+	}
+	if err := m.store.Put(s); err != nil {
+		return err
+	}
+	m.sweep(now)
+
+	reply.Code = AccessChallenge
+	reply.SetAVP(AVP{Type: AttrState, Value: append([]byte(nil), state...)})
+	return nil
+}
+
+// Resume returns the data of the conversation continued by request. It
+// fails with ErrNoChallengeState when request has no State, so a handler can
+// tell a first request from an answer, with ErrUnknownChallengeState when
+// the State is unknown, expired or already resumed, and with
+// ErrChallengeClientMismatch when the request comes from another client,
+// leaving the conversation to its client. A retransmission of the answer is
+// an unknown State: see Handler.
+func (m *ChallengeManager) Resume(request *Packet) (interface{}, error) {
+	avp := request.GetAVP(AttrState)
+	if avp == nil {
+		return nil, ErrNoChallengeState
+	}
+	s, err := m.store.Get(avp.Value)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil || time.Now().After(s.Expires) {
+		return nil, ErrUnknownChallengeState
+	}
+	if s.Client != challengeClient(request) {
+		return nil, ErrChallengeClientMismatch
+	}
+	if s, err = m.store.Take(avp.Value); err != nil {
+		return nil, err
+	}
+	if s == nil {
+		// resumed meanwhile
+		return nil, ErrUnknownChallengeState
+	}
+	return s.Data, nil
+}
+
+// Handler returns a Service running next, which answers the retransmissions
+// of an Access-Request echoing a known State with the reply next gave to it,
+// as Resume only resumes a conversation once. The reply is kept for 30
+// seconds; retransmissions received while next handles the request are
+// dropped.
+func (m *ChallengeManager) Handler(next Service) Service {
+	return HandlerFunc(func(ctx context.Context, request *Packet) *Packet {
+		avp := request.GetAVP(AttrState)
+		if avp == nil {
+			return next.RadiusHandle(ctx, request)
+		}
+		state := string(avp.Value)
+		if reply, ok := m.lastReply(request, state); ok {
+			return reply
+		}
+		// only answers to known conversations are kept
+		client := challengeClient(request)
+		if s, err := m.store.Get(avp.Value); err != nil || s == nil || s.Client != client {
+			return next.RadiusHandle(ctx, request)
+		}
+
+		m.mu.Lock()
+		if reply, ok := m.lastReplyLocked(request, state); ok {
+			m.mu.Unlock()
+			return reply
+		}
+		r := &challengeReply{
+			client:        client,
+			identifier:    request.Identifier,
+			authenticator: request.Authenticator,
+			expires:       time.Now().Add(challengeReplyTimeout),
+		}
+		m.replies[state] = r
+		m.mu.Unlock()
+
+		reply := next.RadiusHandle(ctx, request)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if reply == nil {
+			if m.replies[state] == r {
+				delete(m.replies, state)
+			}
+			return nil
+		}
+		r.code = reply.Code
+		r.avps = make([]AVP, len(reply.AVPs))
+		for i, a := range reply.AVPs {
+			r.avps[i] = AVP{Type: a.Type, Value: append([]byte(nil), a.Value...)}
+		}
+		r.done = true
+		return reply
+	})
+}
+
+// lastReply returns the reply to request when it is a retransmission, nil
+// while the original is handled.
+func (m *ChallengeManager) lastReply(request *Packet, state string) (*Packet, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastReplyLocked(request, state)
+}
+
+func (m *ChallengeManager) lastReplyLocked(request *Packet, state string) (*Packet, bool) {
+	r := m.replies[state]
+	if r == nil || time.Now().After(r.expires) || r.client != challengeClient(request) ||
+		r.identifier != request.Identifier || r.authenticator != request.Authenticator {
+		return nil, false
+	}
+	if !r.done {
+		return nil, true
+	}
+	reply := request.Reply()
+	reply.Code = r.code
+	reply.AVPs = append(reply.AVPs, r.avps...)
+	return reply, true
+}
+
+// sweep removes the expired conversations and replies at most once per
+// timeout.
+func (m *ChallengeManager) sweep(now time.Time) {
+	m.mu.Lock()
+	if now.Sub(m.swept) <= m.timeout {
+		m.mu.Unlock()
+		return
+	}
+	m.swept = now
+	for state, r := range m.replies {
+		if now.After(r.expires) {
+			delete(m.replies, state)
+		}
+	}
+	m.mu.Unlock()
+	if err := m.store.Sweep(now); err != nil {
+		log.Printf("challenge store sweep failed: %v", err)
+	}
+}
+
+// challengeClient returns the address of the client which sent request,
+// without port.
+func challengeClient(request *Packet) string {
+	if host, _, err := net.SplitHostPort(request.ClientAddr); err == nil {
+		return host
+	}
+	return request.ClientAddr
+}
+
+// SetReplyMessage replaces the Reply-Message attributes of the packet with
+// message, split over several attributes when longer than 253 bytes.
+func (p *Packet) SetReplyMessage(message string) {
+	p.DeleteAllType(AttrReplyMessage)
+	for _, chunk := range splitConcat([]byte(message), true) {
+		p.AddAVP(AVP{Type: AttrReplyMessage, Value: chunk})
+	}
+}
+
+// SetPrompt sets the Prompt attribute (RFC 2869), telling the NAS whether
+// to echo the answer of the user to an Access-Challenge.
+func (p *Packet) SetPrompt(prompt PromptEnum) {
+	p.SetAVP(AVP{Type: AttrPrompt, Value: binary.BigEndian.AppendUint32(nil, uint32(prompt))})
+}
@@ -0,0 +1,189 @@
+package radius
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testOTPHandler checks the password, then asks for a one-time code.
+func testOTPHandler(m *ChallengeManager) HandlerFunc {
+	return func(ctx context.Context, request *Packet) *Packet {
+		reply := request.Reply()
+		reply.Code = AccessReject
+		data, err := m.Resume(request)
+		switch {
+		case errors.Is(err, ErrNoChallengeState):
+			if request.GetUsername() != "alice" || string(request.GetAVP(AttrUserPassword).Value) != "secret" {
+				return reply
+			}
+			reply.SetReplyMessage("Enter the code sent to your phone")
+			reply.SetPrompt(PromptEnumEcho)
+			if err := m.Challenge(request, reply, "123456"); err != nil {
+				reply.Code = AccessReject
+			}
+		case err == nil && string(request.GetAVP(AttrUserPassword).Value) == data.(string):
+			reply.Code = AccessAccept
+		}
+		return reply
+	}
+}
+
+// challengeTestRequest builds an Access-Request from client with the
+// password in clear, as handlers see it here.
+func challengeTestRequest(client, password string, state []byte) *Packet {
+	p := Request(AccessRequest, "secret")
+	p.ClientAddr = client
+	p.AddAVP(AVP{Type: AttrUserName, Value: []byte("alice")})
+	p.AddAVP(AVP{Type: AttrUserPassword, Value: []byte(password)})
+	if state != nil {
+		p.AddAVP(AVP{Type: AttrState, Value: state})
+	}
+	return p
+}
+
+func TestChallengeManager(t *testing.T) {
+	m := NewChallengeManager(nil)
+	h := testOTPHandler(m)
+	ctx := context.Background()
+
+	reply := h(ctx, challengeTestRequest("192.0.2.1:1645", "secret", nil))
+	if reply.Code != AccessChallenge {
+		t.Fatalf("first reply = %v; want Access-Challenge", reply.Code)
+	}
+	state := reply.GetAVP(AttrState)
+	if state == nil || len(state.Value) != stateSize {
+		t.Fatalf("Access-Challenge State = %v", state)
+	}
+	if avp := reply.GetAVP(AttrSessionTimeout); avp == nil || binary.BigEndian.Uint32(avp.Value) != 60 {
+		t.Errorf("Session-Timeout = %v; want 60", avp)
+	}
+	if avp := reply.GetAVP(AttrPrompt); avp == nil || binary.BigEndian.Uint32(avp.Value) != uint32(PromptEnumEcho) {
+		t.Errorf("Prompt = %v; want Echo", avp)
+	}
+	if msg := reply.GetAVP(AttrReplyMessage); msg == nil || !strings.HasPrefix(string(msg.Value), "Enter the code") {
+		t.Errorf("Reply-Message = %v", msg)
+	}
+
+	// another State for each conversation
+	other := h(ctx, challengeTestRequest("192.0.2.1:1645", "secret", nil))
+	if bytes.Equal(other.GetAVP(AttrState).Value, state.Value) {
+		t.Errorf("two conversations share State %x", state.Value)
+	}
+
+	// the NAS may answer from another port
+	reply = h(ctx, challengeTestRequest("192.0.2.1:1812", "123456", state.Value))
+	if reply.Code != AccessAccept {
+		t.Fatalf("answer reply = %v; want Access-Accept", reply.Code)
+	}
+	if _, err := m.Resume(challengeTestRequest("192.0.2.1:1812", "123456", state.Value)); !errors.Is(err, ErrUnknownChallengeState) {
+		t.Errorf("resuming twice: %v; want ErrUnknownChallengeState", err)
+	}
+
+	// a State is only valid for its client, which can still resume it
+	if _, err := m.Resume(challengeTestRequest("198.51.100.7:1645", "123456", other.GetAVP(AttrState).Value)); !errors.Is(err, ErrChallengeClientMismatch) {
+		t.Errorf("resuming from another client: %v; want ErrChallengeClientMismatch", err)
+	}
+	if _, err := m.Resume(challengeTestRequest("192.0.2.1:1645", "123456", other.GetAVP(AttrState).Value)); err != nil {
+		t.Errorf("resuming after another client: %v", err)
+	}
+}
+
+func TestChallengeManagerHandler(t *testing.T) {
+	m := NewChallengeManager(nil)
+	h := m.Handler(testOTPHandler(m))
+	ctx := context.Background()
+
+	reply := h.RadiusHandle(ctx, challengeTestRequest("192.0.2.1:1645", "secret", nil))
+	if reply.Code != AccessChallenge {
+		t.Fatalf("first reply = %v; want Access-Challenge", reply.Code)
+	}
+	state := reply.GetAVP(AttrState).Value
+
+	// the reply to the answer is lost and the NAS sends it again
+	answer := challengeTestRequest("192.0.2.1:1645", "123456", state)
+	for i := 0; i < 2; i++ {
+		if reply := h.RadiusHandle(ctx, answer); reply == nil || reply.Code != AccessAccept {
+			t.Fatalf("answer %d: reply = %v; want Access-Accept", i, reply)
+		}
+	}
+	// a new request is not a retransmission
+	if reply := h.RadiusHandle(ctx, challengeTestRequest("192.0.2.1:1645", "123456", state)); reply.Code != AccessReject {
+		t.Errorf("new request reply = %v; want Access-Reject", reply.Code)
+	}
+}
+
+func TestChallengeManagerExpiry(t *testing.T) {
+	store := NewMemoryChallengeStore()
+	m := NewChallengeManager(store)
+	m.SetTimeout(time.Hour)
+
+	// the reply Session-Timeout sets the lifetime
+	request := challengeTestRequest("192.0.2.1:1645", "secret", nil)
+	reply := request.Reply()
+	reply.SetAVP(AVP{Type: AttrSessionTimeout, Value: []byte{0, 0, 0, 0}})
+	if err := m.Challenge(request, reply, nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(reply.AVPs); n != 2 {
+		t.Errorf("Access-Challenge has %d attributes; want Session-Timeout and State", n)
+	}
+	time.Sleep(time.Millisecond)
+	if _, err := m.Resume(challengeTestRequest("192.0.2.1:1645", "", reply.GetAVP(AttrState).Value)); !errors.Is(err, ErrUnknownChallengeState) {
+		t.Errorf("resuming an expired conversation: %v; want ErrUnknownChallengeState", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := m.Challenge(request, request.Reply(), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Sweep(time.Now().Add(2 * time.Hour)); err != nil || store.Len() != 0 {
+		t.Errorf("Sweep left %d conversations, %v", store.Len(), err)
+	}
+}
+
+// testChallengeStore is a ChallengeStore failing on demand.
+type testChallengeStore struct {
+	*MemoryChallengeStore
+	err error
+}
+
+func (s *testChallengeStore) Put(c *ChallengeSession) error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.MemoryChallengeStore.Put(c)
+}
+
+func TestChallengeManagerStore(t *testing.T) {
+	store := &testChallengeStore{MemoryChallengeStore: NewMemoryChallengeStore()}
+	m := NewChallengeManager(store)
+	request := challengeTestRequest("192.0.2.1:1645", "secret", nil)
+	if err := m.Challenge(request, request.Reply(), "data"); err != nil || store.Len() != 1 {
+		t.Fatalf("Challenge() = %v with %d stored conversations", err, store.Len())
+	}
+
+	store.err = errors.New("store unavailable")
+	reply := request.Reply()
+	if err := m.Challenge(request, reply, "data"); err != store.err || reply.Code == AccessChallenge {
+		t.Errorf("Challenge() = %v, reply %v; want the store error", err, reply.Code)
+	}
+	if _, err := m.Resume(request); !errors.Is(err, ErrNoChallengeState) {
+		t.Errorf("Resume() without State: %v; want ErrNoChallengeState", err)
+	}
+}
+
+func TestSetReplyMessage(t *testing.T) {
+	p := Request(AccessChallenge, "secret")
+	p.SetReplyMessage("old")
+	long := strings.Repeat("x", 300)
+	p.SetReplyMessage(long)
+	if got := string(p.GetConcatenatedAVP(AttrReplyMessage)); got != long {
+		t.Errorf("Reply-Message = %d bytes; want %d", len(got), len(long))
+	}
+}
@@ -3,6 +3,7 @@ package radius
 import (
 	"crypto"
 	_ "crypto/md5"
+	"crypto/subtle"
 	"errors"
 )
 
@@ -17,6 +18,7 @@ type CHAPPassword struct {
 var (
 	ErrInvalidCHAPChallengeLength = errors.New("invalid CHAP-Challenge length (must be 1..16 bytes)")
 	ErrInvalidCHAPPasswordLength  = errors.New("invalid CHAP-Password length (must be 17 bytes)")
+	ErrNoCHAPPassword             = errors.New("no CHAP-Password attribute")
 )
 
 // GetCHAPChallenge returns CHAP-Challenge value bytes, if present.
@@ -101,3 +103,33 @@ func (p *Packet) SetCHAPPasswordFromSecret(chapID uint8, password string, challe
 	return nil
 }
 
+
+// GetEffectiveCHAPChallenge returns the challenge of the CHAP exchange of
+// an Access-Request: CHAP-Challenge, or the Request Authenticator when the
+// NAS used it as challenge (RFC 2865 §2.2).
+func (p *Packet) GetEffectiveCHAPChallenge() []byte {
+	if challenge := p.GetCHAPChallenge(); challenge != nil {
+		return challenge
+	}
+	out := make([]byte, len(p.Authenticator))
+	copy(out, p.Authenticator[:])
+	return out
+}
+
+// VerifyCHAP reports whether the CHAP-Password of an Access-Request was
+// computed from password, comparing in constant time.
+func (p *Packet) VerifyCHAP(password string) (bool, error) {
+	avp := p.GetAVP(AttrCHAPPassword)
+	if avp == nil {
+		return false, ErrNoCHAPPassword
+	}
+	chap, ok := p.GetCHAPPassword()
+	if !ok {
+		return false, ErrInvalidCHAPPasswordLength
+	}
+	expected, err := ComputeCHAPResponse(chap.ID, password, p.GetEffectiveCHAPChallenge())
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(expected[:], chap.Response[:]) == 1, nil
+}
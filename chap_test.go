@@ -92,3 +92,33 @@ func TestSetCHAPChallengeRejectsInvalidLength(t *testing.T) {
 	}
 }
 
+
+func TestVerifyCHAP(t *testing.T) {
+	// with CHAP-Challenge
+	p := Request(AccessRequest, "secret")
+	if err := p.SetCHAPPasswordFromSecret(5, "pw", []byte("0123456789abcdef")); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := p.VerifyCHAP("pw"); err != nil || !ok {
+		t.Fatalf("VerifyCHAP(right password) = %v, %v", ok, err)
+	}
+	if ok, err := p.VerifyCHAP("wrong"); err != nil || ok {
+		t.Fatalf("VerifyCHAP(wrong password) = %v, %v", ok, err)
+	}
+
+	// the Request Authenticator is the challenge without CHAP-Challenge
+	p = Request(AccessRequest, "secret")
+	resp, _ := ComputeCHAPResponse(6, "pw", p.Authenticator[:])
+	p.SetCHAPPassword(6, resp)
+	if ok, err := p.VerifyCHAP("pw"); err != nil || !ok {
+		t.Fatalf("VerifyCHAP(Request Authenticator) = %v, %v", ok, err)
+	}
+
+	if _, err := Request(AccessRequest, "secret").VerifyCHAP("pw"); err != ErrNoCHAPPassword {
+		t.Errorf("VerifyCHAP without CHAP-Password: %v", err)
+	}
+	p.SetAVP(AVP{Type: AttrCHAPPassword, Value: []byte{1, 2, 3}})
+	if _, err := p.VerifyCHAP("pw"); err != ErrInvalidCHAPPasswordLength {
+		t.Errorf("VerifyCHAP with short CHAP-Password: %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+// Command radius-dictgen generates a Go package with typed attribute accessors
+// from FreeRADIUS dictionaries, see radius.Dictionary.GenerateGo.
+//
+// Typical use in a go:generate directive:
+//
+//	//go:generate go run github.com/sergle/radius/v2/cmd/radius-dictgen -rfc -packs cisco -o attributes.go
+//
+// The package name defaults to $GOPACKAGE, set by go generate.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/sergle/radius/v2"
+)
+
+func main() {
+	dictPath := flag.String("dict", "", "Path to a FreeRADIUS dictionary file")
+	rfc := flag.Bool("rfc", false, "Load the embedded RFC dictionaries")
+	packs := flag.String("packs", "", "Comma separated embedded vendor packs to load (e.g. cisco,microsoft)")
+	vendors := flag.String("vendors", "", "Comma separated vendors to generate (default: all)")
+	noStandard := flag.Bool("no-standard", false, "Do not generate the standard attributes")
+	pkg := flag.String("package", os.Getenv("GOPACKAGE"), "Name of the generated package")
+	output := flag.String("o", "", "Output file (default: standard output)")
+	strict := flag.Bool("strict", false, "Fail on any dictionary error instead of skipping the offending lines")
+	flag.Parse()
+
+	if *dictPath == "" && !*rfc && *packs == "" {
+		log.Fatal("no dictionary: use -dict, -rfc or -packs")
+	}
+
+	dict := radius.NewDictionary()
+	if *strict {
+		dict.SetDiagnosticMode(radius.DiagnosticModeStrict)
+	} else {
+		dict.SetDiagnosticMode(radius.DiagnosticModeLenient)
+	}
+	if err := loadDictionary(dict, *dictPath, *rfc, splitList(*packs)); err != nil {
+		log.Fatal(err)
+	}
+	for _, diag := range dict.Diagnostics() {
+		if diag.Severity == radius.SeverityError {
+			log.Print(diag)
+		}
+	}
+
+	var buf bytes.Buffer
+	opts := radius.GenerateOptions{
+		Package:         *pkg,
+		Vendors:         splitList(*vendors),
+		ExcludeStandard: *noStandard,
+		Source:          source(*dictPath, *rfc, *packs),
+	}
+	if err := dict.GenerateGo(&buf, opts); err != nil {
+		log.Fatal(err)
+	}
+
+	var err error
+	if *output == "" {
+		_, err = os.Stdout.Write(buf.Bytes())
+	} else {
+		err = os.WriteFile(*output, buf.Bytes(), 0644)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func loadDictionary(dict *radius.Dictionary, dictPath string, rfc bool, packs []string) error {
+	if rfc {
+		if err := dict.LoadRFC(); err != nil {
+			return err
+		}
+	}
+	for _, pack := range packs {
+		if err := dict.LoadVendorPack(pack); err != nil {
+			return err
+		}
+	}
+	if dictPath != "" {
+		return dict.LoadFile(dictPath)
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// source describes the loaded dictionaries for the header of the generated file.
+func source(dictPath string, rfc bool, packs string) string {
+	var parts []string
+	if rfc {
+		parts = append(parts, "the RFC dictionaries")
+	}
+	if packs != "" {
+		parts = append(parts, "vendor packs "+packs)
+	}
+	if dictPath != "" {
+		parts = append(parts, dictPath)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -5,13 +5,17 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 
 	"log"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	// ASCII whitespace checker for RADIUS files (only space and tab)
 )
 
@@ -43,6 +47,8 @@ func splitLine(line string) []string {
 var (
 	defaultDictionary   *Dictionary
 	defaultDictionaryMu sync.RWMutex
+	// when set, used instead of defaultDictionary by AVP decoding
+	defaultSnapshot atomic.Pointer[DictionarySnapshot]
 )
 
 func init() {
@@ -50,10 +56,24 @@ func init() {
 }
 
 // SetDefaultDictionary sets the dictionary used for package-level lookups (like AVP.Decode)
+// and clears the default snapshot.
 func SetDefaultDictionary(d *Dictionary) {
 	defaultDictionaryMu.Lock()
 	defer defaultDictionaryMu.Unlock()
 	defaultDictionary = d
+	defaultSnapshot.Store(nil)
+}
+
+// SetDefaultSnapshot makes s the source of the package-level lookups (AVP
+// decoding and formatting, VSA layouts) without locking. A nil snapshot
+// falls back to the default dictionary.
+func SetDefaultSnapshot(s *DictionarySnapshot) {
+	defaultSnapshot.Store(s)
+}
+
+// GetDefaultSnapshot returns the default snapshot, nil when none is set.
+func GetDefaultSnapshot() *DictionarySnapshot {
+	return defaultSnapshot.Load()
 }
 
 // GetDefaultDictionary returns the current default dictionary
@@ -75,29 +95,74 @@ type Dictionary struct {
 	attrName map[AttributeType]string
 	// map attribute name to type name
 	attrType map[string]string
+	// map attribute name to flags (has_tag, encrypt=N, ...)
+	attrFlags map[string]AttributeFlags
+	// RFC 6929 extended attributes, sharing attrType, attrFlags and the
+	// VALUE maps with the standard attributes
+	// map attribute name to Extended-Attribute and Extended-Type
+	extAttrID map[string]extendedKey
+	// map Extended-Attribute and Extended-Type to name
+	extAttrName map[extendedKey]string
 	// map attribute name + enum name to id
 	constID map[string]map[string]uint32
 	// map attribute name + enum id to enum name
 	constName map[string]map[uint32]string
 	// list of scanned files - to avoid recursion
-	fileList map[string]bool
+	fileList map[dictionaryFile]bool
+	// number of LoadFS calls, numbering dictionaryFile.source
+	fileSources int
 	// map vendor name to id
 	vendorID map[string]VendorID
 	// map vendor id to name
 	vendorName map[VendorID]string
+	// map vendor id to VSA layout (only non-default formats)
+	vendorFormat map[VendorID]VendorFormat
 	// current vendor (parser state)
 	currentVendor VendorID
 	// current TLV attribute (WiMAX Vendor), ignored
 	currentTLV VendorAttr
+	// file system of the file being parsed, nil for OS paths, and its
+	// dictionaryFile.source (parser state)
+	currentFS     fs.FS
+	currentSource int
+	// position of the line being parsed, for diagnostics (parser state)
+	currentFile string
+	currentLine int
+	// skip the lines of an unknown vendor or TLV block (lenient mode)
+	skipBlock bool
+	// FreeRADIUS v4 parser state: skipped non-RADIUS protocol block, depth
+	// of BEGIN/END blocks, FLAGS internal and struct receiving MEMBER lines
+	skipProtocol  string
+	nestDepth     int
+	internal      bool
+	currentStruct string
+
+	// FreeRADIUS v4 definitions
+	// protocol name -> number
+	protocols map[string]uint32
+	// vendor (0 for standard) -> ENUM name -> type name
+	enumType map[VendorID]map[string]string
+	// vendor (0 for standard) -> ALIAS name -> attribute name
+	aliases map[VendorID]map[string]string
+	// struct attribute or STRUCT label -> members
+	structMembers map[string][]AttributeDef
+	// how problems are reported, and the problems found so far
+	diagMode    DiagnosticMode
+	diagnostics []Diagnostic
 
 	// vsa
 	// vendor -> attribute name -> attribute id
 	vsaAttrID   map[VendorID]map[string]VendorAttr
 	vsaAttrName map[VendorID]map[VendorAttr]string
 	vsaAttrType map[VendorID]map[string]string
+	// vendor -> attribute name -> flags
+	vsaAttrFlags map[VendorID]map[string]AttributeFlags
 	// vendor -> attribute name -> constant name -> constant id
 	vsaConstID   map[VendorID]map[string]map[string]uint32
 	vsaConstName map[VendorID]map[string]map[uint32]string
+	// read-only copies of the constant ids by valueKey, handed out by
+	// valueIDs and replaced when VALUEs are defined
+	valueCache *sync.Map
 }
 
 // NewDictionary returns an empty dictionary ready to load dictionary files.
@@ -107,16 +172,26 @@ func NewDictionary() *Dictionary {
 	dict.attrID = make(map[string]AttributeType)
 	dict.attrName = make(map[AttributeType]string)
 	dict.attrType = make(map[string]string)
+	dict.attrFlags = make(map[string]AttributeFlags)
+	dict.extAttrID = make(map[string]extendedKey)
+	dict.extAttrName = make(map[extendedKey]string)
 	dict.constID = make(map[string]map[string]uint32)
 	dict.constName = make(map[string]map[uint32]string)
-	dict.fileList = make(map[string]bool)
+	dict.fileList = make(map[dictionaryFile]bool)
 	dict.vendorID = make(map[string]VendorID)
 	dict.vendorName = make(map[VendorID]string)
+	dict.vendorFormat = make(map[VendorID]VendorFormat)
 	dict.vsaAttrID = make(map[VendorID]map[string]VendorAttr)
 	dict.vsaAttrName = make(map[VendorID]map[VendorAttr]string)
 	dict.vsaAttrType = make(map[VendorID]map[string]string)
+	dict.vsaAttrFlags = make(map[VendorID]map[string]AttributeFlags)
 	dict.vsaConstID = make(map[VendorID]map[string]map[string]uint32)
 	dict.vsaConstName = make(map[VendorID]map[string]map[uint32]string)
+	dict.protocols = make(map[string]uint32)
+	dict.enumType = make(map[VendorID]map[string]string)
+	dict.aliases = make(map[VendorID]map[string]string)
+	dict.structMembers = make(map[string][]AttributeDef)
+	dict.valueCache = new(sync.Map)
 
 	return dict
 }
@@ -124,7 +199,8 @@ func NewDictionary() *Dictionary {
 // LoadFile loads and parses a dictionary file.
 //
 // The file format is compatible with FreeRADIUS dictionary files and supports
-// $INCLUDE recursion (with internal/unsupported files skipped).
+// $INCLUDE recursion (with internal/unsupported files skipped). Problems are
+// reported according to the DiagnosticMode.
 func (d *Dictionary) LoadFile(fname string) error {
 	d.Lock()
 	defer d.Unlock()
@@ -132,28 +208,106 @@ func (d *Dictionary) LoadFile(fname string) error {
 }
 
 func (d *Dictionary) loadFileInternal(fname string) error {
-	log.Printf("Reading file %s\n", fname)
+	d.logf("Reading file %s\n", fname)
 
-	if _, ok := d.fileList[fname]; ok {
-		log.Printf("File %s already read\n", fname)
+	key := dictionaryFile{name: fname}
+	if _, ok := d.fileList[key]; ok {
+		d.logf("File %s already read\n", fname)
 		return nil
 	}
 
-	d.fileList[fname] = true
+	d.fileList[key] = true
 
 	file, err := os.Open(fname)
 	if err != nil {
-		log.Printf("Failed to open file %s, error %s\n", fname, err)
-		return err
+		return d.failf(err, "failed to open file %s: %s", fname, err)
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
+	return d.parseReader(fname, file)
+}
+
+// LoadFS loads and parses a dictionary file from fsys.
+//
+// $INCLUDE paths are resolved relative to the including file inside fsys.
+// Each file of fsys is read once per call; files of the same name on the OS
+// file system or read by another call are distinct.
+func (d *Dictionary) LoadFS(fsys fs.FS, name string) error {
+	d.Lock()
+	defer d.Unlock()
+	d.fileSources++
+	return d.loadFSInternal(fsys, d.fileSources, name)
+}
+
+func (d *Dictionary) loadFSInternal(fsys fs.FS, source int, fname string) error {
+	d.logf("Reading file %s\n", fname)
+
+	key := dictionaryFile{source: source, name: fname}
+	if _, ok := d.fileList[key]; ok {
+		d.logf("File %s already read\n", fname)
+		return nil
+	}
+
+	d.fileList[key] = true
+
+	file, err := fsys.Open(fname)
+	if err != nil {
+		return d.failf(err, "failed to open file %s: %s", fname, err)
+	}
+	defer file.Close()
+
+	prevFS, prevSource := d.currentFS, d.currentSource
+	d.currentFS, d.currentSource = fsys, source
+	defer func() { d.currentFS, d.currentSource = prevFS, prevSource }()
+
+	return d.parseReader(fname, file)
+}
+
+// LoadReader parses dictionary lines read from r.
+//
+// name identifies the source for the recursion guard, as an OS path for
+// LoadFile; $INCLUDE paths are resolved on the OS file system relative to
+// its directory.
+func (d *Dictionary) LoadReader(name string, r io.Reader) error {
+	d.Lock()
+	defer d.Unlock()
+
+	key := dictionaryFile{name: name}
+	if _, ok := d.fileList[key]; ok {
+		d.logf("File %s already read\n", name)
+		return nil
+	}
+
+	d.fileList[key] = true
+
+	return d.parseReader(name, r)
+}
+
+// dictionaryFile identifies a file read by the dictionary: its name and where
+// it was read from, 0 for the OS file system, the number of the LoadFS call
+// or embeddedSource.
+type dictionaryFile struct {
+	source int
+	name   string
+}
+
+// parseReader parses dictionary lines from r; fname is used to resolve $INCLUDE.
+func (d *Dictionary) parseReader(fname string, r io.Reader) error {
+	prevFile, prevLine := d.currentFile, d.currentLine
+	defer func() { d.currentFile, d.currentLine = prevFile, prevLine }()
+	// FLAGS apply to the rest of the file
+	prevInternal := d.internal
+	defer func() { d.internal = prevInternal }()
+
+	scanner := bufio.NewScanner(r)
 	scanner.Split(bufio.ScanLines)
 
+	lineNo := 0
 	for scanner.Scan() {
 		line := scanner.Text()
-		//log.Printf("Line: %s\n", line)
+		lineNo++
+		// restored after each line, $INCLUDE changes the position
+		d.currentFile, d.currentLine = fname, lineNo
 
 		err := d.parseLine(fname, line)
 		if err != nil {
@@ -161,9 +315,37 @@ func (d *Dictionary) loadFileInternal(fname string) error {
 		}
 	}
 
+	if err := scanner.Err(); err != nil {
+		return d.failf(err, "failed to read file %s: %s", fname, err)
+	}
 	return nil
 }
 
+// minimum number of fields for each dictionary command
+var dictionaryCommandFields = map[string]int{
+	"ATTRIBUTE":    4,
+	"VALUE":        4,
+	"$INCLUDE":     2,
+	"VENDOR":       3,
+	"BEGIN-VENDOR": 2,
+	"END-VENDOR":   2,
+	"BEGIN-TLV":    2,
+	"END-TLV":      2,
+	// FreeRADIUS v3.2 / v4
+	"$INCLUDE-":      2,
+	"ALIAS":          3,
+	"ENUM":           3,
+	"DEFINE":         3,
+	"STRUCT":         4,
+	"MEMBER":         3,
+	"FLAGS":          2,
+	"PROTOCOL":       3,
+	"BEGIN-PROTOCOL": 2,
+	"END-PROTOCOL":   2,
+	"BEGIN":          2,
+	"END":            2,
+}
+
 func (d *Dictionary) parseLine(fname string, line string) error {
 
 	// ATTRIBUTE     User-Name                               1       string
@@ -178,151 +360,318 @@ func (d *Dictionary) parseLine(fname string, line string) error {
 	}
 
 	cmd := parts[0]
+	if d.skipProtocol != "" && cmd != "END-PROTOCOL" {
+		// definitions for another protocol (DHCP, TACACS+, ...)
+		return nil
+	}
+	if d.nestDepth > 0 && cmd != "BEGIN" && cmd != "END" {
+		// nested TLV and struct members are not supported
+		return nil
+	}
+
+	fields, ok := dictionaryCommandFields[cmd]
+	if !ok {
+		return d.errorf("unsupported command %s", cmd)
+	}
+	if len(parts) < fields {
+		return d.errorf("invalid %s line: %s", cmd, line)
+	}
+
 	switch cmd {
 	case "ATTRIBUTE":
-		if len(parts) < 4 {
-			return errors.New("Invalid ATTRIBUTE line: " + line)
+		flags := ""
+		if len(parts) > 4 && !strings.HasPrefix(parts[4], "#") {
+			flags = parts[4]
 		}
-		return d.parseAttribute(parts[1], parts[2], parts[3])
+		return d.parseAttribute(parts[1], parts[2], parts[3], flags)
 	case "VALUE":
-		if len(parts) < 4 {
-			return errors.New("Invalid VALUE line: " + line)
-		}
 		return d.parseValue(parts[1], parts[2], parts[3])
-	case "$INCLUDE":
-		if len(parts) < 2 {
-			return errors.New("Invalid $INCLUDE line: " + line)
-		}
-		return d.parseInclude(fname, parts[1])
+	case "$INCLUDE", "$INCLUDE-":
+		return d.parseInclude(fname, parts[1], cmd == "$INCLUDE-")
 	case "VENDOR":
-		if len(parts) < 3 {
-			return errors.New("Invalid VENDOR line: " + line)
+		format := ""
+		if len(parts) > 3 && !strings.HasPrefix(parts[3], "#") {
+			format = parts[3]
 		}
-		return d.parseVendor(parts[1], parts[2])
+		return d.parseVendor(parts[1], parts[2], format)
 	case "BEGIN-VENDOR":
-		if len(parts) < 2 {
-			return errors.New("Invalid BEGIN-VENDOR line: " + line)
-		}
 		return d.parseBeginVendor(parts[1])
 	case "END-VENDOR":
-		if len(parts) < 2 {
-			return errors.New("Invalid END-VENDOR line: " + line)
-		}
 		return d.parseEndVendor(parts[1])
 	case "BEGIN-TLV":
-		if len(parts) < 2 {
-			return errors.New("Invalid BEGIN-TLV line: " + line)
-		}
 		return d.parseBeginTLV(parts[1])
 	case "END-TLV":
-		if len(parts) < 2 {
-			return errors.New("Invalid END-TLV line: " + line)
-		}
 		return d.parseEndTLV(parts[1])
 	default:
-		log.Printf("UNKNOWN cmd: %s\n", cmd)
-		return errors.New("Unsupported command " + cmd)
+		return d.parseV4Line(cmd, parts)
 	}
+}
 
+// attrLabel names an attribute in diagnostics, prefixed by its vendor.
+func (d *Dictionary) attrLabel(vendorID VendorID, attrName string) string {
+	if vendorID > 0 {
+		return d.vendorName[vendorID] + "." + attrName
+	}
+	return attrName
 }
 
-func (d *Dictionary) parseAttribute(attrName string, attrID string, attrType string) error {
-	// id_size := 8
-	// if d.current_vendor > 0 {
-	//     // some vendors has 16-bit attr id (Lucent)
-	//     id_size = 16
-	// }
+func (d *Dictionary) parseAttribute(attrName string, attrID string, attrType string, attrFlags string) error {
+	d.currentStruct = ""
+	if d.skipBlock || d.internal {
+		return nil
+	}
+
+	idSize := 8
+	if d.currentVendor > 0 {
+		// some vendors has 16-bit (Lucent) or 32-bit (USR) attr id
+		idSize = 8 * d.getVendorFormat(d.currentVendor).TypeSize
+	}
 
 	// 0 - guess base (0x for hex)
-	aID, err := strconv.ParseUint(attrID, 0, 8)
+	aID, err := strconv.ParseUint(attrID, 0, idSize)
+	var extended AttributeType
+	if err != nil && d.currentVendor == 0 {
+		// 241.8 style extended attributes
+		extended, aID, err = d.parseExtendedID(attrID)
+	}
 	if err != nil {
-		log.Printf("Failed to convert attr %s id %s to uint: %s. Ignoring\n", attrName, attrID, err)
-		// ignore errors
-		return nil
+		if strings.Contains(attrID, ".") {
+			// 241.1 style extended or TLV attributes
+			d.warnf("unsupported attribute id %s for %s, ignored", attrID, attrName)
+			return nil
+		}
+		return d.recoverf("invalid attribute id %s for %s, ignored", attrID, attrName)
 	}
 
 	//TODO WiMAX
 	if d.currentTLV > 0 {
 		// ignore tlv sub-attributes
-		log.Printf("Ignore TVL attribute %s\n", attrName)
+		d.warnf("TLV attribute %s ignored", attrName)
 		return nil
 	}
 
-	if d.currentVendor > 0 {
+	// ATTRIBUTE Tunnel-Password 69 string has_tag,encrypt=2
+	flags, err := ParseAttributeFlags(attrFlags)
+	if err != nil {
+		return d.errorf("attribute %s: %s", attrName, err)
+	}
+	if flags.Virtual {
+		return nil
+	}
+
+	attrType = canonicalAttrType(attrType)
+	if _, ok := attrTypeHandlers[attrType]; !ok {
+		d.warnf("attribute %s has unsupported type %s, handled as octets", attrName, attrType)
+	}
+	if _, ok := d.enumType[d.currentVendor][flags.Enum]; flags.Enum != "" && !ok {
+		if err := d.errorf("attribute %s: unknown ENUM %s", attrName, flags.Enum); err != nil {
+			return err
+		}
+		flags.Enum = ""
+	}
+
+	def := AttributeDef{Name: attrName, Vendor: d.currentVendor, ID: uint32(aID), Type: attrType, Flags: flags, Extended: extended}
+	if err := d.checkAttribute(def); err != nil {
+		return err
+	}
+
+	if extended > 0 {
+		key := extendedKey{extended, uint8(aID)}
+		d.extAttrID[attrName] = key
+		d.extAttrName[key] = attrName
+		d.attrType[attrName] = attrType
+		d.attrFlags[attrName] = flags
+	} else if d.currentVendor > 0 {
 		if _, ok := d.vsaAttrID[d.currentVendor]; !ok {
 			d.vsaAttrID[d.currentVendor] = make(map[string]VendorAttr)
 			d.vsaAttrName[d.currentVendor] = make(map[VendorAttr]string)
 			d.vsaAttrType[d.currentVendor] = make(map[string]string)
+			d.vsaAttrFlags[d.currentVendor] = make(map[string]AttributeFlags)
 		}
 
 		d.vsaAttrID[d.currentVendor][attrName] = VendorAttr(aID)
 		d.vsaAttrName[d.currentVendor][VendorAttr(aID)] = attrName
 		d.vsaAttrType[d.currentVendor][attrName] = attrType
-		//fmt.Printf("Attr %s / %s has id %d and type %s\n", d.vendorName[ d.currentVendor ], attrName, aID, attrType)
+		d.vsaAttrFlags[d.currentVendor][attrName] = flags
 	} else {
 		d.attrID[attrName] = AttributeType(aID)
 		d.attrName[AttributeType(aID)] = attrName
 		d.attrType[attrName] = attrType
-		//log.Printf("Attr %s has id %d and type %s\n", attrName, aID, attrType)
+		d.attrFlags[attrName] = flags
+	}
+
+	if flags.Enum != "" {
+		d.shareValueMaps(d.currentVendor, attrName, flags.Enum)
+	}
+	if attrType == "struct" {
+		// MEMBER lines follow
+		d.currentStruct = d.attrLabel(d.currentVendor, attrName)
+	}
+
+	return nil
+}
+
+// parseExtendedID parses the id of an extended attribute, like "241.8": the
+// Extended-Attribute, which must be defined with an extended type, and the
+// Extended-Type.
+func (d *Dictionary) parseExtendedID(attrID string) (AttributeType, uint64, error) {
+	parent, ext, ok := strings.Cut(attrID, ".")
+	if !ok {
+		return 0, 0, errors.New("not an extended attribute id")
+	}
+	n, err := strconv.ParseUint(parent, 10, 8)
+	if err != nil {
+		return 0, 0, err
+	}
+	switch d.attrType[d.attrName[AttributeType(n)]] {
+	case "extended", "long-extended":
+	default:
+		return 0, 0, errors.New("not an extended attribute id")
+	}
+	extType, err := strconv.ParseUint(ext, 10, 8)
+	if err != nil {
+		return 0, 0, err
+	}
+	return AttributeType(n), extType, nil
+}
+
+// checkAttribute reports duplicate and conflicting definitions of def.
+func (d *Dictionary) checkAttribute(def AttributeDef) error {
+	var old AttributeDef
+	var oldOK, nameOK bool
+	var oldName string
+	if def.Vendor > 0 {
+		old, oldOK = d.getVSAAttributeDef(def.Vendor, def.Name)
+		oldName, nameOK = d.vsaAttrName[def.Vendor][VendorAttr(def.ID)]
+	} else if def.Extended > 0 {
+		old, oldOK = d.getAttributeDef(def.Name)
+		oldName, nameOK = d.extAttrName[extendedKey{def.Extended, uint8(def.ID)}]
+	} else {
+		old, oldOK = d.getAttributeDef(def.Name)
+		oldName, nameOK = d.attrName[AttributeType(def.ID)]
 	}
 
+	label := d.attrLabel(def.Vendor, def.Name)
+	if oldOK {
+		if old == def {
+			d.notef("duplicate attribute %s", label)
+			return nil
+		}
+		if old.ID != def.ID || old.Extended != def.Extended {
+			return d.conflictf("attribute %s redefined with id %s (was %s)", label, def.idString(), old.idString())
+		}
+		if old.Type != def.Type {
+			return d.conflictf("attribute %s redefined with type %s (was %s)", label, def.Type, old.Type)
+		}
+		return d.conflictf("attribute %s redefined with flags %q (was %q)", label, def.Flags, old.Flags)
+	}
+	if nameOK && oldName != def.Name {
+		return d.conflictf("attribute id %s redefined as %s (was %s)", def.idString(), label, d.attrLabel(def.Vendor, oldName))
+	}
 	return nil
 }
 
 func (d *Dictionary) parseValue(attrName string, constName string, constValue string) error {
+	if d.skipBlock {
+		return nil
+	}
+
 	//TODO WiMAX
 	if d.currentTLV > 0 {
 		// ignore tlv sub-attributes
-		log.Printf("Ignore TVL attribute %s\n", attrName)
+		d.warnf("value %s of TLV attribute %s ignored", constName, attrName)
 		return nil
 	}
 
+	// VALUE lines apply to an attribute or to an ENUM (FreeRADIUS v4)
+	var attrType string
 	var present bool
 	if d.currentVendor > 0 {
-		_, present = d.vsaAttrID[d.currentVendor][attrName]
+		attrType, present = d.vsaAttrType[d.currentVendor][attrName]
 	} else {
-		_, present = d.attrID[attrName]
+		attrType, present = d.attrType[attrName]
+	}
+	if !present {
+		attrType, present = d.enumType[d.currentVendor][attrName]
 	}
 
+	label := d.attrLabel(d.currentVendor, attrName)
 	if !present {
-		log.Printf("Value %s for non-existing attribute %s\n", constName, attrName)
 		// ignore 'compat' errors
+		d.warnf("value %s for unknown attribute %s ignored", constName, label)
+		return nil
+	}
+	if !isEnumType(attrType) {
+		d.notef("value %s for %s attribute %s ignored", constName, attrType, label)
 		return nil
 	}
 
 	// some values defined as 0x.. - using '0' to auto-detect
 	cID, err := strconv.ParseUint(constValue, 0, 32)
 	if err != nil {
-		log.Printf("Failed to convert constant value to int: %s\n", err)
-		return err
+		return d.errorf("invalid value %s for %s %s", constValue, label, constName)
 	}
 
-	if d.currentVendor > 0 {
-		if _, ok := d.vsaConstID[d.currentVendor]; !ok {
-			d.vsaConstID[d.currentVendor] = make(map[string]map[string]uint32)
-			d.vsaConstName[d.currentVendor] = make(map[string]map[uint32]string)
+	ids, names := d.valueMaps(d.currentVendor, attrName)
+	if oldID, ok := ids[constName]; ok && oldID == uint32(cID) {
+		d.notef("duplicate value %s for %s", constName, label)
+	} else if ok {
+		if err := d.conflictf("value %s for %s redefined as %d (was %d)", constName, label, cID, oldID); err != nil {
+			return err
 		}
+	}
+
+	ids[constName] = uint32(cID)
+	names[uint32(cID)] = constName
+
+	return nil
+}
 
-		if _, ok := d.vsaConstID[d.currentVendor][attrName]; !ok {
-			d.vsaConstID[d.currentVendor][attrName] = make(map[string]uint32)
-			d.vsaConstName[d.currentVendor][attrName] = make(map[uint32]string)
+// isEnumType reports whether VALUE lines apply to the type.
+func isEnumType(attrType string) bool {
+	switch attrType {
+	case "integer", "byte", "short":
+		return true
+	}
+	return false
+}
+
+// valueMaps returns the VALUE maps of an attribute or ENUM, creating them
+// when needed.
+func (d *Dictionary) valueMaps(vendorID VendorID, attrName string) (map[string]uint32, map[uint32]string) {
+	// the maps returned are about to change
+	d.valueCache = new(sync.Map)
+	if vendorID > 0 {
+		if _, ok := d.vsaConstID[vendorID]; !ok {
+			d.vsaConstID[vendorID] = make(map[string]map[string]uint32)
+			d.vsaConstName[vendorID] = make(map[string]map[uint32]string)
 		}
 
-		d.vsaConstID[d.currentVendor][attrName][constName] = uint32(cID)
-		d.vsaConstName[d.currentVendor][attrName][uint32(cID)] = constName
-		//fmt.Printf("Attr %s / %s has value %s = %s\n", d.vendorName[ d.currentVendor ], attrName, constName, constValue)
-	} else {
-		if _, exist := d.constID[attrName]; !exist {
-			d.constID[attrName] = make(map[string]uint32)
-			d.constName[attrName] = make(map[uint32]string)
+		if _, ok := d.vsaConstID[vendorID][attrName]; !ok {
+			d.vsaConstID[vendorID][attrName] = make(map[string]uint32)
+			d.vsaConstName[vendorID][attrName] = make(map[uint32]string)
 		}
+		return d.vsaConstID[vendorID][attrName], d.vsaConstName[vendorID][attrName]
+	}
 
-		d.constID[attrName][constName] = uint32(cID)
-		d.constName[attrName][uint32(cID)] = constName
-		//log.Printf("Attr %s has value %s = %s\n", attrName, constName, constValue)
+	if _, exist := d.constID[attrName]; !exist {
+		d.constID[attrName] = make(map[string]uint32)
+		d.constName[attrName] = make(map[uint32]string)
 	}
+	return d.constID[attrName], d.constName[attrName]
+}
 
-	return nil
+// shareValueMaps makes attrName use the VALUE maps of source, so values
+// defined later for either name apply to both.
+func (d *Dictionary) shareValueMaps(vendorID VendorID, attrName string, source string) {
+	ids, names := d.valueMaps(vendorID, source)
+	if vendorID > 0 {
+		d.vsaConstID[vendorID][attrName] = ids
+		d.vsaConstName[vendorID][attrName] = names
+	} else {
+		d.constID[attrName] = ids
+		d.constName[attrName] = names
+	}
 }
 
 var blacklistDictionary = map[string]int{
@@ -330,32 +679,72 @@ var blacklistDictionary = map[string]int{
 	"dictionary.compat":              1,
 	"dictionary.usr.illegal":         1,
 	"dictionary.vqp":                 1,
-	// attribute id > 1byte
-	"dictionary.lucent":  1,
-	"dictionary.starent": 1,
-	"dictionary.usr":     1,
 }
 
-func (d *Dictionary) parseInclude(fname string, incName string) error {
+func (d *Dictionary) parseInclude(fname string, incName string, optional bool) error {
 	// ignore files in unsupported format
 	if _, ok := blacklistDictionary[incName]; ok {
-		log.Printf("Skip internal/unsupported FreeRADIUS dictionary: %s\n", incName)
+		d.warnf("skipping internal/unsupported FreeRADIUS dictionary %s", incName)
 		return nil
 	}
 
-	log.Printf("-- include file %s --\n", incName)
-	// included file locate in the same directory
-	fullName := filepath.Join(filepath.Dir(fname), incName)
+	d.logf("-- include file %s --\n", incName)
 	// clear vendor
 	d.currentVendor = 0
+	d.currentStruct = ""
+	d.skipBlock = false
+	if d.currentFS != nil {
+		// fs.FS paths are always slash separated
+		fullName := path.Join(path.Dir(fname), incName)
+		if _, err := fs.Stat(d.currentFS, fullName); optional && errors.Is(err, fs.ErrNotExist) {
+			d.logf("Optional file %s not found\n", fullName)
+			return nil
+		}
+		return d.loadFSInternal(d.currentFS, d.currentSource, fullName)
+	}
+	// included file locate in the same directory
+	fullName := filepath.Join(filepath.Dir(fname), incName)
+	if _, err := os.Stat(fullName); optional && errors.Is(err, fs.ErrNotExist) {
+		d.logf("Optional file %s not found\n", fullName)
+		return nil
+	}
 	return d.loadFileInternal(fullName)
 }
 
-func (d *Dictionary) parseVendor(vendorName string, vendorID string) error {
+func (d *Dictionary) parseVendor(vendorName string, vendorID string, format string) error {
 	vID, err := strconv.ParseUint(vendorID, 0, 32)
 	if err != nil {
-		log.Printf("Failed to convert vendor id: %s\n", err)
-		return err
+		return d.errorf("invalid vendor id %s for %s", vendorID, vendorName)
+	}
+
+	// VENDOR USR 429 format=4,0
+	f := DefaultVendorFormat
+	if format != "" {
+		f, err = ParseVendorFormat(format)
+		if err != nil {
+			return d.errorf("vendor %s: %s", vendorName, err)
+		}
+	}
+
+	if oldID, ok := d.vendorID[vendorName]; ok && oldID != VendorID(vID) {
+		if err := d.conflictf("vendor %s redefined with id %d (was %d)", vendorName, vID, oldID); err != nil {
+			return err
+		}
+	} else if ok && d.getVendorFormat(oldID) != f {
+		if err := d.conflictf("vendor %s redefined with format %s (was %s)", vendorName, f, d.getVendorFormat(oldID)); err != nil {
+			return err
+		}
+	} else if ok {
+		d.notef("duplicate vendor %s", vendorName)
+	} else if oldName, ok := d.vendorName[VendorID(vID)]; ok {
+		// aliases exist in FreeRADIUS dictionaries (Altiga / Cisco-VPN3000)
+		d.notef("vendor id %d redefined as %s (was %s)", vID, vendorName, oldName)
+	}
+
+	if f != DefaultVendorFormat {
+		d.vendorFormat[VendorID(vID)] = f
+	} else {
+		delete(d.vendorFormat, VendorID(vID))
 	}
 
 	d.vendorID[vendorName] = VendorID(vID)
@@ -367,22 +756,30 @@ func (d *Dictionary) parseVendor(vendorName string, vendorID string) error {
 func (d *Dictionary) parseBeginVendor(vendorName string) error {
 	vID, ok := d.vendorID[vendorName]
 	if !ok {
-		log.Printf("vendor %s not found", vendorName)
-		return errors.New("unknown vendor " + vendorName)
+		if err := d.errorf("unknown vendor %s", vendorName); err != nil {
+			return err
+		}
+		// lenient: don't mix the vendor attributes with standard ones
+		d.skipBlock = true
+		return nil
 	}
 	d.currentVendor = vID
 	return nil
 }
 
 func (d *Dictionary) parseEndVendor(vendorName string) error {
+	if d.skipBlock {
+		d.skipBlock = false
+		return nil
+	}
+
 	vID, ok := d.vendorID[vendorName]
 	if !ok {
-		log.Printf("vendor %s not found", vendorName)
-		return errors.New("unknown vendor " + vendorName)
+		return d.errorf("unknown vendor %s", vendorName)
 	}
 
 	if d.currentVendor == 0 || d.currentVendor != vID {
-		return errors.New("unexpected END-VENDOR found")
+		return d.errorf("unexpected END-VENDOR %s", vendorName)
 	}
 
 	d.currentVendor = 0
@@ -391,25 +788,38 @@ func (d *Dictionary) parseEndVendor(vendorName string) error {
 }
 
 func (d *Dictionary) parseBeginTLV(attrName string) error {
+	if d.skipBlock {
+		return nil
+	}
+
 	aID, ok := d.vsaAttrID[d.currentVendor][attrName]
 	if !ok {
-		log.Printf("TLV attribute %s not found\n", attrName)
-		return errors.New("Unknown TLV attribute " + attrName)
+		if err := d.errorf("unknown TLV attribute %s", attrName); err != nil {
+			return err
+		}
+		d.skipBlock = true
+		return nil
 	}
 	d.currentTLV = aID
 	return nil
 }
 
 func (d *Dictionary) parseEndTLV(attrName string) error {
+	if d.skipBlock {
+		if d.currentVendor > 0 {
+			// skipped TLV inside a known vendor block
+			d.skipBlock = false
+		}
+		return nil
+	}
+
 	aID, ok := d.vsaAttrID[d.currentVendor][attrName]
 	if !ok {
-		log.Printf("TLV attribute %s not found\n", attrName)
-		return errors.New("Unknown TLV attribute " + attrName)
+		return d.errorf("unknown TLV attribute %s", attrName)
 	}
 
 	if d.currentTLV == 0 || d.currentTLV != aID {
-		log.Printf("Current TLV %d expected %d", d.currentTLV, aID)
-		return errors.New("unexpected END-TLV")
+		return d.errorf("unexpected END-TLV %s", attrName)
 	}
 
 	d.currentTLV = 0
@@ -426,91 +836,165 @@ var attrTypeHandlers = map[string]avpDataType{
 	"password":   avpPassword,
 	"vsa":        avpVendor,
 	"eapmessage": avpEapMessage,
-	// "byte"
-	// "short"
-	// "date"
-	// "tlv"
-	// "combo-ip"
-	// "ether"
-	// "abinary"
-	// "ifid"
-	// "signed"
-	// "ipv6prefix"
+	"byte":       avpUint8,
+	"short":      avpUint16,
+	"integer64":  avpUint64,
+	"signed":     avpInt32,
+	"date":       avpDate,
+	"ipv4prefix": avpIPv4Prefix,
+	"ipv6prefix": avpIPv6Prefix,
+	"ifid":       avpIfID,
+	"ether":      avpEther,
+	"combo-ip":   avpIP,
+	"abinary":    avpABinary,
+	// RFC 6929 extended attribute containers, Extended-Vendor-Specific,
+	// TLVs and FreeRADIUS v4 structural types are kept as raw octets
+	"extended":      avpBinary,
+	"long-extended": avpBinary,
+	"evs":           avpBinary,
+	"tlv":           avpBinary,
+	"struct":        avpBinary,
+	"group":         avpBinary,
+	"vendor":        avpBinary,
 }
 
 // DecodeAVPValue returns a human-readable string for the given AVP.
 //
-// When possible, DecodeAVPValue uses dictionary type information and enum
-// mappings (including VSA enums) to format values.
+// When possible, DecodeAVPValue uses dictionary type information, attribute
+// flags and enum mappings (including VSA enums) to format values.
 func (d *Dictionary) DecodeAVPValue(p *Packet, a AVP) string {
+	return decodeAVPValue(d, p, a)
+}
+
+// dictionaryLookup is the read side shared by Dictionary and
+// DictionarySnapshot.
+type dictionaryLookup interface {
+	// attrHandler and vsaAttrHandler return the definition of an attribute
+	// with its data type handler, nil when unknown.
+	attrHandler(attrID AttributeType) (AttributeDef, avpDataType)
+	vsaAttrHandler(vendorID VendorID, attrID VendorAttr) (AttributeDef, avpDataType)
+	extAttrHandler(attrType AttributeType, extType uint8) (AttributeDef, avpDataType)
+	GetVendorName(vendorID VendorID) string
+	GetVendorFormat(vendorID VendorID) VendorFormat
+	lookupConstName(vendorID VendorID, attrName string, v uint32) (string, bool)
+}
+
+func decodeAVPValue(l dictionaryLookup, p *Packet, a AVP) string {
 	if a.Type == AttrUserPassword {
 		return avpPassword.String(p, a)
 	} else if a.Type == AttrVendorSpecific {
-		vsa := ToVSA(a)
-
-		vendorName := d.GetVendorName(vsa.Vendor)
-		attrName := d.GetVSAAttributeName(vsa.Vendor, vsa.Type)
-		attrType := d.GetVSAAttributeType(vsa.Vendor, attrName)
-		handler := attrTypeHandlers[attrType]
-		if handler == nil {
-			handler = avpBinary
+		var vsa *VSA
+		if len(a.Value) < 4 {
+			vsa = new(VSA)
+		} else {
+			vsa = ToVSAWithFormat(a, l.GetVendorFormat(VendorID(binary.BigEndian.Uint32(a.Value[0:4]))))
 		}
 
-		valStr := handler.String(p, AVP{Value: vsa.Value})
-		// Try to lookup enum name for VSAs too
-		if attrType == "integer" {
-			vID := binary.BigEndian.Uint32(vsa.Value)
-			d.RLock()
-			if d.vsaConstName[vsa.Vendor] != nil && d.vsaConstName[vsa.Vendor][attrName] != nil {
-				if enumName, ok := d.vsaConstName[vsa.Vendor][attrName][vID]; ok {
-					valStr = enumName
-				}
-			}
-			d.RUnlock()
-		}
+		vendorName := l.GetVendorName(vsa.Vendor)
+		def, handler := l.vsaAttrHandler(vsa.Vendor, vsa.Type)
+		valStr := formatHandlerValue(l, p, def, handler, AVP{Value: vsa.Value})
 
 		return fmt.Sprintf("{Vendor:%s #%d, Attr: %s #%d, Value: %s}",
-			vendorName, vsa.Vendor, attrName, vsa.Type, valStr)
+			vendorName, vsa.Vendor, def.Name, vsa.Type, valStr)
 
+	} else if e := ToExtendedAttr(a); e != nil {
+		// fragments of long extended attributes are formatted one by one
+		def, handler := l.extAttrHandler(e.Type, e.ExtType)
+		valStr := formatHandlerValue(l, p, def, handler, AVP{Type: a.Type, Value: e.Value})
+
+		return fmt.Sprintf("{Attr: %s #%d.%d, Value: %s}", def.Name, e.Type, e.ExtType, valStr)
 	}
 
-	attrName := d.GetAttributeName(a.Type)
-	attrType := d.GetAttributeType(attrName)
-	handler := attrTypeHandlers[attrType]
+	def, handler := l.attrHandler(a.Type)
+	return formatHandlerValue(l, p, def, handler, a)
+}
+
+// formatValue formats a using the attribute definition, preferring enum names
+// for integer attributes.
+func formatValue(l dictionaryLookup, p *Packet, def AttributeDef, a AVP) string {
+	return formatHandlerValue(l, p, def, def.handler(), a)
+}
+
+// formatHandlerValue is formatValue with the data type handler of def, as
+// compiled by snapshots.
+func formatHandlerValue(l dictionaryLookup, p *Packet, def AttributeDef, handler avpDataType, a AVP) string {
 	if handler == nil {
 		handler = avpBinary
 	}
 
-	// Try to lookup enum name for standard attributes
-	if attrType == "integer" {
-		vID := binary.BigEndian.Uint32(a.Value)
-		d.RLock()
-		if d.constName[attrName] != nil {
-			if enumName, ok := d.constName[attrName][vID]; ok {
-				d.RUnlock()
+	// Try to lookup enum name
+	if isEnumType(def.Type) && !def.Flags.Array {
+		value := a.Value
+		if def.Flags.HasTag && def.Type == "integer" {
+			_, value = AvpTagged{integer: true}.Split(value)
+		}
+		if v, ok := enumValue(value); ok {
+			if enumName, ok := l.lookupConstName(def.Vendor, def.Name, v); ok {
 				return enumName
 			}
 		}
-		d.RUnlock()
 	}
 
 	return handler.String(p, a)
 }
 
+// enumValue decodes a byte, short or integer value as an enum key.
+func enumValue(b []byte) (uint32, bool) {
+	switch len(b) {
+	case 1:
+		return uint32(b[0]), true
+	case uint16Size:
+		return uint32(binary.BigEndian.Uint16(b)), true
+	case 4:
+		return binary.BigEndian.Uint32(b), true
+	}
+	return 0, false
+}
+
+func (d *Dictionary) attrHandler(attrID AttributeType) (AttributeDef, avpDataType) {
+	def, _ := d.GetAttributeDefByID(attrID)
+	return def, def.handler()
+}
+
+func (d *Dictionary) vsaAttrHandler(vendorID VendorID, attrID VendorAttr) (AttributeDef, avpDataType) {
+	def, _ := d.GetVSAAttributeDefByID(vendorID, attrID)
+	return def, def.handler()
+}
+
+func (d *Dictionary) extAttrHandler(attrType AttributeType, extType uint8) (AttributeDef, avpDataType) {
+	def, _ := d.GetExtendedAttributeDefByID(attrType, extType)
+	return def, def.handler()
+}
+
+func (d *Dictionary) lookupConstName(vendorID VendorID, attrName string, v uint32) (string, bool) {
+	d.RLock()
+	defer d.RUnlock()
+	var enumName string
+	var ok bool
+	if vendorID > 0 {
+		enumName, ok = d.vsaConstName[vendorID][attrName][v]
+	} else {
+		enumName, ok = d.constName[attrName][v]
+	}
+	return enumName, ok
+}
+
 // public
 
-// GetAttributeID returns the AttributeType for an attribute name.
+// GetAttributeID returns the AttributeType for an attribute name, 0 for
+// extended attributes.
 func (d *Dictionary) GetAttributeID(attrName string) AttributeType {
 	d.RLock()
 	defer d.RUnlock()
 	return d.attrID[attrName]
 }
 
-// HasAttribute reports whether the dictionary defines the given attribute name.
+// HasAttribute reports whether the dictionary defines the given attribute
+// name, standard or extended.
 func (d *Dictionary) HasAttribute(attrName string) bool {
 	d.RLock()
 	defer d.RUnlock()
-	_, present := d.attrID[attrName]
+	_, present := d.getAttributeDef(attrName)
 	return present
 }
 
@@ -566,6 +1050,21 @@ func (d *Dictionary) GetVendorName(vendorID VendorID) string {
 	return d.vendorName[vendorID]
 }
 
+// GetVendorFormat returns the VSA layout declared for a vendor, or
+// DefaultVendorFormat when the vendor has no format= option.
+func (d *Dictionary) GetVendorFormat(vendorID VendorID) VendorFormat {
+	d.RLock()
+	defer d.RUnlock()
+	return d.getVendorFormat(vendorID)
+}
+
+func (d *Dictionary) getVendorFormat(vendorID VendorID) VendorFormat {
+	if f, ok := d.vendorFormat[vendorID]; ok {
+		return f
+	}
+	return DefaultVendorFormat
+}
+
 // GetVendorID returns the VendorID for a vendor name.
 func (d *Dictionary) GetVendorID(vendorName string) VendorID {
 	d.RLock()
@@ -574,32 +1073,28 @@ func (d *Dictionary) GetVendorID(vendorName string) VendorID {
 }
 
 // NewAVP constructs an AVP from the attribute name and a string value using
-// the attribute type defined in the dictionary.
+// the attribute type defined in the dictionary. Invalid values are logged and
+// give an empty AVP, see ParseAVP.
+//
+// Encrypted attributes cannot be encoded without a packet and give an empty
+// AVP; use AddAttribute or a template to add them.
 func (d *Dictionary) NewAVP(attrName string, attrValue string) AVP {
-	attrID := d.GetAttributeID(attrName)
-	attrType := d.GetAttributeType(attrName)
-	handler := attrTypeHandlers[attrType]
-	if handler == nil {
-		log.Printf("Unknown type %s\n", attrType)
+	avp, err := d.ParseAVP(attrName, attrValue)
+	if err != nil {
+		log.Printf("%s\n", err)
 		return AVP{}
 	}
-
-	value := handler.FromString(attrValue)
-
-	avp := AVP{Type: attrID, Value: value}
 	return avp
 }
 
 // NewVSA constructs a Vendor-Specific Attribute from the vendor name, attribute
 // name, and a string value using the VSA type defined in the dictionary.
+// Invalid values are logged and give an empty VSA, see ParseVSA.
 func (d *Dictionary) NewVSA(vendorName string, attrName string, attrValue string) VSA {
-	vendorID := d.GetVendorID(vendorName)
-	attrID := d.GetVSAAttributeID(vendorID, attrName)
-	attrType := d.GetVSAAttributeType(vendorID, attrName)
-	handler := attrTypeHandlers[attrType]
-
-	value := handler.FromString(attrValue)
-
-	vsa := VSA{Vendor: vendorID, Type: attrID, Value: value}
+	vsa, err := d.ParseVSA(vendorName, attrName, attrValue)
+	if err != nil {
+		log.Printf("%s\n", err)
+		return VSA{}
+	}
 	return vsa
 }
@@ -0,0 +1,342 @@
+package radius
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// AttributeFlags holds the optional flags of an ATTRIBUTE definition, for
+// example "has_tag,encrypt=2".
+type AttributeFlags struct {
+	// Encrypt is the obfuscation method (EncryptMethod*), 0 for none.
+	Encrypt int
+	// HasTag marks RFC 2868 tagged attributes.
+	HasTag bool
+	// Concat marks values that are split over several consecutive attributes
+	// when longer than 253 bytes (like EAP-Message).
+	Concat bool
+	// Array marks attributes carrying several values of the same type.
+	Array bool
+	// Enum names the ENUM (FreeRADIUS v4) providing the attribute values.
+	Enum string
+	// Virtual marks server side attributes never sent in packets (the
+	// FreeRADIUS "internal" and "virtual" flags). They are not loaded.
+	Virtual bool
+}
+
+// encryptMethodNames maps the FreeRADIUS v4 encrypt= names to methods.
+var encryptMethodNames = map[string]int{
+	"User-Password":      EncryptMethodUserPassword,
+	"Tunnel-Password":    EncryptMethodTunnelPassword,
+	"Ascend-Send-Secret": EncryptMethodAscendSecret,
+}
+
+// FreeRADIUS v4 flags which don't change the wire encoding and are ignored.
+var ignoredAttributeFlags = map[string]bool{
+	"secret":  true,
+	"key":     true,
+	"counter": true,
+	"unsafe":  true,
+	"clone":   true,
+	"ref":     true,
+	"length":  true,
+	"offset":  true,
+	// "precision" applies to time_delta and date, "subtype" to date
+	"precision": true,
+	"subtype":   true,
+}
+
+// String returns the flags in dictionary notation, or "" when none are set.
+func (f AttributeFlags) String() string {
+	var flags []string
+	if f.HasTag {
+		flags = append(flags, "has_tag")
+	}
+	if f.Encrypt != EncryptMethodNone {
+		flags = append(flags, "encrypt="+strconv.Itoa(f.Encrypt))
+	}
+	if f.Concat {
+		flags = append(flags, "concat")
+	}
+	if f.Array {
+		flags = append(flags, "array")
+	}
+	if f.Enum != "" {
+		flags = append(flags, "enum="+f.Enum)
+	}
+	if f.Virtual {
+		flags = append(flags, "virtual")
+	}
+	return strings.Join(flags, ",")
+}
+
+// ParseAttributeFlags parses the comma separated flags column of an ATTRIBUTE line.
+func ParseAttributeFlags(s string) (AttributeFlags, error) {
+	var f AttributeFlags
+	for _, flag := range strings.Split(s, ",") {
+		switch {
+		case flag == "":
+		case flag == "has_tag":
+			f.HasTag = true
+		case flag == "concat":
+			f.Concat = true
+		case flag == "array":
+			f.Array = true
+		case flag == "internal" || flag == "virtual":
+			f.Virtual = true
+		case strings.HasPrefix(flag, "encrypt="):
+			method := strings.TrimPrefix(flag, "encrypt=")
+			n, ok := encryptMethodNames[method]
+			if !ok {
+				var err error
+				n, err = strconv.Atoi(method)
+				if err != nil || n < EncryptMethodNone || n > EncryptMethodAscendSecret {
+					return f, errors.New("invalid attribute flag " + flag)
+				}
+			}
+			f.Encrypt = n
+		case strings.HasPrefix(flag, "enum="):
+			f.Enum = strings.TrimPrefix(flag, "enum=")
+			if f.Enum == "" {
+				return f, errors.New("invalid attribute flag " + flag)
+			}
+		default:
+			name, _, _ := strings.Cut(flag, "=")
+			if !ignoredAttributeFlags[name] {
+				return f, errors.New("unknown attribute flag " + flag)
+			}
+		}
+	}
+	return f, nil
+}
+
+// AttributeDef describes an attribute as defined by the dictionary.
+type AttributeDef struct {
+	Name string
+	// Vendor is 0 for standard attributes.
+	Vendor VendorID
+	// ID is the AttributeType for standard attributes, the VendorAttr for
+	// VSAs or the Extended-Type for extended attributes.
+	ID    uint32
+	Type  string
+	Flags AttributeFlags
+	// Extended is the Extended-Attribute (241 to 246) carrying RFC 6929
+	// extended attributes, 0 for other attributes.
+	Extended AttributeType
+}
+
+// IsVSA reports whether the attribute is vendor-specific.
+func (a AttributeDef) IsVSA() bool {
+	return a.Vendor != 0
+}
+
+// IsExtended reports whether the attribute is an RFC 6929 extended attribute.
+func (a AttributeDef) IsExtended() bool {
+	return a.Extended != 0
+}
+
+// idString returns the attribute number in dictionary notation, "241.8" for
+// extended attributes.
+func (a AttributeDef) idString() string {
+	id := strconv.FormatUint(uint64(a.ID), 10)
+	if a.Extended != 0 {
+		return strconv.Itoa(int(a.Extended)) + "." + id
+	}
+	return id
+}
+
+// handler returns the data type handler for the attribute, honouring its
+// flags, or nil when the type is unknown.
+func (a AttributeDef) handler() avpDataType {
+	return attrHandler(a.Type, a.Flags)
+}
+
+// attrHandler builds the data type handler for a type name and flags.
+func attrHandler(typeName string, flags AttributeFlags) avpDataType {
+	handler := attrTypeHandlers[typeName]
+	if handler == nil {
+		return nil
+	}
+	if flags.Array {
+		handler = AvpArray{elem: handler, size: arrayElementSize[typeName]}
+	}
+	if flags.Encrypt != EncryptMethodNone {
+		handler = AvpEncrypted{method: flags.Encrypt, inner: handler}
+	}
+	if flags.HasTag {
+		handler = AvpTagged{
+			inner:     handler,
+			integer:   typeName == "integer",
+			encrypted: flags.Encrypt == EncryptMethodTunnelPassword,
+		}
+	}
+	return handler
+}
+
+// encodeValue encodes a string value for the packet p, applying encryption,
+// tag and array handling according to the attribute definition. Values of
+// enumerated attributes may be given by name, values maps the VALUE names
+// of the attribute. Encrypted attributes need the packet, see EncodeBytes.
+func (a AttributeDef) encodeValue(p *Packet, tag uint8, value string, values map[string]uint32) ([]byte, error) {
+	handler := attrTypeHandlers[a.Type]
+	if handler == nil {
+		return nil, errors.New("no handler found for type " + a.Type)
+	}
+	if a.Flags.Array {
+		handler = AvpArray{elem: handler, size: arrayElementSize[a.Type]}
+	} else if v, ok := values[value]; ok && isEnumType(a.Type) {
+		value = strconv.FormatUint(uint64(v), 10)
+	}
+
+	b := handler.FromString(value)
+	if b == nil {
+		return nil, errors.New("invalid value for attribute " + a.Name + ": " + value)
+	}
+	return a.EncodeBytes(p, tag, b)
+}
+
+// EncodeBytes completes the encoding of b, a value already encoded for the
+// data type: it encrypts b for the packet p and adds tag, according to the
+// attribute flags. Encrypted attributes need the packet: without one
+// EncodeBytes fails with ErrEncryptWithoutPacket.
+func (a AttributeDef) EncodeBytes(p *Packet, tag uint8, b []byte) ([]byte, error) {
+	if a.Flags.Encrypt != EncryptMethodNone {
+		var err error
+		if b, err = (AvpEncrypted{method: a.Flags.Encrypt}).Encrypt(p, b); err != nil {
+			return nil, fmt.Errorf("attribute %s: %w", a.Name, err)
+		}
+	}
+	if a.Flags.HasTag {
+		b = AvpTagged{
+			integer:   a.Type == "integer",
+			encrypted: a.Flags.Encrypt == EncryptMethodTunnelPassword,
+		}.Join(tag, b)
+	}
+	return b, nil
+}
+
+// DecodeBytes reverses EncodeBytes for a value received in the packet p: it
+// splits the tag and decrypts with requestAuth, returning the plain value.
+// requestAuth is the Request Authenticator: p.Authenticator for a request, the
+// Authenticator of the request sent for a received reply.
+func (a AttributeDef) DecodeBytes(p *Packet, requestAuth []byte, b []byte) (uint8, []byte, error) {
+	var tag uint8
+	if a.Flags.HasTag {
+		tag, b = AvpTagged{
+			integer:   a.Type == "integer",
+			encrypted: a.Flags.Encrypt == EncryptMethodTunnelPassword,
+		}.Split(b)
+	}
+	method := a.Flags.Encrypt
+	if a.Type == "password" {
+		method = EncryptMethodUserPassword
+	}
+	if method != EncryptMethodNone {
+		plain, err := AvpEncrypted{method: method}.Decrypt(p, requestAuth, b)
+		if err != nil {
+			return tag, nil, err
+		}
+		b = plain
+	}
+	return tag, b, nil
+}
+
+// GetAttributeDef returns the definition of a standard or extended attribute
+// by name.
+func (d *Dictionary) GetAttributeDef(attrName string) (AttributeDef, bool) {
+	d.RLock()
+	defer d.RUnlock()
+	return d.getAttributeDef(attrName)
+}
+
+// GetAttributeDefByID returns the definition of a standard attribute by type.
+func (d *Dictionary) GetAttributeDefByID(attrID AttributeType) (AttributeDef, bool) {
+	d.RLock()
+	defer d.RUnlock()
+	name, ok := d.attrName[attrID]
+	if !ok {
+		return AttributeDef{}, false
+	}
+	return d.getAttributeDef(name)
+}
+
+func (d *Dictionary) getAttributeDef(attrName string) (AttributeDef, bool) {
+	if id, ok := d.attrID[attrName]; ok {
+		return AttributeDef{
+			Name:  attrName,
+			ID:    uint32(id),
+			Type:  d.attrType[attrName],
+			Flags: d.attrFlags[attrName],
+		}, true
+	}
+	if key, ok := d.extAttrID[attrName]; ok {
+		return AttributeDef{
+			Name:     attrName,
+			ID:       uint32(key.ext),
+			Type:     d.attrType[attrName],
+			Flags:    d.attrFlags[attrName],
+			Extended: key.attr,
+		}, true
+	}
+	return AttributeDef{}, false
+}
+
+// GetExtendedAttributeDefByID returns the definition of an extended attribute
+// by Extended-Attribute and Extended-Type.
+func (d *Dictionary) GetExtendedAttributeDefByID(attrType AttributeType, extType uint8) (AttributeDef, bool) {
+	d.RLock()
+	defer d.RUnlock()
+	name, ok := d.extAttrName[extendedKey{attrType, extType}]
+	if !ok {
+		return AttributeDef{}, false
+	}
+	return d.getAttributeDef(name)
+}
+
+// GetVSAAttributeDef returns the definition of a vendor-specific attribute by name.
+func (d *Dictionary) GetVSAAttributeDef(vendorID VendorID, attrName string) (AttributeDef, bool) {
+	d.RLock()
+	defer d.RUnlock()
+	return d.getVSAAttributeDef(vendorID, attrName)
+}
+
+// GetVSAAttributeDefByID returns the definition of a vendor-specific attribute by type.
+func (d *Dictionary) GetVSAAttributeDefByID(vendorID VendorID, attrID VendorAttr) (AttributeDef, bool) {
+	d.RLock()
+	defer d.RUnlock()
+	name, ok := d.vsaAttrName[vendorID][attrID]
+	if !ok {
+		return AttributeDef{}, false
+	}
+	return d.getVSAAttributeDef(vendorID, name)
+}
+
+func (d *Dictionary) getVSAAttributeDef(vendorID VendorID, attrName string) (AttributeDef, bool) {
+	id, ok := d.vsaAttrID[vendorID][attrName]
+	if !ok {
+		return AttributeDef{}, false
+	}
+	return AttributeDef{
+		Name:   attrName,
+		Vendor: vendorID,
+		ID:     uint32(id),
+		Type:   d.vsaAttrType[vendorID][attrName],
+		Flags:  d.vsaAttrFlags[vendorID][attrName],
+	}, true
+}
+
+// GetAttributeFlags returns the flags of a standard attribute.
+func (d *Dictionary) GetAttributeFlags(attrName string) AttributeFlags {
+	d.RLock()
+	defer d.RUnlock()
+	return d.attrFlags[attrName]
+}
+
+// GetVSAAttributeFlags returns the flags of a vendor-specific attribute.
+func (d *Dictionary) GetVSAAttributeFlags(vendorID VendorID, attrName string) AttributeFlags {
+	d.RLock()
+	defer d.RUnlock()
+	return d.vsaAttrFlags[vendorID][attrName]
+}
@@ -0,0 +1,203 @@
+package radius
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseAttributeFlags(t *testing.T) {
+	testCases := []struct {
+		in      string
+		want    AttributeFlags
+		wantErr bool
+	}{
+		{"", AttributeFlags{}, false},
+		{"has_tag", AttributeFlags{HasTag: true}, false},
+		{"has_tag,encrypt=2", AttributeFlags{HasTag: true, Encrypt: 2}, false},
+		{"encrypt=1", AttributeFlags{Encrypt: 1}, false},
+		{"concat", AttributeFlags{Concat: true}, false},
+		{"array", AttributeFlags{Array: true}, false},
+		{"enum=Service-Kind", AttributeFlags{Enum: "Service-Kind"}, false},
+		{"virtual", AttributeFlags{Virtual: true}, false},
+		{"enum=", AttributeFlags{}, true},
+		{"encrypt=9", AttributeFlags{}, true},
+		{"bogus", AttributeFlags{}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.in, func(t *testing.T) {
+			f, err := ParseAttributeFlags(tc.in)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("ParseAttributeFlags(%s) expected error", tc.in)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseAttributeFlags(%s) error: %v", tc.in, err)
+			}
+			if f != tc.want {
+				t.Errorf("ParseAttributeFlags(%s) = %+v; want %+v", tc.in, f, tc.want)
+			}
+			if f.String() != tc.in {
+				t.Errorf("String() = %s; want %s", f.String(), tc.in)
+			}
+		})
+	}
+}
+
+func loadFlagsDictionary(t *testing.T) *Dictionary {
+	tmpDir := t.TempDir()
+	content := `
+ATTRIBUTE	User-Name		1	string
+ATTRIBUTE	Tunnel-Type		64	integer	has_tag
+VALUE		Tunnel-Type		VLAN	13
+ATTRIBUTE	Tunnel-Password		69	string	has_tag,encrypt=2
+ATTRIBUTE	EAP-Message		79	octets	concat
+ATTRIBUTE	Test-DNS-Servers	200	ipaddr	array
+VENDOR		Test		9999
+BEGIN-VENDOR	Test
+ATTRIBUTE	Test-Secret		1	string	encrypt=1
+ATTRIBUTE	Test-Plain		2	string	# comment
+END-VENDOR	Test
+`
+	path := filepath.Join(tmpDir, "dictionary")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write dictionary: %v", err)
+	}
+	d := NewDictionary()
+	if err := d.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	return d
+}
+
+func TestAttributeDef(t *testing.T) {
+	d := loadFlagsDictionary(t)
+
+	def, ok := d.GetAttributeDef("Tunnel-Password")
+	if !ok {
+		t.Fatal("Tunnel-Password not found")
+	}
+	want := AttributeDef{Name: "Tunnel-Password", ID: 69, Type: "string",
+		Flags: AttributeFlags{HasTag: true, Encrypt: EncryptMethodTunnelPassword}}
+	if def != want {
+		t.Errorf("GetAttributeDef = %+v; want %+v", def, want)
+	}
+	if byID, _ := d.GetAttributeDefByID(69); byID != def {
+		t.Errorf("GetAttributeDefByID = %+v; want %+v", byID, def)
+	}
+
+	vdef, ok := d.GetVSAAttributeDefByID(9999, 1)
+	if !ok || !vdef.IsVSA() || vdef.Flags.Encrypt != EncryptMethodUserPassword {
+		t.Errorf("GetVSAAttributeDefByID = %+v", vdef)
+	}
+	if f := d.GetVSAAttributeFlags(9999, "Test-Plain"); f != (AttributeFlags{}) {
+		t.Errorf("Test-Plain flags = %+v", f)
+	}
+	if !d.GetAttributeFlags("Test-DNS-Servers").Array {
+		t.Error("Test-DNS-Servers not flagged array")
+	}
+	if _, ok := d.GetAttributeDef("Missing"); ok {
+		t.Error("GetAttributeDef returned unknown attribute")
+	}
+}
+
+func TestAttributeFlagsEncoding(t *testing.T) {
+	d := loadFlagsDictionary(t)
+	p := Request(AccessRequest, "secret")
+
+	t.Run("EncryptedVSA", func(t *testing.T) {
+		tmpl, err := d.GetVSATemplate("Test", "Test-Secret")
+		if err != nil {
+			t.Fatalf("GetVSATemplate failed: %v", err)
+		}
+		tmpl.Add(p, "s3cr3t")
+		avp := p.GetAVP(AttrVendorSpecific)
+		vsa := ToVSAWithFormat(*avp, DefaultVendorFormat)
+		if len(vsa.Value) != 16 || bytes.Contains(vsa.Value, []byte("s3cr3t")) {
+			t.Errorf("VSA not obfuscated: %v", vsa.Value)
+		}
+		want := "{Vendor:Test #9999, Attr: Test-Secret #1, Value: s3cr3t}"
+		if s := d.DecodeAVPValue(p, *avp); s != want {
+			t.Errorf("DecodeAVPValue = %s; want %s", s, want)
+		}
+	})
+
+	t.Run("Tagged", func(t *testing.T) {
+		tmpl, err := d.GetTemplate("Tunnel-Type:1")
+		if err != nil {
+			t.Fatalf("GetTemplate failed: %v", err)
+		}
+		tmpl.Add(p, "13")
+		avp := p.GetAVP(AttrTunnelType)
+		if !bytes.Equal(avp.Value, []byte{1, 0, 0, 13}) {
+			t.Errorf("Tunnel-Type = %v", avp.Value)
+		}
+		if s := d.DecodeAVPValue(p, *avp); s != "VLAN" {
+			t.Errorf("DecodeAVPValue = %s; want VLAN", s)
+		}
+
+		if _, err := d.GetTemplate("User-Name:1"); err == nil {
+			t.Error("expected error for tag on untagged attribute")
+		}
+		if _, err := d.GetTemplate("Tunnel-Type:99"); err == nil {
+			t.Error("expected error for out of range tag")
+		}
+	})
+
+	t.Run("TunnelPassword", func(t *testing.T) {
+		tmpl, err := d.GetTemplate("Tunnel-Password:2")
+		if err != nil {
+			t.Fatalf("GetTemplate failed: %v", err)
+		}
+		tmpl.Add(p, "tunnel-secret")
+		avp := p.GetAVP(AttrTunnelPassword)
+		if avp.Value[0] != 2 {
+			t.Errorf("tag = %d; want 2", avp.Value[0])
+		}
+		if s := d.DecodeAVPValue(p, *avp); s != "tunnel-secret" {
+			t.Errorf("DecodeAVPValue = %s; want tunnel-secret", s)
+		}
+	})
+
+	t.Run("Concat", func(t *testing.T) {
+		tmpl, err := d.GetTemplate("EAP-Message")
+		if err != nil {
+			t.Fatalf("GetTemplate failed: %v", err)
+		}
+		value := string(bytes.Repeat([]byte("x"), 600))
+		tmpl.Add(p, value)
+		n := 0
+		p.EachAVP(func(a AVP) bool {
+			if a.Type == AttrEAPMessage {
+				n++
+			}
+			return true
+		})
+		if n != 3 {
+			t.Errorf("EAP-Message split into %d attributes; want 3", n)
+		}
+		if got := p.GetConcatenatedAVP(AttrEAPMessage); string(got) != value {
+			t.Errorf("GetConcatenatedAVP returned %d bytes; want %d", len(got), len(value))
+		}
+	})
+
+	t.Run("Array", func(t *testing.T) {
+		old := GetDefaultDictionary()
+		SetDefaultDictionary(d)
+		defer SetDefaultDictionary(old)
+
+		avp := d.NewAVP("Test-DNS-Servers", "192.0.2.53, 198.51.100.53")
+		v, ok := avp.Decode(nil).([]interface{})
+		if !ok || len(v) != 2 || !v[1].(net.IP).Equal(net.ParseIP("198.51.100.53")) {
+			t.Errorf("Decode() = %v", avp.Decode(nil))
+		}
+		if s := d.DecodeAVPValue(nil, avp); s != "192.0.2.53, 198.51.100.53" {
+			t.Errorf("DecodeAVPValue = %s", s)
+		}
+	})
+}
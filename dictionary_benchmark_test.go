@@ -40,3 +40,101 @@ func BenchmarkLoadFile(b *testing.B) {
 		}
 	}
 }
+
+func benchmarkRFCDictionary(b *testing.B) *Dictionary {
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	d, err := NewRFCDictionary()
+	if err != nil {
+		b.Fatalf("NewRFCDictionary failed: %v", err)
+	}
+	if err := d.LoadVendorPack("microsoft"); err != nil {
+		b.Fatalf("LoadVendorPack failed: %v", err)
+	}
+	return d
+}
+
+func BenchmarkDictionaryLookup(b *testing.B) {
+	d := benchmarkRFCDictionary(b)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			d.GetAttributeDefByID(AttrServiceType)
+			d.GetVSAAttributeDefByID(311, 26)
+			d.GetAttributeID("Acct-Status-Type")
+		}
+	})
+}
+
+func BenchmarkSnapshotLookup(b *testing.B) {
+	s := benchmarkRFCDictionary(b).Snapshot()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			s.GetAttributeDefByID(AttrServiceType)
+			s.GetVSAAttributeDefByID(311, 26)
+			s.GetAttributeID("Acct-Status-Type")
+		}
+	})
+}
+
+func BenchmarkDecodeAVPValue(b *testing.B) {
+	d := benchmarkRFCDictionary(b)
+	avp := AVP{Type: AttrServiceType, Value: []byte{0, 0, 0, 2}}
+
+	b.Run("Dictionary", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			d.DecodeAVPValue(nil, avp)
+		}
+	})
+	b.Run("Snapshot", func(b *testing.B) {
+		s := d.Snapshot()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			s.DecodeAVPValue(nil, avp)
+		}
+	})
+}
+
+func BenchmarkAVPStringDefaultSnapshot(b *testing.B) {
+	SetDefaultSnapshot(benchmarkRFCDictionary(b).Snapshot())
+	defer SetDefaultSnapshot(nil)
+	avp := AVP{Type: AttrUserName, Value: []byte("alice")}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = avp.String()
+		}
+	})
+}
+
+func BenchmarkSnapshot(b *testing.B) {
+	d := benchmarkRFCDictionary(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.Snapshot()
+	}
+}
+
+func BenchmarkReload(b *testing.B) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	path := generateBenchmarkDictionary(b, b.TempDir(), 1000)
+	r, err := NewReloadableDictionaryFile(path)
+	if err != nil {
+		b.Fatalf("NewReloadableDictionaryFile failed: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.Reload(); err != nil {
+			b.Fatalf("Reload failed: %v", err)
+		}
+	}
+}
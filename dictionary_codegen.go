@@ -0,0 +1,599 @@
+package radius
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// GenerateOptions controls the Go code written by Dictionary.GenerateGo.
+type GenerateOptions struct {
+	// Package is the name of the generated package.
+	Package string
+	// Vendors limits the vendor attributes to the named vendors, all vendors
+	// when empty.
+	Vendors []string
+	// ExcludeStandard leaves out the standard attributes.
+	ExcludeStandard bool
+	// Source describes the dictionary in the header comment.
+	Source string
+}
+
+// radiusImportPath is the import path of this package in generated code.
+const radiusImportPath = "github.com/sergle/radius/v2"
+
+// codegenKind describes how values of a dictionary type are represented in
+// generated code.
+type codegenKind struct {
+	goType string
+	// names of the generated helpers: decode returns (goType, bool), encode
+	// returns ([]byte, error)
+	decode string
+	encode string
+}
+
+var codegenKinds = map[string]codegenKind{
+	"string":    {"string", "decodeString", "encodeString"},
+	"octets":    {"[]byte", "decodeOctets", "encodeOctets"},
+	"integer":   {"uint32", "decodeUint32", "encodeUint32"},
+	"byte":      {"uint8", "decodeUint8", "encodeUint8"},
+	"short":     {"uint16", "decodeUint16", "encodeUint16"},
+	"signed":    {"int32", "decodeInt32", "encodeInt32"},
+	"integer64": {"uint64", "decodeUint64", "encodeUint64"},
+	"date":      {"time.Time", "decodeDate", "encodeDate"},
+	"ipaddr":    {"net.IP", "decodeIPv4", "encodeIPv4"},
+	"ipv6addr":  {"net.IP", "decodeIPv6", "encodeIPv6"},
+	"combo-ip":  {"net.IP", "decodeIP", "encodeIP"},
+}
+
+// codegenHelpers holds the helper functions of generated code and the
+// imports they need.
+var codegenHelpers = map[string]struct {
+	imports []string
+	code    string
+}{
+	"decodeString": {nil, `func decodeString(b []byte) (string, bool) {
+	return string(b), true
+}`},
+	"encodeString": {nil, `func encodeString(v string) ([]byte, error) {
+	return []byte(v), nil
+}`},
+	"decodeOctets": {nil, `func decodeOctets(b []byte) ([]byte, bool) {
+	return append([]byte(nil), b...), true
+}`},
+	"encodeOctets": {nil, `func encodeOctets(v []byte) ([]byte, error) {
+	return v, nil
+}`},
+	"decodeUint32": {[]string{"encoding/binary"}, `func decodeUint32(b []byte) (uint32, bool) {
+	if len(b) != 4 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(b), true
+}`},
+	"encodeUint32": {[]string{"encoding/binary"}, `func encodeUint32(v uint32) ([]byte, error) {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b, nil
+}`},
+	"decodeUint8": {nil, `func decodeUint8(b []byte) (uint8, bool) {
+	if len(b) != 1 {
+		return 0, false
+	}
+	return b[0], true
+}`},
+	"encodeUint8": {nil, `func encodeUint8(v uint8) ([]byte, error) {
+	return []byte{v}, nil
+}`},
+	"decodeUint16": {[]string{"encoding/binary"}, `func decodeUint16(b []byte) (uint16, bool) {
+	if len(b) != 2 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint16(b), true
+}`},
+	"encodeUint16": {[]string{"encoding/binary"}, `func encodeUint16(v uint16) ([]byte, error) {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+	return b, nil
+}`},
+	"decodeInt32": {[]string{"encoding/binary"}, `func decodeInt32(b []byte) (int32, bool) {
+	if len(b) != 4 {
+		return 0, false
+	}
+	return int32(binary.BigEndian.Uint32(b)), true
+}`},
+	"encodeInt32": {[]string{"encoding/binary"}, `func encodeInt32(v int32) ([]byte, error) {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(v))
+	return b, nil
+}`},
+	"decodeUint64": {[]string{"encoding/binary"}, `func decodeUint64(b []byte) (uint64, bool) {
+	if len(b) != 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(b), true
+}`},
+	"encodeUint64": {[]string{"encoding/binary"}, `func encodeUint64(v uint64) ([]byte, error) {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b, nil
+}`},
+	"decodeDate": {[]string{"encoding/binary", "time"}, `func decodeDate(b []byte) (time.Time, bool) {
+	if len(b) != 4 {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(binary.BigEndian.Uint32(b)), 0).UTC(), true
+}`},
+	"encodeDate": {[]string{"encoding/binary", "errors", "time"}, `func encodeDate(v time.Time) ([]byte, error) {
+	sec := v.Unix()
+	if sec < 0 || sec > 0xffffffff {
+		return nil, errors.New("date out of range")
+	}
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(sec))
+	return b, nil
+}`},
+	"decodeIPv4": {[]string{"net"}, `func decodeIPv4(b []byte) (net.IP, bool) {
+	if len(b) != net.IPv4len {
+		return nil, false
+	}
+	return net.IP(append([]byte(nil), b...)), true
+}`},
+	"encodeIPv4": {[]string{"errors", "net"}, `func encodeIPv4(v net.IP) ([]byte, error) {
+	ip := v.To4()
+	if ip == nil {
+		return nil, errors.New("not an IPv4 address")
+	}
+	return ip, nil
+}`},
+	"decodeIPv6": {[]string{"net"}, `func decodeIPv6(b []byte) (net.IP, bool) {
+	if len(b) != net.IPv6len {
+		return nil, false
+	}
+	return net.IP(append([]byte(nil), b...)), true
+}`},
+	"encodeIPv6": {[]string{"errors", "net"}, `func encodeIPv6(v net.IP) ([]byte, error) {
+	if len(v) != net.IPv6len || v.To4() != nil {
+		return nil, errors.New("not an IPv6 address")
+	}
+	return v, nil
+}`},
+	"decodeIP": {[]string{"net"}, `func decodeIP(b []byte) (net.IP, bool) {
+	if len(b) != net.IPv4len && len(b) != net.IPv6len {
+		return nil, false
+	}
+	return net.IP(append([]byte(nil), b...)), true
+}`},
+	"encodeIP": {[]string{"errors", "net"}, `func encodeIP(v net.IP) ([]byte, error) {
+	if ip := v.To4(); ip != nil {
+		return ip, nil
+	}
+	if len(v) != net.IPv6len {
+		return nil, errors.New("not an IP address")
+	}
+	return v, nil
+}`},
+	"getAVP": {nil, `func getAVP(p *radius.Packet, t radius.AttributeType) ([]byte, bool) {
+	avp := p.GetAVP(t)
+	if avp == nil {
+		return nil, false
+	}
+	return avp.Value, true
+}`},
+	"getConcatAVP": {nil, `func getConcatAVP(p *radius.Packet, t radius.AttributeType) ([]byte, bool) {
+	b := p.GetConcatenatedAVP(t)
+	return b, b != nil
+}`},
+	"addAVP": {[]string{"fmt"}, `func addAVP(p *radius.Packet, name string, t radius.AttributeType, b []byte, concat bool, replace bool) error {
+	if len(b) > 253 && !concat {
+		return fmt.Errorf("%s: value of %d bytes too long", name, len(b))
+	}
+	if replace {
+		p.DeleteAllType(t)
+	}
+	for len(b) > 253 {
+		p.AddAVP(radius.AVP{Type: t, Value: b[:253]})
+		b = b[253:]
+	}
+	p.AddAVP(radius.AVP{Type: t, Value: b})
+	return nil
+}`},
+	"getVSA": {nil, `func getVSA(p *radius.Packet, vendor radius.VendorID, t radius.VendorAttr, f radius.VendorFormat) ([]byte, bool) {
+	vsa := p.GetVSAWithFormat(vendor, t, f)
+	if vsa == nil {
+		return nil, false
+	}
+	return vsa.Value, true
+}`},
+	"addVSA": {[]string{"fmt"}, `func addVSA(p *radius.Packet, name string, vendor radius.VendorID, t radius.VendorAttr, f radius.VendorFormat, b []byte, replace bool) error {
+	if max := 255 - 6 - f.TypeSize - f.LengthSize; len(b) > max && !f.Continuation {
+		return fmt.Errorf("%s: value of %d bytes too long", name, len(b))
+	}
+	if replace {
+		p.DeleteVSAWithFormat(vendor, t, f)
+	}
+	vsa := radius.VSA{Vendor: vendor, Type: t, Value: b}
+	for _, avp := range vsa.ToAVPsWithFormat(f) {
+		p.AddAVP(avp)
+	}
+	return nil
+}`},
+}
+
+// codegen holds the state of a GenerateGo run.
+type codegen struct {
+	d       *Dictionary
+	body    bytes.Buffer
+	idents  map[string]bool
+	helpers map[string]bool
+	// standard library imports
+	imports map[string]bool
+}
+
+// GenerateGo writes a Go package with typed accessors for the attributes of
+// the dictionary: for each attribute X a constant X_Type and the functions
+// X_Get, X_Lookup, X_Set, X_Add and X_Del, and for enumerated attributes a
+// type X with a constant per VALUE and a String method. Vendors get a
+// V_VendorID constant and a V_Format variable.
+//
+// The accessors of tagged attributes take and return the tag with the value,
+// and array attributes have slice values. Tagged and encrypted attributes
+// also get an X_Def variable applying the tag and encryption, the latter
+// with the packet Secret and Authenticator. Aliases and arrays of variable
+// size values are left out, with a comment, and extended attributes are not
+// generated. The cmd/radius-dictgen tool
+// wraps GenerateGo for go generate.
+func (d *Dictionary) GenerateGo(w io.Writer, opts GenerateOptions) error {
+	if opts.Package == "" {
+		return fmt.Errorf("no package name")
+	}
+
+	d.RLock()
+	defer d.RUnlock()
+
+	g := &codegen{
+		d:       d,
+		idents:  make(map[string]bool),
+		helpers: make(map[string]bool),
+		imports: make(map[string]bool),
+	}
+
+	if !opts.ExcludeStandard {
+		for _, def := range d.attributes(0) {
+			g.attribute(def, nil)
+		}
+	}
+
+	vendors := d.vendors()
+	if len(opts.Vendors) > 0 {
+		vendors = vendors[:0]
+		for _, name := range opts.Vendors {
+			id, ok := d.vendorID[name]
+			if !ok {
+				return fmt.Errorf("vendor %s not found in dictionary", name)
+			}
+			vendors = append(vendors, VendorDef{Name: name, ID: id, Format: d.getVendorFormat(id)})
+		}
+	}
+	generated := make(map[VendorID]bool)
+	for _, v := range vendors {
+		// vendors may have several names
+		if !generated[v.ID] {
+			generated[v.ID] = true
+			g.vendor(v)
+		}
+	}
+	if len(g.idents) == 0 {
+		return fmt.Errorf("no attributes to generate")
+	}
+
+	var out bytes.Buffer
+	out.WriteString("// Code generated by radius-dictgen")
+	if opts.Source != "" {
+		out.WriteString(" from " + opts.Source)
+	}
+	out.WriteString(". DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n\nimport (\n", opts.Package)
+	imports := make([]string, 0, len(g.imports))
+	for imp := range g.imports {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	for _, imp := range imports {
+		fmt.Fprintf(&out, "\t%q\n", imp)
+	}
+	fmt.Fprintf(&out, "\n\t%q\n)\n", radiusImportPath)
+	out.Write(g.body.Bytes())
+
+	helpers := make([]string, 0, len(g.helpers))
+	for name := range g.helpers {
+		helpers = append(helpers, name)
+	}
+	sort.Strings(helpers)
+	for _, name := range helpers {
+		out.WriteString("\n" + codegenHelpers[name].code + "\n")
+	}
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return fmt.Errorf("generated code: %w", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// goIdent turns a dictionary name like "Framed-IP-Address" into an exported
+// Go identifier ("FramedIPAddress"). Names starting with a digit get prefix.
+func goIdent(name string, prefix string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	ident := b.String()
+	if ident != "" && unicode.IsDigit(rune(ident[0])) {
+		ident = prefix + ident
+	}
+	return ident
+}
+
+func (g *codegen) use(helpers ...string) {
+	for _, name := range helpers {
+		g.helpers[name] = true
+		for _, imp := range codegenHelpers[name].imports {
+			g.imports[imp] = true
+		}
+	}
+}
+
+func (g *codegen) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.body, format, args...)
+}
+
+func (g *codegen) vendor(v VendorDef) {
+	ident := goIdent(v.Name, "Vendor")
+	if ident == "" || g.idents[ident+"_VendorID"] {
+		g.printf("\n// Vendor %s (%d) is not generated: duplicate name.\n", v.Name, v.ID)
+		return
+	}
+	g.idents[ident+"_VendorID"] = true
+
+	g.printf("\n// %s_VendorID is the %s vendor.\n", ident, v.Name)
+	g.printf("const %s_VendorID radius.VendorID = %d\n", ident, v.ID)
+	g.printf("\n// %s_Format is the VSA layout of the %s vendor.\n", ident, v.Name)
+	g.printf("var %s_Format = radius.VendorFormat{TypeSize: %d, LengthSize: %d, Continuation: %t}\n",
+		ident, v.Format.TypeSize, v.Format.LengthSize, v.Format.Continuation)
+
+	for _, def := range g.d.attributes(v.ID) {
+		g.attribute(def, &v)
+	}
+}
+
+// attribute writes the accessors of a standard (vendor nil) or vendor attribute.
+func (g *codegen) attribute(def AttributeDef, vendor *VendorDef) {
+	size := arrayElementSize[def.Type]
+	if def.Flags.Array && size == 0 {
+		g.printf("\n// %s is not generated: array of %s values.\n", def.Name, def.Type)
+		return
+	}
+
+	ident := goIdent(def.Name, "Attr")
+	if ident == "" || g.idents[ident] {
+		g.printf("\n// %s is not generated: duplicate name.\n", def.Name)
+		return
+	}
+	g.idents[ident] = true
+
+	kind, ok := codegenKinds[def.Type]
+	if !ok {
+		kind = codegenKinds["octets"]
+	}
+	elemType := kind.goType
+	var vendorID VendorID
+	if vendor != nil {
+		vendorID = vendor.ID
+	}
+	if isEnumType(def.Type) && len(g.d.valueNames(vendorID, def.Name)) > 0 {
+		g.enum(ident, def, vendorID, kind)
+		elemType = ident
+	}
+	goType := elemType
+	if def.Flags.Array {
+		goType = "[]" + elemType
+	}
+
+	label := def.Name
+	var get, add, del string
+	if vendor != nil {
+		vident := goIdent(vendor.Name, "Vendor")
+		label = vendor.Name + "." + def.Name
+		g.printf("\n// %s_Type is the %s attribute.\n", ident, label)
+		g.printf("const %s_Type radius.VendorAttr = %d\n", ident, def.ID)
+		g.use("getVSA", "addVSA")
+		get = fmt.Sprintf("getVSA(p, %s_VendorID, %s_Type, %s_Format)", vident, ident, vident)
+		add = fmt.Sprintf("addVSA(p, %q, %s_VendorID, %s_Type, %s_Format, b, %%t)", def.Name, vident, ident, vident)
+		del = fmt.Sprintf("p.DeleteVSAWithFormat(%s_VendorID, %s_Type, %s_Format)", vident, ident, vident)
+	} else {
+		g.printf("\n// %s_Type is the %s attribute.\n", ident, label)
+		g.printf("const %s_Type radius.AttributeType = %d\n", ident, def.ID)
+		if def.Flags.Concat {
+			g.use("getConcatAVP")
+			get = fmt.Sprintf("getConcatAVP(p, %s_Type)", ident)
+		} else {
+			g.use("getAVP")
+			get = fmt.Sprintf("getAVP(p, %s_Type)", ident)
+		}
+		g.use("addAVP")
+		add = fmt.Sprintf("addAVP(p, %q, %s_Type, b, %t, %%t)", def.Name, ident, def.Flags.Concat)
+		del = fmt.Sprintf("p.DeleteAllType(%s_Type)", ident)
+	}
+	g.use(kind.decode, kind.encode)
+
+	// tagged and encrypted values are completed by the attribute definition
+	wrapped := def.Flags.HasTag || def.Flags.Encrypt != EncryptMethodNone
+	if wrapped {
+		var does, flags []string
+		if def.Flags.HasTag {
+			does = append(does, "tags")
+			flags = append(flags, "HasTag: true")
+		}
+		if def.Flags.Encrypt != EncryptMethodNone {
+			does = append(does, "encrypts")
+			flags = append(flags, "Encrypt: "+strconv.Itoa(def.Flags.Encrypt))
+		}
+		g.printf("\n// %s_Def %s the %s values", ident, strings.Join(does, " and "), label)
+		if def.Flags.Encrypt != EncryptMethodNone {
+			g.printf(", with the packet Secret and\n// Authenticator")
+		}
+		g.printf(".\n")
+		vendorField := ""
+		if def.Vendor > 0 {
+			vendorField = fmt.Sprintf("Vendor: %d, ", def.Vendor)
+		}
+		g.printf("var %s_Def = radius.AttributeDef{Name: %q, %sID: %d, Type: %q, Flags: radius.AttributeFlags{%s}}\n",
+			ident, def.Name, vendorField, def.ID, def.Type, strings.Join(flags, ", "))
+	}
+	// the tag of tagged attributes follows the value in results and
+	// precedes it in parameters
+	getDoc, lookupDoc, setDoc := "", "", ""
+	tagResult, tagParam, tagArg := "", "", "0"
+	if def.Flags.HasTag {
+		getDoc, lookupDoc, setDoc = " and its tag", ", its tag", " tagged tag"
+		tagResult, tagParam, tagArg = ", tag", ", tag uint8", "tag"
+	}
+	ret := func(value, ok string) string {
+		return "return " + value + tagResult + ", " + ok
+	}
+	decoded := "v"
+	if elemType != kind.goType {
+		decoded = elemType + "(v)"
+	}
+
+	g.printf("\n// %s_Get returns the %s value%s, the zero value when absent or invalid.\n", ident, label, getDoc)
+	g.printf("func %s_Get(p *radius.Packet) (value %s%s) {\n\tvalue%s, _ = %s_Lookup(p)\n\treturn\n}\n",
+		ident, goType, tagParam, tagResult, ident)
+
+	g.printf("\n// %s_Lookup returns the %s value%s and whether it is present and valid.\n", ident, label, lookupDoc)
+	g.printf("func %s_Lookup(p *radius.Packet) (value %s%s, ok bool) {\n", ident, goType, tagParam)
+	g.printf("\tb, ok := %s\n\tif !ok {\n\t\treturn\n\t}\n", get)
+	if wrapped {
+		split := "_"
+		if def.Flags.HasTag {
+			split = "tag"
+		}
+		g.printf("\t%s, b, err := %s_Def.DecodeBytes(p, p.Authenticator[:], b)\n\tif err != nil {\n\t\t%s\n\t}\n", split, ident, ret("value", "false"))
+	}
+	switch {
+	case def.Flags.Array:
+		g.printf("\tif len(b) == 0 || len(b)%%%d != 0 {\n\t\t%s\n\t}\n", size, ret("value", "false"))
+		g.printf("\tfor ; len(b) > 0; b = b[%d:] {\n", size)
+		g.printf("\t\tv, ok := %s(b[:%d])\n\t\tif !ok {\n\t\t\t%s\n\t\t}\n", kind.decode, size, ret("nil", "false"))
+		g.printf("\t\tvalue = append(value, %s)\n\t}\n\t%s\n}\n", decoded, ret("value", "true"))
+	case elemType != kind.goType || def.Flags.HasTag:
+		g.printf("\tv, ok := %s(b)\n\t%s\n}\n", kind.decode, ret(decoded, "ok"))
+	default:
+		g.printf("\treturn %s(b)\n}\n", kind.decode)
+	}
+
+	encode := "value"
+	if def.Flags.Array {
+		encode = "v"
+	}
+	if elemType != kind.goType {
+		encode = kind.goType + "(" + encode + ")"
+	}
+	failed := fmt.Sprintf("return fmt.Errorf(%s, err)", strconv.Quote(strings.ReplaceAll(def.Name, "%", "%%")+": %w"))
+	for _, fn := range []struct {
+		name, doc string
+		replace   bool
+	}{
+		{"Set", "replaces the %s attributes with value%s.", true},
+		{"Add", "adds a %s attribute with value%s.", false},
+	} {
+		g.printf("\n// %s_%s "+fn.doc+"\n", ident, fn.name, label, setDoc)
+		g.printf("func %s_%s(p *radius.Packet%s, value %s) error {\n", ident, fn.name, tagParam, goType)
+		if def.Flags.Array {
+			g.printf("\tvar b []byte\n\tfor _, v := range value {\n")
+			g.printf("\t\te, err := %s(%s)\n\t\tif err != nil {\n\t\t\t%s\n\t\t}\n", kind.encode, encode, failed)
+			g.printf("\t\tb = append(b, e...)\n\t}\n")
+		} else {
+			g.printf("\tb, err := %s(%s)\n\tif err != nil {\n\t\t%s\n\t}\n", kind.encode, encode, failed)
+		}
+		if wrapped {
+			assign := "="
+			if def.Flags.Array {
+				assign = ":="
+			}
+			g.printf("\tb, err %s %s_Def.EncodeBytes(p, %s, b)\n\tif err != nil {\n\t\treturn err\n\t}\n", assign, ident, tagArg)
+		}
+		g.printf("\treturn "+add+"\n}\n", fn.replace)
+	}
+	g.imports["fmt"] = true
+
+	g.printf("\n// %s_Del removes the %s attributes.\n", ident, label)
+	g.printf("func %s_Del(p *radius.Packet) {\n\t%s\n}\n", ident, del)
+}
+
+// enum writes the type of an enumerated attribute with its values.
+func (g *codegen) enum(ident string, def AttributeDef, vendorID VendorID, kind codegenKind) {
+	g.printf("\n// %s is a value of the %s attribute.\n", ident, def.Name)
+	g.printf("type %s %s\n\nconst (\n", ident, kind.goType)
+	values := g.d.values(vendorID, def.Name)
+	seen := make(map[string]bool)
+	for _, v := range values {
+		vident := goIdent(v.Name, "")
+		if vident == "" || seen[vident] || !enumValueFits(def.Type, v.Value) {
+			continue
+		}
+		seen[vident] = true
+		g.printf("\t%s_Value_%s %s = %d\n", ident, vident, ident, v.Value)
+	}
+	g.printf(")\n")
+
+	g.imports["strconv"] = true
+	g.printf("\n// String returns the VALUE name, or the number for unknown values.\n")
+	g.printf("func (v %s) String() string {\n\tswitch v {\n", ident)
+	names := g.d.valueNames(vendorID, def.Name)
+	numbers := make([]uint32, 0, len(names))
+	for n := range names {
+		if enumValueFits(def.Type, n) {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	for _, n := range numbers {
+		g.printf("\tcase %d:\n\t\treturn %q\n", n, names[n])
+	}
+	g.printf("\t}\n\treturn strconv.FormatUint(uint64(v), 10)\n}\n")
+}
+
+// enumValueFits reports whether a VALUE fits the size of the attribute type.
+func enumValueFits(attrType string, v uint32) bool {
+	switch attrType {
+	case "byte":
+		return v <= 0xff
+	case "short":
+		return v <= 0xffff
+	}
+	return true
+}
+
+// valueNames returns the value -> VALUE name map of an attribute.
+func (d *Dictionary) valueNames(vendorID VendorID, attrName string) map[uint32]string {
+	if vendorID > 0 {
+		return d.vsaConstName[vendorID][attrName]
+	}
+	return d.constName[attrName]
+}
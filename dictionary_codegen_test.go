@@ -0,0 +1,267 @@
+package radius
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGoIdent(t *testing.T) {
+	testCases := []struct {
+		name string
+		want string
+	}{
+		{"User-Name", "UserName"},
+		{"Framed-IP-Address", "FramedIPAddress"},
+		{"ascend-data-filter", "AscendDataFilter"},
+		{"Cisco_AVPair", "CiscoAVPair"},
+		{"3GPP-IMSI", "Attr3GPPIMSI"},
+		{"Login-User", "LoginUser"},
+		{"--", ""},
+	}
+	for _, tc := range testCases {
+		if got := goIdent(tc.name, "Attr"); got != tc.want {
+			t.Errorf("goIdent(%s) = %s; want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+const codegenTestDictionary = `
+ATTRIBUTE	User-Name		1	string
+ATTRIBUTE	Service-Type		6	integer
+ATTRIBUTE	Tunnel-Type		64	integer	has_tag
+ATTRIBUTE	EAP-Message		79	octets	concat
+ATTRIBUTE	Event-Timestamp		55	date
+ATTRIBUTE	Small			200	byte
+ATTRIBUTE	Small-Alias		201	string
+ALIAS	Also-Small	Small
+ATTRIBUTE	Tunnel-Password		69	string	has_tag,encrypt=2
+ATTRIBUTE	Hosts			202	ipaddr	array
+ATTRIBUTE	Names			203	string	array
+
+VALUE	Service-Type		Login-User		1
+VALUE	Service-Type		Framed-User		2
+VALUE	Small			One			1
+VALUE	Small			Too-Big			300
+
+VENDOR		Acme		4242	format=2,1
+BEGIN-VENDOR	Acme
+ATTRIBUTE	Acme-Host		1	ipaddr
+ATTRIBUTE	Acme-Mode		2	short
+VALUE	Acme-Mode		Fast			7
+END-VENDOR	Acme
+`
+
+func TestGenerateGo(t *testing.T) {
+	d := NewDictionary()
+	if err := d.LoadReader("dictionary.codegen", strings.NewReader(codegenTestDictionary)); err != nil {
+		t.Fatalf("LoadReader failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := d.GenerateGo(&buf, GenerateOptions{Package: "acme", Source: "dictionary.codegen"}); err != nil {
+		t.Fatalf("GenerateGo failed: %v", err)
+	}
+	src := buf.String()
+
+	for _, want := range []string{
+		"// Code generated by radius-dictgen from dictionary.codegen. DO NOT EDIT.",
+		"package acme",
+		"const UserName_Type radius.AttributeType = 1",
+		"func UserName_Get(p *radius.Packet) (value string)",
+		"type ServiceType uint32",
+		"ServiceType_Value_FramedUser ServiceType = 2",
+		"func ServiceType_Set(p *radius.Packet, value ServiceType) error",
+		"func TunnelType_Set(p *radius.Packet, tag uint8, value uint32) error",
+		"func TunnelType_Lookup(p *radius.Packet) (value uint32, tag uint8, ok bool)",
+		`var TunnelPassword_Def = radius.AttributeDef{Name: "Tunnel-Password", ID: 69, Type: "string", Flags: radius.AttributeFlags{HasTag: true, Encrypt: 2}}`,
+		"func Hosts_Get(p *radius.Packet) (value []net.IP)",
+		"// Names is not generated: array of string values.",
+		"getConcatAVP(p, EAPMessage_Type)",
+		"func EventTimestamp_Get(p *radius.Packet) (value time.Time)",
+		"type Small uint8",
+		"const Acme_VendorID radius.VendorID = 4242",
+		"var Acme_Format = radius.VendorFormat{TypeSize: 2, LengthSize: 1, Continuation: false}",
+		"func AcmeHost_Add(p *radius.Packet, value net.IP) error",
+		"AcmeMode_Value_Fast AcmeMode = 7",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("generated code lacks %q", want)
+		}
+	}
+	for _, unwanted := range []string{"TooBig", "AlsoSmall"} {
+		if strings.Contains(src, unwanted) {
+			t.Errorf("generated code contains %q", unwanted)
+		}
+	}
+
+	buf.Reset()
+	if err := d.GenerateGo(&buf, GenerateOptions{Package: "acme", Vendors: []string{"Acme"}, ExcludeStandard: true}); err != nil {
+		t.Fatalf("GenerateGo(Acme) failed: %v", err)
+	}
+	if src := buf.String(); strings.Contains(src, "UserName_Type") || !strings.Contains(src, "AcmeHost_Type") {
+		t.Errorf("GenerateGo(Acme) did not limit the output to the vendor")
+	}
+
+	failures := []GenerateOptions{
+		{},
+		{Package: "acme", Vendors: []string{"Nope"}},
+		{Package: "acme", ExcludeStandard: true, Vendors: []string{}},
+	}
+	empty := NewDictionary()
+	for i, opts := range failures {
+		dict := d
+		if i == 2 {
+			dict = empty
+		}
+		if err := dict.GenerateGo(&buf, opts); err == nil {
+			t.Errorf("GenerateGo(%+v) succeeded", opts)
+		}
+	}
+}
+
+const codegenTestProgram = `package gentest
+
+import (
+	"net"
+	"testing"
+
+	"github.com/sergle/radius/v2"
+)
+
+func TestGenerated(t *testing.T) {
+	p := radius.Request(radius.AccessRequest, "secret")
+	if err := UserName_Set(p, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ServiceType_Add(p, ServiceType_Value_FramedUser); err != nil {
+		t.Fatal(err)
+	}
+	if err := CiscoAVPair_Add(p, "shell:priv-lvl=15"); err != nil {
+		t.Fatal(err)
+	}
+	if err := MSPrimaryDNSServer_Set(p, net.IPv4(192, 0, 2, 53)); err != nil {
+		t.Fatal(err)
+	}
+	if err := FramedIPAddress_Set(p, net.ParseIP("2001:db8::1")); err == nil {
+		t.Error("FramedIPAddress_Set accepted an IPv6 address")
+	}
+	if err := UserPassword_Set(p, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if err := TunnelType_Add(p, 1, TunnelType_Value_L2TP); err != nil {
+		t.Fatal(err)
+	}
+	if err := TunnelPassword_Add(p, 2, "tunnel secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := TestModes_Set(p, []TestModes{TestModes_Value_Fast, TestModes_Value_Slow}); err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := p.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := radius.DecodeRequest("secret", buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := UserName_Get(q); v != "alice" {
+		t.Errorf("UserName_Get() = %s", v)
+	}
+	if v := ServiceType_Get(q); v != ServiceType_Value_FramedUser || v.String() != "Framed-User" {
+		t.Errorf("ServiceType_Get() = %v", v)
+	}
+	if v := CiscoAVPair_Get(q); v != "shell:priv-lvl=15" {
+		t.Errorf("CiscoAVPair_Get() = %s", v)
+	}
+	if v := MSPrimaryDNSServer_Get(q); !v.Equal(net.IPv4(192, 0, 2, 53)) {
+		t.Errorf("MSPrimaryDNSServer_Get() = %v", v)
+	}
+	if _, ok := NASPort_Lookup(q); ok {
+		t.Error("NASPort_Lookup() found an absent attribute")
+	}
+	if v := UserPassword_Get(q); v != "hello" || q.GetPassword() != "hello" {
+		t.Errorf("UserPassword_Get() = %q", v)
+	}
+	if v, tag := TunnelType_Get(q); v != TunnelType_Value_L2TP || tag != 1 {
+		t.Errorf("TunnelType_Get() = %v, %d", v, tag)
+	}
+	if v, tag := TunnelPassword_Get(q); v != "tunnel secret" || tag != 2 {
+		t.Errorf("TunnelPassword_Get() = %q, %d", v, tag)
+	}
+	if v := TestModes_Get(q); len(v) != 2 || v[0] != TestModes_Value_Fast || v[1] != TestModes_Value_Slow {
+		t.Errorf("TestModes_Get() = %v", v)
+	}
+
+	UserName_Del(q)
+	CiscoAVPair_Del(q)
+	if _, ok := UserName_Lookup(q); ok {
+		t.Error("UserName_Del() left the attribute")
+	}
+	if _, ok := CiscoAVPair_Lookup(q); ok {
+		t.Error("CiscoAVPair_Del() left the attribute")
+	}
+}
+`
+
+// TestGenerateGoCompiles builds and runs code generated from the embedded
+// dictionaries in a package of this module.
+func TestGenerateGoCompiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping go build in short mode")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+
+	d, err := NewRFCDictionary()
+	if err != nil {
+		t.Fatalf("NewRFCDictionary failed: %v", err)
+	}
+	for _, pack := range VendorPacks() {
+		if err := d.LoadVendorPack(pack); err != nil {
+			t.Fatalf("LoadVendorPack(%s) failed: %v", pack, err)
+		}
+	}
+	// the shipped dictionaries have no array attribute
+	if err := d.LoadReader("dictionary.codegen", strings.NewReader(`
+VENDOR		Codegen-Test	65000
+BEGIN-VENDOR	Codegen-Test
+ATTRIBUTE	Test-Modes	1	integer	array
+VALUE	Test-Modes	Fast	1
+VALUE	Test-Modes	Slow	2
+END-VENDOR	Codegen-Test
+`)); err != nil {
+		t.Fatalf("LoadReader failed: %v", err)
+	}
+
+	// inside the module, ignored by ./... patterns
+	dir, err := os.MkdirTemp(".", "_codegen")
+	if err != nil {
+		t.Fatalf("MkdirTemp failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	if err := d.GenerateGo(&buf, GenerateOptions{Package: "gentest"}); err != nil {
+		t.Fatalf("GenerateGo failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "attributes.go"), buf.Bytes(), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "attributes_test.go"), []byte(codegenTestProgram), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	out, err := exec.Command(goTool, "test", "-count=1", "./"+filepath.Base(dir)).CombinedOutput()
+	if err != nil {
+		t.Fatalf("go test of generated code failed: %v\n%s", err, out)
+	}
+}
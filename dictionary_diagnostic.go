@@ -0,0 +1,164 @@
+package radius
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// DiagnosticSeverity classifies a dictionary Diagnostic.
+type DiagnosticSeverity int
+
+const (
+	// SeverityWarning marks definitions that were skipped or are suspicious
+	// but harmless, like duplicates or unsupported constructs.
+	SeverityWarning DiagnosticSeverity = iota
+	// SeverityError marks invalid lines and conflicting definitions.
+	SeverityError
+)
+
+func (s DiagnosticSeverity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return "severity(" + strconv.Itoa(int(s)) + ")"
+}
+
+// Diagnostic is a problem found while loading a dictionary.
+type Diagnostic struct {
+	// File and Line locate the offending line; Line is 0 when unknown.
+	File     string
+	Line     int
+	Severity DiagnosticSeverity
+	Message  string
+	// Err is the underlying error, if any (for example a failed open).
+	Err error
+}
+
+// Error formats the diagnostic as "file:line: severity: message".
+func (d Diagnostic) Error() string {
+	pos := d.File
+	if d.Line > 0 {
+		pos += ":" + strconv.Itoa(d.Line)
+	}
+	if pos != "" {
+		pos += ": "
+	}
+	return pos + d.Severity.String() + ": " + d.Message
+}
+
+func (d Diagnostic) Unwrap() error {
+	return d.Err
+}
+
+// DiagnosticMode selects how a Dictionary reports problems while loading.
+type DiagnosticMode int
+
+const (
+	// DiagnosticModeLog writes problems to the standard logger and stops on
+	// invalid lines, skipping unsupported ones. Conflicting redefinitions
+	// silently overwrite earlier ones and duplicates are not reported.
+	// Nothing is kept for Diagnostics. This is the default.
+	DiagnosticModeLog DiagnosticMode = iota
+	// DiagnosticModeLenient collects all problems without logging, skipping
+	// invalid lines and keeping conflicting redefinitions. Loading only fails
+	// when a file can't be read.
+	DiagnosticModeLenient
+	// DiagnosticModeStrict collects problems without logging and fails at the
+	// first error, including conflicting redefinitions.
+	DiagnosticModeStrict
+)
+
+// SetDiagnosticMode selects how subsequent loads report problems.
+func (d *Dictionary) SetDiagnosticMode(mode DiagnosticMode) {
+	d.Lock()
+	defer d.Unlock()
+	d.diagMode = mode
+}
+
+// Diagnostics returns the problems found by all loads so far, in order. Only
+// loads in DiagnosticModeLenient and DiagnosticModeStrict record them.
+func (d *Dictionary) Diagnostics() []Diagnostic {
+	d.RLock()
+	defer d.RUnlock()
+	return append([]Diagnostic(nil), d.diagnostics...)
+}
+
+// logf writes to the standard logger, in DiagnosticModeLog only.
+func (d *Dictionary) logf(format string, args ...interface{}) {
+	if d.diagMode == DiagnosticModeLog {
+		log.Printf(format, args...)
+	}
+}
+
+// warnf records a warning at the current parser position.
+func (d *Dictionary) warnf(format string, args ...interface{}) {
+	d.report(SeverityWarning, nil, true, format, args...)
+}
+
+// notef records a harmless warning, like a duplicate definition, which is
+// not logged in DiagnosticModeLog.
+func (d *Dictionary) notef(format string, args ...interface{}) {
+	d.report(SeverityWarning, nil, false, format, args...)
+}
+
+// errorf records an invalid line at the current parser position. It returns
+// the diagnostic when loading must stop, nil when the line is to be skipped.
+func (d *Dictionary) errorf(format string, args ...interface{}) error {
+	diag := d.report(SeverityError, nil, true, format, args...)
+	if d.diagMode == DiagnosticModeLenient {
+		return nil
+	}
+	return diag
+}
+
+// recoverf records an error the loader can recover from, like a conflicting
+// redefinition (the new definition wins) or an unparsable attribute ID (the
+// line is skipped). It returns the diagnostic in strict mode only.
+func (d *Dictionary) recoverf(format string, args ...interface{}) error {
+	diag := d.report(SeverityError, nil, true, format, args...)
+	if d.diagMode == DiagnosticModeStrict {
+		return diag
+	}
+	return nil
+}
+
+// conflictf records a conflicting redefinition like recoverf, without
+// logging it in DiagnosticModeLog where the new definition silently wins.
+func (d *Dictionary) conflictf(format string, args ...interface{}) error {
+	diag := d.report(SeverityError, nil, false, format, args...)
+	if d.diagMode == DiagnosticModeStrict {
+		return diag
+	}
+	return nil
+}
+
+// failf records an error that always stops loading, wrapping err.
+func (d *Dictionary) failf(err error, format string, args ...interface{}) error {
+	return d.report(SeverityError, err, true, format, args...)
+}
+
+// report builds a diagnostic at the current parser position. It is kept for
+// Diagnostics in DiagnosticModeLenient and DiagnosticModeStrict, and logged
+// in DiagnosticModeLog when logged is set, so the default mode neither grows
+// with every load nor reports more than it used to.
+func (d *Dictionary) report(sev DiagnosticSeverity, err error, logged bool, format string, args ...interface{}) Diagnostic {
+	diag := Diagnostic{
+		File:     d.currentFile,
+		Line:     d.currentLine,
+		Severity: sev,
+		Message:  fmt.Sprintf(format, args...),
+		Err:      err,
+	}
+	if d.diagMode == DiagnosticModeLog {
+		if logged {
+			log.Printf("%s\n", diag.Error())
+		}
+	} else {
+		d.diagnostics = append(d.diagnostics, diag)
+	}
+	return diag
+}
@@ -0,0 +1,195 @@
+package radius
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const diagnosticTestDictionary = `# comment
+ATTRIBUTE	Test-Attr	100	string
+ATTRIBUTE	Test-Attr	100	string
+ATTRIBUTE	Test-Int	101	integer
+VALUE		Test-Int	One	1
+VALUE		Test-Int	One	1
+VALUE		Missing-Attr	One	1
+ATTRIBUTE	Test-Ext	241.1	string
+ATTRIBUTE	Test-Attr-New	100	string
+ATTRIBUTE	Test-Int	101	string
+ATTRIBUTE	Test-Bad	abc	string
+$INCLUDE	dictionary.vendor
+`
+
+const diagnosticTestVendor = `VENDOR		Acme	9999
+BEGIN-VENDOR	Acme
+ATTRIBUTE	Acme-Attr	1	string
+ATTRIBUTE	Acme-Attr	2	string
+END-VENDOR	Acme
+BEGIN-VENDOR	Unknown
+ATTRIBUTE	Unknown-Attr	1	string
+END-VENDOR	Unknown
+ATTRIBUTE	Test-After	102	string
+`
+
+func diagnosticTestFS() fstest.MapFS {
+	return fstest.MapFS{
+		"dictionary":        &fstest.MapFile{Data: []byte(diagnosticTestDictionary)},
+		"dictionary.vendor": &fstest.MapFile{Data: []byte(diagnosticTestVendor)},
+	}
+}
+
+func TestDiagnosticsLenient(t *testing.T) {
+	d := NewDictionary()
+	d.SetDiagnosticMode(DiagnosticModeLenient)
+	if err := d.LoadFS(diagnosticTestFS(), "dictionary"); err != nil {
+		t.Fatalf("LoadFS failed: %v", err)
+	}
+
+	want := []Diagnostic{
+		{File: "dictionary", Line: 3, Severity: SeverityWarning, Message: "duplicate attribute Test-Attr"},
+		{File: "dictionary", Line: 6, Severity: SeverityWarning, Message: "duplicate value One for Test-Int"},
+		{File: "dictionary", Line: 7, Severity: SeverityWarning, Message: "value One for unknown attribute Missing-Attr ignored"},
+		{File: "dictionary", Line: 8, Severity: SeverityWarning, Message: "unsupported attribute id 241.1 for Test-Ext, ignored"},
+		{File: "dictionary", Line: 9, Severity: SeverityError, Message: "attribute id 100 redefined as Test-Attr-New (was Test-Attr)"},
+		{File: "dictionary", Line: 10, Severity: SeverityError, Message: "attribute Test-Int redefined with type string (was integer)"},
+		{File: "dictionary", Line: 11, Severity: SeverityError, Message: "invalid attribute id abc for Test-Bad, ignored"},
+		{File: "dictionary.vendor", Line: 4, Severity: SeverityError, Message: "attribute Acme.Acme-Attr redefined with id 2 (was 1)"},
+		{File: "dictionary.vendor", Line: 6, Severity: SeverityError, Message: "unknown vendor Unknown"},
+	}
+	got := d.Diagnostics()
+	if len(got) != len(want) {
+		t.Fatalf("Diagnostics() returned %d entries; want %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Diagnostics()[%d] = %+v; want %+v", i, got[i], want[i])
+		}
+	}
+
+	// the later definitions win, the unknown vendor block is skipped
+	if name := d.GetAttributeName(100); name != "Test-Attr-New" {
+		t.Errorf("GetAttributeName(100) = %s; want Test-Attr-New", name)
+	}
+	if d.HasAttribute("Unknown-Attr") {
+		t.Error("attribute of unknown vendor added as standard attribute")
+	}
+	if !d.HasAttribute("Test-After") {
+		t.Error("attribute after unknown vendor block not added")
+	}
+}
+
+func TestDiagnosticsStrict(t *testing.T) {
+	d := NewDictionary()
+	d.SetDiagnosticMode(DiagnosticModeStrict)
+	err := d.LoadFS(diagnosticTestFS(), "dictionary")
+	if err == nil {
+		t.Fatal("LoadFS expected error")
+	}
+
+	var diag Diagnostic
+	if !errors.As(err, &diag) {
+		t.Fatalf("LoadFS error %T is not a Diagnostic", err)
+	}
+	want := "dictionary:9: error: attribute id 100 redefined as Test-Attr-New (was Test-Attr)"
+	if diag.Error() != want {
+		t.Errorf("LoadFS error = %s; want %s", diag.Error(), want)
+	}
+	if n := len(d.Diagnostics()); n != 5 {
+		t.Errorf("Diagnostics() returned %d entries; want 5", n)
+	}
+}
+
+func TestDiagnosticsLogMode(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	// the default mode keeps the historical behaviour: conflicts overwrite
+	// silently, unknown vendors stop loading
+	d := NewDictionary()
+	err := d.LoadFS(diagnosticTestFS(), "dictionary")
+	if err == nil {
+		t.Fatal("LoadFS expected error")
+	}
+	if !strings.Contains(err.Error(), "dictionary.vendor:6: error: unknown vendor Unknown") {
+		t.Errorf("LoadFS error = %v", err)
+	}
+	if name := d.GetAttributeName(100); name != "Test-Attr-New" {
+		t.Errorf("GetAttributeName(100) = %s; want Test-Attr-New", name)
+	}
+
+	for _, quiet := range []string{"duplicate", "redefined"} {
+		if strings.Contains(logged.String(), quiet) {
+			t.Errorf("log mode reported %s definitions:\n%s", quiet, logged.String())
+		}
+	}
+	if !strings.Contains(logged.String(), "value One for unknown attribute Missing-Attr ignored") {
+		t.Errorf("log mode did not report the value of an unknown attribute:\n%s", logged.String())
+	}
+	if n := len(d.Diagnostics()); n != 0 {
+		t.Errorf("Diagnostics() kept %d entries in log mode", n)
+	}
+}
+
+func TestDiagnosticsOpenError(t *testing.T) {
+	d := NewDictionary()
+	d.SetDiagnosticMode(DiagnosticModeLenient)
+	fsys := fstest.MapFS{
+		"dictionary": &fstest.MapFile{Data: []byte("\n$INCLUDE dictionary.missing\n")},
+	}
+	err := d.LoadFS(fsys, "dictionary")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("LoadFS error = %v; want fs.ErrNotExist", err)
+	}
+	var diag Diagnostic
+	if !errors.As(err, &diag) || diag.File != "dictionary" || diag.Line != 2 {
+		t.Errorf("LoadFS error = %+v; want position dictionary:2", diag)
+	}
+}
+
+// The shipped dictionaries must load cleanly in strict mode.
+func TestDiagnosticsShippedDictionaries(t *testing.T) {
+	d := NewDictionary()
+	d.SetDiagnosticMode(DiagnosticModeStrict)
+	if err := d.LoadRFC(); err != nil {
+		t.Fatalf("LoadRFC failed: %v", err)
+	}
+	for _, pack := range VendorPacks() {
+		if err := d.LoadVendorPack(pack); err != nil {
+			t.Fatalf("LoadVendorPack(%s) failed: %v", pack, err)
+		}
+	}
+	for _, diag := range d.Diagnostics() {
+		t.Errorf("unexpected diagnostic: %s", diag)
+	}
+
+	builtin := NewDictionary()
+	builtin.SetDiagnosticMode(DiagnosticModeStrict)
+	if err := builtin.LoadFile("dictionary.builtin"); err != nil {
+		t.Fatalf("LoadFile(dictionary.builtin) failed: %v", err)
+	}
+	for _, diag := range builtin.Diagnostics() {
+		t.Errorf("unexpected diagnostic: %s", diag)
+	}
+}
+
+func TestDiagnosticError(t *testing.T) {
+	testCases := []struct {
+		diag Diagnostic
+		want string
+	}{
+		{Diagnostic{File: "dictionary", Line: 3, Severity: SeverityWarning, Message: "m"}, "dictionary:3: warning: m"},
+		{Diagnostic{File: "dictionary", Severity: SeverityError, Message: "m"}, "dictionary: error: m"},
+		{Diagnostic{Severity: SeverityError, Message: "m"}, "error: m"},
+	}
+	for _, tc := range testCases {
+		if got := tc.diag.Error(); got != tc.want {
+			t.Errorf("Error() = %s; want %s", got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package radius
+
+import (
+	"embed"
+	"errors"
+	"log"
+	"path"
+	"sort"
+	"strings"
+)
+
+// embeddedDictionaries holds the RFC dictionaries and the vendor packs shipped
+// with the package, in FreeRADIUS format.
+//
+//go:embed dictionaries/dictionary.*
+var embeddedDictionaries embed.FS
+
+const embeddedDictionaryDir = "dictionaries"
+
+// rfcDictionaries lists the embedded RFC dictionaries in load order: later
+// files add values to attributes defined by earlier ones, and RFC 6929
+// defines the Extended-Attributes of the later extended attributes.
+var rfcDictionaries = []string{
+	"dictionary.rfc2865",
+	"dictionary.rfc2866",
+	"dictionary.rfc2867",
+	"dictionary.rfc2868",
+	"dictionary.rfc2869",
+	"dictionary.rfc3162",
+	"dictionary.rfc3576",
+	"dictionary.rfc4072",
+	"dictionary.rfc4372",
+	"dictionary.rfc4675",
+	"dictionary.rfc4679",
+	"dictionary.rfc4818",
+	"dictionary.rfc4849",
+	"dictionary.rfc5090",
+	"dictionary.rfc5176",
+	"dictionary.rfc5580",
+	"dictionary.rfc5607",
+	"dictionary.rfc5904",
+	"dictionary.rfc6519",
+	"dictionary.rfc6572",
+	"dictionary.rfc6911",
+	"dictionary.rfc6929",
+	"dictionary.rfc7055",
+	"dictionary.rfc7155",
+	"dictionary.rfc7268",
+	"dictionary.rfc8045",
+	"dictionary.rfc8559",
+}
+
+// vendorPacks maps the names accepted by LoadVendorPack to embedded files.
+var vendorPacks = map[string]string{
+	"microsoft": "dictionary.microsoft",
+	"cisco":     "dictionary.cisco",
+	"juniper":   "dictionary.juniper",
+	"mikrotik":  "dictionary.mikrotik",
+	"wispr":     "dictionary.wispr",
+}
+
+// NewRFCDictionary returns a dictionary loaded with the embedded standard RFC
+// dictionaries (RFC 2865 through RFC 8559). Vendor packs can be added with
+// LoadVendorPack.
+func NewRFCDictionary() (*Dictionary, error) {
+	d := NewDictionary()
+	if err := d.LoadRFC(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// LoadRFC loads the embedded standard RFC dictionaries into d.
+func (d *Dictionary) LoadRFC() error {
+	d.Lock()
+	defer d.Unlock()
+	for _, name := range rfcDictionaries {
+		if err := d.loadEmbedded(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadVendorPack loads an embedded vendor dictionary into d. The name is case
+// insensitive, see VendorPacks for the available packs.
+func (d *Dictionary) LoadVendorPack(name string) error {
+	fname, ok := vendorPacks[strings.ToLower(name)]
+	if !ok {
+		log.Printf("Unknown vendor pack %s\n", name)
+		return errors.New("unknown vendor pack " + name)
+	}
+	d.Lock()
+	defer d.Unlock()
+	return d.loadEmbedded(fname)
+}
+
+// VendorPacks returns the sorted names of the embedded vendor packs.
+func VendorPacks() []string {
+	names := make([]string, 0, len(vendorPacks))
+	for name := range vendorPacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (d *Dictionary) loadEmbedded(name string) error {
+	// embedded files have no $INCLUDE, each one starts outside of any vendor
+	d.currentVendor = 0
+	return d.loadFSInternal(embeddedDictionaries, embeddedSource, path.Join(embeddedDictionaryDir, name))
+}
+
+// embeddedSource is the dictionaryFile.source of the embedded dictionaries,
+// read once by a Dictionary.
+const embeddedSource = -1
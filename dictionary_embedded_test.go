@@ -0,0 +1,238 @@
+package radius
+
+import (
+	"bytes"
+	"io/fs"
+	"net"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewRFCDictionary(t *testing.T) {
+	d, err := NewRFCDictionary()
+	if err != nil {
+		t.Fatalf("NewRFCDictionary failed: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		id       AttributeType
+		attrType string
+	}{
+		{"User-Name", 1, "string"},
+		{"Acct-Status-Type", 40, "integer"},
+		{"Tunnel-Password", 69, "string"},
+		{"Event-Timestamp", 55, "date"},
+		{"Framed-IPv6-Prefix", 97, "ipv6prefix"},
+		{"Error-Cause", 101, "integer"},
+		{"Delegated-IPv6-Prefix", 123, "ipv6prefix"},
+		{"Operator-Name", 126, "octets"},
+		{"PKM-SAID", 141, "short"},
+		{"PMIP6-Home-IPv4-HoA", 155, "ipv4prefix"},
+		{"GSS-Acceptor-Realm-Name", 167, "string"},
+		{"Framed-IPv6-Address", 168, "ipv6addr"},
+		{"WLAN-RF-Band", 190, "integer"},
+		{"Extended-Attribute-1", 241, "extended"},
+		{"Extended-Attribute-5", 245, "long-extended"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if id := d.GetAttributeID(tc.name); id != tc.id {
+				t.Errorf("GetAttributeID(%s) = %d; want %d", tc.name, id, tc.id)
+			}
+			if typ := d.GetAttributeType(tc.name); typ != tc.attrType {
+				t.Errorf("GetAttributeType(%s) = %s; want %s", tc.name, typ, tc.attrType)
+			}
+		})
+	}
+
+	if flags := d.GetAttributeFlags("Tunnel-Password"); !flags.HasTag || flags.Encrypt != EncryptMethodTunnelPassword {
+		t.Errorf("Tunnel-Password flags = %s", flags)
+	}
+
+	// values added to Error-Cause by RFC 5176
+	p := &Packet{}
+	avp := AVP{Type: 101, Value: []byte{0, 0, 0x01, 0x97}}
+	if s := d.DecodeAVPValue(p, avp); s != "Invalid-Attribute-Value" {
+		t.Errorf("DecodeAVPValue(Error-Cause) = %s; want Invalid-Attribute-Value", s)
+	}
+
+	// RFC 4679 defines a vendor
+	if id := d.GetVendorID("ADSL-Forum"); id != 3561 {
+		t.Errorf("GetVendorID(ADSL-Forum) = %d; want 3561", id)
+	}
+
+	// vendor packs are opt-in
+	if id := d.GetVendorID("Microsoft"); id != 0 {
+		t.Errorf("Microsoft loaded without LoadVendorPack")
+	}
+}
+
+func TestRFCExtendedAttributes(t *testing.T) {
+	d, err := NewRFCDictionary()
+	if err != nil {
+		t.Fatalf("NewRFCDictionary failed: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		extended AttributeType
+		id       uint32
+		attrType string
+	}{
+		{"IP-Port-Limit-Info", 241, 5, "tlv"},
+		{"Operator-NAS-Identifier", 241, 8, "octets"},
+		{"Extended-Vendor-Specific-1", 241, 26, "evs"},
+		{"Extended-Vendor-Specific-6", 246, 26, "evs"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			want := AttributeDef{Name: tc.name, ID: tc.id, Type: tc.attrType, Extended: tc.extended}
+			if def, ok := d.GetAttributeDef(tc.name); !ok || def != want {
+				t.Errorf("GetAttributeDef(%s) = %+v, %v; want %+v", tc.name, def, ok, want)
+			}
+			if def, ok := d.GetExtendedAttributeDefByID(tc.extended, uint8(tc.id)); !ok || def != want {
+				t.Errorf("GetExtendedAttributeDefByID(%d, %d) = %+v, %v", tc.extended, tc.id, def, ok)
+			}
+			if id := d.GetAttributeID(tc.name); id != 0 {
+				t.Errorf("GetAttributeID(%s) = %d; want 0", tc.name, id)
+			}
+		})
+	}
+
+	if def, err := d.ResolveAttribute("241.8"); err != nil || def.Name != "Operator-NAS-Identifier" {
+		t.Errorf("ResolveAttribute(241.8) = %+v, %v", def, err)
+	}
+
+	p := &Packet{}
+	if err := d.AddAttribute(p, "Operator-NAS-Identifier", "\x01\x02"); err != nil {
+		t.Fatalf("AddAttribute failed: %v", err)
+	}
+	want := AVP{Type: AttrExtendedAttribute1, Value: []byte{8, 1, 2}}
+	if len(p.AVPs) != 1 || !reflect.DeepEqual(p.AVPs[0], want) {
+		t.Fatalf("AddAttribute added %v; want %v", p.AVPs, want)
+	}
+	if avp, err := d.ParseAVP("Operator-NAS-Identifier", "\x01\x02"); err != nil || !reflect.DeepEqual(avp, want) {
+		t.Errorf("ParseAVP = %v, %v; want %v", avp, err, want)
+	}
+	wantText := "{Attr: Operator-NAS-Identifier #241.8, Value: []byte{0x1, 0x2}}"
+	if s := d.DecodeAVPValue(p, p.AVPs[0]); s != wantText {
+		t.Errorf("DecodeAVPValue = %s; want %s", s, wantText)
+	}
+	if s := d.Snapshot().DecodeAVPValue(p, p.AVPs[0]); s != wantText {
+		t.Errorf("Snapshot DecodeAVPValue = %s; want %s", s, wantText)
+	}
+
+	// long extended values are split and joined again
+	long := strings.Repeat("x", 600)
+	if err := d.AddAttribute(p, "Extended-Vendor-Specific-5", long); err != nil {
+		t.Fatalf("AddAttribute failed: %v", err)
+	}
+	if len(p.AVPs) != 4 {
+		t.Fatalf("AddAttribute added %d attributes; want 3", len(p.AVPs)-1)
+	}
+	var v struct {
+		NASID []byte `radius:"Operator-NAS-Identifier"`
+		EVS   []byte `radius:"245.26"`
+	}
+	if err := d.Unmarshal(p, &v); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !bytes.Equal(v.NASID, []byte{1, 2}) || string(v.EVS) != long {
+		t.Errorf("Unmarshal = %x, %d bytes", v.NASID, len(v.EVS))
+	}
+
+	// only long extended values may need several attributes
+	if err := d.AddAttribute(p, "Operator-NAS-Identifier", long); err == nil {
+		t.Error("AddAttribute of a value too long expected error")
+	}
+	if _, err := d.ParseAVP("Extended-Vendor-Specific-5", long); err == nil {
+		t.Error("ParseAVP of a value too long expected error")
+	}
+}
+
+func TestLoadVendorPack(t *testing.T) {
+	d, err := NewRFCDictionary()
+	if err != nil {
+		t.Fatalf("NewRFCDictionary failed: %v", err)
+	}
+
+	testCases := []struct {
+		pack   string
+		vendor string
+		id     VendorID
+		attr   string
+		attrID VendorAttr
+	}{
+		{"microsoft", "Microsoft", 311, "MS-MPPE-Send-Key", 16},
+		{"Cisco", "Cisco", 9, "Cisco-AVPair", 1},
+		{"juniper", "Juniper", 2636, "Juniper-Local-User-Name", 1},
+		{"mikrotik", "Mikrotik", 14988, "Mikrotik-Rate-Limit", 8},
+		{"WISPr", "WISPr", 14122, "WISPr-Bandwidth-Max-Down", 8},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.pack, func(t *testing.T) {
+			if err := d.LoadVendorPack(tc.pack); err != nil {
+				t.Fatalf("LoadVendorPack(%s) failed: %v", tc.pack, err)
+			}
+			if id := d.GetVendorID(tc.vendor); id != tc.id {
+				t.Errorf("GetVendorID(%s) = %d; want %d", tc.vendor, id, tc.id)
+			}
+			if id := d.GetVSAAttributeID(tc.id, tc.attr); id != tc.attrID {
+				t.Errorf("GetVSAAttributeID(%s) = %d; want %d", tc.attr, id, tc.attrID)
+			}
+		})
+	}
+
+	if flags := d.GetVSAAttributeFlags(311, "MS-MPPE-Recv-Key"); flags.Encrypt != EncryptMethodTunnelPassword {
+		t.Errorf("MS-MPPE-Recv-Key flags = %s", flags)
+	}
+
+	vsa := d.NewVSA("Mikrotik", "Mikrotik-Host-IP", "10.0.0.1")
+	if !net.IP(vsa.Value).Equal(net.ParseIP("10.0.0.1")) {
+		t.Errorf("NewVSA(Mikrotik-Host-IP) = %v", vsa.Value)
+	}
+
+	if err := d.LoadVendorPack("acme"); err == nil {
+		t.Error("LoadVendorPack(acme) expected error")
+	}
+}
+
+func TestVendorPacks(t *testing.T) {
+	want := []string{"cisco", "juniper", "microsoft", "mikrotik", "wispr"}
+	got := VendorPacks()
+	if len(got) != len(want) {
+		t.Fatalf("VendorPacks() = %v; want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("VendorPacks() = %v; want %v", got, want)
+		}
+	}
+}
+
+// Every embedded file must be reachable from NewRFCDictionary or a vendor pack.
+func TestEmbeddedDictionariesListed(t *testing.T) {
+	listed := make(map[string]bool)
+	for _, name := range rfcDictionaries {
+		listed[name] = true
+	}
+	for _, name := range vendorPacks {
+		listed[name] = true
+	}
+
+	files, err := fs.Glob(embeddedDictionaries, path.Join(embeddedDictionaryDir, "dictionary.*"))
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	if len(files) != len(listed) {
+		t.Errorf("embedded %d files; listed %d", len(files), len(listed))
+	}
+	for _, f := range files {
+		if !listed[path.Base(f)] {
+			t.Errorf("embedded file %s is not listed", f)
+		}
+	}
+}
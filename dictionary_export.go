@@ -0,0 +1,365 @@
+package radius
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// VendorDef describes a vendor as defined by the dictionary.
+type VendorDef struct {
+	Name   string
+	ID     VendorID
+	Format VendorFormat
+	// Aliases are the other names the vendor is defined under, sorted.
+	Aliases []string
+}
+
+// ValueDef is a named value (VALUE line) of an attribute or ENUM.
+type ValueDef struct {
+	Name  string
+	Value uint32
+}
+
+// EnumDef is a FreeRADIUS v4 ENUM: a set of values shared by attributes with
+// the enum= flag.
+type EnumDef struct {
+	Name string
+	Type string
+}
+
+// AliasDef is an ALIAS name for the Target attribute of the same vendor.
+type AliasDef struct {
+	Name   string
+	Target string
+}
+
+// Vendors returns the vendors sorted by ID. A vendor defined under several
+// names is listed once, with the name its ID resolves to and the others as
+// Aliases.
+func (d *Dictionary) Vendors() []VendorDef {
+	d.RLock()
+	defer d.RUnlock()
+	return d.vendors()
+}
+
+func (d *Dictionary) vendors() []VendorDef {
+	vendors := make([]VendorDef, 0, len(d.vendorName))
+	index := make(map[VendorID]int, len(d.vendorName))
+	for id, name := range d.vendorName {
+		index[id] = len(vendors)
+		vendors = append(vendors, VendorDef{Name: name, ID: id, Format: d.getVendorFormat(id)})
+	}
+	for name, id := range d.vendorID {
+		if v := &vendors[index[id]]; name != v.Name {
+			v.Aliases = append(v.Aliases, name)
+		}
+	}
+	for _, v := range vendors {
+		sort.Strings(v.Aliases)
+	}
+	sort.Slice(vendors, func(i, j int) bool { return vendors[i].ID < vendors[j].ID })
+	return vendors
+}
+
+// Attributes returns the standard attributes sorted by ID, without aliases.
+func (d *Dictionary) Attributes() []AttributeDef {
+	d.RLock()
+	defer d.RUnlock()
+	return d.attributes(0)
+}
+
+// VSAAttributes returns the attributes of a vendor sorted by ID, without aliases.
+func (d *Dictionary) VSAAttributes(vendorID VendorID) []AttributeDef {
+	d.RLock()
+	defer d.RUnlock()
+	return d.attributes(vendorID)
+}
+
+func (d *Dictionary) attributes(vendorID VendorID) []AttributeDef {
+	var attrs []AttributeDef
+	if vendorID > 0 {
+		for name := range d.vsaAttrID[vendorID] {
+			if _, ok := d.aliases[vendorID][name]; !ok {
+				def, _ := d.getVSAAttributeDef(vendorID, name)
+				attrs = append(attrs, def)
+			}
+		}
+	} else {
+		for name := range d.attrID {
+			if _, ok := d.aliases[0][name]; !ok {
+				def, _ := d.getAttributeDef(name)
+				attrs = append(attrs, def)
+			}
+		}
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].ID != attrs[j].ID {
+			return attrs[i].ID < attrs[j].ID
+		}
+		return attrs[i].Name < attrs[j].Name
+	})
+	return attrs
+}
+
+// ExtendedAttributes returns the RFC 6929 extended attributes sorted by
+// Extended-Attribute and Extended-Type, without aliases.
+func (d *Dictionary) ExtendedAttributes() []AttributeDef {
+	d.RLock()
+	defer d.RUnlock()
+	return d.extendedAttributes()
+}
+
+func (d *Dictionary) extendedAttributes() []AttributeDef {
+	var attrs []AttributeDef
+	for name := range d.extAttrID {
+		if _, ok := d.aliases[0][name]; !ok {
+			def, _ := d.getAttributeDef(name)
+			attrs = append(attrs, def)
+		}
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].Extended != attrs[j].Extended {
+			return attrs[i].Extended < attrs[j].Extended
+		}
+		if attrs[i].ID != attrs[j].ID {
+			return attrs[i].ID < attrs[j].ID
+		}
+		return attrs[i].Name < attrs[j].Name
+	})
+	return attrs
+}
+
+// scopeAttributes returns the attributes of a vendor (0 for standard), the
+// standard ones followed by the extended attributes.
+func (d *Dictionary) scopeAttributes(vendorID VendorID) []AttributeDef {
+	attrs := d.attributes(vendorID)
+	if vendorID == 0 {
+		attrs = append(attrs, d.extendedAttributes()...)
+	}
+	return attrs
+}
+
+// Values returns the named values of an attribute or ENUM sorted by value.
+// Use vendor 0 for standard attributes.
+func (d *Dictionary) Values(vendorID VendorID, attrName string) []ValueDef {
+	d.RLock()
+	defer d.RUnlock()
+	return d.values(vendorID, attrName)
+}
+
+func (d *Dictionary) values(vendorID VendorID, attrName string) []ValueDef {
+	var ids map[string]uint32
+	if vendorID > 0 {
+		ids = d.vsaConstID[vendorID][attrName]
+	} else {
+		ids = d.constID[attrName]
+	}
+	values := make([]ValueDef, 0, len(ids))
+	for name, v := range ids {
+		values = append(values, ValueDef{Name: name, Value: v})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Value != values[j].Value {
+			return values[i].Value < values[j].Value
+		}
+		return values[i].Name < values[j].Name
+	})
+	return values
+}
+
+// Enums returns the ENUMs of a vendor (0 for standard) sorted by name.
+func (d *Dictionary) Enums(vendorID VendorID) []EnumDef {
+	d.RLock()
+	defer d.RUnlock()
+	return d.enums(vendorID)
+}
+
+func (d *Dictionary) enums(vendorID VendorID) []EnumDef {
+	enums := make([]EnumDef, 0, len(d.enumType[vendorID]))
+	for name, typ := range d.enumType[vendorID] {
+		enums = append(enums, EnumDef{Name: name, Type: typ})
+	}
+	sort.Slice(enums, func(i, j int) bool { return enums[i].Name < enums[j].Name })
+	return enums
+}
+
+// Aliases returns the ALIAS names of a vendor (0 for standard) sorted by name.
+func (d *Dictionary) Aliases(vendorID VendorID) []AliasDef {
+	d.RLock()
+	defer d.RUnlock()
+	return d.aliasList(vendorID)
+}
+
+func (d *Dictionary) aliasList(vendorID VendorID) []AliasDef {
+	aliases := make([]AliasDef, 0, len(d.aliases[vendorID]))
+	for name, target := range d.aliases[vendorID] {
+		aliases = append(aliases, AliasDef{Name: name, Target: target})
+	}
+	sort.Slice(aliases, func(i, j int) bool { return aliases[i].Name < aliases[j].Name })
+	return aliases
+}
+
+// WriteFreeRADIUS writes the dictionary in canonical FreeRADIUS format:
+// standard definitions first, then one BEGIN-VENDOR block per vendor, each
+// sorted by attribute ID, the extended attributes after the standard ones.
+// The aliases of a vendor are written as VENDOR lines before its name.
+// Loading the output gives an equivalent dictionary, except for STRUCT
+// variants which are not written.
+func (d *Dictionary) WriteFreeRADIUS(w io.Writer) error {
+	d.RLock()
+	defer d.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("# -*- text -*-\n")
+	d.writeScope(bw, 0)
+	for _, v := range d.vendors() {
+		bw.WriteString("\n")
+		for _, name := range append(v.Aliases, v.Name) {
+			line := "VENDOR\t" + name + "\t" + strconv.FormatUint(uint64(v.ID), 10)
+			if v.Format != DefaultVendorFormat {
+				line += "\t" + v.Format.String()
+			}
+			bw.WriteString(line + "\n")
+		}
+		bw.WriteString("BEGIN-VENDOR\t" + v.Name + "\n")
+		d.writeScope(bw, v.ID)
+		bw.WriteString("END-VENDOR\t" + v.Name + "\n")
+	}
+	return bw.Flush()
+}
+
+// writeScope writes the ENUMs, attributes, values and aliases of a vendor.
+func (d *Dictionary) writeScope(bw *bufio.Writer, vendorID VendorID) {
+	for _, e := range d.enums(vendorID) {
+		fmt.Fprintf(bw, "ENUM\t%s\t%s\n", e.Name, e.Type)
+		for _, v := range d.values(vendorID, e.Name) {
+			fmt.Fprintf(bw, "VALUE\t%s\t%s\t%d\n", e.Name, v.Name, v.Value)
+		}
+	}
+
+	attrs := d.scopeAttributes(vendorID)
+	for _, a := range attrs {
+		line := "ATTRIBUTE\t" + a.Name + "\t" + a.idString() + "\t" + a.Type
+		if flags := a.Flags.String(); flags != "" {
+			line += "\t" + flags
+		}
+		bw.WriteString(line + "\n")
+		for _, m := range d.structMembers[d.attrLabel(vendorID, a.Name)] {
+			line := "MEMBER\t" + m.Name + "\t" + m.Type
+			if flags := m.Flags.String(); flags != "" {
+				line += "\t" + flags
+			}
+			bw.WriteString(line + "\n")
+		}
+	}
+
+	for _, a := range attrs {
+		if a.Flags.Enum != "" {
+			// written with the ENUM
+			continue
+		}
+		for _, v := range d.values(vendorID, a.Name) {
+			fmt.Fprintf(bw, "VALUE\t%s\t%s\t%d\n", a.Name, v.Name, v.Value)
+		}
+	}
+
+	for _, a := range d.aliasList(vendorID) {
+		fmt.Fprintf(bw, "ALIAS\t%s\t%s\n", a.Name, a.Target)
+	}
+}
+
+type valueJSON struct {
+	Name  string `json:"name"`
+	Value uint32 `json:"value"`
+}
+
+type attributeJSON struct {
+	Name     string          `json:"name"`
+	ID       uint32          `json:"id"`
+	Type     string          `json:"type"`
+	Extended uint8           `json:"extended,omitempty"`
+	Flags    string          `json:"flags,omitempty"`
+	Values   []valueJSON     `json:"values,omitempty"`
+	Members  []attributeJSON `json:"members,omitempty"`
+}
+
+type enumJSON struct {
+	Name   string      `json:"name"`
+	Type   string      `json:"type"`
+	Values []valueJSON `json:"values,omitempty"`
+}
+
+type aliasJSON struct {
+	Name   string `json:"name"`
+	Target string `json:"target"`
+}
+
+type scopeJSON struct {
+	Attributes []attributeJSON `json:"attributes"`
+	Enums      []enumJSON      `json:"enums,omitempty"`
+	Aliases    []aliasJSON     `json:"aliases,omitempty"`
+}
+
+type vendorJSON struct {
+	Name          string   `json:"name"`
+	VendorAliases []string `json:"vendor_aliases,omitempty"`
+	ID            uint32   `json:"id"`
+	Format        string   `json:"format,omitempty"`
+	scopeJSON
+}
+
+type dictionaryJSON struct {
+	scopeJSON
+	Vendors []vendorJSON `json:"vendors"`
+}
+
+// MarshalJSON encodes the dictionary as a JSON object with the standard
+// "attributes", "enums" and "aliases" and the "vendors" with theirs. Values
+// are listed with the attribute or ENUM they belong to.
+func (d *Dictionary) MarshalJSON() ([]byte, error) {
+	d.RLock()
+	defer d.RUnlock()
+
+	out := dictionaryJSON{scopeJSON: d.scopeJSON(0), Vendors: []vendorJSON{}}
+	for _, v := range d.vendors() {
+		vj := vendorJSON{Name: v.Name, VendorAliases: v.Aliases, ID: uint32(v.ID), scopeJSON: d.scopeJSON(v.ID)}
+		if v.Format != DefaultVendorFormat {
+			vj.Format = v.Format.String()
+		}
+		out.Vendors = append(out.Vendors, vj)
+	}
+	return json.Marshal(out)
+}
+
+func (d *Dictionary) scopeJSON(vendorID VendorID) scopeJSON {
+	scope := scopeJSON{Attributes: []attributeJSON{}}
+	for _, a := range d.scopeAttributes(vendorID) {
+		aj := attributeJSON{Name: a.Name, ID: a.ID, Type: a.Type, Extended: uint8(a.Extended), Flags: a.Flags.String()}
+		if a.Flags.Enum == "" {
+			aj.Values = valuesJSON(d.values(vendorID, a.Name))
+		}
+		for _, m := range d.structMembers[d.attrLabel(vendorID, a.Name)] {
+			aj.Members = append(aj.Members, attributeJSON{Name: m.Name, ID: m.ID, Type: m.Type, Flags: m.Flags.String()})
+		}
+		scope.Attributes = append(scope.Attributes, aj)
+	}
+	for _, e := range d.enums(vendorID) {
+		scope.Enums = append(scope.Enums, enumJSON{Name: e.Name, Type: e.Type, Values: valuesJSON(d.values(vendorID, e.Name))})
+	}
+	for _, a := range d.aliasList(vendorID) {
+		scope.Aliases = append(scope.Aliases, aliasJSON{Name: a.Name, Target: a.Target})
+	}
+	return scope
+}
+
+func valuesJSON(values []ValueDef) []valueJSON {
+	var out []valueJSON
+	for _, v := range values {
+		out = append(out, valueJSON{Name: v.Name, Value: v.Value})
+	}
+	return out
+}
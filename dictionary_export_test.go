@@ -0,0 +1,216 @@
+package radius
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func loadExportDictionary(t *testing.T) *Dictionary {
+	d, err := NewRFCDictionary()
+	if err != nil {
+		t.Fatalf("NewRFCDictionary failed: %v", err)
+	}
+	for _, pack := range VendorPacks() {
+		if err := d.LoadVendorPack(pack); err != nil {
+			t.Fatalf("LoadVendorPack(%s) failed: %v", pack, err)
+		}
+	}
+	return d
+}
+
+func TestDictionaryEnumeration(t *testing.T) {
+	d := loadExportDictionary(t)
+
+	attrs := d.Attributes()
+	if len(attrs) == 0 || attrs[0].Name != "User-Name" || attrs[0].ID != 1 {
+		t.Fatalf("Attributes()[0] = %+v; want User-Name", attrs[0])
+	}
+	for i := 1; i < len(attrs); i++ {
+		if attrs[i].ID < attrs[i-1].ID {
+			t.Fatalf("Attributes() not sorted: %d after %d", attrs[i].ID, attrs[i-1].ID)
+		}
+	}
+
+	vendors := d.Vendors()
+	var names []string
+	for _, v := range vendors {
+		names = append(names, v.Name)
+	}
+	want := "Cisco,Microsoft,Juniper,ADSL-Forum,WISPr,Mikrotik"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("Vendors() = %s; want %s", got, want)
+	}
+
+	vsas := d.VSAAttributes(311)
+	if len(vsas) == 0 || vsas[0].Name != "MS-CHAP-Response" || vsas[0].Vendor != 311 {
+		t.Errorf("VSAAttributes(311)[0] = %+v", vsas[0])
+	}
+
+	values := d.Values(0, "Acct-Status-Type")
+	if len(values) < 3 || values[0] != (ValueDef{Name: "Start", Value: 1}) || values[2] != (ValueDef{Name: "Interim-Update", Value: 3}) {
+		t.Errorf("Values(Acct-Status-Type) = %+v", values)
+	}
+	if values := d.Values(14988, "Mikrotik-Wireless-Enc-Algo"); len(values) != 5 {
+		t.Errorf("Values(Mikrotik-Wireless-Enc-Algo) = %+v", values)
+	}
+}
+
+func TestWriteFreeRADIUSRoundTrip(t *testing.T) {
+	testCases := []struct {
+		name string
+		load func(t *testing.T) *Dictionary
+	}{
+		{"Embedded", loadExportDictionary},
+		{"V4", func(t *testing.T) *Dictionary {
+			d := NewDictionary()
+			if err := d.LoadFS(v4TestFS(), "radius/dictionary"); err != nil {
+				t.Fatalf("LoadFS failed: %v", err)
+			}
+			return d
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := tc.load(t)
+			var first bytes.Buffer
+			if err := d.WriteFreeRADIUS(&first); err != nil {
+				t.Fatalf("WriteFreeRADIUS failed: %v", err)
+			}
+
+			reloaded := NewDictionary()
+			reloaded.SetDiagnosticMode(DiagnosticModeStrict)
+			if err := reloaded.LoadReader("exported", bytes.NewReader(first.Bytes())); err != nil {
+				t.Fatalf("LoadReader of exported dictionary failed: %v", err)
+			}
+			for _, diag := range reloaded.Diagnostics() {
+				t.Errorf("unexpected diagnostic: %s", diag)
+			}
+
+			var second bytes.Buffer
+			if err := reloaded.WriteFreeRADIUS(&second); err != nil {
+				t.Fatalf("WriteFreeRADIUS failed: %v", err)
+			}
+			if first.String() != second.String() {
+				t.Errorf("export differs after reload:\n%s\n---\n%s", first.String(), second.String())
+			}
+		})
+	}
+}
+
+func TestWriteFreeRADIUS(t *testing.T) {
+	d := NewDictionary()
+	// a vendor defined under two names keeps both, the last one resolving
+	content := `
+VENDOR		Acme-Old	9999	format=2,1
+VENDOR		Acme		9999	format=2,1
+ATTRIBUTE	Test-Int	101	integer	has_tag
+VALUE		Test-Int	Two	2
+VALUE		Test-Int	One	1
+ATTRIBUTE	Test-Attr	100	string
+BEGIN-VENDOR	Acme
+ATTRIBUTE	Acme-Attr	300	ipaddr
+END-VENDOR	Acme
+`
+	if err := d.LoadReader("dictionary", strings.NewReader(content)); err != nil {
+		t.Fatalf("LoadReader failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := d.WriteFreeRADIUS(&buf); err != nil {
+		t.Fatalf("WriteFreeRADIUS failed: %v", err)
+	}
+	want := `# -*- text -*-
+ATTRIBUTE	Test-Attr	100	string
+ATTRIBUTE	Test-Int	101	integer	has_tag
+VALUE	Test-Int	One	1
+VALUE	Test-Int	Two	2
+
+VENDOR	Acme-Old	9999	format=2,1
+VENDOR	Acme	9999	format=2,1
+BEGIN-VENDOR	Acme
+ATTRIBUTE	Acme-Attr	300	ipaddr
+END-VENDOR	Acme
+`
+	if buf.String() != want {
+		t.Errorf("WriteFreeRADIUS() =\n%s\nwant\n%s", buf.String(), want)
+	}
+	if vendors := d.Vendors(); len(vendors) != 1 || vendors[0].Name != "Acme" || !reflect.DeepEqual(vendors[0].Aliases, []string{"Acme-Old"}) {
+		t.Errorf("Vendors() = %+v; want Acme with alias Acme-Old", vendors)
+	}
+	reloaded := NewDictionary()
+	if err := reloaded.LoadReader("dictionary", &buf); err != nil {
+		t.Fatalf("loading the output failed: %v", err)
+	}
+	if got, want := reloaded.Vendors(), d.Vendors(); !reflect.DeepEqual(got, want) {
+		t.Errorf("reloaded Vendors() = %+v; want %+v", got, want)
+	}
+}
+
+func TestDictionaryMarshalJSON(t *testing.T) {
+	d := NewDictionary()
+	if err := d.LoadFS(v4TestFS(), "radius/dictionary"); err != nil {
+		t.Fatalf("LoadFS failed: %v", err)
+	}
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+
+	var out struct {
+		Attributes []struct {
+			Name    string `json:"name"`
+			ID      uint32 `json:"id"`
+			Type    string `json:"type"`
+			Flags   string `json:"flags"`
+			Members []struct {
+				Name string `json:"name"`
+			} `json:"members"`
+		} `json:"attributes"`
+		Enums []struct {
+			Name   string `json:"name"`
+			Values []struct {
+				Name  string `json:"name"`
+				Value uint32 `json:"value"`
+			} `json:"values"`
+		} `json:"enums"`
+		Aliases []AliasDef `json:"aliases"`
+		Vendors []struct {
+			Name    string `json:"name"`
+			ID      uint32 `json:"id"`
+			Aliases []struct {
+				Name   string `json:"name"`
+				Target string `json:"target"`
+			} `json:"aliases"`
+		} `json:"vendors"`
+	}
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+
+	if len(out.Attributes) == 0 || out.Attributes[0].Name != "User-Name" {
+		t.Errorf("attributes = %+v", out.Attributes)
+	}
+	var password, structAttr bool
+	for _, a := range out.Attributes {
+		switch a.Name {
+		case "User-Password":
+			password = a.Flags == "encrypt=1"
+		case "Test-Struct":
+			structAttr = len(a.Members) == 2
+		}
+	}
+	if !password || !structAttr {
+		t.Errorf("attributes = %+v", out.Attributes)
+	}
+	if len(out.Enums) != 1 || out.Enums[0].Name != "Service-Kind" || len(out.Enums[0].Values) != 2 {
+		t.Errorf("enums = %+v", out.Enums)
+	}
+	if len(out.Vendors) != 1 || out.Vendors[0].ID != 9 || len(out.Vendors[0].Aliases) != 2 {
+		t.Errorf("vendors = %+v", out.Vendors)
+	}
+}
@@ -0,0 +1,216 @@
+package radius
+
+import (
+	"fmt"
+)
+
+// ResolveAttribute returns the definition of the attribute a path refers to.
+// A path is one of:
+//
+//   - a standard attribute name or number: "User-Name", "1"
+//   - an extended attribute name or number: "Operator-NAS-Identifier", "241.8"
+//   - a vendor attribute name defined by a single vendor: "Cisco-AVPair"
+//   - a vendor and attribute by names or numbers, optionally prefixed with
+//     "Vendor-Specific." or "26.": "Cisco.Cisco-AVPair", "Vendor-Specific.9.1"
+func (d *Dictionary) ResolveAttribute(path string) (AttributeDef, error) {
+	d.RLock()
+	defer d.RUnlock()
+	return d.resolveAttribute(path)
+}
+
+func (d *Dictionary) resolveAttribute(path string) (AttributeDef, error) {
+	vendorID, name, ok := d.resolveAttrPath(0, path)
+	if !ok {
+		vendorID, name, ok = d.findVSAAttribute(path)
+	}
+	if !ok {
+		return AttributeDef{}, fmt.Errorf("attribute %s not found in dictionary", path)
+	}
+	if vendorID > 0 {
+		def, _ := d.getVSAAttributeDef(vendorID, name)
+		return def, nil
+	}
+	def, _ := d.getAttributeDef(name)
+	return def, nil
+}
+
+// findVSAAttribute finds a vendor attribute by name, which must not be
+// defined by several vendors.
+func (d *Dictionary) findVSAAttribute(attrName string) (VendorID, string, bool) {
+	var found VendorID
+	for vendorID, attrs := range d.vsaAttrID {
+		if _, ok := attrs[attrName]; ok {
+			if found > 0 {
+				return 0, "", false
+			}
+			found = vendorID
+		}
+	}
+	return found, attrName, found > 0
+}
+
+// extendedKey identifies an extended attribute by Extended-Attribute and
+// Extended-Type.
+type extendedKey struct {
+	attr AttributeType
+	ext  uint8
+}
+
+// valueKey identifies the VALUE names of an attribute.
+type valueKey struct {
+	vendor VendorID
+	name   string
+}
+
+// valueIDs returns the VALUE names of an attribute, nil when it has none. The
+// map is a copy shared by the callers until VALUEs are defined again, and
+// must not be modified.
+func (d *Dictionary) valueIDs(vendorID VendorID, attrName string) map[string]uint32 {
+	key := valueKey{vendorID, attrName}
+	if values, ok := d.valueCache.Load(key); ok {
+		return values.(map[string]uint32)
+	}
+	var ids map[string]uint32
+	if vendorID > 0 {
+		ids = d.vsaConstID[vendorID][attrName]
+	} else {
+		ids = d.constID[attrName]
+	}
+	var values map[string]uint32
+	if len(ids) > 0 {
+		values = make(map[string]uint32, len(ids))
+		for name, v := range ids {
+			values[name] = v
+		}
+	}
+	d.valueCache.Store(key, values)
+	return values
+}
+
+// resolveTaggedAttribute resolves a path with an optional ":tag" suffix,
+// reporting whether the suffix was present.
+func (d *Dictionary) resolveTaggedAttribute(path string) (AttributeDef, uint8, bool, error) {
+	def, err := d.resolveAttribute(path)
+	if err == nil {
+		return def, 0, false, nil
+	}
+	base, tag, tagErr := splitTag(path)
+	if tagErr != nil || base == path {
+		return AttributeDef{}, 0, false, err
+	}
+	if def, err = d.resolveAttribute(base); err != nil {
+		return AttributeDef{}, 0, false, err
+	}
+	if !def.Flags.HasTag {
+		return AttributeDef{}, 0, false, fmt.Errorf("attribute %s does not support tags", base)
+	}
+	return def, tag, true, nil
+}
+
+// GetPathTemplate creates a template for the attribute a path refers to (see
+// ResolveAttribute). For has_tag attributes the path may carry a tag suffix,
+// e.g. "Tunnel-Type:1".
+func (d *Dictionary) GetPathTemplate(path string) (AVPTemplate, error) {
+	d.RLock()
+	defer d.RUnlock()
+
+	def, tag, _, err := d.resolveTaggedAttribute(path)
+	if err != nil {
+		return nil, err
+	}
+
+	handler, ok := attrTypeHandlers[def.Type]
+	if !ok {
+		return nil, fmt.Errorf("no handler found for type %s", def.Type)
+	}
+
+	if def.Extended > 0 {
+		return &ExtendedTemplate{
+			attrType: def.Extended,
+			extType:  uint8(def.ID),
+			def:      def,
+			tag:      tag,
+			values:   d.valueIDs(0, def.Name),
+		}, nil
+	}
+	if def.Vendor > 0 {
+		return &VSATemplate{
+			vendorID: def.Vendor,
+			vsaType:  VendorAttr(def.ID),
+			format:   d.getVendorFormat(def.Vendor),
+			handler:  handler,
+			def:      def,
+			tag:      tag,
+			values:   d.valueIDs(def.Vendor, def.Name),
+		}, nil
+	}
+	return &AttributeTemplate{
+		attrType: AttributeType(def.ID),
+		handler:  handler,
+		def:      def,
+		tag:      tag,
+		values:   d.valueIDs(0, def.Name),
+	}, nil
+}
+
+// AddAttribute encodes value for the attribute a path refers to (see
+// GetPathTemplate) and adds it to p. Enumerated attributes accept VALUE
+// names, as in AddAttribute(p, "Service-Type", "Framed").
+func (d *Dictionary) AddAttribute(p *Packet, path string, value string) error {
+	t, err := d.GetPathTemplate(path)
+	if err != nil {
+		return err
+	}
+	return t.AddValue(p, value)
+}
+
+// ParseAVP encodes a value of a standard attribute like NewAVP, accepting
+// VALUE names for enumerated attributes and reporting unknown attributes and
+// invalid values. Encrypted attributes fail with ErrEncryptWithoutPacket.
+//
+// Extended attributes are encoded with their Extended-Type header, and fail
+// when the value needs several attributes: use AddAttribute for them.
+func (d *Dictionary) ParseAVP(attrName string, attrValue string) (AVP, error) {
+	d.RLock()
+	defer d.RUnlock()
+
+	def, ok := d.getAttributeDef(attrName)
+	if !ok {
+		return AVP{}, fmt.Errorf("attribute %s not found in dictionary", attrName)
+	}
+	value, err := def.encodeValue(nil, 0, attrValue, d.valueIDs(0, attrName))
+	if err != nil {
+		return AVP{}, err
+	}
+	if def.Extended > 0 {
+		e := ExtendedAttr{Type: def.Extended, ExtType: uint8(def.ID), Value: value}
+		if len(value) > e.maxValueSize() {
+			return AVP{}, fmt.Errorf("value too long for attribute %s", attrName)
+		}
+		return e.ToAVPs()[0], nil
+	}
+	return AVP{Type: AttributeType(def.ID), Value: value}, nil
+}
+
+// ParseVSA encodes a value of a vendor-specific attribute like NewVSA,
+// accepting VALUE names for enumerated attributes and reporting unknown
+// attributes and invalid values. Encrypted attributes fail with
+// ErrEncryptWithoutPacket.
+func (d *Dictionary) ParseVSA(vendorName string, attrName string, attrValue string) (VSA, error) {
+	d.RLock()
+	defer d.RUnlock()
+
+	vendorID, ok := d.vendorID[vendorName]
+	if !ok {
+		return VSA{}, fmt.Errorf("vendor %s not found in dictionary", vendorName)
+	}
+	def, ok := d.getVSAAttributeDef(vendorID, attrName)
+	if !ok {
+		return VSA{}, fmt.Errorf("VSA attribute %s not found for vendor %s", attrName, vendorName)
+	}
+	value, err := def.encodeValue(nil, 0, attrValue, d.valueIDs(vendorID, attrName))
+	if err != nil {
+		return VSA{}, err
+	}
+	return VSA{Vendor: vendorID, Type: VendorAttr(def.ID), Value: value}, nil
+}
@@ -0,0 +1,189 @@
+package radius
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newResolveTestDictionary(t *testing.T) *Dictionary {
+	t.Helper()
+	d, err := NewRFCDictionary()
+	if err != nil {
+		t.Fatalf("NewRFCDictionary failed: %v", err)
+	}
+	for _, pack := range []string{"cisco", "microsoft"} {
+		if err := d.LoadVendorPack(pack); err != nil {
+			t.Fatalf("LoadVendorPack(%s) failed: %v", pack, err)
+		}
+	}
+	return d
+}
+
+func TestResolveAttribute(t *testing.T) {
+	d := newResolveTestDictionary(t)
+
+	testCases := []struct {
+		path   string
+		vendor VendorID
+		name   string
+	}{
+		{"User-Name", 0, "User-Name"},
+		{"1", 0, "User-Name"},
+		{"Cisco-AVPair", 9, "Cisco-AVPair"},
+		{"Cisco.Cisco-AVPair", 9, "Cisco-AVPair"},
+		{"Cisco.1", 9, "Cisco-AVPair"},
+		{"9.1", 9, "Cisco-AVPair"},
+		{"Vendor-Specific.9.1", 9, "Cisco-AVPair"},
+		{"26.Microsoft.MS-CHAP-Challenge", 311, "MS-CHAP-Challenge"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			def, err := d.ResolveAttribute(tc.path)
+			if err != nil {
+				t.Fatalf("ResolveAttribute(%s) failed: %v", tc.path, err)
+			}
+			if def.Vendor != tc.vendor || def.Name != tc.name {
+				t.Errorf("ResolveAttribute(%s) = %d.%s; want %d.%s", tc.path, def.Vendor, def.Name, tc.vendor, tc.name)
+			}
+		})
+	}
+
+	for _, path := range []string{"", "No-Such-Attribute", "Cisco.No-Such", "Vendor-Specific.9.9999", "300"} {
+		if _, err := d.ResolveAttribute(path); err == nil {
+			t.Errorf("ResolveAttribute(%q) succeeded", path)
+		}
+	}
+}
+
+func TestParseAVP(t *testing.T) {
+	d := newResolveTestDictionary(t)
+
+	testCases := []struct {
+		attr  string
+		value string
+		want  []byte
+	}{
+		{"Service-Type", "Framed-User", []byte{0, 0, 0, 2}},
+		{"Service-Type", "2", []byte{0, 0, 0, 2}},
+		{"Tunnel-Type", "L2F", []byte{0, 0, 0, 2}},
+		{"User-Name", "Framed-User", []byte("Framed-User")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.attr+"="+tc.value, func(t *testing.T) {
+			avp, err := d.ParseAVP(tc.attr, tc.value)
+			if err != nil {
+				t.Fatalf("ParseAVP failed: %v", err)
+			}
+			if !bytes.Equal(avp.Value, tc.want) {
+				t.Errorf("ParseAVP(%s, %s) = %v; want %v", tc.attr, tc.value, avp.Value, tc.want)
+			}
+		})
+	}
+
+	if avp := d.NewAVP("Service-Type", "Framed-User"); !bytes.Equal(avp.Value, []byte{0, 0, 0, 2}) {
+		t.Errorf("NewAVP(Service-Type, Framed-User) = %v", avp.Value)
+	}
+
+	for _, tc := range []struct{ attr, value string }{
+		{"Service-Type", "Not-A-Service"},
+		{"Framed-IP-Address", "not-an-ip"},
+		{"NAS-Port", "-1"},
+		{"No-Such-Attribute", "x"},
+	} {
+		if _, err := d.ParseAVP(tc.attr, tc.value); err == nil {
+			t.Errorf("ParseAVP(%s, %s) succeeded", tc.attr, tc.value)
+		}
+	}
+
+	// encrypted attributes need a packet, never encode them in clear
+	if _, err := d.ParseAVP("User-Password", "s3cret"); !errors.Is(err, ErrEncryptWithoutPacket) {
+		t.Errorf("ParseAVP(User-Password) error = %v; want ErrEncryptWithoutPacket", err)
+	}
+	if avp := d.NewAVP("Tunnel-Password", "s3cret"); avp.Value != nil {
+		t.Errorf("NewAVP(Tunnel-Password) = %v; want empty AVP", avp.Value)
+	}
+}
+
+func TestParseVSA(t *testing.T) {
+	d := newResolveTestDictionary(t)
+
+	vsa, err := d.ParseVSA("Microsoft", "MS-MPPE-Encryption-Policy", "Encryption-Required")
+	if err != nil {
+		t.Fatalf("ParseVSA failed: %v", err)
+	}
+	if vsa.Vendor != 311 || vsa.Type != 7 || !bytes.Equal(vsa.Value, []byte{0, 0, 0, 2}) {
+		t.Errorf("ParseVSA() = %+v", vsa)
+	}
+
+	if _, err := d.ParseVSA("Microsoft", "MS-MPPE-Encryption-Policy", "Sometimes"); err == nil {
+		t.Errorf("ParseVSA with an unknown value name succeeded")
+	}
+	if _, err := d.ParseVSA("Acme", "Acme-Thing", "1"); err == nil {
+		t.Errorf("ParseVSA with an unknown vendor succeeded")
+	}
+}
+
+func TestAddAttribute(t *testing.T) {
+	d := newResolveTestDictionary(t)
+	p := Request(AccessRequest, "secret")
+
+	adds := []struct{ path, value string }{
+		{"Service-Type", "Framed-User"},
+		{"Tunnel-Type:3", "L2F"},
+		{"Cisco.Cisco-AVPair", "shell:priv-lvl=15"},
+		{"Vendor-Specific.311.7", "Encryption-Allowed"},
+	}
+	for _, a := range adds {
+		if err := d.AddAttribute(p, a.path, a.value); err != nil {
+			t.Fatalf("AddAttribute(%s, %s) failed: %v", a.path, a.value, err)
+		}
+	}
+
+	if avp := p.GetAVP(AttrServiceType); avp == nil || !bytes.Equal(avp.Value, []byte{0, 0, 0, 2}) {
+		t.Errorf("Service-Type = %v", avp)
+	}
+	if avp := p.GetAVP(AttrTunnelType); avp == nil || !bytes.Equal(avp.Value, []byte{3, 0, 0, 2}) {
+		t.Errorf("Tunnel-Type = %v", avp)
+	}
+	if vsa := p.GetVSA(9, 1); vsa == nil || string(vsa.Value) != "shell:priv-lvl=15" {
+		t.Errorf("Cisco-AVPair = %+v", vsa)
+	}
+	if vsa := p.GetVSA(311, 7); vsa == nil || !bytes.Equal(vsa.Value, []byte{0, 0, 0, 1}) {
+		t.Errorf("MS-MPPE-Encryption-Policy = %+v", vsa)
+	}
+
+	failures := []struct{ path, value string }{
+		{"Service-Type", "Bogus"},
+		{"User-Name:1", "alice"},
+		{"Nope.Nope", "x"},
+	}
+	for _, f := range failures {
+		if err := d.AddAttribute(p, f.path, f.value); err == nil {
+			t.Errorf("AddAttribute(%s, %s) succeeded", f.path, f.value)
+		}
+	}
+}
+
+func TestValueIDs(t *testing.T) {
+	d := newResolveTestDictionary(t)
+	values := d.valueIDs(0, "Service-Type")
+	if values["Framed-User"] != 2 {
+		t.Fatalf("Service-Type values = %v", values)
+	}
+	// the lookups share one read-only map
+	if again := d.valueIDs(0, "Service-Type"); reflect.ValueOf(again).Pointer() != reflect.ValueOf(values).Pointer() {
+		t.Error("valueIDs() copied the values again")
+	}
+	if err := d.LoadReader("local", strings.NewReader("VALUE Service-Type Local-User 99\n")); err != nil {
+		t.Fatalf("LoadReader failed: %v", err)
+	}
+	if _, ok := values["Local-User"]; ok {
+		t.Error("a later VALUE changed the map returned before")
+	}
+	if v := d.valueIDs(0, "Service-Type")["Local-User"]; v != 99 {
+		t.Errorf("Local-User = %d; want 99", v)
+	}
+}
@@ -0,0 +1,367 @@
+package radius
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// DictionarySnapshot is an immutable, compiled copy of a Dictionary. Its
+// lookups take no lock, which makes it suited to the hot path of servers and
+// proxies; standard attributes are indexed by type in a fixed array and the
+// data type handlers are built once.
+//
+// A snapshot never changes: load a new Dictionary and compile it again (see
+// ReloadableDictionary) to pick up new definitions.
+type DictionarySnapshot struct {
+	attrByID   [256]*snapshotAttr
+	attrByName map[string]*snapshotAttr
+	// extended attributes, also in attrByName
+	extAttrByID map[extendedKey]*snapshotAttr
+	// attribute name -> value -> value name
+	values map[string]map[uint32]string
+
+	vendorByName map[string]VendorID
+	vendors      map[VendorID]*snapshotVendor
+}
+
+type snapshotAttr struct {
+	def     AttributeDef
+	handler avpDataType
+}
+
+type snapshotVendor struct {
+	name       string
+	format     VendorFormat
+	attrByID   map[VendorAttr]*snapshotAttr
+	attrByName map[string]*snapshotAttr
+	values     map[string]map[uint32]string
+}
+
+func newSnapshotAttr(def AttributeDef) *snapshotAttr {
+	handler := def.handler()
+	if handler == nil {
+		handler = avpBinary
+	}
+	return &snapshotAttr{def: def, handler: handler}
+}
+
+// Snapshot compiles the current definitions of d into an immutable
+// DictionarySnapshot. Later loads into d don't affect the snapshot.
+func (d *Dictionary) Snapshot() *DictionarySnapshot {
+	d.RLock()
+	defer d.RUnlock()
+
+	s := &DictionarySnapshot{
+		attrByName:   make(map[string]*snapshotAttr, len(d.attrID)+len(d.extAttrID)),
+		extAttrByID:  make(map[extendedKey]*snapshotAttr, len(d.extAttrName)),
+		values:       copyValueNames(d.constName),
+		vendorByName: make(map[string]VendorID, len(d.vendorID)),
+		vendors:      make(map[VendorID]*snapshotVendor, len(d.vendorName)),
+	}
+
+	for name := range d.attrID {
+		def, _ := d.getAttributeDef(name)
+		s.attrByName[name] = newSnapshotAttr(def)
+	}
+	for id, name := range d.attrName {
+		s.attrByID[id] = s.attrByName[name]
+	}
+	for name := range d.extAttrID {
+		def, _ := d.getAttributeDef(name)
+		s.attrByName[name] = newSnapshotAttr(def)
+	}
+	for key, name := range d.extAttrName {
+		s.extAttrByID[key] = s.attrByName[name]
+	}
+
+	for name, id := range d.vendorID {
+		s.vendorByName[name] = id
+		s.vendor(id)
+	}
+	for id, name := range d.vendorName {
+		s.vendor(id).name = name
+	}
+	for id, f := range d.vendorFormat {
+		s.vendor(id).format = f
+	}
+	for id, attrs := range d.vsaAttrID {
+		v := s.vendor(id)
+		for name := range attrs {
+			def, _ := d.getVSAAttributeDef(id, name)
+			v.attrByName[name] = newSnapshotAttr(def)
+		}
+		for attrID, name := range d.vsaAttrName[id] {
+			v.attrByID[attrID] = v.attrByName[name]
+		}
+		v.values = copyValueNames(d.vsaConstName[id])
+	}
+	return s
+}
+
+// vendor returns the vendor entry of id, adding it while compiling.
+func (s *DictionarySnapshot) vendor(id VendorID) *snapshotVendor {
+	v, ok := s.vendors[id]
+	if !ok {
+		v = &snapshotVendor{
+			format:     DefaultVendorFormat,
+			attrByID:   make(map[VendorAttr]*snapshotAttr),
+			attrByName: make(map[string]*snapshotAttr),
+		}
+		s.vendors[id] = v
+	}
+	return v
+}
+
+// copyValueNames copies the value names so that ENUMs shared by several
+// attributes of the dictionary don't leak later changes into the snapshot.
+func copyValueNames(src map[string]map[uint32]string) map[string]map[uint32]string {
+	dst := make(map[string]map[uint32]string, len(src))
+	for attrName, names := range src {
+		m := make(map[uint32]string, len(names))
+		for v, name := range names {
+			m[v] = name
+		}
+		dst[attrName] = m
+	}
+	return dst
+}
+
+// GetAttributeID returns the AttributeType for an attribute name, 0 for
+// extended attributes.
+func (s *DictionarySnapshot) GetAttributeID(attrName string) AttributeType {
+	if a, ok := s.attrByName[attrName]; ok && !a.def.IsExtended() {
+		return AttributeType(a.def.ID)
+	}
+	return 0
+}
+
+// HasAttribute reports whether the snapshot defines the given attribute
+// name, standard or extended.
+func (s *DictionarySnapshot) HasAttribute(attrName string) bool {
+	_, ok := s.attrByName[attrName]
+	return ok
+}
+
+// GetAttributeName returns the attribute name for an AttributeType.
+func (s *DictionarySnapshot) GetAttributeName(attrID AttributeType) string {
+	if a := s.attrByID[attrID]; a != nil {
+		return a.def.Name
+	}
+	return ""
+}
+
+// GetAttributeType returns the type name for an attribute name.
+func (s *DictionarySnapshot) GetAttributeType(attrName string) string {
+	if a, ok := s.attrByName[attrName]; ok {
+		return a.def.Type
+	}
+	return ""
+}
+
+// GetAttributeDef returns the definition of a standard or extended attribute
+// by name.
+func (s *DictionarySnapshot) GetAttributeDef(attrName string) (AttributeDef, bool) {
+	if a, ok := s.attrByName[attrName]; ok {
+		return a.def, true
+	}
+	return AttributeDef{}, false
+}
+
+// GetAttributeDefByID returns the definition of a standard attribute by type.
+func (s *DictionarySnapshot) GetAttributeDefByID(attrID AttributeType) (AttributeDef, bool) {
+	if a := s.attrByID[attrID]; a != nil {
+		return a.def, true
+	}
+	return AttributeDef{}, false
+}
+
+// GetExtendedAttributeDefByID returns the definition of an extended attribute
+// by Extended-Attribute and Extended-Type.
+func (s *DictionarySnapshot) GetExtendedAttributeDefByID(attrType AttributeType, extType uint8) (AttributeDef, bool) {
+	if a, ok := s.extAttrByID[extendedKey{attrType, extType}]; ok {
+		return a.def, true
+	}
+	return AttributeDef{}, false
+}
+
+// GetVSAAttributeID returns the vendor-specific attribute ID for a vendor and attribute name.
+func (s *DictionarySnapshot) GetVSAAttributeID(vendorID VendorID, attrName string) VendorAttr {
+	if a := s.vsaAttr(vendorID, attrName); a != nil {
+		return VendorAttr(a.def.ID)
+	}
+	return 0
+}
+
+// HasVSAAttribute reports whether the snapshot defines the given vendor-specific attribute.
+func (s *DictionarySnapshot) HasVSAAttribute(vendorID VendorID, attrName string) bool {
+	return s.vsaAttr(vendorID, attrName) != nil
+}
+
+// GetVSAAttributeName returns the attribute name for a vendor-specific attribute ID.
+func (s *DictionarySnapshot) GetVSAAttributeName(vendorID VendorID, attrID VendorAttr) string {
+	if def, ok := s.GetVSAAttributeDefByID(vendorID, attrID); ok {
+		return def.Name
+	}
+	return ""
+}
+
+// GetVSAAttributeType returns the type name for a vendor-specific attribute.
+func (s *DictionarySnapshot) GetVSAAttributeType(vendorID VendorID, attrName string) string {
+	if a := s.vsaAttr(vendorID, attrName); a != nil {
+		return a.def.Type
+	}
+	return ""
+}
+
+// GetVSAAttributeDef returns the definition of a vendor-specific attribute by name.
+func (s *DictionarySnapshot) GetVSAAttributeDef(vendorID VendorID, attrName string) (AttributeDef, bool) {
+	if a := s.vsaAttr(vendorID, attrName); a != nil {
+		return a.def, true
+	}
+	return AttributeDef{}, false
+}
+
+// GetVSAAttributeDefByID returns the definition of a vendor-specific attribute by type.
+func (s *DictionarySnapshot) GetVSAAttributeDefByID(vendorID VendorID, attrID VendorAttr) (AttributeDef, bool) {
+	if v, ok := s.vendors[vendorID]; ok {
+		if a, ok := v.attrByID[attrID]; ok {
+			return a.def, true
+		}
+	}
+	return AttributeDef{}, false
+}
+
+func (s *DictionarySnapshot) vsaAttr(vendorID VendorID, attrName string) *snapshotAttr {
+	if v, ok := s.vendors[vendorID]; ok {
+		return v.attrByName[attrName]
+	}
+	return nil
+}
+
+// GetVendorID returns the VendorID for a vendor name.
+func (s *DictionarySnapshot) GetVendorID(vendorName string) VendorID {
+	return s.vendorByName[vendorName]
+}
+
+// GetVendorName returns the vendor name for a VendorID.
+func (s *DictionarySnapshot) GetVendorName(vendorID VendorID) string {
+	if v, ok := s.vendors[vendorID]; ok {
+		return v.name
+	}
+	return ""
+}
+
+// GetVendorFormat returns the VSA layout declared for a vendor, or
+// DefaultVendorFormat when the vendor has no format= option.
+func (s *DictionarySnapshot) GetVendorFormat(vendorID VendorID) VendorFormat {
+	if v, ok := s.vendors[vendorID]; ok {
+		return v.format
+	}
+	return DefaultVendorFormat
+}
+
+// DecodeAVPValue returns a human-readable string for the given AVP, like
+// Dictionary.DecodeAVPValue.
+func (s *DictionarySnapshot) DecodeAVPValue(p *Packet, a AVP) string {
+	return decodeAVPValue(s, p, a)
+}
+
+func (s *DictionarySnapshot) attrHandler(attrID AttributeType) (AttributeDef, avpDataType) {
+	if a := s.attrByID[attrID]; a != nil {
+		return a.def, a.handler
+	}
+	return AttributeDef{}, nil
+}
+
+func (s *DictionarySnapshot) vsaAttrHandler(vendorID VendorID, attrID VendorAttr) (AttributeDef, avpDataType) {
+	if v, ok := s.vendors[vendorID]; ok {
+		if a, ok := v.attrByID[attrID]; ok {
+			return a.def, a.handler
+		}
+	}
+	return AttributeDef{}, nil
+}
+
+func (s *DictionarySnapshot) extAttrHandler(attrType AttributeType, extType uint8) (AttributeDef, avpDataType) {
+	if a, ok := s.extAttrByID[extendedKey{attrType, extType}]; ok {
+		return a.def, a.handler
+	}
+	return AttributeDef{}, nil
+}
+
+func (s *DictionarySnapshot) lookupConstName(vendorID VendorID, attrName string, v uint32) (string, bool) {
+	values := s.values
+	if vendorID > 0 {
+		vendor, ok := s.vendors[vendorID]
+		if !ok {
+			return "", false
+		}
+		values = vendor.values
+	}
+	name, ok := values[attrName][v]
+	return name, ok
+}
+
+// typeDesc returns the name and compiled handler of a standard attribute.
+func (s *DictionarySnapshot) typeDesc(t AttributeType) (attributeTypeDesc, bool) {
+	if a := s.attrByID[t]; a != nil {
+		return attributeTypeDesc{name: a.def.Name, dataType: a.handler}, true
+	}
+	return attributeTypeDesc{}, false
+}
+
+// ReloadableDictionary holds the current DictionarySnapshot and replaces it
+// atomically on Reload. Readers calling Snapshot never block, not even while
+// a reload is in progress, and keep a consistent view for as long as they
+// hold the returned snapshot.
+type ReloadableDictionary struct {
+	load func() (*Dictionary, error)
+	// serializes reloads
+	mu      sync.Mutex
+	current atomic.Pointer[DictionarySnapshot]
+}
+
+// NewReloadableDictionary builds the first snapshot with load, which must
+// return a newly loaded Dictionary on every call.
+func NewReloadableDictionary(load func() (*Dictionary, error)) (*ReloadableDictionary, error) {
+	r := &ReloadableDictionary{load: load}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// NewReloadableDictionaryFile returns a ReloadableDictionary reading the
+// dictionary file fname (and its includes) from disk on every Reload.
+func NewReloadableDictionaryFile(fname string) (*ReloadableDictionary, error) {
+	return NewReloadableDictionary(func() (*Dictionary, error) {
+		d := NewDictionary()
+		if err := d.LoadFile(fname); err != nil {
+			return nil, err
+		}
+		return d, nil
+	})
+}
+
+// Snapshot returns the current snapshot.
+func (r *ReloadableDictionary) Snapshot() *DictionarySnapshot {
+	return r.current.Load()
+}
+
+// Reload loads the dictionary again, compiles it and swaps it in, also as
+// the default snapshot when the current one is (see SetDefaultSnapshot). On
+// error the current snapshot is kept.
+func (r *ReloadableDictionary) Reload() (*DictionarySnapshot, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	s := d.Snapshot()
+	if old := r.current.Swap(s); old != nil {
+		defaultSnapshot.CompareAndSwap(old, s)
+	}
+	return s, nil
+}
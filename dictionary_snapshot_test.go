@@ -0,0 +1,243 @@
+package radius
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDictionarySnapshot(t *testing.T) {
+	d, err := NewRFCDictionary()
+	if err != nil {
+		t.Fatalf("NewRFCDictionary failed: %v", err)
+	}
+	if err := d.LoadVendorPack("microsoft"); err != nil {
+		t.Fatalf("LoadVendorPack failed: %v", err)
+	}
+	s := d.Snapshot()
+
+	for _, want := range d.Attributes() {
+		if def, ok := s.GetAttributeDefByID(AttributeType(want.ID)); !ok || def != want {
+			t.Errorf("GetAttributeDefByID(%d) = %v, %v; want %v", want.ID, def, ok, want)
+		}
+		if def, ok := s.GetAttributeDef(want.Name); !ok || def != want {
+			t.Errorf("GetAttributeDef(%s) = %v, %v; want %v", want.Name, def, ok, want)
+		}
+	}
+	for _, v := range d.Vendors() {
+		if id := s.GetVendorID(v.Name); id != v.ID {
+			t.Errorf("GetVendorID(%s) = %d; want %d", v.Name, id, v.ID)
+		}
+		if name := s.GetVendorName(v.ID); name != v.Name {
+			t.Errorf("GetVendorName(%d) = %s; want %s", v.ID, name, v.Name)
+		}
+		if f := s.GetVendorFormat(v.ID); f != v.Format {
+			t.Errorf("GetVendorFormat(%d) = %v; want %v", v.ID, f, v.Format)
+		}
+		for _, want := range d.VSAAttributes(v.ID) {
+			if def, ok := s.GetVSAAttributeDefByID(v.ID, VendorAttr(want.ID)); !ok || def != want {
+				t.Errorf("GetVSAAttributeDefByID(%d, %d) = %v, %v; want %v", v.ID, want.ID, def, ok, want)
+			}
+		}
+	}
+
+	testCases := []struct {
+		name string
+		avp  AVP
+	}{
+		{"enum", AVP{Type: AttrServiceType, Value: []byte{0, 0, 0, 2}}},
+		{"unknown enum", AVP{Type: AttrServiceType, Value: []byte{0, 0, 1, 2}}},
+		{"string", AVP{Type: AttrUserName, Value: []byte("alice")}},
+		{"vsa", VSA{Vendor: 311, Type: 7, Value: []byte{0, 0, 0, 1}}.ToAVP()},
+		{"unknown", AVP{Type: 250, Value: []byte{1, 2}}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			want := d.DecodeAVPValue(nil, tc.avp)
+			if got := s.DecodeAVPValue(nil, tc.avp); got != want {
+				t.Errorf("DecodeAVPValue() = %s; want %s", got, want)
+			}
+		})
+	}
+
+	if name := s.GetAttributeName(250); name != "" {
+		t.Errorf("GetAttributeName(250) = %s; want empty", name)
+	}
+	if s.HasVSAAttribute(9, "Cisco-AVPair") {
+		t.Errorf("HasVSAAttribute(Cisco-AVPair) = true before loading Cisco")
+	}
+}
+
+func TestDictionarySnapshotImmutable(t *testing.T) {
+	d := NewDictionary()
+	if err := d.LoadReader("dictionary.a", strings.NewReader(
+		"ATTRIBUTE Color 200 integer\nVALUE Color Red 1\n")); err != nil {
+		t.Fatalf("LoadReader failed: %v", err)
+	}
+	s := d.Snapshot()
+
+	if err := d.LoadReader("dictionary.b", strings.NewReader(
+		"ATTRIBUTE Shape 201 integer\nVALUE Color Green 2\n")); err != nil {
+		t.Fatalf("LoadReader failed: %v", err)
+	}
+
+	if s.HasAttribute("Shape") {
+		t.Errorf("snapshot sees attribute loaded after Snapshot()")
+	}
+	avp := AVP{Type: 200, Value: []byte{0, 0, 0, 2}}
+	if got := s.DecodeAVPValue(nil, avp); got == "Green" {
+		t.Errorf("snapshot sees value loaded after Snapshot()")
+	}
+	if got := d.Snapshot().DecodeAVPValue(nil, avp); got != "Green" {
+		t.Errorf("DecodeAVPValue() = %s; want Green", got)
+	}
+}
+
+func TestReloadableDictionary(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	fname := filepath.Join(t.TempDir(), "dictionary")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(fname, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	write("ATTRIBUTE Color 200 integer\n")
+	r, err := NewReloadableDictionaryFile(fname)
+	if err != nil {
+		t.Fatalf("NewReloadableDictionaryFile failed: %v", err)
+	}
+	first := r.Snapshot()
+	if id := first.GetAttributeID("Color"); id != 200 {
+		t.Fatalf("GetAttributeID(Color) = %d; want 200", id)
+	}
+
+	write("ATTRIBUTE Color 202 integer\n")
+	second, err := r.Reload()
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if r.Snapshot() != second {
+		t.Errorf("Snapshot() is not the reloaded snapshot")
+	}
+	if id := second.GetAttributeID("Color"); id != 202 {
+		t.Errorf("GetAttributeID(Color) = %d after reload; want 202", id)
+	}
+	if id := first.GetAttributeID("Color"); id != 200 {
+		t.Errorf("old snapshot changed: GetAttributeID(Color) = %d; want 200", id)
+	}
+
+	write("ATTRIBUTE Color\n")
+	if _, err := r.Reload(); err == nil {
+		t.Errorf("Reload of an invalid dictionary succeeded")
+	}
+	if r.Snapshot() != second {
+		t.Errorf("failed Reload replaced the snapshot")
+	}
+
+	if _, err := NewReloadableDictionaryFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("NewReloadableDictionaryFile of a missing file succeeded")
+	}
+}
+
+func TestReloadableDictionaryDefault(t *testing.T) {
+	color := "Color"
+	r, err := NewReloadableDictionary(func() (*Dictionary, error) {
+		d := NewDictionary()
+		return d, d.LoadReader("dictionary", strings.NewReader("ATTRIBUTE "+color+" 200 integer\n"))
+	})
+	if err != nil {
+		t.Fatalf("NewReloadableDictionary failed: %v", err)
+	}
+	SetDefaultSnapshot(r.Snapshot())
+	defer SetDefaultSnapshot(nil)
+
+	color = "Colour"
+	if _, err := r.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if GetDefaultSnapshot() != r.Snapshot() {
+		t.Errorf("Reload did not publish the default snapshot")
+	}
+	if name := getAttributeTypeDesc(200).name; name != "Colour" {
+		t.Errorf("attribute 200 = %s after reload; want Colour", name)
+	}
+
+	// a reloadable dictionary which is not the default leaves it alone
+	other, err := NewReloadableDictionary(NewRFCDictionary)
+	if err != nil {
+		t.Fatalf("NewReloadableDictionary failed: %v", err)
+	}
+	if _, err := other.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if GetDefaultSnapshot() != r.Snapshot() {
+		t.Errorf("Reload of another dictionary replaced the default snapshot")
+	}
+}
+
+func TestReloadableDictionaryConcurrent(t *testing.T) {
+	r, err := NewReloadableDictionary(NewRFCDictionary)
+	if err != nil {
+		t.Fatalf("NewReloadableDictionary failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if name := r.Snapshot().GetAttributeName(AttrUserName); name != "User-Name" {
+					t.Errorf("GetAttributeName(1) = %s; want User-Name", name)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := r.Reload(); err != nil {
+			t.Errorf("Reload failed: %v", err)
+		}
+	}
+	wg.Wait()
+}
+
+func TestDefaultSnapshot(t *testing.T) {
+	d := NewDictionary()
+	if err := d.LoadReader("dictionary.test", strings.NewReader(
+		"ATTRIBUTE Color 200 integer\nVALUE Color Red 1\n"+
+			"VENDOR Wide 4242 format=2,1\n")); err != nil {
+		t.Fatalf("LoadReader failed: %v", err)
+	}
+	s := d.Snapshot()
+
+	SetDefaultSnapshot(s)
+	defer SetDefaultSnapshot(nil)
+
+	if GetDefaultSnapshot() != s {
+		t.Errorf("GetDefaultSnapshot() is not the snapshot set")
+	}
+	if name := AttributeType(200).String(); name != "Color" {
+		t.Errorf("AttributeType(200).String() = %s; want Color", name)
+	}
+	// the builtin User-Name is not in the snapshot
+	if name := AttrUserName.String(); name != "Unknown 1" {
+		t.Errorf("AttrUserName.String() = %s; want Unknown 1", name)
+	}
+	if f := vendorFormat(4242); f.TypeSize != 2 {
+		t.Errorf("vendorFormat(4242) = %v; want format=2,1", f)
+	}
+
+	SetDefaultSnapshot(nil)
+	if name := AttrUserName.String(); name != "User-Name" {
+		t.Errorf("AttrUserName.String() = %s after clearing the snapshot; want User-Name", name)
+	}
+}
@@ -1,10 +1,13 @@
 package radius
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
+	"testing/fstest"
 )
 
 func TestLoadFile(t *testing.T) {
@@ -129,3 +132,152 @@ func TestConcurrency(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestLoadFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"etc/dictionary": &fstest.MapFile{Data: []byte(`
+ATTRIBUTE	Test-Attr-String	100	string
+$INCLUDE	vendor/dictionary.testvendor
+`)},
+		"etc/vendor/dictionary.testvendor": &fstest.MapFile{Data: []byte(`
+VENDOR		TestVendor	9999
+BEGIN-VENDOR	TestVendor
+ATTRIBUTE	Test-Vendor-Attr	1	string
+END-VENDOR	TestVendor
+$INCLUDE	../dictionary
+$INCLUDE	dictionary.other
+`)},
+		"etc/vendor/dictionary.other": &fstest.MapFile{Data: []byte(`
+ATTRIBUTE	Test-Attr-Other		101	integer
+`)},
+	}
+
+	d := NewDictionary()
+	if err := d.LoadFS(fsys, "etc/dictionary"); err != nil {
+		t.Fatalf("LoadFS failed: %v", err)
+	}
+
+	if id := d.GetAttributeID("Test-Attr-String"); id != 100 {
+		t.Errorf("GetAttributeID(Test-Attr-String) = %d; want 100", id)
+	}
+	if id := d.GetAttributeID("Test-Attr-Other"); id != 101 {
+		t.Errorf("GetAttributeID(Test-Attr-Other) = %d; want 101", id)
+	}
+	if id := d.GetVSAAttributeID(9999, "Test-Vendor-Attr"); id != 1 {
+		t.Errorf("GetVSAAttributeID(Test-Vendor-Attr) = %d; want 1", id)
+	}
+
+	if err := d.LoadFS(fsys, "etc/missing"); err == nil {
+		t.Error("LoadFS(etc/missing) expected error")
+	}
+
+	// the include guard is kept per call: a file system which cannot be
+	// compared is still read once through its cyclic includes
+	wrapped := struct {
+		fstest.MapFS
+		tags []string
+	}{MapFS: fsys}
+	if err := d.LoadFS(wrapped, "etc/dictionary"); err != nil {
+		t.Fatalf("LoadFS failed: %v", err)
+	}
+
+	// the same name is read again by another call, from another fs.FS or
+	// the OS file system
+	other := fstest.MapFS{
+		"etc/dictionary": &fstest.MapFile{Data: []byte("ATTRIBUTE Test-Attr-FS 102 string\n")},
+	}
+	if err := d.LoadFS(other, "etc/dictionary"); err != nil {
+		t.Fatalf("LoadFS failed: %v", err)
+	}
+	if err := d.LoadReader("etc/dictionary", strings.NewReader("ATTRIBUTE Test-Attr-OS 103 string\n")); err != nil {
+		t.Fatalf("LoadReader failed: %v", err)
+	}
+	for _, name := range []string{"Test-Attr-FS", "Test-Attr-OS"} {
+		if !d.HasAttribute(name) {
+			t.Errorf("%s not loaded: etc/dictionary of another source skipped", name)
+		}
+	}
+}
+
+func TestLoadReader(t *testing.T) {
+	tmpDir := t.TempDir()
+	vendorDictContent := `
+VENDOR		TestVendor	9999
+BEGIN-VENDOR	TestVendor
+ATTRIBUTE	Test-Vendor-Attr	1	string
+END-VENDOR	TestVendor
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "dictionary.testvendor"), []byte(vendorDictContent), 0644); err != nil {
+		t.Fatalf("Failed to write vendor dictionary: %v", err)
+	}
+
+	content := `
+ATTRIBUTE	Test-Attr-String	100	string
+$INCLUDE	dictionary.testvendor
+`
+	d := NewDictionary()
+	name := filepath.Join(tmpDir, "dictionary")
+	if err := d.LoadReader(name, strings.NewReader(content)); err != nil {
+		t.Fatalf("LoadReader failed: %v", err)
+	}
+	if id := d.GetAttributeID("Test-Attr-String"); id != 100 {
+		t.Errorf("GetAttributeID(Test-Attr-String) = %d; want 100", id)
+	}
+	if id := d.GetVSAAttributeID(9999, "Test-Vendor-Attr"); id != 1 {
+		t.Errorf("GetVSAAttributeID(Test-Vendor-Attr) = %d; want 1", id)
+	}
+
+	// the same name is read only once
+	if err := d.LoadReader(name, strings.NewReader("ATTRIBUTE Test-Attr-Dup 102 string")); err != nil {
+		t.Fatalf("LoadReader failed: %v", err)
+	}
+	if d.HasAttribute("Test-Attr-Dup") {
+		t.Error("LoadReader parsed the same name twice")
+	}
+
+	if err := d.LoadReader("bad", strings.NewReader("ATTRIBUTE Bad")); err == nil {
+		t.Error("LoadReader(bad) expected error")
+	}
+}
+
+func TestLoadExtendedAttributes(t *testing.T) {
+	content := `ATTRIBUTE	Test-Extended	241	extended
+ATTRIBUTE	Test-Ext-Int	241.1	integer
+VALUE		Test-Ext-Int	One	1
+ATTRIBUTE	Test-Ext-Str	241.2	string
+ATTRIBUTE	Test-Ext-Str	241.3	string
+ATTRIBUTE	Test-Ext-TLV	241.4.1	integer
+ATTRIBUTE	Test-Not-Ext	242.1	string
+`
+	d := NewDictionary()
+	d.SetDiagnosticMode(DiagnosticModeLenient)
+	if err := d.LoadReader("dictionary", strings.NewReader(content)); err != nil {
+		t.Fatalf("LoadReader failed: %v", err)
+	}
+
+	want := []Diagnostic{
+		{File: "dictionary", Line: 5, Severity: SeverityError, Message: "attribute Test-Ext-Str redefined with id 241.3 (was 241.2)"},
+		{File: "dictionary", Line: 6, Severity: SeverityWarning, Message: "unsupported attribute id 241.4.1 for Test-Ext-TLV, ignored"},
+		{File: "dictionary", Line: 7, Severity: SeverityWarning, Message: "unsupported attribute id 242.1 for Test-Not-Ext, ignored"},
+	}
+	got := d.Diagnostics()
+	if len(got) != len(want) {
+		t.Fatalf("Diagnostics() = %v; want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Diagnostics()[%d] = %+v; want %+v", i, got[i], want[i])
+		}
+	}
+
+	if !d.HasAttribute("Test-Ext-Int") || d.GetAttributeType("Test-Ext-Int") != "integer" {
+		t.Error("Test-Ext-Int not loaded")
+	}
+	avp := AVP{Type: 241, Value: []byte{1, 0, 0, 0, 1}}
+	if s := d.DecodeAVPValue(nil, avp); s != "{Attr: Test-Ext-Int #241.1, Value: One}" {
+		t.Errorf("DecodeAVPValue = %s", s)
+	}
+	if got, err := d.ParseAVP("Test-Ext-Int", "One"); err != nil || !bytes.Equal(got.Value, avp.Value) {
+		t.Errorf("ParseAVP(Test-Ext-Int, One) = %v, %v", got, err)
+	}
+}
@@ -0,0 +1,281 @@
+package radius
+
+import (
+	"strconv"
+	"strings"
+)
+
+// FreeRADIUS v4 data type names and their v3 equivalents.
+var attrTypeAliases = map[string]string{
+	"uint8":        "byte",
+	"uint16":       "short",
+	"uint32":       "integer",
+	"uint64":       "integer64",
+	"int32":        "signed",
+	"ipv4addr":     "ipaddr",
+	"combo-ipaddr": "combo-ip",
+	"bool":         "byte",
+	// seconds, unless a precision= flag says otherwise
+	"time_delta": "integer",
+}
+
+// canonicalAttrType maps FreeRADIUS v4 type names to the v3 names used by
+// the data type handlers.
+func canonicalAttrType(attrType string) string {
+	if t, ok := attrTypeAliases[attrType]; ok {
+		return t
+	}
+	return attrType
+}
+
+// parseV4Line handles the FreeRADIUS v3.2 / v4 directives.
+func (d *Dictionary) parseV4Line(cmd string, parts []string) error {
+	switch cmd {
+	case "ALIAS":
+		return d.parseAlias(parts[1], parts[2])
+	case "ENUM":
+		return d.parseEnum(parts[1], parts[2])
+	case "DEFINE":
+		// attributes without a number are server side only
+		return nil
+	case "STRUCT":
+		d.currentStruct = d.attrLabel(d.currentVendor, parts[1])
+		return nil
+	case "MEMBER":
+		flags := ""
+		if len(parts) > 3 && !strings.HasPrefix(parts[3], "#") {
+			flags = parts[3]
+		}
+		return d.parseMember(parts[1], parts[2], flags)
+	case "FLAGS":
+		return d.parseFlags(parts[1])
+	case "PROTOCOL":
+		return d.parseProtocol(parts[1], parts[2])
+	case "BEGIN-PROTOCOL":
+		if !strings.EqualFold(parts[1], "RADIUS") {
+			d.skipProtocol = parts[1]
+		}
+		return nil
+	case "END-PROTOCOL":
+		if d.skipProtocol == parts[1] {
+			d.skipProtocol = ""
+		}
+		return nil
+	case "BEGIN":
+		if d.nestDepth == 0 {
+			d.warnf("attributes nested in %s ignored", parts[1])
+		}
+		d.nestDepth++
+		return nil
+	default: // END
+		if d.nestDepth == 0 {
+			return d.errorf("unexpected END %s", parts[1])
+		}
+		d.nestDepth--
+		return nil
+	}
+}
+
+// parseAlias handles "ALIAS name target". The target is an attribute name of
+// the current scope or a reference like "Vendor-Specific.Cisco.AVPair" or
+// "26.9.1". The alias is added to the scope of the target.
+func (d *Dictionary) parseAlias(alias string, target string) error {
+	if d.skipBlock {
+		return nil
+	}
+
+	vendorID, attrName, ok := d.resolveAttrRef(target)
+	if !ok {
+		return d.errorf("ALIAS %s: unknown attribute %s", alias, target)
+	}
+
+	label := d.attrLabel(vendorID, alias)
+	if old, ok := d.aliases[vendorID][alias]; ok && old == attrName {
+		d.notef("duplicate alias %s", label)
+		return nil
+	}
+
+	var exists bool
+	if vendorID > 0 {
+		_, exists = d.vsaAttrID[vendorID][alias]
+	} else {
+		_, exists = d.getAttributeDef(alias)
+	}
+	if exists {
+		if err := d.conflictf("alias %s redefines an attribute", label); err != nil {
+			return err
+		}
+	}
+
+	if vendorID > 0 {
+		d.vsaAttrID[vendorID][alias] = d.vsaAttrID[vendorID][attrName]
+		d.vsaAttrType[vendorID][alias] = d.vsaAttrType[vendorID][attrName]
+		d.vsaAttrFlags[vendorID][alias] = d.vsaAttrFlags[vendorID][attrName]
+	} else {
+		if key, ok := d.extAttrID[attrName]; ok {
+			d.extAttrID[alias] = key
+		} else {
+			d.attrID[alias] = d.attrID[attrName]
+		}
+		d.attrType[alias] = d.attrType[attrName]
+		d.attrFlags[alias] = d.attrFlags[attrName]
+	}
+	d.shareValueMaps(vendorID, alias, attrName)
+
+	if _, ok := d.aliases[vendorID]; !ok {
+		d.aliases[vendorID] = make(map[string]string)
+	}
+	d.aliases[vendorID][alias] = attrName
+	return nil
+}
+
+// resolveAttrRef resolves an attribute name of the current scope, or a dotted
+// reference by names or numbers, to a vendor and attribute name.
+func (d *Dictionary) resolveAttrRef(ref string) (VendorID, string, bool) {
+	return d.resolveAttrPath(d.currentVendor, ref)
+}
+
+// resolveAttrPath resolves an attribute name of the scope vendor (0 for
+// standard), or a dotted reference by names or numbers.
+func (d *Dictionary) resolveAttrPath(scope VendorID, ref string) (VendorID, string, bool) {
+	if scope > 0 {
+		if _, ok := d.vsaAttrID[scope][ref]; ok {
+			return scope, ref, true
+		}
+	}
+	if _, ok := d.getAttributeDef(ref); ok {
+		return 0, ref, true
+	}
+
+	path := strings.Split(ref, ".")
+	if len(path) == 2 {
+		if extended, extType, err := d.parseExtendedID(ref); err == nil {
+			name, ok := d.extAttrName[extendedKey{extended, uint8(extType)}]
+			return 0, name, ok
+		}
+	}
+	if len(path) == 3 && (path[0] == "Vendor-Specific" || path[0] == "26") {
+		path = path[1:]
+	}
+
+	switch len(path) {
+	case 1:
+		n, err := strconv.ParseUint(path[0], 10, 8)
+		if err != nil {
+			return 0, "", false
+		}
+		name, ok := d.attrName[AttributeType(n)]
+		return 0, name, ok
+	case 2:
+		vendorID, ok := d.vendorID[path[0]]
+		if !ok {
+			n, err := strconv.ParseUint(path[0], 10, 32)
+			if err != nil {
+				return 0, "", false
+			}
+			vendorID = VendorID(n)
+		}
+		if _, ok := d.vsaAttrID[vendorID][path[1]]; ok {
+			return vendorID, path[1], true
+		}
+		n, err := strconv.ParseUint(path[1], 10, 32)
+		if err != nil {
+			return 0, "", false
+		}
+		name, ok := d.vsaAttrName[vendorID][VendorAttr(n)]
+		return vendorID, name, ok
+	}
+	return 0, "", false
+}
+
+// parseEnum handles "ENUM name type": a set of VALUEs shared by attributes
+// with the enum=name flag.
+func (d *Dictionary) parseEnum(enumName string, enumType string) error {
+	if d.skipBlock {
+		return nil
+	}
+
+	enumType = canonicalAttrType(enumType)
+	label := d.attrLabel(d.currentVendor, enumName)
+	if old, ok := d.enumType[d.currentVendor][enumName]; ok {
+		if old == enumType {
+			d.notef("duplicate ENUM %s", label)
+			return nil
+		}
+		if err := d.conflictf("ENUM %s redefined with type %s (was %s)", label, enumType, old); err != nil {
+			return err
+		}
+	}
+
+	if _, ok := d.enumType[d.currentVendor]; !ok {
+		d.enumType[d.currentVendor] = make(map[string]string)
+	}
+	d.enumType[d.currentVendor][enumName] = enumType
+	return nil
+}
+
+// parseMember handles "MEMBER name type [flags]" following a struct.
+func (d *Dictionary) parseMember(memberName string, memberType string, memberFlags string) error {
+	if d.skipBlock || d.internal {
+		return nil
+	}
+	if d.currentStruct == "" {
+		return d.errorf("MEMBER %s outside of a struct", memberName)
+	}
+
+	flags, err := ParseAttributeFlags(memberFlags)
+	if err != nil {
+		return d.errorf("member %s: %s", memberName, err)
+	}
+
+	members := d.structMembers[d.currentStruct]
+	d.structMembers[d.currentStruct] = append(members, AttributeDef{
+		Name:   memberName,
+		Vendor: d.currentVendor,
+		ID:     uint32(len(members) + 1),
+		Type:   canonicalAttrType(memberType),
+		Flags:  flags,
+	})
+	return nil
+}
+
+// parseFlags handles "FLAGS internal" and "FLAGS !internal" (FreeRADIUS
+// v3.2): internal attributes are server side only and not loaded.
+func (d *Dictionary) parseFlags(flags string) error {
+	switch flags {
+	case "internal":
+		d.internal = true
+	case "!internal":
+		d.internal = false
+	default:
+		d.warnf("unsupported FLAGS %s ignored", flags)
+	}
+	return nil
+}
+
+// parseProtocol handles "PROTOCOL name number". Only the RADIUS protocol
+// definitions are loaded, see BEGIN-PROTOCOL.
+func (d *Dictionary) parseProtocol(name string, number string) error {
+	n, err := strconv.ParseUint(number, 0, 32)
+	if err != nil {
+		return d.errorf("invalid protocol number %s for %s", number, name)
+	}
+	d.protocols[name] = uint32(n)
+	return nil
+}
+
+// GetStructMembers returns the members of a struct attribute or STRUCT, in
+// order; ID holds the 1-based position.
+func (d *Dictionary) GetStructMembers(vendorID VendorID, name string) []AttributeDef {
+	d.RLock()
+	defer d.RUnlock()
+	return append([]AttributeDef(nil), d.structMembers[d.attrLabel(vendorID, name)]...)
+}
+
+// GetAttributeAlias returns the attribute an ALIAS name refers to.
+func (d *Dictionary) GetAttributeAlias(vendorID VendorID, alias string) (string, bool) {
+	d.RLock()
+	defer d.RUnlock()
+	name, ok := d.aliases[vendorID][alias]
+	return name, ok
+}
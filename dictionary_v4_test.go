@@ -0,0 +1,181 @@
+package radius
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func v4TestFS() fstest.MapFS {
+	return fstest.MapFS{
+		"radius/dictionary": &fstest.MapFile{Data: []byte(`
+PROTOCOL	RADIUS		1
+PROTOCOL	DHCPv4		2
+
+BEGIN-PROTOCOL	RADIUS
+$INCLUDE	dictionary.rfc2865
+$INCLUDE-	dictionary.local
+$INCLUDE	dictionary.cisco
+END-PROTOCOL	RADIUS
+
+BEGIN-PROTOCOL	DHCPv4
+ATTRIBUTE	Opcode			1	uint8
+END-PROTOCOL	DHCPv4
+
+FLAGS		internal
+ATTRIBUTE	Internal-Attr		1000	string
+FLAGS		!internal
+ATTRIBUTE	After-Internal		150	uint32
+`)},
+		"radius/dictionary.rfc2865": &fstest.MapFile{Data: []byte(`
+ATTRIBUTE	User-Name		1	string
+ATTRIBUTE	User-Password		2	string	secret,encrypt=User-Password
+ENUM		Service-Kind		uint32
+VALUE		Service-Kind		Login-User	1
+ATTRIBUTE	Service-Type		6	uint32	enum=Service-Kind
+VALUE		Service-Type		Framed-User	2
+ATTRIBUTE	Framed-IP-Address	8	ipv4addr
+ATTRIBUTE	Test-Struct		200	struct
+MEMBER		Kind			uint8
+MEMBER		Value			uint32
+ATTRIBUTE	Test-TLV		201	tlv
+BEGIN		Test-TLV
+ATTRIBUTE	Sub			1	string
+END		Test-TLV
+ATTRIBUTE	Test-Byte		202	uint8
+VALUE		Test-Byte		One		1
+ATTRIBUTE	Test-Server-Side	203	string	internal
+ALIAS		Login-Name		User-Name
+DEFINE		Server-Only		string
+`)},
+		"radius/dictionary.cisco": &fstest.MapFile{Data: []byte(`
+VENDOR		Cisco			9
+BEGIN-VENDOR	Cisco
+ATTRIBUTE	AVPair			1	string
+ATTRIBUTE	NAS-Port		2	string
+END-VENDOR	Cisco
+ALIAS		Cisco-AVPair		Vendor-Specific.Cisco.AVPair
+ALIAS		Cisco-NAS-Port		26.9.2
+`)},
+	}
+}
+
+func TestLoadV4Dictionary(t *testing.T) {
+	d := NewDictionary()
+	d.SetDiagnosticMode(DiagnosticModeStrict)
+	if err := d.LoadFS(v4TestFS(), "radius/dictionary"); err != nil {
+		t.Fatalf("LoadFS failed: %v", err)
+	}
+
+	diags := d.Diagnostics()
+	if len(diags) != 1 || diags[0].Message != "attributes nested in Test-TLV ignored" {
+		t.Errorf("Diagnostics() = %v", diags)
+	}
+
+	testCases := []struct {
+		name     string
+		attrType string
+	}{
+		{"Framed-IP-Address", "ipaddr"},
+		{"Service-Type", "integer"},
+		{"Test-Byte", "byte"},
+		{"Test-Struct", "struct"},
+		{"After-Internal", "integer"},
+		{"Login-Name", "string"},
+	}
+	for _, tc := range testCases {
+		if typ := d.GetAttributeType(tc.name); typ != tc.attrType {
+			t.Errorf("GetAttributeType(%s) = %q; want %q", tc.name, typ, tc.attrType)
+		}
+	}
+
+	for _, name := range []string{"Opcode", "Internal-Attr", "Sub", "Test-Server-Side", "Server-Only"} {
+		if d.HasAttribute(name) {
+			t.Errorf("attribute %s should not be loaded", name)
+		}
+	}
+
+	if flags := d.GetAttributeFlags("User-Password"); flags.Encrypt != EncryptMethodUserPassword {
+		t.Errorf("User-Password flags = %s", flags)
+	}
+
+	p := &Packet{}
+	decodeCases := []struct {
+		avp  AVP
+		want string
+	}{
+		{AVP{Type: 6, Value: []byte{0, 0, 0, 1}}, "Login-User"},
+		{AVP{Type: 6, Value: []byte{0, 0, 0, 2}}, "Framed-User"},
+		{AVP{Type: 202, Value: []byte{1}}, "One"},
+	}
+	for _, tc := range decodeCases {
+		if s := d.DecodeAVPValue(p, tc.avp); s != tc.want {
+			t.Errorf("DecodeAVPValue(%v) = %s; want %s", tc.avp, s, tc.want)
+		}
+	}
+
+	if id := d.GetAttributeID("Login-Name"); id != 1 {
+		t.Errorf("GetAttributeID(Login-Name) = %d; want 1", id)
+	}
+	if name := d.GetAttributeName(1); name != "User-Name" {
+		t.Errorf("GetAttributeName(1) = %s; want User-Name", name)
+	}
+	if name, ok := d.GetAttributeAlias(0, "Login-Name"); !ok || name != "User-Name" {
+		t.Errorf("GetAttributeAlias(Login-Name) = %s, %v", name, ok)
+	}
+
+	if id := d.GetVSAAttributeID(9, "Cisco-AVPair"); id != 1 {
+		t.Errorf("GetVSAAttributeID(Cisco-AVPair) = %d; want 1", id)
+	}
+	if id := d.GetVSAAttributeID(9, "Cisco-NAS-Port"); id != 2 {
+		t.Errorf("GetVSAAttributeID(Cisco-NAS-Port) = %d; want 2", id)
+	}
+	if vsa := d.NewVSA("Cisco", "Cisco-AVPair", "a=b"); vsa.Type != 1 || string(vsa.Value) != "a=b" {
+		t.Errorf("NewVSA(Cisco-AVPair) = %+v", vsa)
+	}
+
+	members := d.GetStructMembers(0, "Test-Struct")
+	if len(members) != 2 || members[0].Name != "Kind" || members[0].Type != "byte" || members[1].ID != 2 || members[1].Type != "integer" {
+		t.Errorf("GetStructMembers(Test-Struct) = %+v", members)
+	}
+}
+
+func TestV4DictionaryErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+	}{
+		{"MissingInclude", "$INCLUDE dictionary.missing"},
+		{"UnexpectedEnd", "END Test-TLV"},
+		{"MemberOutsideStruct", "MEMBER Kind uint8"},
+		{"UnknownEnum", "ATTRIBUTE Test 1 uint32 enum=Missing"},
+		{"UnknownAlias", "ALIAS Test Missing"},
+		{"InvalidProtocol", "PROTOCOL RADIUS one"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := fstest.MapFS{"dictionary": &fstest.MapFile{Data: []byte(tc.content)}}
+			d := NewDictionary()
+			d.SetDiagnosticMode(DiagnosticModeStrict)
+			if err := d.LoadFS(fsys, "dictionary"); err == nil {
+				t.Errorf("LoadFS(%s) expected error", tc.content)
+			}
+		})
+	}
+}
+
+func TestCanonicalAttrType(t *testing.T) {
+	testCases := map[string]string{
+		"uint8":    "byte",
+		"uint16":   "short",
+		"uint32":   "integer",
+		"uint64":   "integer64",
+		"int32":    "signed",
+		"ipv4addr": "ipaddr",
+		"string":   "string",
+	}
+	for in, want := range testCases {
+		if got := canonicalAttrType(in); got != want {
+			t.Errorf("canonicalAttrType(%s) = %s; want %s", in, got, want)
+		}
+	}
+}
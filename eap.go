@@ -39,7 +39,11 @@ const (
 	EapTypeMd5Challenge     EapType = 4
 	EapTypeOneTimePassword  EapType = 5 //otp
 	EapTypeGenericTokenCard EapType = 6 //gtc
+	EapTypeTLS              EapType = 13
+	EapTypeTTLS             EapType = 21
+	EapTypePEAP             EapType = 25
 	EapTypeMSCHAPV2         EapType = 26
+	EapTypeTLV              EapType = 33 //PEAP extensions
 	EapTypeExpandedTypes    EapType = 254
 	EapTypeExperimentalUse  EapType = 255
 )
@@ -58,8 +62,16 @@ func (c EapType) String() string {
 		return "OneTimePassword"
 	case EapTypeGenericTokenCard:
 		return "GenericTokenCard"
+	case EapTypeTLS:
+		return "TLS"
+	case EapTypeTTLS:
+		return "TTLS"
+	case EapTypePEAP:
+		return "PEAP"
 	case EapTypeMSCHAPV2:
 		return "MSCHAPV2"
+	case EapTypeTLV:
+		return "TLV"
 	case EapTypeExpandedTypes:
 		return "ExpandedTypes"
 	case EapTypeExperimentalUse:
@@ -69,18 +81,62 @@ func (c EapType) String() string {
 	}
 }
 
+// EapExpandedType identifies a method in the expanded type space
+// (RFC 3748 §5.7): a vendor (SMI Private Enterprise Number, 24 bits) and a
+// 32-bit type of that vendor. The legacy type t is {0, t}.
+type EapExpandedType struct {
+	Vendor VendorID
+	Type   uint32
+}
+
+// EapExpandedNak is the expanded type of the Expanded Nak (RFC 3748 §5.3.2).
+var EapExpandedNak = EapExpandedType{Vendor: 0, Type: uint32(EapTypeNak)}
+
+func (t EapExpandedType) String() string {
+	if t.Vendor == 0 && t.Type < uint32(EapTypeExpandedTypes) {
+		return EapType(t.Type).String()
+	}
+	return fmt.Sprintf("Expanded(%d/%d)", t.Vendor, t.Type)
+}
+
 type EapPacket struct {
 	Code       EapCode
 	Identifier uint8
 	Type       EapType
+	// VendorID and VendorType are the header of an expanded type, when Type
+	// is EapTypeExpandedTypes; Data follows them.
+	VendorID   VendorID
+	VendorType uint32
 	Data       []byte
 }
 
+// ExpandedType returns the method of the packet in the expanded type space.
+func (a *EapPacket) ExpandedType() EapExpandedType {
+	if a.Type == EapTypeExpandedTypes {
+		return EapExpandedType{Vendor: a.VendorID, Type: a.VendorType}
+	}
+	return EapExpandedType{Type: uint32(a.Type)}
+}
+
+// SetExpandedType sets the Type, VendorID and VendorType fields for t, using
+// the legacy type when t has one.
+func (a *EapPacket) SetExpandedType(t EapExpandedType) {
+	if t.Vendor == 0 && t.Type < uint32(EapTypeExpandedTypes) {
+		a.Type, a.VendorID, a.VendorType = EapType(t.Type), 0, 0
+		return
+	}
+	a.Type, a.VendorID, a.VendorType = EapTypeExpandedTypes, t.Vendor, t.Type
+}
+
 func (a *EapPacket) String() string {
 	if a.Code == EapCodeSuccess || a.Code == EapCodeFailure {
 		return fmt.Sprintf("Eap Code:%s id:%d", a.Code.String(), a.Identifier)
 	}
-	return fmt.Sprintf("Eap Code:%s id:%d Type:%s Data:[%s]", a.Code.String(), a.Identifier, a.Type.String(), a.valueString())
+	typ := a.Type.String()
+	if a.Type == EapTypeExpandedTypes {
+		typ = fmt.Sprintf("Expanded(%d/%d)", a.VendorID, a.VendorType)
+	}
+	return fmt.Sprintf("Eap Code:%s id:%d Type:%s Data:[%s]", a.Code.String(), a.Identifier, typ, a.valueString())
 }
 
 func (a *EapPacket) valueString() string {
@@ -111,15 +167,34 @@ func (a *EapPacket) Encode() (b []byte) {
 		binary.BigEndian.PutUint16(b[2:4], 4)
 		return b
 	}
-	b = make([]byte, len(a.Data)+5)
+	header := 5
+	if a.Type == EapTypeExpandedTypes {
+		header = 12
+	}
+	b = make([]byte, len(a.Data)+header)
 	b[0] = byte(a.Code)
 	b[1] = byte(a.Identifier)
-	binary.BigEndian.PutUint16(b[2:4], uint16(len(a.Data)+5))
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(b)))
 	b[4] = byte(a.Type)
-	copy(b[5:], a.Data)
+	if a.Type == EapTypeExpandedTypes {
+		putEapVendor(b[5:8], a.VendorID)
+		binary.BigEndian.PutUint32(b[8:12], a.VendorType)
+	}
+	copy(b[header:], a.Data)
 	return b
 }
 
+// putEapVendor writes a 3-byte Vendor-Id.
+func putEapVendor(b []byte, vendor VendorID) {
+	b[0] = byte(vendor >> 16)
+	b[1] = byte(vendor >> 8)
+	b[2] = byte(vendor)
+}
+
+func eapVendor(b []byte) VendorID {
+	return VendorID(b[0])<<16 | VendorID(b[1])<<8 | VendorID(b[2])
+}
+
 func (a *EapPacket) ToEAPMessage() *AVP {
 	return &AVP{
 		Type:  AttrEAPMessage,
@@ -127,6 +202,15 @@ func (a *EapPacket) ToEAPMessage() *AVP {
 	}
 }
 
+// SetEAPMessage replaces the EAP-Message attributes of the packet with eap,
+// split over several attributes when longer than 253 bytes (RFC 3579 §3.1).
+func (p *Packet) SetEAPMessage(eap *EapPacket) {
+	p.DeleteAllType(AttrEAPMessage)
+	for _, chunk := range splitConcat(eap.Encode(), true) {
+		p.AddAVP(AVP{Type: AttrEAPMessage, Value: chunk})
+	}
+}
+
 func EapDecode(b []byte) (eap *EapPacket, err error) {
 	if len(b) < 4 {
 		return nil, fmt.Errorf("[EapDecode] protocol error input too small")
@@ -148,6 +232,52 @@ func EapDecode(b []byte) (eap *EapPacket, err error) {
 		return nil, fmt.Errorf("[EapDecode] protocol error: Request/Response too small")
 	}
 	eap.Type = EapType(b[4])
+	if eap.Type == EapTypeExpandedTypes {
+		if length < 12 {
+			return nil, fmt.Errorf("[EapDecode] protocol error: Expanded Type header too small")
+		}
+		eap.VendorID = eapVendor(b[5:8])
+		eap.VendorType = binary.BigEndian.Uint32(b[8:12])
+		eap.Data = b[12:length]
+		return eap, nil
+	}
 	eap.Data = b[5:length]
 	return eap, nil
 }
+
+// NewEapExpandedNak returns the Type-Data of an Expanded Nak proposing
+// types, in order of preference (RFC 3748 §5.3.2). An empty list means no
+// alternative method is acceptable.
+func NewEapExpandedNak(types ...EapExpandedType) []byte {
+	if len(types) == 0 {
+		types = []EapExpandedType{{}}
+	}
+	b := make([]byte, 0, 8*len(types))
+	for _, t := range types {
+		entry := make([]byte, 8)
+		entry[0] = byte(EapTypeExpandedTypes)
+		putEapVendor(entry[1:4], t.Vendor)
+		binary.BigEndian.PutUint32(entry[4:8], t.Type)
+		b = append(b, entry...)
+	}
+	return b
+}
+
+// ParseEapExpandedNak returns the types proposed by the Type-Data of an
+// Expanded Nak. The "no alternative" entry {0, 0} is left out.
+func ParseEapExpandedNak(data []byte) ([]EapExpandedType, error) {
+	if len(data) == 0 || len(data)%8 != 0 {
+		return nil, fmt.Errorf("invalid Expanded Nak length %d", len(data))
+	}
+	var types []EapExpandedType
+	for ; len(data) > 0; data = data[8:] {
+		if EapType(data[0]) != EapTypeExpandedTypes {
+			return nil, fmt.Errorf("invalid Expanded Nak type %d", data[0])
+		}
+		t := EapExpandedType{Vendor: eapVendor(data[1:4]), Type: binary.BigEndian.Uint32(data[4:8])}
+		if t != (EapExpandedType{}) {
+			types = append(types, t)
+		}
+	}
+	return types, nil
+}
@@ -0,0 +1,103 @@
+package radius
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// DefaultEapClientMaxRounds bounds the number of Access-Request round trips
+// of an EapClient conversation.
+const DefaultEapClientMaxRounds = 50
+
+// EapClient runs an EAP conversation with a RADIUS server, playing both the
+// NAS and the supplicant, for example to test an EAP server.
+//
+// Each EAP-Response is sent in an Access-Request carrying the State of the
+// previous Access-Challenge, split over several EAP-Message attributes when
+// needed. Identity and Notification requests are answered by the client,
+// requests of other methods than Method with a Nak.
+type EapClient struct {
+	Client *RadClient
+	// Identity is the EAP identity, also sent as User-Name.
+	Identity string
+	// Method is the peer side of the EAP method, such as EapPeerMD5,
+	// EapPeerMSCHAPv2 or EapPeerTLS.
+	Method EapPeerMethod
+	// Attributes are added to every Access-Request, for example
+	// NAS-IP-Address or Called-Station-Id.
+	Attributes []AVP
+	// MaxRounds bounds the number of round trips.
+	MaxRounds int
+}
+
+// NewEapClient returns a client authenticating identity with method through
+// client.
+func NewEapClient(client *RadClient, identity string, method EapPeerMethod) *EapClient {
+	return &EapClient{Client: client, Identity: identity, Method: method, MaxRounds: DefaultEapClientMaxRounds}
+}
+
+// Authenticate runs the conversation and returns the final Access-Accept or
+// Access-Reject; a reject is not an error. The conversation starts with the
+// EAP-Response/Identity, as sent by a NAS which already asked the
+// supplicant for it.
+func (c *EapClient) Authenticate(ctx context.Context) (*Packet, error) {
+	resp := &EapPacket{Code: EapCodeResponse, Type: EapTypeIdentity, Data: []byte(c.Identity)}
+	var state []byte
+	for round := 0; round < c.MaxRounds; round++ {
+		reply, err := c.Client.SendContext(ctx, c.request(resp, state))
+		if err != nil {
+			return nil, err
+		}
+		switch reply.Code {
+		case AccessAccept, AccessReject:
+			return reply, nil
+		case AccessChallenge:
+		default:
+			return nil, fmt.Errorf("unexpected reply %v to an EAP Access-Request", reply.Code)
+		}
+
+		state = nil
+		if avp := reply.GetAVP(AttrState); avp != nil {
+			state = avp.Value
+		}
+		req := reply.GetEAPMessage()
+		if req == nil || req.Code != EapCodeRequest {
+			return nil, errors.New("Access-Challenge without EAP-Request")
+		}
+		if resp, err = c.respond(req); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("EAP conversation did not end after %d rounds", c.MaxRounds)
+}
+
+// respond answers an EAP-Request.
+func (c *EapClient) respond(req *EapPacket) (*EapPacket, error) {
+	switch req.Type {
+	case EapTypeIdentity:
+		return &EapPacket{Code: EapCodeResponse, Identifier: req.Identifier, Type: req.Type, Data: []byte(c.Identity)}, nil
+	case EapTypeNotification:
+		// acknowledged with an empty Type-Data (RFC 3748 §5.2)
+		return &EapPacket{Code: EapCodeResponse, Identifier: req.Identifier, Type: req.Type}, nil
+	}
+	resp, err := eapPeerResponse(c.Method, req)
+	if err != nil {
+		return nil, fmt.Errorf("EAP %s: %v", req.ExpandedType(), err)
+	}
+	return resp, nil
+}
+
+// request builds the Access-Request carrying an EAP-Response.
+func (c *EapClient) request(eap *EapPacket, state []byte) *Packet {
+	p := c.Client.NewRequest(AccessRequest)
+	p.AddAVP(AVP{Type: AttrUserName, Value: []byte(c.Identity)})
+	for _, avp := range c.Attributes {
+		p.AddAVP(avp)
+	}
+	p.SetEAPMessage(eap)
+	if state != nil {
+		p.AddAVP(AVP{Type: AttrState, Value: state})
+	}
+	return p
+}
@@ -0,0 +1,109 @@
+package radius
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// startEapTestServer runs e on a UDP server and returns its address once the
+// server answers. The port is picked before the server starts so that the
+// test never reads Server fields written by ListenAndServe.
+func startEapTestServer(t *testing.T, e *EapServer) string {
+	t.Helper()
+	l, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.LocalAddr().String()
+	l.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := NewServer(addr, "secret", e)
+	srv.ctx = ctx
+	srv.cancel = cancel
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		srv.ListenAndServe()
+	}()
+
+	// a request without EAP-Message is rejected at once
+	client := NewRadClient(addr, "secret")
+	client.SetTimeout(100 * time.Millisecond)
+	probe := func() error {
+		_, err := client.Send(client.NewRequest(AccessRequest))
+		return err
+	}
+	t.Cleanup(func() {
+		cancel()
+		// wake the server blocked reading the socket
+		probe()
+		<-done
+	})
+	for i := 0; i < 20; i++ {
+		if probe() == nil {
+			return addr
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("RADIUS server failed to start")
+	return ""
+}
+
+func TestEapClient(t *testing.T) {
+	serverConfig, clientConfig := eapTLSTestConfigs(t)
+	passwords := func(username string) (string, error) {
+		return map[string]string{"alice": "secret"}[username], nil
+	}
+	e := NewEapServer(
+		NewEapMD5(passwords),
+		NewEapMSCHAPv2("server", MsChapV2PasswordLookup(passwords)),
+		NewEapTLS(serverConfig),
+	)
+	addr := startEapTestServer(t, e)
+	client := NewRadClient(addr, "secret")
+
+	testCases := []struct {
+		name   string
+		method EapPeerMethod
+		want   PacketCode
+	}{
+		{"MD5", &EapPeerMD5{Password: "secret"}, AccessAccept},
+		{"MD5 wrong password", &EapPeerMD5{Password: "wrong"}, AccessReject},
+		{"MSCHAPv2 after Nak", NewEapPeerMSCHAPv2("alice", "secret"), AccessAccept},
+		{"MSCHAPv2 wrong password", NewEapPeerMSCHAPv2("alice", "wrong"), AccessReject},
+		{"TLS", NewEapPeerTLS(clientConfig), AccessAccept},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewEapClient(client, "alice", tc.method)
+			c.Attributes = []AVP{{Type: AttrNASIdentifier, Value: []byte("test-nas")}}
+			reply, err := c.Authenticate(context.Background())
+			if err != nil {
+				t.Fatalf("Authenticate() failed: %v", err)
+			}
+			if reply.Code != tc.want {
+				t.Fatalf("reply = %v; want %v", reply.Code, tc.want)
+			}
+			eap := reply.GetEAPMessage()
+			if eap == nil || (tc.want == AccessAccept) != (eap.Code == EapCodeSuccess) {
+				t.Errorf("EAP-Message = %v", eap)
+			}
+		})
+	}
+
+	// the MSCHAPv2 peer checks the authenticator response
+	peer := NewEapPeerMSCHAPv2("alice", "secret")
+	req := &EapPacket{Code: EapCodeRequest, Identifier: 3, Type: EapTypeMSCHAPV2}
+	challenge := (&MsChapV2Packet{Eap: req, OpCode: MsChapV2OpCodeChallenge, Data: append([]byte{16}, bytes.Repeat([]byte{1}, 16)...)}).ToEap()
+	if _, err := peer.Process(challenge); err != nil {
+		t.Fatal(err)
+	}
+	success := (&MsChapV2Packet{Eap: req, OpCode: MsChapV2OpCodeSuccess, Data: []byte("S=0000000000000000000000000000000000000000 M=OK")}).ToEap()
+	if _, err := peer.Process(success); err == nil {
+		t.Errorf("forged authenticator response accepted")
+	}
+}
@@ -0,0 +1,62 @@
+package radius
+
+import "errors"
+
+// EapGTC is the server side of EAP Generic Token Card (RFC 3748 §5.6), an
+// EapMethod. The peer answers a displayable prompt with a token or password
+// in clear text, which is checked by Verify; GTC should only run inside a
+// protected tunnel.
+type EapGTC struct {
+	// Prompt is the message displayed to the user.
+	Prompt string
+	// Verify checks the response of a user, looked up by the EAP identity.
+	Verify func(username, response string) (bool, error)
+}
+
+// NewEapGTC returns an EAP-GTC method showing prompt and checking responses
+// with verify, for example against an OTP service.
+func NewEapGTC(prompt string, verify func(username, response string) (bool, error)) *EapGTC {
+	return &EapGTC{Prompt: prompt, Verify: verify}
+}
+
+func (m *EapGTC) Type() EapType { return EapTypeGenericTokenCard }
+
+func (m *EapGTC) Start(s *EapSession) ([]byte, error) {
+	return []byte(m.Prompt), nil
+}
+
+func (m *EapGTC) Process(s *EapSession, data []byte) (EapStatus, []byte, error) {
+	ok, err := m.Verify(s.Identity, string(data))
+	if err != nil {
+		return EapStatusFailure, nil, err
+	}
+	if !ok {
+		return EapStatusFailure, nil, nil
+	}
+	return EapStatusSuccess, nil, nil
+}
+
+// EapPeerGTC is the peer side of EAP-GTC, an EapPeerMethod.
+type EapPeerGTC struct {
+	// Response returns the answer to a prompt, for example the next token
+	// code.
+	Response func(prompt string) (string, error)
+}
+
+// NewEapPeerGTC returns a GTC peer answering every prompt with response.
+func NewEapPeerGTC(response string) *EapPeerGTC {
+	return &EapPeerGTC{Response: func(string) (string, error) { return response, nil }}
+}
+
+func (m *EapPeerGTC) Type() EapType { return EapTypeGenericTokenCard }
+
+func (m *EapPeerGTC) Process(req *EapPacket) ([]byte, error) {
+	if m.Response == nil {
+		return nil, errors.New("EAP-GTC peer without response")
+	}
+	response, err := m.Response(string(req.Data))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(response), nil
+}
@@ -0,0 +1,43 @@
+package radius
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEapGTC(t *testing.T) {
+	var prompts []string
+	m := NewEapGTC("Token code: ", func(username, response string) (bool, error) {
+		if username == "broken" {
+			return false, errors.New("OTP service down")
+		}
+		return username == "alice" && response == "123456", nil
+	})
+	e := NewEapServer(NewEapMD5(nil), m)
+
+	testCases := []struct {
+		user, token string
+		want        PacketCode
+	}{
+		{"alice", "123456", AccessAccept},
+		{"alice", "000000", AccessReject},
+		{"broken", "123456", AccessReject},
+	}
+	for _, tc := range testCases {
+		token := tc.token
+		peer := &EapPeerGTC{Response: func(prompt string) (string, error) {
+			prompts = append(prompts, prompt)
+			return token, nil
+		}}
+		if reply := eapPeerConversation(t, e, tc.user, peer); reply.Code != tc.want {
+			t.Errorf("%s/%s: reply = %v; want %v", tc.user, tc.token, reply.Code, tc.want)
+		}
+	}
+	if len(prompts) != len(testCases) || prompts[0] != "Token code: " {
+		t.Errorf("prompts = %q", prompts)
+	}
+
+	if reply := eapPeerConversation(t, e, "alice", NewEapPeerGTC("123456")); reply.Code != AccessAccept {
+		t.Errorf("NewEapPeerGTC reply = %v; want Access-Accept", reply.Code)
+	}
+}
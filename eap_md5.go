@@ -0,0 +1,104 @@
+package radius
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+)
+
+// eapMD5ChallengeSize is the size of generated EAP-MD5 challenges.
+const eapMD5ChallengeSize = 16
+
+// EapMD5 is the server side of EAP-MD5-Challenge (RFC 3748 §5.4), an
+// EapMethod. The response is the CHAP response (RFC 1994) computed with the
+// EAP identifier of the request; the method exports no keys.
+type EapMD5 struct {
+	// Name is the optional authenticator name sent in the challenge.
+	Name string
+	// Password returns the cleartext password of a user, looked up by the
+	// EAP identity. An empty password rejects the user.
+	Password func(username string) (string, error)
+}
+
+// NewEapMD5 returns an EAP-MD5 method using password to look up the user
+// passwords.
+func NewEapMD5(password func(username string) (string, error)) *EapMD5 {
+	return &EapMD5{Password: password}
+}
+
+// eapMD5State is the per-conversation state of EapMD5.
+type eapMD5State struct {
+	id        uint8
+	challenge []byte
+}
+
+func (m *EapMD5) Type() EapType { return EapTypeMd5Challenge }
+
+func (m *EapMD5) Start(s *EapSession) ([]byte, error) {
+	challenge := make([]byte, eapMD5ChallengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+	s.Data = &eapMD5State{id: s.Identifier, challenge: challenge}
+	return eapMD5Value(challenge, m.Name), nil
+}
+
+func (m *EapMD5) Process(s *EapSession, data []byte) (EapStatus, []byte, error) {
+	st, ok := s.Data.(*eapMD5State)
+	if !ok {
+		return EapStatusFailure, nil, errors.New("EAP-MD5 conversation not started")
+	}
+	value, _, err := parseEapMD5Value(data)
+	if err != nil {
+		return EapStatusFailure, nil, err
+	}
+	password, err := m.Password(s.Identity)
+	if err != nil || checkPassword(password) != nil {
+		return EapStatusFailure, nil, nil
+	}
+	expected, err := ComputeCHAPResponse(st.id, password, st.challenge)
+	if err != nil {
+		return EapStatusFailure, nil, err
+	}
+	if subtle.ConstantTimeCompare(expected[:], value) != 1 {
+		return EapStatusFailure, nil, nil
+	}
+	return EapStatusSuccess, nil, nil
+}
+
+// EapPeerMD5 is the peer side of EAP-MD5-Challenge, an EapPeerMethod.
+type EapPeerMD5 struct {
+	Password string
+}
+
+func (m *EapPeerMD5) Type() EapType { return EapTypeMd5Challenge }
+
+func (m *EapPeerMD5) Process(req *EapPacket) ([]byte, error) {
+	challenge, _, err := parseEapMD5Value(req.Data)
+	if err != nil {
+		return nil, err
+	}
+	response, err := ComputeCHAPResponse(req.Identifier, m.Password, challenge)
+	if err != nil {
+		return nil, err
+	}
+	return eapMD5Value(response[:], ""), nil
+}
+
+// eapMD5Value encodes Value-Size, Value and Name.
+func eapMD5Value(value []byte, name string) []byte {
+	b := make([]byte, 1+len(value)+len(name))
+	b[0] = byte(len(value))
+	copy(b[1:], value)
+	copy(b[1+len(value):], name)
+	return b
+}
+
+func parseEapMD5Value(data []byte) (value []byte, name string, err error) {
+	if len(data) < 1 || int(data[0]) > len(data)-1 || data[0] == 0 {
+		return nil, "", fmt.Errorf("invalid EAP-MD5 value length")
+	}
+	n := int(data[0])
+	return data[1 : 1+n], string(data[1+n:]), nil
+}
@@ -0,0 +1,51 @@
+package radius
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEapMD5(t *testing.T) {
+	m := NewEapMD5(func(username string) (string, error) {
+		if username != "alice" {
+			return "", errors.New("unknown user")
+		}
+		return "secret", nil
+	})
+	m.Name = "server"
+	e := NewEapServer(m)
+
+	testCases := []struct {
+		user, password string
+		want           PacketCode
+	}{
+		{"alice", "secret", AccessAccept},
+		{"alice", "wrong", AccessReject},
+		{"bob", "secret", AccessReject},
+	}
+	for _, tc := range testCases {
+		reply := eapPeerConversation(t, e, tc.user, &EapPeerMD5{Password: tc.password})
+		if reply.Code != tc.want {
+			t.Errorf("%s/%s: reply = %v; want %v", tc.user, tc.password, reply.Code, tc.want)
+		}
+	}
+}
+
+func TestEapPeerMD5(t *testing.T) {
+	challenge := []byte("0123456789abcdef")
+	req := &EapPacket{Code: EapCodeRequest, Identifier: 42, Type: EapTypeMd5Challenge, Data: eapMD5Value(challenge, "server")}
+	data, err := (&EapPeerMD5{Password: "secret"}).Process(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := ComputeCHAPResponse(42, "secret", challenge)
+	if value, name, err := parseEapMD5Value(data); err != nil || string(value) != string(want[:]) || name != "" {
+		t.Errorf("Process() = %x; want %x", data, want)
+	}
+
+	for _, bad := range [][]byte{nil, {0}, {17, 1, 2}} {
+		if _, err := (&EapPeerMD5{}).Process(&EapPacket{Type: EapTypeMd5Challenge, Data: bad}); err == nil {
+			t.Errorf("Process(%x) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package radius
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// msChapV2ChallengeSize and msChapV2ResponseSize are the Value-Size of the
+// Challenge and Response packets (RFC 2759 §4).
+const (
+	msChapV2ChallengeSize = 16
+	msChapV2ResponseSize  = 49
+)
+
+// MsChapV2LookupFunc returns the NT-Hash of the password of a user (see
+// MSCHAPv2NTHash). Returning an MsChapError rejects the user with that code;
+// any other error is reported as an authentication failure. An expired
+// password is reported by returning its NT-Hash with
+// MsChapErrorPasswordExpired.
+type MsChapV2LookupFunc func(username string) (ntHash []byte, err error)
+
+// MsChapV2PasswordLookup adapts a lookup returning cleartext passwords. An
+// empty password rejects the user.
+func MsChapV2PasswordLookup(f func(username string) (string, error)) MsChapV2LookupFunc {
+	return func(username string) ([]byte, error) {
+		password, err := f(username)
+		if err != nil && !errors.Is(err, MsChapErrorPasswordExpired) {
+			return nil, err
+		}
+		if password == "" {
+			return nil, MsChapErrorAuthenticationFailure
+		}
+		return MSCHAPv2NTHash(password), err
+	}
+}
+
+// EapMSCHAPv2 is the server side of EAP-MSCHAPv2
+// (draft-kamath-pppext-eap-mschapv2), an EapMethod.
+//
+// The peer answers the Challenge with its NT-Response, which is verified
+// against the NT-Hash returned by Lookup for the name of the Response; that
+// name must be the EAP identity, domain and realm aside. The
+// server then proves its own knowledge of the password with the "S="
+// authenticator response in a Success-Request, and the method succeeds with
+// the MPPE keys of RFC 3079 as MSK once the peer acknowledges it. Failures
+// are reported to the peer with a Failure-Request carrying the "E=" code; an
+// expired password may be changed with a Change-Password packet.
+type EapMSCHAPv2 struct {
+	// Name is the authenticator name sent in the Challenge.
+	Name string
+	// Lookup returns the NT-Hash of the user password.
+	Lookup MsChapV2LookupFunc
+	// Retries is how many times a peer may retry after a wrong password.
+	Retries int
+	// ChangePassword, when set, lets users whose Lookup returned
+	// MsChapErrorPasswordExpired choose a new password, which it stores.
+	// Returning an MsChapError rejects the change with that code.
+	ChangePassword func(username, newPassword string) error
+}
+
+// NewEapMSCHAPv2 returns an EAP-MSCHAPv2 method using lookup to get the NT-Hash
+// of the user passwords.
+func NewEapMSCHAPv2(name string, lookup MsChapV2LookupFunc) *EapMSCHAPv2 {
+	return &EapMSCHAPv2{Name: name, Lookup: lookup}
+}
+
+// eapMSCHAPv2State is the per-conversation state of EapMSCHAPv2.
+type eapMSCHAPv2State struct {
+	challenge []byte
+	retries   int
+	// result is the outcome announced by the last Success-Request or
+	// Failure-Request, EapStatusContinue while a Response is expected.
+	result EapStatus
+	msk    []byte
+	// name and oldHash are kept while the peer is asked to change its
+	// expired password.
+	name    string
+	oldHash []byte
+}
+
+func (m *EapMSCHAPv2) Type() EapType { return EapTypeMSCHAPV2 }
+
+func (m *EapMSCHAPv2) Start(s *EapSession) ([]byte, error) {
+	challenge, err := msChapV2Challenge()
+	if err != nil {
+		return nil, err
+	}
+	s.Data = &eapMSCHAPv2State{challenge: challenge}
+
+	data := make([]byte, 1+msChapV2ChallengeSize+len(m.Name))
+	data[0] = msChapV2ChallengeSize
+	copy(data[1:], challenge)
+	copy(data[1+msChapV2ChallengeSize:], m.Name)
+	return m.packet(s, MsChapV2OpCodeChallenge, data), nil
+}
+
+func (m *EapMSCHAPv2) Process(s *EapSession, data []byte) (EapStatus, []byte, error) {
+	st, ok := s.Data.(*eapMSCHAPv2State)
+	if !ok {
+		return EapStatusFailure, nil, errors.New("EAP-MSCHAPv2 conversation not started")
+	}
+	p, err := MsChapV2PacketFromEap(&EapPacket{Code: EapCodeResponse, Type: EapTypeMSCHAPV2, Data: data})
+	if err != nil {
+		return EapStatusFailure, nil, err
+	}
+
+	switch {
+	case st.result == EapStatusSuccess && p.OpCode == MsChapV2OpCodeSuccess:
+		s.MSK = st.msk
+		return EapStatusSuccess, nil, nil
+	case st.result == EapStatusContinue && p.OpCode == MsChapV2OpCodeResponse:
+		return m.verify(s, st, p.Data)
+	case st.result == EapStatusContinue && p.OpCode == MsChapV2OpCodeChangePassword && st.oldHash != nil:
+		return m.changePassword(s, st, p.Data)
+	}
+	// Failure-Response, or a packet out of sequence
+	return EapStatusFailure, nil, nil
+}
+
+// verify checks the Value and Name of a Response packet.
+func (m *EapMSCHAPv2) verify(s *EapSession, st *eapMSCHAPv2State, data []byte) (EapStatus, []byte, error) {
+	if len(data) < 1+msChapV2ResponseSize || data[0] != msChapV2ResponseSize {
+		return EapStatusFailure, nil, fmt.Errorf("invalid EAP-MSCHAPv2 Response length %d", len(data))
+	}
+	peerChallenge := data[1:17]
+	ntResponse := data[25:49]
+	name := string(data[1+msChapV2ResponseSize:])
+	if !strings.EqualFold(msChapV2Account(name), msChapV2Account(s.Identity)) {
+		// the credentials of another user than the EAP identity
+		return m.fail(s, st, MsChapErrorAuthenticationFailure)
+	}
+
+	ntHash, err := m.Lookup(name)
+	expired := errors.Is(err, MsChapErrorPasswordExpired)
+	if err != nil && !expired {
+		code := MsChapErrorAuthenticationFailure
+		errors.As(err, &code)
+		return m.fail(s, st, code)
+	}
+	if checkNTHash(ntHash) != nil {
+		return m.fail(s, st, MsChapErrorAuthenticationFailure)
+	}
+	ok, err := msChapV2Verify(st.challenge, peerChallenge, ntResponse, name, ntHash)
+	switch {
+	case err != nil:
+		return EapStatusFailure, nil, err
+	case !ok:
+		return m.fail(s, st, MsChapErrorAuthenticationFailure)
+	case expired:
+		// the peer proved the old password: let it choose a new one
+		st.name = name
+		st.oldHash = ntHash
+		return m.fail(s, st, MsChapErrorPasswordExpired)
+	}
+	return m.succeed(s, st, ntHash, ntResponse, peerChallenge, name)
+}
+
+// msChapV2Account returns the account of an EAP identity or MS-CHAPv2 Name,
+// without Windows domain or NAI realm.
+func msChapV2Account(name string) string {
+	name = msChapUserName(name)
+	if i := strings.IndexByte(name, '@'); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// changePassword handles a Change-Password packet answering a Failure-Request
+// with E=648 (RFC 2759 §7).
+func (m *EapMSCHAPv2) changePassword(s *EapSession, st *eapMSCHAPv2State, data []byte) (EapStatus, []byte, error) {
+	cp, err := parseMsChapV2ChangePassword(data)
+	if err != nil {
+		return EapStatusFailure, nil, err
+	}
+	newPassword, newHash, err := cp.newPassword(st.oldHash)
+	if err != nil {
+		return m.fail(s, st, MsChapErrorAuthenticationFailure)
+	}
+	ok, err := msChapV2Verify(st.challenge, cp.peerChallenge, cp.ntResponse, st.name, newHash)
+	if err != nil {
+		return EapStatusFailure, nil, err
+	}
+	if !ok {
+		return m.fail(s, st, MsChapErrorAuthenticationFailure)
+	}
+	if err := m.ChangePassword(st.name, newPassword); err != nil {
+		code := MsChapErrorChangingPassword
+		errors.As(err, &code)
+		return m.fail(s, st, code)
+	}
+	return m.succeed(s, st, newHash, cp.ntResponse, cp.peerChallenge, st.name)
+}
+
+// succeed sends the Success-Request and prepares the MSK.
+func (m *EapMSCHAPv2) succeed(s *EapSession, st *eapMSCHAPv2State, ntHash, ntResponse, peerChallenge []byte, name string) (EapStatus, []byte, error) {
+	masterKey := MSCHAPv2MasterKey(ntHash, ntResponse)
+	// MSK = server MS-MPPE-Recv-Key | MS-MPPE-Send-Key
+	st.msk = append(MSCHAPv2AsymmetricStartKey(masterKey, 16, false, true),
+		MSCHAPv2AsymmetricStartKey(masterKey, 16, true, true)...)
+	st.result = EapStatusSuccess
+
+	auth := MSCHAPv2AuthenticatorResponse(ntHash, ntResponse, peerChallenge, st.challenge, msChapUserName(name))
+	return EapStatusContinue, m.packet(s, MsChapV2OpCodeSuccess, []byte(auth+" M=Authentication succeeded")), nil
+}
+
+// fail sends a Failure-Request with a new challenge. The peer may retry a
+// wrong password while retries are left, and change an expired one when
+// ChangePassword is set.
+func (m *EapMSCHAPv2) fail(s *EapSession, st *eapMSCHAPv2State, code MsChapError) (EapStatus, []byte, error) {
+	challenge, err := msChapV2Challenge()
+	if err != nil {
+		return EapStatusFailure, nil, err
+	}
+	st.challenge = challenge
+
+	f := &MsChapV2Failure{Error: code, Challenge: challenge, Version: 3, Message: code.Error()}
+	switch {
+	case code == MsChapErrorAuthenticationFailure && st.retries < m.Retries:
+		st.retries++
+		f.Retry = true
+	case code == MsChapErrorPasswordExpired && m.ChangePassword != nil:
+	default:
+		st.result = EapStatusFailure
+	}
+	if code != MsChapErrorPasswordExpired {
+		st.oldHash = nil
+	}
+	return EapStatusContinue, m.packet(s, MsChapV2OpCodeFailure, []byte(f.String())), nil
+}
+
+// msChapV2Verify compares an NT-Response in constant time.
+func msChapV2Verify(challenge, peerChallenge, ntResponse []byte, name string, ntHash []byte) (bool, error) {
+	expected, err := MSCHAPv2NTResponseHash(challenge, peerChallenge, msChapUserName(name), ntHash)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(expected, ntResponse) == 1, nil
+}
+
+// packet returns the Type-Data of an EAP-MSCHAPv2 request, whose
+// MS-CHAPv2-ID is the EAP identifier.
+func (m *EapMSCHAPv2) packet(s *EapSession, op MsChapV2OpCode, data []byte) []byte {
+	p := &MsChapV2Packet{
+		Eap:    &EapPacket{Code: EapCodeRequest, Identifier: s.Identifier, Type: EapTypeMSCHAPV2},
+		OpCode: op,
+		Data:   data,
+	}
+	return p.ToEap().Data
+}
+
+func msChapV2Challenge() ([]byte, error) {
+	challenge := make([]byte, msChapV2ChallengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// EapPeerMSCHAPv2 is the peer side of EAP-MSCHAPv2, an EapPeerMethod. It
+// answers the Challenge, checks the authenticator response of the server
+// and acknowledges Success and Failure requests without retrying.
+type EapPeerMSCHAPv2 struct {
+	Username string
+	Password string
+	// Failure is the last Failure-Request received.
+	Failure *MsChapV2Failure
+
+	challenge, peerChallenge, ntResponse []byte
+}
+
+// NewEapPeerMSCHAPv2 returns an EAP-MSCHAPv2 peer for username and password.
+func NewEapPeerMSCHAPv2(username, password string) *EapPeerMSCHAPv2 {
+	return &EapPeerMSCHAPv2{Username: username, Password: password}
+}
+
+func (m *EapPeerMSCHAPv2) Type() EapType { return EapTypeMSCHAPV2 }
+
+func (m *EapPeerMSCHAPv2) Process(req *EapPacket) ([]byte, error) {
+	p, err := MsChapV2PacketFromEap(req)
+	if err != nil {
+		return nil, err
+	}
+	resp := &MsChapV2Packet{
+		Eap:    &EapPacket{Code: EapCodeResponse, Identifier: req.Identifier, Type: EapTypeMSCHAPV2},
+		OpCode: p.OpCode,
+	}
+	switch p.OpCode {
+	case MsChapV2OpCodeChallenge:
+		if resp.Data, err = m.response(p.Data); err != nil {
+			return nil, err
+		}
+		resp.OpCode = MsChapV2OpCodeResponse
+	case MsChapV2OpCodeSuccess:
+		if m.ntResponse == nil {
+			return nil, errors.New("EAP-MSCHAPv2 Success before Response")
+		}
+		ntHash := MSCHAPv2NTHash(m.Password)
+		auth := MSCHAPv2AuthenticatorResponse(ntHash, m.ntResponse, m.peerChallenge, m.challenge, msChapUserName(m.Username))
+		if len(p.Data) < len(auth) || subtle.ConstantTimeCompare(p.Data[:len(auth)], []byte(auth)) != 1 {
+			return nil, errors.New("invalid EAP-MSCHAPv2 authenticator response")
+		}
+	case MsChapV2OpCodeFailure:
+		if m.Failure, err = ParseMsChapV2Failure(string(p.Data)); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unexpected EAP-MSCHAPv2 request %d", p.OpCode)
+	}
+	return resp.ToEap().Data, nil
+}
+
+// response returns the Response data answering a Challenge.
+func (m *EapPeerMSCHAPv2) response(data []byte) ([]byte, error) {
+	if len(data) < 1+msChapV2ChallengeSize || data[0] != msChapV2ChallengeSize {
+		return nil, fmt.Errorf("invalid EAP-MSCHAPv2 Challenge length %d", len(data))
+	}
+	m.challenge = append([]byte(nil), data[1:1+msChapV2ChallengeSize]...)
+	m.peerChallenge = make([]byte, msChapV2ChallengeSize)
+	if _, err := rand.Read(m.peerChallenge); err != nil {
+		return nil, err
+	}
+	var err error
+	m.ntResponse, err = MSCHAPv2NTResponse(m.challenge, m.peerChallenge, msChapUserName(m.Username), m.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	// Value: Peer-Challenge, 8 reserved bytes, NT-Response, Flags
+	out := make([]byte, 1+msChapV2ResponseSize+len(m.Username))
+	out[0] = msChapV2ResponseSize
+	copy(out[1:17], m.peerChallenge)
+	copy(out[25:49], m.ntResponse)
+	copy(out[1+msChapV2ResponseSize:], m.Username)
+	return out, nil
+}
@@ -14,7 +14,9 @@ import (
 	"bytes"
 	"context"
 	cryptorand "crypto/rand"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -333,3 +335,218 @@ func runEAPMSCHAPv2(t *testing.T, serverAddr, radiusSecret, username, password s
 		t.Fatal("test timed out")
 	}
 }
+
+// ── EapMSCHAPv2 method ────────────────────────────────────────────────────────
+
+// msChapV2PeerResponse answers an EAP-MSCHAPv2 Challenge request and returns
+// the Response with the data needed to check the authenticator response.
+func msChapV2PeerResponse(t *testing.T, req *EapPacket, username, password string) (resp *EapPacket, challenge, peerChallenge, ntResponse []byte) {
+	t.Helper()
+	mschap, err := MsChapV2PacketFromEap(req)
+	if err != nil || len(mschap.Data) < 17 {
+		t.Fatalf("bad MSCHAPv2 request %v: %v", req, err)
+	}
+	challenge = mschap.Data[1:17]
+	peerChallenge = bytes.Repeat([]byte{0x21}, 16)
+	ntResponse, err = MSCHAPv2NTResponse(challenge, peerChallenge, msChapUserName(username), password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 50+len(username))
+	data[0] = 49
+	copy(data[1:17], peerChallenge)
+	copy(data[25:49], ntResponse)
+	copy(data[50:], username)
+	base := &EapPacket{Code: EapCodeResponse, Identifier: req.Identifier, Type: EapTypeMSCHAPV2}
+	return (&MsChapV2Packet{Eap: base, OpCode: MsChapV2OpCodeResponse, Data: data}).ToEap(), challenge, peerChallenge, ntResponse
+}
+
+// msChapV2Message returns the message of a Success or Failure request.
+func msChapV2Message(t *testing.T, req *EapPacket, op MsChapV2OpCode) string {
+	t.Helper()
+	mschap, err := MsChapV2PacketFromEap(req)
+	if err != nil || mschap.OpCode != op {
+		t.Fatalf("request = %v; want MSCHAPv2 %v", req, op)
+	}
+	return string(mschap.Data)
+}
+
+func msChapV2ShortResponse(req *EapPacket, op MsChapV2OpCode) *EapPacket {
+	base := &EapPacket{Code: EapCodeResponse, Identifier: req.Identifier, Type: EapTypeMSCHAPV2}
+	return (&MsChapV2Packet{Eap: base, OpCode: op}).ToEap()
+}
+
+func TestEapMSCHAPv2(t *testing.T) {
+	m := NewEapMSCHAPv2("server", MsChapV2PasswordLookup(func(username string) (string, error) {
+		switch username {
+		case `CORP\alice`:
+			return "secret", nil
+		case "disabled":
+			return "", MsChapErrorAccountDisabled
+		}
+		return "", errors.New("unknown user")
+	}))
+	m.Retries = 1
+	e := NewEapServer(m)
+	start := func(user string) (*EapPacket, []byte) {
+		identity := &EapPacket{Code: EapCodeResponse, Identifier: 1, Type: EapTypeIdentity, Data: []byte(user)}
+		reply, req, state := eapExchange(t, e, identity, nil)
+		if reply.Code != AccessChallenge || req.Type != EapTypeMSCHAPV2 {
+			t.Fatalf("Identity reply = %v %v", reply.Code, req)
+		}
+		if got := string(req.Data[4+1+16:]); got != "server" {
+			t.Errorf("Challenge name = %q; want server", got)
+		}
+		return req, state
+	}
+
+	t.Run("success", func(t *testing.T) {
+		req, state := start(`CORP\alice`)
+		resp, challenge, peerChallenge, ntResponse := msChapV2PeerResponse(t, req, `CORP\alice`, "secret")
+		reply, req, state := eapExchange(t, e, resp, state)
+		if reply.Code != AccessChallenge {
+			t.Fatalf("Response reply = %v", reply.Code)
+		}
+		ntHash := MSCHAPv2NTHash("secret")
+		want := MSCHAPv2AuthenticatorResponse(ntHash, ntResponse, peerChallenge, challenge, "alice") + " M="
+		if got := msChapV2Message(t, req, MsChapV2OpCodeSuccess); !strings.HasPrefix(got, want) {
+			t.Errorf("Success-Request = %q; want %q...", got, want)
+		}
+
+		reply, result, _ := eapExchange(t, e, msChapV2ShortResponse(req, MsChapV2OpCodeSuccess), state)
+		if reply.Code != AccessAccept || result.Code != EapCodeSuccess {
+			t.Fatalf("Success-Response reply = %v %v", reply.Code, result)
+		}
+		masterKey := MSCHAPv2MasterKey(ntHash, ntResponse)
+		vsa := reply.GetVSA(VendorMicrosoft, AttrMSMPPESendKey)
+		if vsa == nil {
+			t.Fatalf("Access-Accept lacks MS-MPPE-Send-Key")
+		}
+		key, err := DecryptTunnelPassword(vsa.Value, "secret", reply.Authenticator[:])
+		if want := MSCHAPv2AsymmetricStartKey(masterKey, 16, true, true); err != nil || !bytes.Equal(key, want) {
+			t.Errorf("MS-MPPE-Send-Key = %X, %v; want %X", key, err, want)
+		}
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		req, state := start(`CORP\alice`)
+		resp, _, _, _ := msChapV2PeerResponse(t, req, `CORP\alice`, "wrong")
+		_, req, state = eapExchange(t, e, resp, state)
+		msg := msChapV2Message(t, req, MsChapV2OpCodeFailure)
+		if !strings.HasPrefix(msg, "E=691 R=1 C=") || !strings.Contains(msg, " V=3 M=") {
+			t.Fatalf("Failure-Request = %q", msg)
+		}
+
+		// the retry answers the new challenge carried by the Failure-Request
+		f, err := ParseMsChapV2Failure(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		retry := &EapPacket{Code: EapCodeRequest, Identifier: req.Identifier, Type: EapTypeMSCHAPV2,
+			Data: append([]byte{1, req.Identifier, 0, 0, 16}, f.Challenge...)}
+		resp, _, _, _ = msChapV2PeerResponse(t, retry, `CORP\alice`, "secret")
+		reply, req, state := eapExchange(t, e, resp, state)
+		if reply.Code != AccessChallenge {
+			t.Fatalf("retry reply = %v", reply.Code)
+		}
+		msChapV2Message(t, req, MsChapV2OpCodeSuccess)
+		if reply, _, _ = eapExchange(t, e, msChapV2ShortResponse(req, MsChapV2OpCodeSuccess), state); reply.Code != AccessAccept {
+			t.Errorf("Success-Response reply = %v", reply.Code)
+		}
+	})
+
+	t.Run("identity mismatch", func(t *testing.T) {
+		req, state := start("bob@corp.example")
+		resp, _, _, _ := msChapV2PeerResponse(t, req, `CORP\alice`, "secret")
+		_, req, _ = eapExchange(t, e, resp, state)
+		if msg := msChapV2Message(t, req, MsChapV2OpCodeFailure); !strings.HasPrefix(msg, "E=691 ") {
+			t.Errorf("Failure-Request = %q; want E=691...", msg)
+		}
+	})
+
+	testCases := []struct {
+		user, password, want string
+	}{
+		{"disabled", "x", "E=647 R=0 "},
+		{"nobody", "x", "E=691 R=1 "},
+		{`CORP\alice`, "wrong", "E=691 R=1 "},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.user, func(t *testing.T) {
+			req, state := start(tc.user)
+			resp, _, _, _ := msChapV2PeerResponse(t, req, tc.user, tc.password)
+			_, req, state = eapExchange(t, e, resp, state)
+			if msg := msChapV2Message(t, req, MsChapV2OpCodeFailure); !strings.HasPrefix(msg, tc.want) {
+				t.Errorf("Failure-Request = %q; want %q...", msg, tc.want)
+			}
+			reply, result, _ := eapExchange(t, e, msChapV2ShortResponse(req, MsChapV2OpCodeFailure), state)
+			if reply.Code != AccessReject || result.Code != EapCodeFailure {
+				t.Errorf("Failure-Response reply = %v %v", reply.Code, result)
+			}
+		})
+	}
+}
+
+func TestEapMSCHAPv2ChangePassword(t *testing.T) {
+	passwords := map[string]string{"bob": "old"}
+	m := NewEapMSCHAPv2("server", MsChapV2PasswordLookup(func(username string) (string, error) {
+		if passwords[username] == "old" {
+			return "old", MsChapErrorPasswordExpired
+		}
+		return passwords[username], nil
+	}))
+	m.ChangePassword = func(username, newPassword string) error {
+		if len(newPassword) < 4 {
+			return MsChapErrorChangingPassword
+		}
+		passwords[username] = newPassword
+		return nil
+	}
+	e := NewEapServer(m)
+
+	expire := func() (*EapPacket, []byte) {
+		identity := &EapPacket{Code: EapCodeResponse, Identifier: 1, Type: EapTypeIdentity, Data: []byte("bob")}
+		_, req, state := eapExchange(t, e, identity, nil)
+		resp, _, _, _ := msChapV2PeerResponse(t, req, "bob", "old")
+		_, req, state = eapExchange(t, e, resp, state)
+		if msg := msChapV2Message(t, req, MsChapV2OpCodeFailure); !strings.HasPrefix(msg, "E=648 R=0 C=") {
+			t.Fatalf("Failure-Request = %q; want E=648", msg)
+		}
+		return req, state
+	}
+
+	req, state := expire()
+	cp, err := NewMsChapV2ChangePassword(req, "bob", "old", "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, req, state = eapExchange(t, e, cp.ToEap(), state)
+	if msg := msChapV2Message(t, req, MsChapV2OpCodeFailure); !strings.HasPrefix(msg, "E=709 R=0 ") {
+		t.Errorf("rejected change Failure-Request = %q; want E=709", msg)
+	}
+
+	req, state = expire()
+	if cp, err = NewMsChapV2ChangePassword(req, "bob", "wrong", "new-password"); err != nil {
+		t.Fatal(err)
+	}
+	_, req, state = eapExchange(t, e, cp.ToEap(), state)
+	if msg := msChapV2Message(t, req, MsChapV2OpCodeFailure); !strings.HasPrefix(msg, "E=691 ") {
+		t.Errorf("wrong old password Failure-Request = %q; want E=691", msg)
+	}
+	if passwords["bob"] != "old" {
+		t.Fatalf("password changed to %q", passwords["bob"])
+	}
+
+	req, state = expire()
+	if cp, err = NewMsChapV2ChangePassword(req, "bob", "old", "new-password"); err != nil {
+		t.Fatal(err)
+	}
+	_, req, state = eapExchange(t, e, cp.ToEap(), state)
+	msChapV2Message(t, req, MsChapV2OpCodeSuccess)
+	if reply, _, _ := eapExchange(t, e, msChapV2ShortResponse(req, MsChapV2OpCodeSuccess), state); reply.Code != AccessAccept {
+		t.Errorf("Success-Response reply = %v", reply.Code)
+	}
+	if passwords["bob"] != "new-password" {
+		t.Errorf("password = %q; want new-password", passwords["bob"])
+	}
+}
@@ -0,0 +1,325 @@
+package radius
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PEAP Result TLV (draft-kamath-pppext-peapv0 §2.2).
+const (
+	peapTLVMandatory     = 0x8000
+	peapTLVResult        = 3
+	peapResultSuccess    = 1
+	peapResultFailure    = 2
+	peapResultTLVPayload = 2
+)
+
+// EapPEAP is the server side of PEAPv0 (draft-kamath-pppext-peapv0), an
+// EapMethod.
+//
+// Once the TLS tunnel is established, the inner identity is requested and
+// the inner EAP methods run through it: the inner packets are sent without
+// their EAP header, except for the final Result TLV. On success the MSK and
+// EMSK are exported from the TLS keying material. Cryptobinding is not
+// supported.
+type EapPEAP struct {
+	// Config is the server TLS configuration.
+	Config *tls.Config
+	// Methods are the inner methods, in order of preference, such as
+	// EapMSCHAPv2 or EapGTC.
+	Methods []EapMethod
+	// FragmentSize is the largest TLS data sent in one EAP packet.
+	FragmentSize int
+	// HandshakeTimeout bounds the whole handshake.
+	HandshakeTimeout time.Duration
+}
+
+// NewEapPEAP returns a PEAPv0 method running the inner methods in a TLS
+// tunnel using a copy of config.
+func NewEapPEAP(config *tls.Config, methods ...EapMethod) *EapPEAP {
+	return &EapPEAP{
+		Config:           config.Clone(),
+		Methods:          methods,
+		FragmentSize:     DefaultEapTLSFragmentSize,
+		HandshakeTimeout: DefaultEapTLSHandshakeTimeout,
+	}
+}
+
+// eapPEAPState is the per-conversation state of EapPEAP.
+type eapPEAPState struct {
+	t     *eapTLSTunnel
+	up    bool
+	inner *EapSession
+	// result is the status of the Result TLV sent, 0 before.
+	result uint16
+}
+
+// Close stops the tunnel and the inner method.
+func (st *eapPEAPState) Close() error {
+	if st.inner != nil {
+		closeEapData(st.inner.Data)
+	}
+	return st.t.Close()
+}
+
+func (m *EapPEAP) Type() EapType { return EapTypePEAP }
+
+func (m *EapPEAP) Start(s *EapSession) ([]byte, error) {
+	t := newEapTLSServerTunnel(m.Config, m.FragmentSize, m.HandshakeTimeout)
+	s.Data = &eapPEAPState{t: t}
+	return t.start(), nil
+}
+
+func (m *EapPEAP) Process(s *EapSession, data []byte) (EapStatus, []byte, error) {
+	st, ok := s.Data.(*eapPEAPState)
+	if !ok {
+		return EapStatusFailure, nil, errors.New("PEAP conversation not started")
+	}
+	done, msg, out, err := st.t.serverStep(data)
+	if err != nil {
+		return EapStatusFailure, nil, err
+	}
+	if !done {
+		return EapStatusContinue, out, nil
+	}
+	if !st.up {
+		// phase 2: ask for the inner identity
+		st.up = true
+		st.inner = &EapSession{Request: s.Request}
+		return m.send(s, st, &EapPacket{Code: EapCodeRequest, Type: EapTypeIdentity})
+	}
+
+	app, err := st.t.readApp(msg)
+	if err != nil {
+		return EapStatusFailure, nil, err
+	}
+	resp, err := peapDecode(app, EapCodeResponse, s.Identifier-1)
+	if err != nil {
+		return EapStatusFailure, nil, err
+	}
+	if st.result != 0 {
+		return m.finish(s, st, resp)
+	}
+
+	inner := st.inner
+	inner.Request = s.Request
+	inner.Identifier = s.Identifier
+	switch {
+	case resp.Type == EapTypeIdentity && inner.Method == 0:
+		inner.Identity = string(resp.Data)
+		return m.startMethod(s, st, m.Methods)
+	case resp.ExpandedType() == EapExpandedNak && inner.Method != 0:
+		return m.startMethod(s, st, eapNakMethods(m.Methods, resp))
+	case resp.ExpandedType() != inner.MethodType || inner.Method == 0:
+		return m.result(s, st, peapResultFailure)
+	}
+
+	status, typeData, err := eapMethodByType(m.Methods, inner.MethodType).Process(inner, resp.Data)
+	if err != nil {
+		return EapStatusFailure, nil, fmt.Errorf("PEAP inner %s: %v", inner.MethodType, err)
+	}
+	switch status {
+	case EapStatusContinue:
+		return m.send(s, st, innerRequest(inner, typeData))
+	case EapStatusSuccess:
+		return m.result(s, st, peapResultSuccess)
+	}
+	return m.result(s, st, peapResultFailure)
+}
+
+// startMethod starts the first inner method of candidates not tried yet.
+func (m *EapPEAP) startMethod(s *EapSession, st *eapPEAPState, candidates []EapMethod) (EapStatus, []byte, error) {
+	inner := st.inner
+	for _, method := range candidates {
+		t := eapMethodType(method)
+		if inner.hasTried(t) {
+			continue
+		}
+		inner.tried = append(inner.tried, t)
+		inner.Method = method.Type()
+		inner.MethodType = t
+		closeEapData(inner.Data)
+		inner.Data = nil
+		typeData, err := method.Start(inner)
+		if err != nil {
+			return EapStatusFailure, nil, fmt.Errorf("PEAP inner %s: %v", inner.MethodType, err)
+		}
+		return m.send(s, st, innerRequest(inner, typeData))
+	}
+	return m.result(s, st, peapResultFailure)
+}
+
+// result sends the Result TLV concluding the inner authentication.
+func (m *EapPEAP) result(s *EapSession, st *eapPEAPState, status uint16) (EapStatus, []byte, error) {
+	st.result = status
+	s.Reply = append(s.Reply, st.inner.Reply...)
+	return m.send(s, st, &EapPacket{Code: EapCodeRequest, Type: EapTypeTLV, Data: peapResultTLV(status)})
+}
+
+// finish ends the conversation once the peer answered the Result TLV.
+func (m *EapPEAP) finish(s *EapSession, st *eapPEAPState, resp *EapPacket) (EapStatus, []byte, error) {
+	if st.result != peapResultSuccess {
+		return EapStatusFailure, nil, nil
+	}
+	if resp.Type != EapTypeTLV {
+		return EapStatusFailure, nil, errors.New("PEAP response to the Result TLV is not a TLV")
+	}
+	status, err := peapParseResultTLV(resp.Data)
+	if err != nil {
+		return EapStatusFailure, nil, err
+	}
+	if status != peapResultSuccess {
+		return EapStatusFailure, nil, nil
+	}
+	// the inner methods authenticate the inner identity
+	s.InnerIdentity = st.inner.Identity
+	if s.MSK, s.EMSK, err = st.t.keys("client EAP encryption", EapTypePEAP); err != nil {
+		return EapStatusFailure, nil, err
+	}
+	return EapStatusSuccess, nil, nil
+}
+
+// innerRequest returns the request of the current inner method.
+func innerRequest(inner *EapSession, typeData []byte) *EapPacket {
+	eap := &EapPacket{Code: EapCodeRequest, Data: typeData}
+	eap.SetExpandedType(inner.MethodType)
+	return eap
+}
+
+// send writes an inner request, whose identifier is the one of the outer
+// request carrying it, to the tunnel.
+func (m *EapPEAP) send(s *EapSession, st *eapPEAPState, eap *EapPacket) (EapStatus, []byte, error) {
+	eap.Identifier = s.Identifier
+	if _, err := st.t.tls.Write(peapEncode(eap)); err != nil {
+		return EapStatusFailure, nil, err
+	}
+	return EapStatusContinue, st.t.flush(), nil
+}
+
+// peapEncode encodes an inner EAP packet: PEAPv0 strips the EAP header but
+// for the TLV packets.
+func peapEncode(eap *EapPacket) []byte {
+	b := eap.Encode()
+	if eap.Type == EapTypeTLV {
+		return b
+	}
+	return b[4:]
+}
+
+// peapDecode decodes an inner EAP packet, restoring the stripped header with
+// code and identifier.
+func peapDecode(b []byte, code EapCode, identifier uint8) (*EapPacket, error) {
+	if len(b) >= 5 && EapCode(b[0]) == code && int(binary.BigEndian.Uint16(b[2:4])) == len(b) && EapType(b[4]) == EapTypeTLV {
+		return EapDecode(b)
+	}
+	if len(b) < 1 {
+		return nil, errors.New("empty PEAP inner packet")
+	}
+	full := make([]byte, 4+len(b))
+	full[0] = byte(code)
+	full[1] = identifier
+	binary.BigEndian.PutUint16(full[2:4], uint16(len(full)))
+	copy(full[4:], b)
+	return EapDecode(full)
+}
+
+func peapResultTLV(status uint16) []byte {
+	b := make([]byte, 6)
+	binary.BigEndian.PutUint16(b[0:2], peapTLVMandatory|peapTLVResult)
+	binary.BigEndian.PutUint16(b[2:4], peapResultTLVPayload)
+	binary.BigEndian.PutUint16(b[4:6], status)
+	return b
+}
+
+// peapParseResultTLV returns the status of the Result TLV of a TLV packet.
+func peapParseResultTLV(b []byte) (uint16, error) {
+	for len(b) >= 4 {
+		typ := binary.BigEndian.Uint16(b[0:2]) &^ peapTLVMandatory
+		n := int(binary.BigEndian.Uint16(b[2:4]))
+		if len(b) < 4+n {
+			break
+		}
+		if typ == peapTLVResult && n == peapResultTLVPayload {
+			return binary.BigEndian.Uint16(b[4:6]), nil
+		}
+		b = b[4+n:]
+	}
+	return 0, errors.New("PEAP TLV packet without Result TLV")
+}
+
+// EapPeerPEAP is the peer side of PEAPv0, an EapPeerMethod.
+type EapPeerPEAP struct {
+	// Config is the client TLS configuration, with the roots and name used
+	// to verify the server.
+	Config *tls.Config
+	// Identity is the inner identity.
+	Identity string
+	// Method is the inner method.
+	Method EapPeerMethod
+	// FragmentSize is the largest TLS data sent in one EAP packet.
+	FragmentSize int
+	// MSK and EMSK are exported once the tunnel is established.
+	MSK, EMSK []byte
+
+	t *eapTLSTunnel
+}
+
+// NewEapPeerPEAP returns a PEAPv0 peer authenticating identity with method
+// inside the tunnel.
+func NewEapPeerPEAP(config *tls.Config, identity string, method EapPeerMethod) *EapPeerPEAP {
+	return &EapPeerPEAP{Config: config, Identity: identity, Method: method, FragmentSize: DefaultEapTLSFragmentSize}
+}
+
+func (m *EapPeerPEAP) Type() EapType { return EapTypePEAP }
+
+func (m *EapPeerPEAP) Process(req *EapPacket) ([]byte, error) {
+	if len(req.Data) > 0 && req.Data[0]&eapTLSFlagStart != 0 {
+		if m.t != nil {
+			m.t.Close()
+		}
+		m.t = newEapTLSClientTunnel(m.Config, m.FragmentSize)
+		m.MSK, m.EMSK = nil, nil
+	}
+	if m.t == nil {
+		return nil, errors.New("PEAP request before Start")
+	}
+	done, app, out, err := m.t.peerStep(req.Data)
+	if err != nil || !done {
+		return out, err
+	}
+	if m.MSK == nil {
+		if m.MSK, m.EMSK, err = m.t.keys("client EAP encryption", EapTypePEAP); err != nil {
+			return nil, err
+		}
+	}
+	if len(app) == 0 {
+		return m.t.ack(), nil
+	}
+
+	inner, err := peapDecode(app, EapCodeRequest, req.Identifier)
+	if err != nil {
+		return nil, err
+	}
+	resp := &EapPacket{Code: EapCodeResponse, Identifier: inner.Identifier, Type: inner.Type}
+	switch inner.Type {
+	case EapTypeIdentity:
+		resp.Data = []byte(m.Identity)
+	case EapTypeTLV:
+		status, err := peapParseResultTLV(inner.Data)
+		if err != nil {
+			return nil, err
+		}
+		resp.Data = peapResultTLV(status)
+	default:
+		if resp, err = eapPeerResponse(m.Method, inner); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := m.t.tls.Write(peapEncode(resp)); err != nil {
+		return nil, err
+	}
+	return m.t.flush(), nil
+}
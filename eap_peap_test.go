@@ -0,0 +1,119 @@
+package radius
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"testing"
+)
+
+// testPeerMSCHAPv2 answers EAP-MSCHAPv2 requests for a user.
+type testPeerMSCHAPv2 struct {
+	t                  *testing.T
+	username, password string
+}
+
+func (p testPeerMSCHAPv2) Type() EapType { return EapTypeMSCHAPV2 }
+
+func (p testPeerMSCHAPv2) Process(req *EapPacket) ([]byte, error) {
+	mschap, err := MsChapV2PacketFromEap(req)
+	if err != nil {
+		return nil, err
+	}
+	if mschap.OpCode != MsChapV2OpCodeChallenge {
+		return msChapV2ShortResponse(req, mschap.OpCode).Data, nil
+	}
+	resp, _, _, _ := msChapV2PeerResponse(p.t, req, p.username, p.password)
+	return resp.Data, nil
+}
+
+func TestEapPEAP(t *testing.T) {
+	serverConfig, clientConfig := eapTLSTestConfigs(t)
+	clientConfig.Certificates = nil
+
+	gtc := NewEapGTC("Token:", func(username, response string) (bool, error) {
+		return username == "alice" && response == "123456", nil
+	})
+	mschap := NewEapMSCHAPv2("server", MsChapV2PasswordLookup(func(username string) (string, error) {
+		return "secret", nil
+	}))
+
+	testCases := []struct {
+		name     string
+		version  uint16
+		identity string
+		inner    EapPeerMethod
+		accept   bool
+	}{
+		{"TLS 1.2 MSCHAPv2", tls.VersionTLS12, "alice", testPeerMSCHAPv2{t, "alice", "secret"}, true},
+		{"TLS 1.3 MSCHAPv2", tls.VersionTLS13, "alice", testPeerMSCHAPv2{t, "alice", "secret"}, true},
+		{"TLS 1.3 GTC after Nak", tls.VersionTLS13, "alice", NewEapPeerGTC("123456"), true},
+		{"wrong password", tls.VersionTLS12, "alice", testPeerMSCHAPv2{t, "alice", "wrong"}, false},
+		{"wrong token", tls.VersionTLS13, "alice", NewEapPeerGTC("000000"), false},
+		{"credentials of another user", tls.VersionTLS12, "mallory", testPeerMSCHAPv2{t, "alice", "secret"}, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewEapPEAP(serverConfig, mschap, gtc)
+			m.Config.MaxVersion = tc.version
+			e := NewEapServer(m)
+			var inner string
+			e.SetAcceptFunc(func(ctx context.Context, s *EapSession, reply *Packet) bool {
+				inner = s.InnerIdentity
+				return true
+			})
+
+			peer := NewEapPeerPEAP(clientConfig, tc.identity, tc.inner)
+			reply := eapPeerConversation(t, e, "anonymous", peer)
+			if !tc.accept {
+				if reply.Code != AccessReject {
+					t.Fatalf("reply = %v; want Access-Reject", reply.Code)
+				}
+				return
+			}
+			if reply.Code != AccessAccept {
+				t.Fatalf("reply = %v; want Access-Accept", reply.Code)
+			}
+			if inner != "alice" {
+				t.Errorf("InnerIdentity = %q; want alice", inner)
+			}
+			vsa := reply.GetVSA(VendorMicrosoft, AttrMSMPPERecvKey)
+			if vsa == nil {
+				t.Fatalf("Access-Accept lacks MS-MPPE-Recv-Key")
+			}
+			key, err := DecryptTunnelPassword(vsa.Value, "secret", reply.Authenticator[:])
+			if err != nil || !bytes.Equal(key, peer.MSK[:32]) {
+				t.Errorf("MS-MPPE-Recv-Key = %x, %v; want the peer MSK %x", key, err, peer.MSK[:32])
+			}
+		})
+	}
+}
+
+func TestPeapInnerPackets(t *testing.T) {
+	identity := &EapPacket{Code: EapCodeRequest, Identifier: 7, Type: EapTypeIdentity, Data: []byte("alice")}
+	b := peapEncode(identity)
+	if !bytes.Equal(b, []byte("\x01alice")) {
+		t.Errorf("peapEncode(Identity) = %q; want the header stripped", b)
+	}
+	p, err := peapDecode(b, EapCodeRequest, 7)
+	if err != nil || p.Identifier != 7 || p.Type != EapTypeIdentity || string(p.Data) != "alice" {
+		t.Errorf("peapDecode(%q) = %v, %v", b, p, err)
+	}
+
+	result := &EapPacket{Code: EapCodeResponse, Identifier: 8, Type: EapTypeTLV, Data: peapResultTLV(peapResultSuccess)}
+	b = peapEncode(result)
+	want := []byte{2, 8, 0, 11, 33, 0x80, 0x03, 0, 2, 0, 1}
+	if !bytes.Equal(b, want) {
+		t.Errorf("peapEncode(Result TLV) = %x; want %x", b, want)
+	}
+	p, err = peapDecode(b, EapCodeResponse, 0)
+	if err != nil || p.Identifier != 8 || p.Type != EapTypeTLV {
+		t.Fatalf("peapDecode(%x) = %v, %v", b, p, err)
+	}
+	if status, err := peapParseResultTLV(p.Data); err != nil || status != peapResultSuccess {
+		t.Errorf("Result TLV status = %d, %v; want success", status, err)
+	}
+	if _, err := peapParseResultTLV([]byte{0, 4, 0, 2, 0, 1}); err == nil {
+		t.Errorf("TLV without Result TLV accepted")
+	}
+}
@@ -0,0 +1,35 @@
+package radius
+
+// EapPeerMethod is the peer (supplicant) side of an EAP authentication
+// method. A peer method serves a single conversation and may keep its state
+// between requests.
+type EapPeerMethod interface {
+	// Type returns the EAP type of the method.
+	Type() EapType
+	// Process handles an EAP-Request of the method and returns the
+	// Type-Data of the EAP-Response.
+	Process(req *EapPacket) ([]byte, error)
+}
+
+// eapPeerResponse answers a request of method, or proposes method with a Nak,
+// an Expanded Nak when the request is of an expanded type (RFC 3748 §5.3).
+func eapPeerResponse(method EapPeerMethod, req *EapPacket) (*EapPacket, error) {
+	resp := &EapPacket{Code: EapCodeResponse, Identifier: req.Identifier}
+	switch {
+	case req.ExpandedType() == eapMethodType(method):
+		data, err := method.Process(req)
+		if err != nil {
+			return nil, err
+		}
+		resp.SetExpandedType(req.ExpandedType())
+		resp.Data = data
+	case req.Type == EapTypeExpandedTypes:
+		resp.Type = EapTypeExpandedTypes
+		resp.VendorType = EapExpandedNak.Type
+		resp.Data = NewEapExpandedNak(eapMethodType(method))
+	default:
+		resp.Type = EapTypeNak
+		resp.Data = []byte{byte(method.Type())}
+	}
+	return resp, nil
+}
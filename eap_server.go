@@ -0,0 +1,439 @@
+package radius
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// EapStatus is the outcome of an EAP method step.
+type EapStatus int
+
+const (
+	// EapStatusContinue sends another EAP-Request of the method.
+	EapStatusContinue EapStatus = iota
+	// EapStatusSuccess ends the conversation with EAP-Success.
+	EapStatusSuccess
+	// EapStatusFailure ends the conversation with EAP-Failure.
+	EapStatusFailure
+)
+
+// EapMethod is the server side of an EAP authentication method (RFC 3748 §5).
+//
+// Methods only produce and consume the Type-Data of their packets: the
+// EapServer adds the EAP header and the RADIUS transport. A method is shared
+// by all conversations and keeps its per-conversation state in
+// EapSession.Data, which is closed when it implements io.Closer and the
+// conversation ends, expires or moves to another method.
+type EapMethod interface {
+	// Type returns the EAP type of the method.
+	Type() EapType
+	// Start returns the Type-Data of the first EAP-Request of the method.
+	Start(s *EapSession) ([]byte, error)
+	// Process handles the Type-Data of an EAP-Response. With
+	// EapStatusContinue it returns the Type-Data of the next EAP-Request.
+	Process(s *EapSession, data []byte) (EapStatus, []byte, error)
+}
+
+// EapExpandedMethod is implemented by the methods of an expanded type
+// (RFC 3748 §5.7), server or peer side, whose Type returns
+// EapTypeExpandedTypes. Methods are registered and selected by this
+// (vendor, type) pair.
+type EapExpandedMethod interface {
+	ExpandedType() EapExpandedType
+}
+
+// eapMethodType returns the expanded type of a server or peer method.
+func eapMethodType(m interface{ Type() EapType }) EapExpandedType {
+	if x, ok := m.(EapExpandedMethod); ok {
+		return x.ExpandedType()
+	}
+	return EapExpandedType{Type: uint32(m.Type())}
+}
+
+// EapSession is an EAP conversation spanning several Access-Request /
+// Access-Challenge rounds, identified by the RADIUS State attribute.
+type EapSession struct {
+	// State is the RADIUS State attribute of the conversation.
+	State []byte
+	// Identity is the peer identity from the EAP-Response/Identity.
+	Identity string
+	// InnerIdentity is the identity authenticated inside a PEAP or EAP-TTLS
+	// tunnel, while Identity is the outer one, often anonymous. It is only
+	// set once the inner authentication succeeded.
+	InnerIdentity string
+	// Request is the Access-Request being processed.
+	Request *Packet
+	// Method is the type of the current method, 0 before one is started.
+	Method EapType
+	// MethodType is the expanded type of the current method, the vendor
+	// and type of an expanded method when Method is EapTypeExpandedTypes.
+	MethodType EapExpandedType
+	// Identifier is the identifier of the last EAP-Request sent.
+	Identifier uint8
+	// Data holds the method state of the conversation.
+	Data interface{}
+	// MSK is the Master Session Key exported by the method on success. Its
+	// first and second halves, up to 32 bytes each, are sent as
+	// MS-MPPE-Recv-Key and MS-MPPE-Send-Key.
+	MSK []byte
+	// EMSK is the Extended Master Session Key exported by the method, which
+	// is not sent to the NAS.
+	EMSK []byte
+	// Reply holds attributes added to the final Access-Accept or
+	// Access-Reject, for example an MS-CHAP error message.
+	Reply []AVP
+
+	tried   []EapExpandedType
+	expires time.Time
+	// busy is set while a round is processed.
+	busy bool
+	// challenge holds the attributes of the last Access-Challenge, resent
+	// to a retransmitted request.
+	challenge []AVP
+}
+
+// mppeKeySize is the size of MS-MPPE-Recv-Key and MS-MPPE-Send-Key.
+const mppeKeySize = 32
+
+// DefaultEapSessionTimeout is how long an EAP conversation waits for the
+// next Access-Request.
+const DefaultEapSessionTimeout = 60 * time.Second
+
+// stateSize is the size of generated State attributes.
+const stateSize = 16
+
+// EapServer is a Service authenticating Access-Requests with EAP
+// (RFC 3748, RFC 3579).
+//
+// The first round answers the EAP-Response/Identity with the first method
+// (or, for an empty EAP-Message, requests the identity). Further rounds are
+// matched to their conversation by the State attribute and handed to the
+// method until it succeeds or fails. A peer may propose other methods with
+// a Nak, which are tried in the server order of preference. A retransmitted
+// request is answered with the last Access-Challenge again, and requests out
+// of sequence are dropped without ending the conversation.
+//
+// The server replies with Access-Challenge carrying State and EAP-Message,
+// and ends with Access-Accept / EAP-Success (with MS-MPPE keys when the
+// method exported an MSK) or Access-Reject / EAP-Failure.
+// Message-Authenticator is required in requests and added when the reply is
+// encoded.
+type EapServer struct {
+	methods []EapMethod
+	accept  func(ctx context.Context, s *EapSession, reply *Packet) bool
+
+	mu       sync.Mutex
+	timeout  time.Duration
+	sessions map[string]*EapSession
+	swept    time.Time
+}
+
+// NewEapServer returns an EapServer offering methods in order of preference.
+func NewEapServer(methods ...EapMethod) *EapServer {
+	return &EapServer{
+		methods:  methods,
+		timeout:  DefaultEapSessionTimeout,
+		sessions: map[string]*EapSession{},
+	}
+}
+
+// SetSessionTimeout sets how long a conversation waits for the next round.
+func (e *EapServer) SetSessionTimeout(t time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.timeout = t
+}
+
+// SetAcceptFunc sets a function called before sending an Access-Accept, for
+// example to add authorization attributes. The request is rejected when it
+// returns false.
+func (e *EapServer) SetAcceptFunc(f func(ctx context.Context, s *EapSession, reply *Packet) bool) {
+	e.accept = f
+}
+
+// RadiusHandle processes an Access-Request carrying EAP-Message attributes.
+// Requests without EAP-Message are rejected; requests with EAP-Message but
+// without Message-Authenticator are silently discarded (RFC 3579 §3.3).
+func (e *EapServer) RadiusHandle(ctx context.Context, request *Packet) *Packet {
+	reply := request.Reply()
+
+	if !request.HasAVP(AttrEAPMessage) {
+		reply.Code = AccessReject
+		return reply
+	}
+	if !request.HasAVP(AttrMessageAuthenticator) {
+		// the decoder checked its value when present
+		log.Printf("EAP-Message without Message-Authenticator from %s dropped", request.ClientAddr)
+		return nil
+	}
+	eap := request.GetEAPMessage()
+	if eap == nil {
+		if len(request.GetConcatenatedAVP(AttrEAPMessage)) > 0 {
+			return e.fail(reply, nil, 0)
+		}
+		// EAP-Start (RFC 3579 §2.1): ask for the identity
+		s, err := e.newSession(request)
+		if err != nil {
+			log.Printf("EAP session failed: %v", err)
+			return e.fail(reply, nil, 0)
+		}
+		return e.challenge(reply, s, &EapPacket{Code: EapCodeRequest, Type: EapTypeIdentity})
+	}
+	if eap.Code != EapCodeResponse {
+		return e.fail(reply, nil, eap.Identifier)
+	}
+
+	var s *EapSession
+	if state := request.GetAVP(AttrState); state != nil {
+		var resend []AVP
+		var drop bool
+		s, resend, drop = e.takeSession(state.Value, eap.Identifier)
+		switch {
+		case resend != nil:
+			reply.Code = AccessChallenge
+			reply.AVPs = append(reply.AVPs, resend...)
+			return reply
+		case drop:
+			return nil
+		case s == nil:
+			return e.fail(reply, nil, eap.Identifier)
+		}
+	} else {
+		var err error
+		if s, err = e.newSession(request); err != nil {
+			log.Printf("EAP session failed: %v", err)
+			return e.fail(reply, nil, eap.Identifier)
+		}
+	}
+	s.Request = request
+	s.Identifier = eap.Identifier + 1
+
+	switch {
+	case eap.Type == EapTypeIdentity && s.Method == 0:
+		s.Identity = string(eap.Data)
+		return e.startMethod(ctx, reply, s, e.methods)
+	case eap.ExpandedType() == EapExpandedNak && s.Method != 0:
+		return e.startMethod(ctx, reply, s, eapNakMethods(e.methods, eap))
+	case eap.ExpandedType() != s.MethodType || s.Method == 0:
+		return e.fail(reply, s, eap.Identifier)
+	}
+
+	m := eapMethodByType(e.methods, s.MethodType)
+	status, data, err := m.Process(s, eap.Data)
+	if err != nil {
+		log.Printf("EAP %s failed for %q: %v", s.MethodType, s.Identity, err)
+		status = EapStatusFailure
+	}
+	return e.result(ctx, reply, s, status, data)
+}
+
+// startMethod starts the first method of candidates not tried yet.
+func (e *EapServer) startMethod(ctx context.Context, reply *Packet, s *EapSession, candidates []EapMethod) *Packet {
+	for _, m := range candidates {
+		t := eapMethodType(m)
+		if s.hasTried(t) {
+			continue
+		}
+		s.tried = append(s.tried, t)
+		s.Method = m.Type()
+		s.MethodType = t
+		closeEapData(s.Data)
+		s.Data = nil
+		data, err := m.Start(s)
+		if err != nil {
+			log.Printf("EAP %s failed for %q: %v", s.MethodType, s.Identity, err)
+			return e.fail(reply, s, s.Identifier-1)
+		}
+		return e.result(ctx, reply, s, EapStatusContinue, data)
+	}
+	return e.fail(reply, s, s.Identifier-1)
+}
+
+// eapNakMethods returns the methods proposed by a Nak or an Expanded Nak,
+// in the server order of preference. A legacy Nak proposing
+// EapTypeExpandedTypes selects the methods of expanded types.
+func eapNakMethods(methods []EapMethod, nak *EapPacket) []EapMethod {
+	var desired []EapExpandedType
+	if nak.Type == EapTypeNak {
+		for _, t := range nak.Data {
+			desired = append(desired, EapExpandedType{Type: uint32(t)})
+		}
+	} else {
+		desired, _ = ParseEapExpandedNak(nak.Data)
+	}
+	var out []EapMethod
+	for _, m := range methods {
+		mt := eapMethodType(m)
+		for _, t := range desired {
+			if t == mt || (t == EapExpandedType{Type: uint32(EapTypeExpandedTypes)} && m.Type() == EapTypeExpandedTypes) {
+				out = append(out, m)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func eapMethodByType(methods []EapMethod, t EapExpandedType) EapMethod {
+	for _, m := range methods {
+		if eapMethodType(m) == t {
+			return m
+		}
+	}
+	return nil
+}
+
+func (s *EapSession) hasTried(t EapExpandedType) bool {
+	for _, tried := range s.tried {
+		if tried == t {
+			return true
+		}
+	}
+	return false
+}
+
+// result builds the reply for a method step.
+func (e *EapServer) result(ctx context.Context, reply *Packet, s *EapSession, status EapStatus, data []byte) *Packet {
+	switch status {
+	case EapStatusContinue:
+		eap := &EapPacket{Code: EapCodeRequest, Data: data}
+		eap.SetExpandedType(s.MethodType)
+		return e.challenge(reply, s, eap)
+	case EapStatusSuccess:
+		reply.Code = AccessAccept
+		for _, avp := range s.Reply {
+			reply.AddAVP(avp)
+		}
+		if n := len(s.MSK) / 2; n > 0 {
+			if n > mppeKeySize {
+				n = mppeKeySize
+			}
+			if err := reply.SetMPPEKeys(s.MSK[n:2*n], s.MSK[:n]); err != nil {
+				log.Printf("EAP %s failed for %q: %v", s.MethodType, s.Identity, err)
+				return e.fail(s.Request.Reply(), s, s.Identifier-1)
+			}
+		}
+		if e.accept != nil && !e.accept(ctx, s, reply) {
+			return e.fail(s.Request.Reply(), s, s.Identifier-1)
+		}
+		e.endSession(s)
+		reply.SetEAPMessage(&EapPacket{Code: EapCodeSuccess, Identifier: s.Identifier - 1})
+		return reply
+	}
+	return e.fail(reply, s, s.Identifier-1)
+}
+
+// challenge sends an EAP-Request and keeps the conversation for the next
+// round.
+func (e *EapServer) challenge(reply *Packet, s *EapSession, eap *EapPacket) *Packet {
+	eap.Identifier = s.Identifier
+	reply.Code = AccessChallenge
+	reply.SetEAPMessage(eap)
+	reply.AddAVP(AVP{Type: AttrState, Value: append([]byte(nil), s.State...)})
+	e.putSession(s, append([]AVP(nil), reply.AVPs...))
+	return reply
+}
+
+// fail rejects the request with EAP-Failure, ending the conversation.
+func (e *EapServer) fail(reply *Packet, s *EapSession, identifier uint8) *Packet {
+	reply.Code = AccessReject
+	if s != nil {
+		e.endSession(s)
+		for _, avp := range s.Reply {
+			reply.AddAVP(avp)
+		}
+	}
+	reply.SetEAPMessage(&EapPacket{Code: EapCodeFailure, Identifier: identifier})
+	return reply
+}
+
+func (e *EapServer) newSession(request *Packet) (*EapSession, error) {
+	state := make([]byte, stateSize)
+	if _, err := rand.Read(state); err != nil {
+		return nil, err
+	}
+	s := &EapSession{State: state, Request: request}
+	if eap := request.GetEAPMessage(); eap != nil {
+		s.Identifier = eap.Identifier
+	} else {
+		var id [1]byte
+		if _, err := rand.Read(id[:]); err != nil {
+			return nil, err
+		}
+		s.Identifier = id[0]
+	}
+	return s, nil
+}
+
+// takeSession returns the unexpired conversation of a State for a response
+// with identifier, marked busy until the round is processed, so that a
+// retransmitted request cannot run the method concurrently. A duplicate of
+// the previous response gets the attributes of the last Access-Challenge to
+// resend instead, and other responses are dropped while the conversation
+// goes on. It returns neither for an unknown or expired State.
+func (e *EapServer) takeSession(state []byte, identifier uint8) (s *EapSession, resend []AVP, drop bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s, ok := e.sessions[string(state)]
+	switch {
+	case !ok:
+		return nil, nil, false
+	case s.busy:
+		// the reply to the first copy is on its way
+		return nil, nil, true
+	case time.Now().After(s.expires):
+		delete(e.sessions, string(state))
+		closeEapData(s.Data)
+		return nil, nil, false
+	case identifier == s.Identifier:
+		s.busy = true
+		return s, nil, false
+	case identifier == s.Identifier-1:
+		return nil, s.challenge, false
+	}
+	return nil, nil, true
+}
+
+// putSession keeps the conversation until the next round, with the
+// attributes of the Access-Challenge sent.
+func (e *EapServer) putSession(s *EapSession, challenge []AVP) {
+	s.Request = nil
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := time.Now()
+	s.expires = now.Add(e.timeout)
+	s.busy = false
+	s.challenge = challenge
+	e.sessions[string(s.State)] = s
+	if now.Sub(e.swept) > e.timeout {
+		for state, old := range e.sessions {
+			if !old.busy && now.After(old.expires) {
+				delete(e.sessions, state)
+				closeEapData(old.Data)
+			}
+		}
+		e.swept = now
+	}
+}
+
+// endSession forgets a conversation which ended and closes its method
+// state.
+func (e *EapServer) endSession(s *EapSession) {
+	e.mu.Lock()
+	delete(e.sessions, string(s.State))
+	e.mu.Unlock()
+	closeEapData(s.Data)
+}
+
+// closeEapData releases the method state of a conversation, such as a
+// pending TLS handshake.
+func closeEapData(data interface{}) {
+	if c, ok := data.(io.Closer); ok {
+		c.Close()
+	}
+}
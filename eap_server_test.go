@@ -0,0 +1,385 @@
+package radius
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+// testEapMethod asks for a password in two rounds: the first response must
+// be "ready", the second the password.
+type testEapMethod struct {
+	typ      EapType
+	password string
+}
+
+func (m testEapMethod) Type() EapType { return m.typ }
+
+func (m testEapMethod) Start(s *EapSession) ([]byte, error) {
+	s.Data = 0
+	return []byte("ready?"), nil
+}
+
+func (m testEapMethod) Process(s *EapSession, data []byte) (EapStatus, []byte, error) {
+	round := s.Data.(int) + 1
+	s.Data = round
+	switch {
+	case round == 1 && string(data) == "ready":
+		return EapStatusContinue, []byte("password?"), nil
+	case round == 2 && string(data) == m.password:
+		s.MSK = bytes.Repeat([]byte{0x5a}, 64)
+		return EapStatusSuccess, nil, nil
+	}
+	s.Reply = append(s.Reply, AVP{Type: AttrReplyMessage, Value: []byte("denied")})
+	return EapStatusFailure, nil, nil
+}
+
+// testExpandedMethod is a testEapMethod of an expanded type.
+type testExpandedMethod struct {
+	testEapMethod
+	expanded EapExpandedType
+}
+
+func (m testExpandedMethod) ExpandedType() EapExpandedType { return m.expanded }
+
+func newTestExpandedMethod(vendor VendorID, typ uint32, password string) testExpandedMethod {
+	return testExpandedMethod{testEapMethod{typ: EapTypeExpandedTypes, password: password}, EapExpandedType{Vendor: vendor, Type: typ}}
+}
+
+// eapRequest builds an Access-Request carrying eap and state, as decoded by
+// the server: with a Message-Authenticator, whose value the decoder checks.
+func eapRequest(eap *EapPacket, state []byte) *Packet {
+	p := Request(AccessRequest, "secret")
+	p.AddAVP(AVP{Type: AttrMessageAuthenticator, Value: make([]byte, 16)})
+	if eap != nil {
+		p.SetEAPMessage(eap)
+	} else {
+		p.AddAVP(AVP{Type: AttrEAPMessage})
+	}
+	if state != nil {
+		p.AddAVP(AVP{Type: AttrState, Value: state})
+	}
+	return p
+}
+
+// eapExchange sends a response with the server and returns the reply, its
+// EAP packet and State.
+func eapExchange(t *testing.T, e *EapServer, eap *EapPacket, state []byte) (*Packet, *EapPacket, []byte) {
+	t.Helper()
+	reply := e.RadiusHandle(context.Background(), eapRequest(eap, state))
+	if reply == nil {
+		t.Fatalf("RadiusHandle() = nil")
+	}
+	var replyState []byte
+	if avp := reply.GetAVP(AttrState); avp != nil {
+		replyState = avp.Value
+	}
+	return reply, reply.GetEAPMessage(), replyState
+}
+
+func eapResponse(req *EapPacket, data string) *EapPacket {
+	return &EapPacket{Code: EapCodeResponse, Identifier: req.Identifier, Type: req.Type, VendorID: req.VendorID, VendorType: req.VendorType, Data: []byte(data)}
+}
+
+// eapPeerConversation authenticates identity with peer, answering requests
+// of other methods with a Nak, and returns the final reply.
+func eapPeerConversation(t *testing.T, e *EapServer, identity string, peer EapPeerMethod) *Packet {
+	t.Helper()
+	resp := &EapPacket{Code: EapCodeResponse, Identifier: 1, Type: EapTypeIdentity, Data: []byte(identity)}
+	var state []byte
+	for i := 0; i < 100; i++ {
+		reply, req, replyState := eapExchange(t, e, resp, state)
+		if reply.Code != AccessChallenge {
+			return reply
+		}
+		state = replyState
+		resp = &EapPacket{Code: EapCodeResponse, Identifier: req.Identifier, Type: peer.Type()}
+		if req.Type != peer.Type() {
+			resp.Type = EapTypeNak
+			resp.Data = []byte{byte(peer.Type())}
+			continue
+		}
+		data, err := peer.Process(req)
+		if err != nil {
+			t.Fatalf("%s peer failed: %v", peer.Type(), err)
+		}
+		resp.Data = data
+	}
+	t.Fatalf("EAP conversation does not end")
+	return nil
+}
+
+func TestEapServer(t *testing.T) {
+	e := NewEapServer(testEapMethod{typ: 200, password: "first"}, testEapMethod{typ: 201, password: "second"})
+
+	// EAP-Start
+	reply, req, state := eapExchange(t, e, nil, nil)
+	if reply.Code != AccessChallenge || req == nil || req.Type != EapTypeIdentity || state == nil {
+		t.Fatalf("EAP-Start reply = %v %v", reply.Code, req)
+	}
+
+	reply, req, state = eapExchange(t, e, eapResponse(req, "alice"), state)
+	if reply.Code != AccessChallenge || req.Type != 200 || string(req.Data) != "ready?" {
+		t.Fatalf("Identity reply = %v %v", reply.Code, req)
+	}
+
+	// prefer the second method
+	nak := &EapPacket{Code: EapCodeResponse, Identifier: req.Identifier, Type: EapTypeNak, Data: []byte{201}}
+	reply, req, state = eapExchange(t, e, nak, state)
+	if reply.Code != AccessChallenge || req.Type != 201 {
+		t.Fatalf("Nak reply = %v %v", reply.Code, req)
+	}
+
+	reply, req, state = eapExchange(t, e, eapResponse(req, "ready"), state)
+	if reply.Code != AccessChallenge || string(req.Data) != "password?" {
+		t.Fatalf("round 1 reply = %v %v", reply.Code, req)
+	}
+
+	id := req.Identifier
+	reply, result, _ := eapExchange(t, e, eapResponse(req, "second"), state)
+	if reply.Code != AccessAccept || result == nil || result.Code != EapCodeSuccess || result.Identifier != id {
+		t.Fatalf("final reply = %v %v", reply.Code, result)
+	}
+	if vsa := reply.GetVSA(VendorMicrosoft, AttrMSMPPERecvKey); vsa == nil {
+		t.Errorf("Access-Accept lacks MS-MPPE-Recv-Key")
+	} else if key, err := DecryptTunnelPassword(vsa.Value, "secret", reply.Authenticator[:]); err != nil || !bytes.Equal(key, bytes.Repeat([]byte{0x5a}, 32)) {
+		t.Errorf("MS-MPPE-Recv-Key = %x, %v", key, err)
+	}
+
+	// the conversation is over
+	reply, result, _ = eapExchange(t, e, eapResponse(req, "second"), state)
+	if reply.Code != AccessReject || result == nil || result.Code != EapCodeFailure {
+		t.Errorf("replayed State reply = %v %v", reply.Code, result)
+	}
+}
+
+func TestEapServerFailure(t *testing.T) {
+	e := NewEapServer(testEapMethod{typ: 200, password: "first"})
+	identity := &EapPacket{Code: EapCodeResponse, Identifier: 7, Type: EapTypeIdentity, Data: []byte("bob")}
+
+	_, req, state := eapExchange(t, e, identity, nil)
+	if req == nil || req.Identifier != 8 {
+		t.Fatalf("Identity reply = %v", req)
+	}
+	_, req, state = eapExchange(t, e, eapResponse(req, "ready"), state)
+	reply, result, _ := eapExchange(t, e, eapResponse(req, "wrong"), state)
+	if reply.Code != AccessReject || result.Code != EapCodeFailure {
+		t.Errorf("wrong password reply = %v %v", reply.Code, result)
+	}
+	if avp := reply.GetAVP(AttrReplyMessage); avp == nil || string(avp.Value) != "denied" {
+		t.Errorf("Access-Reject lacks the method reply attributes")
+	}
+
+	testCases := []struct {
+		name  string
+		setup func() (*EapPacket, []byte)
+	}{
+		{"unknown state", func() (*EapPacket, []byte) {
+			return eapResponse(&EapPacket{Type: 200}, "ready"), []byte("nope")
+		}},
+		{"wrong type", func() (*EapPacket, []byte) {
+			_, req, state := eapExchange(t, e, identity, nil)
+			resp := eapResponse(req, "ready")
+			resp.Type = 201
+			return resp, state
+		}},
+		{"nak without alternative", func() (*EapPacket, []byte) {
+			_, req, state := eapExchange(t, e, identity, nil)
+			return &EapPacket{Code: EapCodeResponse, Identifier: req.Identifier, Type: EapTypeNak, Data: []byte{0}}, state
+		}},
+		{"request code", func() (*EapPacket, []byte) {
+			return &EapPacket{Code: EapCodeRequest, Identifier: 1, Type: EapTypeIdentity}, nil
+		}},
+		{"method response first", func() (*EapPacket, []byte) {
+			return eapResponse(&EapPacket{Type: 200}, "ready"), nil
+		}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			eap, state := tc.setup()
+			reply, result, _ := eapExchange(t, e, eap, state)
+			if reply.Code != AccessReject || result == nil || result.Code != EapCodeFailure {
+				t.Errorf("reply = %v %v; want Access-Reject with EAP-Failure", reply.Code, result)
+			}
+		})
+	}
+
+	if reply := e.RadiusHandle(context.Background(), Request(AccessRequest, "secret")); reply.Code != AccessReject {
+		t.Errorf("request without EAP-Message: reply = %v", reply.Code)
+	}
+	request := eapRequest(identity, nil)
+	request.DeleteAllType(AttrMessageAuthenticator)
+	if reply := e.RadiusHandle(context.Background(), request); reply != nil {
+		t.Errorf("request without Message-Authenticator: reply = %v; want none", reply.Code)
+	}
+}
+
+func TestEapServerRetransmission(t *testing.T) {
+	e := NewEapServer(testEapMethod{typ: 200, password: "first"})
+	identity := &EapPacket{Code: EapCodeResponse, Identifier: 1, Type: EapTypeIdentity, Data: []byte("bob")}
+	_, req, state := eapExchange(t, e, identity, nil)
+	ready := eapResponse(req, "ready")
+	first, req, _ := eapExchange(t, e, ready, state)
+
+	// a retransmitted request gets the same Access-Challenge
+	again, resent, resentState := eapExchange(t, e, ready, state)
+	if again.Code != AccessChallenge || resent == nil || !bytes.Equal(resent.Encode(), req.Encode()) || !bytes.Equal(resentState, state) {
+		t.Fatalf("retransmission reply = %v %v; want %v %v", again.Code, resent, first.Code, req)
+	}
+
+	// a stale response is dropped, leaving the conversation alive
+	stale := eapResponse(req, "first")
+	stale.Identifier += 5
+	if reply := e.RadiusHandle(context.Background(), eapRequest(stale, state)); reply != nil {
+		t.Fatalf("stale response reply = %v; want none", reply.Code)
+	}
+	if reply, _, _ := eapExchange(t, e, eapResponse(req, "first"), state); reply.Code != AccessAccept {
+		t.Errorf("reply = %v; want Access-Accept", reply.Code)
+	}
+}
+
+func TestEapServerSessionTimeout(t *testing.T) {
+	e := NewEapServer(testEapMethod{typ: 200, password: "first"})
+	e.SetSessionTimeout(time.Millisecond)
+
+	identity := &EapPacket{Code: EapCodeResponse, Identifier: 1, Type: EapTypeIdentity, Data: []byte("bob")}
+	_, req, state := eapExchange(t, e, identity, nil)
+	time.Sleep(5 * time.Millisecond)
+	// the next conversation sweeps the expired one
+	eapExchange(t, e, identity, nil)
+	e.mu.Lock()
+	n := len(e.sessions)
+	e.mu.Unlock()
+	if n != 1 {
+		t.Errorf("%d sessions stored; want 1", n)
+	}
+	if reply, _, _ := eapExchange(t, e, eapResponse(req, "ready"), state); reply.Code != AccessReject {
+		t.Errorf("expired State reply = %v; want Access-Reject", reply.Code)
+	}
+}
+
+func TestEapServerAcceptFunc(t *testing.T) {
+	e := NewEapServer(testEapMethod{typ: 200, password: "pw"})
+	e.SetAcceptFunc(func(ctx context.Context, s *EapSession, reply *Packet) bool {
+		if s.Identity != "alice" {
+			return false
+		}
+		reply.AddAVP(AVP{Type: AttrReplyMessage, Value: []byte("welcome " + s.Identity)})
+		return true
+	})
+
+	for _, user := range []string{"alice", "mallory"} {
+		identity := &EapPacket{Code: EapCodeResponse, Identifier: 1, Type: EapTypeIdentity, Data: []byte(user)}
+		_, req, state := eapExchange(t, e, identity, nil)
+		_, req, state = eapExchange(t, e, eapResponse(req, "ready"), state)
+		reply, _, _ := eapExchange(t, e, eapResponse(req, "pw"), state)
+
+		want := AccessAccept
+		if user != "alice" {
+			want = AccessReject
+		}
+		if reply.Code != want {
+			t.Errorf("%s: reply = %v; want %v", user, reply.Code, want)
+		}
+		if want == AccessAccept {
+			if avp := reply.GetAVP(AttrReplyMessage); avp == nil || string(avp.Value) != "welcome alice" {
+				t.Errorf("Access-Accept lacks the attributes added by the accept function")
+			}
+		}
+	}
+}
+
+func TestSetEAPMessage(t *testing.T) {
+	p := Request(AccessRequest, "secret")
+	eap := &EapPacket{Code: EapCodeRequest, Identifier: 3, Type: 200, Data: bytes.Repeat([]byte{1}, 600)}
+	p.SetEAPMessage(eap)
+	p.SetEAPMessage(eap)
+
+	n := 0
+	p.EachAVP(func(a AVP) bool {
+		if a.Type == AttrEAPMessage {
+			n++
+		}
+		return true
+	})
+	if n != 3 {
+		t.Errorf("SetEAPMessage() added %d attributes; want 3", n)
+	}
+	if got := p.GetEAPMessage(); got == nil || !bytes.Equal(got.Data, eap.Data) {
+		t.Errorf("GetEAPMessage() = %v", got)
+	}
+}
+
+func TestEapServerExpandedTypes(t *testing.T) {
+	e := NewEapServer(
+		testEapMethod{typ: 200, password: "legacy"},
+		newTestExpandedMethod(9, 1, "first"),
+		newTestExpandedMethod(9, 2, "second"),
+	)
+	identity := &EapPacket{Code: EapCodeResponse, Identifier: 1, Type: EapTypeIdentity, Data: []byte("alice")}
+	_, req, state := eapExchange(t, e, identity, nil)
+
+	// a legacy Nak proposing expanded types selects the first expanded method
+	nak := &EapPacket{Code: EapCodeResponse, Identifier: req.Identifier, Type: EapTypeNak, Data: []byte{byte(EapTypeExpandedTypes)}}
+	reply, req, state := eapExchange(t, e, nak, state)
+	if reply.Code != AccessChallenge || req.ExpandedType() != (EapExpandedType{Vendor: 9, Type: 1}) || string(req.Data) != "ready?" {
+		t.Fatalf("Nak reply = %v %v", reply.Code, req)
+	}
+
+	// an Expanded Nak selects by vendor and type
+	nak = &EapPacket{Code: EapCodeResponse, Identifier: req.Identifier, Type: EapTypeExpandedTypes, VendorType: uint32(EapTypeNak),
+		Data: NewEapExpandedNak(EapExpandedType{Vendor: 9, Type: 2})}
+	reply, req, state = eapExchange(t, e, nak, state)
+	if reply.Code != AccessChallenge || req.ExpandedType() != (EapExpandedType{Vendor: 9, Type: 2}) {
+		t.Fatalf("Expanded Nak reply = %v %v", reply.Code, req)
+	}
+
+	// responses must match the vendor type of the request
+	wrong := eapResponse(req, "ready")
+	wrong.SetExpandedType(EapExpandedType{Vendor: 9, Type: 1})
+	if reply, _, _ := eapExchange(t, e, wrong, state); reply.Code != AccessReject {
+		t.Errorf("response of another vendor type = %v; want Access-Reject", reply.Code)
+	}
+
+	_, req, state = eapExchange(t, e, identity, nil)
+	peer := newTestExpandedPeer(9, 2)
+	resp, err := eapPeerResponse(peer, req)
+	if err != nil || resp.Type != EapTypeNak || !bytes.Equal(resp.Data, []byte{byte(EapTypeExpandedTypes)}) {
+		t.Fatalf("peer answer to a legacy request = %v, %v; want a Nak", resp, err)
+	}
+	_, req, state = eapExchange(t, e, resp, state)
+	if resp, err = eapPeerResponse(peer, req); err != nil || resp.ExpandedType() != EapExpandedNak || resp.Type != EapTypeExpandedTypes {
+		t.Fatalf("peer answer to %v = %v, %v; want an Expanded Nak", req, resp, err)
+	}
+	_, req, state = eapExchange(t, e, resp, state)
+	for _, answer := range []string{"ready", "second"} {
+		resp, err = eapPeerResponse(peer.answering(answer), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reply, req, state = eapExchange(t, e, resp, state)
+	}
+	if reply.Code != AccessAccept {
+		t.Errorf("final reply = %v; want Access-Accept", reply.Code)
+	}
+}
+
+// testExpandedPeer answers requests of an expanded type with a fixed answer.
+type testExpandedPeer struct {
+	expanded EapExpandedType
+	answer   string
+}
+
+func newTestExpandedPeer(vendor VendorID, typ uint32) testExpandedPeer {
+	return testExpandedPeer{expanded: EapExpandedType{Vendor: vendor, Type: typ}}
+}
+
+func (p testExpandedPeer) answering(answer string) testExpandedPeer {
+	p.answer = answer
+	return p
+}
+
+func (p testExpandedPeer) Type() EapType                      { return EapTypeExpandedTypes }
+func (p testExpandedPeer) ExpandedType() EapExpandedType      { return p.expanded }
+func (p testExpandedPeer) Process(*EapPacket) ([]byte, error) { return []byte(p.answer), nil }
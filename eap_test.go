@@ -263,3 +263,61 @@ func TestEapDecodeRequestResponseTooShort(t *testing.T) {
 		t.Error("expected error for Request with Length=4, got nil")
 	}
 }
+
+func TestEapExpandedType(t *testing.T) {
+	// Expanded Type: Code=1, ID=4, Length=14, Type=254, Vendor-Id=0x00010203,
+	// Vendor-Type=0x04050607, Data="hi"
+	b := []byte{0x01, 0x04, 0x00, 0x0e, 0xfe, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 'h', 'i'}
+	eap, err := EapDecode(b)
+	if err != nil {
+		t.Fatalf("EapDecode error: %v", err)
+	}
+	want := EapExpandedType{Vendor: 0x010203, Type: 0x04050607}
+	if eap.Type != EapTypeExpandedTypes || eap.ExpandedType() != want || string(eap.Data) != "hi" {
+		t.Errorf("decoded %+v; want %v with data \"hi\"", eap, want)
+	}
+	if got := eap.Encode(); string(got) != string(b) {
+		t.Errorf("Encode: got %x, want %x", got, b)
+	}
+	if s := eap.String(); s != `Eap Code:Request id:4 Type:Expanded(66051/67438087) Data:[[]byte{0x68, 0x69}]` {
+		t.Errorf("String: got %q", s)
+	}
+
+	legacy := &EapPacket{}
+	legacy.SetExpandedType(EapExpandedType{Type: uint32(EapTypeMSCHAPV2)})
+	if legacy.Type != EapTypeMSCHAPV2 || legacy.ExpandedType() != (EapExpandedType{Type: 26}) {
+		t.Errorf("SetExpandedType(legacy) = %+v", legacy)
+	}
+	legacy.SetExpandedType(want)
+	if legacy.Type != EapTypeExpandedTypes || legacy.VendorID != want.Vendor || legacy.VendorType != want.Type {
+		t.Errorf("SetExpandedType(expanded) = %+v", legacy)
+	}
+
+	// the expanded header is 8 bytes long
+	if _, err := EapDecode([]byte{0x02, 0x01, 0x00, 0x0b, 0xfe, 0, 0, 0, 0, 0, 3}); err == nil {
+		t.Error("expected error for truncated Expanded Type header, got nil")
+	}
+}
+
+func TestEapExpandedNak(t *testing.T) {
+	types := []EapExpandedType{{Vendor: 9, Type: 1}, {Type: uint32(EapTypeTLS)}}
+	b := NewEapExpandedNak(types...)
+	want := []byte{0xfe, 0, 0, 9, 0, 0, 0, 1, 0xfe, 0, 0, 0, 0, 0, 0, 13}
+	if string(b) != string(want) {
+		t.Fatalf("NewEapExpandedNak: got %x, want %x", b, want)
+	}
+	got, err := ParseEapExpandedNak(b)
+	if err != nil || len(got) != 2 || got[0] != types[0] || got[1] != types[1] {
+		t.Errorf("ParseEapExpandedNak: got %v, %v; want %v", got, err, types)
+	}
+
+	// no alternative
+	if got, err := ParseEapExpandedNak(NewEapExpandedNak()); err != nil || len(got) != 0 {
+		t.Errorf("ParseEapExpandedNak(no alternative): got %v, %v", got, err)
+	}
+	for _, bad := range [][]byte{nil, {0xfe, 0, 0}, {0x03, 0, 0, 0, 0, 0, 0, 0}} {
+		if _, err := ParseEapExpandedNak(bad); err == nil {
+			t.Errorf("ParseEapExpandedNak(%x) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,537 @@
+package radius
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// EAP-TLS flags (RFC 5216 §3.1). The low bits carry the version of PEAP and
+// EAP-TTLS.
+const (
+	eapTLSFlagLength  = 0x80
+	eapTLSFlagMore    = 0x40
+	eapTLSFlagStart   = 0x20
+	eapTLSVersionMask = 0x07
+)
+
+// DefaultEapTLSFragmentSize is the largest TLS data sent in one EAP packet,
+// keeping Access-Challenge packets well below the RADIUS size limit.
+const DefaultEapTLSFragmentSize = 1024
+
+// DefaultEapTLSHandshakeTimeout bounds a TLS handshake spanning several
+// Access-Request rounds.
+const DefaultEapTLSHandshakeTimeout = 2 * time.Minute
+
+// maxEapTLSMessage bounds the size of a reassembled TLS message.
+const maxEapTLSMessage = 64 * 1024
+
+// errEapTLSWouldBlock is returned by eapTLSConn.Read when no input is left
+// outside of the handshake. It is a temporary net.Error, so crypto/tls keeps
+// the connection usable for the next round.
+var errEapTLSWouldBlock net.Error = eapTLSWouldBlock{}
+
+type eapTLSWouldBlock struct{}
+
+func (eapTLSWouldBlock) Error() string   { return "EAP-TLS: no more data in this round" }
+func (eapTLSWouldBlock) Timeout() bool   { return true }
+func (eapTLSWouldBlock) Temporary() bool { return true }
+
+// eapTLSConn is the in-memory transport of a TLS connection carried by EAP
+// packets. Input is fed round by round; output is collected and fragmented.
+//
+// The handshake runs in its own goroutine and blocks in Read until the next
+// round feeds more input. Afterwards Read returns errEapTLSWouldBlock once
+// the input of the round is consumed, so application data is read inline.
+type eapTLSConn struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	in, out  []byte
+	blocking bool // the handshake goroutine runs
+	waiting  bool // the handshake waits in Read for input
+	closed   bool
+}
+
+func newEapTLSConn() *eapTLSConn {
+	c := &eapTLSConn{}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *eapTLSConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.in) == 0 {
+		if c.closed {
+			return 0, io.EOF
+		}
+		if !c.blocking {
+			return 0, errEapTLSWouldBlock
+		}
+		c.waiting = true
+		c.cond.Broadcast()
+		c.cond.Wait()
+		c.waiting = false
+	}
+	n := copy(b, c.in)
+	c.in = c.in[n:]
+	return n, nil
+}
+
+func (c *eapTLSConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	c.out = append(c.out, b...)
+	return len(b), nil
+}
+
+func (c *eapTLSConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	c.cond.Broadcast()
+	return nil
+}
+
+func (c *eapTLSConn) LocalAddr() net.Addr                { return eapTLSAddr{} }
+func (c *eapTLSConn) RemoteAddr() net.Addr               { return eapTLSAddr{} }
+func (c *eapTLSConn) SetDeadline(t time.Time) error      { return nil }
+func (c *eapTLSConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *eapTLSConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// feed adds input and, while the handshake runs, waits until it has
+// consumed the input and needs more, or has ended.
+func (c *eapTLSConn) feed(b []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.in = append(c.in, b...)
+	c.cond.Broadcast()
+	for c.blocking && !c.closed && !(c.waiting && len(c.in) == 0) {
+		c.cond.Wait()
+	}
+}
+
+// take returns and clears the output.
+func (c *eapTLSConn) take() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := c.out
+	c.out = nil
+	return out
+}
+
+type eapTLSAddr struct{}
+
+func (eapTLSAddr) Network() string { return "eap" }
+func (eapTLSAddr) String() string  { return "eap" }
+
+// eapTLSTunnel is one end of a TLS connection carried by EAP-TLS style
+// packets: Flags, optional TLS Message Length and TLS data (RFC 5216 §3.1).
+// It is shared by EAP-TLS, PEAP and EAP-TTLS, and by servers and peers.
+type eapTLSTunnel struct {
+	conn         *eapTLSConn
+	tls          *tls.Conn
+	version      byte
+	fragmentSize int
+	timeout      time.Duration
+	// afterHandshake runs in the handshake goroutine once it succeeds.
+	afterHandshake func() error
+
+	started bool
+	done    bool // under conn.mu
+	err     error
+
+	recv    []byte
+	recvLen int
+	more    bool
+	send    []byte
+	sending bool
+}
+
+func newEapTLSTunnel(conn *eapTLSConn, t *tls.Conn, fragmentSize int, timeout time.Duration) *eapTLSTunnel {
+	if fragmentSize <= 0 {
+		fragmentSize = DefaultEapTLSFragmentSize
+	}
+	if timeout <= 0 {
+		timeout = DefaultEapTLSHandshakeTimeout
+	}
+	return &eapTLSTunnel{conn: conn, tls: t, fragmentSize: fragmentSize, timeout: timeout}
+}
+
+// newEapTLSServerTunnel returns the server end of a tunnel.
+func newEapTLSServerTunnel(config *tls.Config, fragmentSize int, timeout time.Duration) *eapTLSTunnel {
+	conn := newEapTLSConn()
+	return newEapTLSTunnel(conn, tls.Server(conn, config), fragmentSize, timeout)
+}
+
+// newEapTLSClientTunnel returns the peer end of a tunnel.
+func newEapTLSClientTunnel(config *tls.Config, fragmentSize int) *eapTLSTunnel {
+	conn := newEapTLSConn()
+	return newEapTLSTunnel(conn, tls.Client(conn, config), fragmentSize, 0)
+}
+
+// receive reassembles the Type-Data of a packet. While a message is being
+// received or sent in fragments it returns the acknowledgement or next
+// fragment to send; otherwise the complete message, empty when the packet
+// only acknowledged the last one sent.
+func (t *eapTLSTunnel) receive(data []byte) (msg, reply []byte, err error) {
+	if len(data) < 1 {
+		return nil, nil, errors.New("EAP-TLS packet without flags")
+	}
+	flags := data[0]
+	data = data[1:]
+	if t.sending {
+		if len(data) != 0 || flags&(eapTLSFlagLength|eapTLSFlagMore) != 0 {
+			return nil, nil, errors.New("EAP-TLS data received while sending fragments")
+		}
+		return nil, t.nextFragment(), nil
+	}
+
+	if flags&eapTLSFlagLength != 0 {
+		if len(data) < 4 {
+			return nil, nil, errors.New("EAP-TLS packet too short for its TLS Message Length")
+		}
+		if !t.more {
+			t.recvLen = int(binary.BigEndian.Uint32(data))
+		}
+		data = data[4:]
+	}
+	t.recv = append(t.recv, data...)
+	if len(t.recv) > maxEapTLSMessage || t.recvLen > maxEapTLSMessage {
+		return nil, nil, fmt.Errorf("EAP-TLS message longer than %d bytes", maxEapTLSMessage)
+	}
+	if flags&eapTLSFlagMore != 0 {
+		t.more = true
+		return nil, t.ack(), nil
+	}
+
+	msg, recvLen := t.recv, t.recvLen
+	t.recv, t.recvLen, t.more = nil, 0, false
+	if recvLen != 0 && len(msg) != recvLen {
+		return nil, nil, fmt.Errorf("EAP-TLS message of %d bytes; TLS Message Length is %d", len(msg), recvLen)
+	}
+	if msg == nil {
+		msg = []byte{}
+	}
+	return msg, nil, nil
+}
+
+// ack returns an empty packet acknowledging a fragment.
+func (t *eapTLSTunnel) ack() []byte {
+	return []byte{t.version}
+}
+
+// start returns the Start packet of a server.
+func (t *eapTLSTunnel) start() []byte {
+	return []byte{t.version | eapTLSFlagStart}
+}
+
+// flush queues the TLS output and returns its first fragment, or nil when
+// there is nothing to send.
+func (t *eapTLSTunnel) flush() []byte {
+	t.send = t.conn.take()
+	if len(t.send) == 0 {
+		return nil
+	}
+	return t.nextFragment()
+}
+
+func (t *eapTLSTunnel) nextFragment() []byte {
+	flags := t.version
+	var header []byte
+	if !t.sending && len(t.send) > t.fragmentSize {
+		flags |= eapTLSFlagLength
+		header = make([]byte, 4)
+		binary.BigEndian.PutUint32(header, uint32(len(t.send)))
+	}
+	n := len(t.send)
+	if n > t.fragmentSize {
+		n = t.fragmentSize
+		flags |= eapTLSFlagMore
+	}
+	b := make([]byte, 0, 1+len(header)+n)
+	b = append(b, flags)
+	b = append(b, header...)
+	b = append(b, t.send[:n]...)
+	t.send = t.send[n:]
+	t.sending = len(t.send) > 0
+	return b
+}
+
+// handshake feeds a message to the handshake, started on the first call,
+// and waits until it needs the next message or ends.
+func (t *eapTLSTunnel) handshake(msg []byte) error {
+	if !t.started {
+		t.started = true
+		t.conn.mu.Lock()
+		t.conn.blocking = true
+		t.conn.mu.Unlock()
+		go t.runHandshake()
+	}
+	t.conn.feed(msg)
+	_, err := t.result()
+	return err
+}
+
+func (t *eapTLSTunnel) runHandshake() {
+	ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
+	defer cancel()
+	err := t.tls.HandshakeContext(ctx)
+	if err == nil && t.afterHandshake != nil {
+		err = t.afterHandshake()
+	}
+
+	t.conn.mu.Lock()
+	defer t.conn.mu.Unlock()
+	t.done = true
+	t.err = err
+	t.conn.blocking = false
+	t.conn.cond.Broadcast()
+}
+
+// result reports whether the handshake has ended, and its error.
+func (t *eapTLSTunnel) result() (bool, error) {
+	t.conn.mu.Lock()
+	defer t.conn.mu.Unlock()
+	return t.done, t.err
+}
+
+// handshakeDone reports whether the handshake has succeeded.
+func (t *eapTLSTunnel) handshakeDone() bool {
+	done, err := t.result()
+	return done && err == nil
+}
+
+// readApp feeds a message received after the handshake and returns the
+// application data it carries.
+func (t *eapTLSTunnel) readApp(msg []byte) ([]byte, error) {
+	t.conn.feed(msg)
+	var out []byte
+	buf := make([]byte, 4096)
+	for {
+		n, err := t.tls.Read(buf)
+		out = append(out, buf[:n]...)
+		if err == errEapTLSWouldBlock {
+			return out, nil
+		}
+		if err != nil {
+			return out, err
+		}
+	}
+}
+
+// Close stops a pending handshake. The EapServer calls it when the
+// conversation ends.
+func (t *eapTLSTunnel) Close() error {
+	return t.conn.Close()
+}
+
+// serverStep runs the server side of the handshake. Once the handshake has
+// succeeded and its last message was sent, it returns done with the next
+// incoming message, empty when the peer only acknowledged it.
+func (t *eapTLSTunnel) serverStep(data []byte) (done bool, msg, out []byte, err error) {
+	msg, reply, err := t.receive(data)
+	if err != nil {
+		t.Close()
+		return false, nil, nil, err
+	}
+	if reply != nil {
+		return false, nil, reply, nil
+	}
+	if t.handshakeDone() {
+		return true, msg, nil, nil
+	}
+	if len(msg) == 0 {
+		t.Close()
+		return false, nil, nil, errors.New("EAP-TLS acknowledgement received during the handshake")
+	}
+	if err := t.handshake(msg); err != nil {
+		t.Close()
+		return false, nil, nil, err
+	}
+	if out := t.flush(); out != nil {
+		return false, nil, out, nil
+	}
+	if t.handshakeDone() {
+		// TLS 1.3 without further server messages
+		return true, []byte{}, nil, nil
+	}
+	t.Close()
+	return false, nil, nil, errors.New("EAP-TLS handshake stalled")
+}
+
+// peerStep runs the peer side of the handshake. Once the handshake has
+// succeeded and its last message was sent, it returns done with the
+// application data received from the server.
+func (t *eapTLSTunnel) peerStep(data []byte) (done bool, app, out []byte, err error) {
+	if len(data) > 0 && data[0]&eapTLSFlagStart != 0 {
+		if err := t.handshake(nil); err != nil {
+			return false, nil, nil, err
+		}
+		return false, nil, t.flush(), nil
+	}
+	msg, reply, err := t.receive(data)
+	if err != nil || reply != nil {
+		return false, nil, reply, err
+	}
+	if t.handshakeDone() {
+		app, err := t.readApp(msg)
+		return err == nil, app, nil, err
+	}
+	if len(msg) == 0 {
+		return false, nil, nil, errors.New("EAP-TLS acknowledgement received with nothing sent")
+	}
+	if err := t.handshake(msg); err != nil {
+		return false, nil, nil, err
+	}
+	if out := t.flush(); out != nil {
+		return false, nil, out, nil
+	}
+	if !t.handshakeDone() {
+		return false, nil, nil, errors.New("EAP-TLS handshake stalled")
+	}
+	return true, nil, nil, nil
+}
+
+// keys exports the MSK and EMSK: with TLS 1.3 as in RFC 9190 §2.3, otherwise
+// with the PRF label of the method (RFC 5216 §2.3).
+func (t *eapTLSTunnel) keys(label string, typ EapType) (msk, emsk []byte, err error) {
+	state := t.tls.ConnectionState()
+	var km []byte
+	if state.Version == tls.VersionTLS13 {
+		km, err = state.ExportKeyingMaterial("EXPORTER_EAP_TLS_Key_Material", []byte{byte(typ)}, 128)
+	} else {
+		km, err = state.ExportKeyingMaterial(label, nil, 128)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return km[:64], km[64:], nil
+}
+
+// EapTLS is the server side of EAP-TLS (RFC 5216, RFC 9190), an EapMethod.
+//
+// The TLS handshake runs over the EAP conversation, TLS records being
+// fragmented over several Access-Challenge rounds with the L, M and S flags.
+// On success the MSK and EMSK are exported from the TLS keying material.
+type EapTLS struct {
+	// Config is the server TLS configuration. Client certificates are
+	// checked against Config.ClientCAs.
+	Config *tls.Config
+	// FragmentSize is the largest TLS data sent in one EAP packet.
+	FragmentSize int
+	// HandshakeTimeout bounds the whole handshake.
+	HandshakeTimeout time.Duration
+	// VerifyPeer, when set, is called after a successful handshake, for
+	// example to match the client certificate with the EAP identity.
+	// Returning an error rejects the peer.
+	VerifyPeer func(s *EapSession, state tls.ConnectionState) error
+}
+
+// NewEapTLS returns an EAP-TLS method using a copy of config. A client
+// certificate is required and verified unless config sets ClientAuth.
+func NewEapTLS(config *tls.Config) *EapTLS {
+	config = config.Clone()
+	if config.ClientAuth == tls.NoClientCert {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return &EapTLS{
+		Config:           config,
+		FragmentSize:     DefaultEapTLSFragmentSize,
+		HandshakeTimeout: DefaultEapTLSHandshakeTimeout,
+	}
+}
+
+func (m *EapTLS) Type() EapType { return EapTypeTLS }
+
+func (m *EapTLS) Start(s *EapSession) ([]byte, error) {
+	t := newEapTLSServerTunnel(m.Config, m.FragmentSize, m.HandshakeTimeout)
+	t.afterHandshake = func() error {
+		// commitment message: no further handshake messages (RFC 9190 §2.5)
+		if t.tls.ConnectionState().Version == tls.VersionTLS13 {
+			_, err := t.tls.Write([]byte{0})
+			return err
+		}
+		return nil
+	}
+	s.Data = t
+	return t.start(), nil
+}
+
+func (m *EapTLS) Process(s *EapSession, data []byte) (EapStatus, []byte, error) {
+	t, ok := s.Data.(*eapTLSTunnel)
+	if !ok {
+		return EapStatusFailure, nil, errors.New("EAP-TLS conversation not started")
+	}
+	done, msg, out, err := t.serverStep(data)
+	if err != nil {
+		return EapStatusFailure, nil, err
+	}
+	if !done {
+		return EapStatusContinue, out, nil
+	}
+	if len(msg) != 0 {
+		return EapStatusFailure, nil, errors.New("EAP-TLS data received after the handshake")
+	}
+
+	if m.VerifyPeer != nil {
+		if err := m.VerifyPeer(s, t.tls.ConnectionState()); err != nil {
+			return EapStatusFailure, nil, err
+		}
+	}
+	if s.MSK, s.EMSK, err = t.keys("client EAP encryption", EapTypeTLS); err != nil {
+		return EapStatusFailure, nil, err
+	}
+	return EapStatusSuccess, nil, nil
+}
+
+// EapPeerTLS is the peer side of EAP-TLS, an EapPeerMethod.
+type EapPeerTLS struct {
+	// Config is the client TLS configuration, with the client certificate
+	// and the roots and name used to verify the server.
+	Config *tls.Config
+	// FragmentSize is the largest TLS data sent in one EAP packet.
+	FragmentSize int
+	// MSK and EMSK are exported once the handshake succeeds.
+	MSK, EMSK []byte
+
+	t *eapTLSTunnel
+}
+
+// NewEapPeerTLS returns an EAP-TLS peer using config.
+func NewEapPeerTLS(config *tls.Config) *EapPeerTLS {
+	return &EapPeerTLS{Config: config, FragmentSize: DefaultEapTLSFragmentSize}
+}
+
+func (m *EapPeerTLS) Type() EapType { return EapTypeTLS }
+
+func (m *EapPeerTLS) Process(req *EapPacket) ([]byte, error) {
+	if len(req.Data) > 0 && req.Data[0]&eapTLSFlagStart != 0 {
+		if m.t != nil {
+			m.t.Close()
+		}
+		m.t = newEapTLSClientTunnel(m.Config, m.FragmentSize)
+	}
+	if m.t == nil {
+		return nil, errors.New("EAP-TLS request before Start")
+	}
+	done, _, out, err := m.t.peerStep(req.Data)
+	if err != nil || !done {
+		return out, err
+	}
+	if m.MSK == nil {
+		if m.MSK, m.EMSK, err = m.t.keys("client EAP encryption", EapTypeTLS); err != nil {
+			return nil, err
+		}
+	}
+	return m.t.ack(), nil
+}
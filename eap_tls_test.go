@@ -0,0 +1,269 @@
+package radius
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// eapTLSTestCert issues a certificate for name, signed by parent (self-signed
+// when parent is nil).
+func eapTLSTestCert(t *testing.T, name string, parent *tls.Certificate, usage x509.ExtKeyUsage) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	signer, signerKey := template, interface{}(key)
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// eapTLSTestConfigs returns server and client configurations sharing a CA,
+// the client presenting a certificate for "alice".
+func eapTLSTestConfigs(t *testing.T) (server, client *tls.Config) {
+	t.Helper()
+	ca := eapTLSTestCert(t, "Test CA", nil, x509.ExtKeyUsageAny)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+	server = &tls.Config{
+		Certificates: []tls.Certificate{eapTLSTestCert(t, "radius.example.com", &ca, x509.ExtKeyUsageServerAuth)},
+		ClientCAs:    pool,
+	}
+	client = &tls.Config{
+		Certificates: []tls.Certificate{eapTLSTestCert(t, "alice", &ca, x509.ExtKeyUsageClientAuth)},
+		RootCAs:      pool,
+		ServerName:   "radius.example.com",
+	}
+	return server, client
+}
+
+func TestEapTLS(t *testing.T) {
+	serverConfig, clientConfig := eapTLSTestConfigs(t)
+
+	testCases := []struct {
+		name         string
+		version      uint16
+		fragmentSize int
+	}{
+		{"TLS 1.2", tls.VersionTLS12, 0},
+		{"TLS 1.3", tls.VersionTLS13, 0},
+		{"TLS 1.2 fragmented", tls.VersionTLS12, 200},
+		{"TLS 1.3 fragmented", tls.VersionTLS13, 200},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewEapTLS(serverConfig)
+			m.Config.MaxVersion = tc.version
+			if tc.fragmentSize != 0 {
+				m.FragmentSize = tc.fragmentSize
+			}
+			var identity string
+			m.VerifyPeer = func(s *EapSession, state tls.ConnectionState) error {
+				if state.Version != tc.version {
+					t.Errorf("TLS version = %x; want %x", state.Version, tc.version)
+				}
+				identity = state.PeerCertificates[0].Subject.CommonName
+				return nil
+			}
+			e := NewEapServer(m)
+			e.SetAcceptFunc(func(ctx context.Context, s *EapSession, reply *Packet) bool {
+				if len(s.MSK) != 64 || len(s.EMSK) != 64 {
+					t.Errorf("MSK/EMSK length = %d/%d; want 64", len(s.MSK), len(s.EMSK))
+				}
+				return true
+			})
+
+			peer := NewEapPeerTLS(clientConfig)
+			if tc.fragmentSize != 0 {
+				peer.FragmentSize = tc.fragmentSize
+			}
+			reply := eapPeerConversation(t, e, "alice", peer)
+			if reply.Code != AccessAccept {
+				t.Fatalf("reply = %v; want Access-Accept", reply.Code)
+			}
+			if identity != "alice" {
+				t.Errorf("client certificate = %q; want alice", identity)
+			}
+			vsa := reply.GetVSA(VendorMicrosoft, AttrMSMPPERecvKey)
+			if vsa == nil {
+				t.Fatalf("Access-Accept lacks MS-MPPE-Recv-Key")
+			}
+			key, err := DecryptTunnelPassword(vsa.Value, "secret", reply.Authenticator[:])
+			if err != nil || !bytes.Equal(key, peer.MSK[:32]) {
+				t.Errorf("MS-MPPE-Recv-Key = %x, %v; want the peer MSK %x", key, err, peer.MSK[:32])
+			}
+		})
+	}
+}
+
+func TestEapTLSRejected(t *testing.T) {
+	serverConfig, clientConfig := eapTLSTestConfigs(t)
+
+	noCert := clientConfig.Clone()
+	noCert.Certificates = nil
+	otherCA, _ := eapTLSTestConfigs(t)
+
+	m := NewEapTLS(serverConfig)
+	m.VerifyPeer = func(s *EapSession, state tls.ConnectionState) error {
+		if state.PeerCertificates[0].Subject.CommonName != s.Identity {
+			return errors.New("identity does not match the certificate")
+		}
+		return nil
+	}
+	e := NewEapServer(m)
+
+	testCases := []struct {
+		name     string
+		identity string
+		config   *tls.Config
+	}{
+		{"identity mismatch", "bob", clientConfig},
+		{"no client certificate", "alice", noCert},
+		{"untrusted client certificate", "alice", func() *tls.Config {
+			c := clientConfig.Clone()
+			c.Certificates = otherCA.Certificates
+			c.InsecureSkipVerify = true
+			return c
+		}()},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reply := e.RadiusHandle(context.Background(), eapRequest(&EapPacket{Code: EapCodeResponse, Identifier: 1, Type: EapTypeIdentity, Data: []byte(tc.identity)}, nil))
+			peer := NewEapPeerTLS(tc.config)
+			for reply.Code == AccessChallenge {
+				req := reply.GetEAPMessage()
+				data, err := peer.Process(req)
+				if err != nil {
+					// the peer gives up: answer with an empty response
+					data = []byte{0}
+				}
+				resp := &EapPacket{Code: EapCodeResponse, Identifier: req.Identifier, Type: EapTypeTLS, Data: data}
+				reply, _, _ = eapExchange(t, e, resp, reply.GetAVP(AttrState).Value)
+			}
+			if reply.Code != AccessReject {
+				t.Errorf("reply = %v; want Access-Reject", reply.Code)
+			}
+		})
+	}
+}
+
+func TestEapTLSSessionEnd(t *testing.T) {
+	serverConfig, clientConfig := eapTLSTestConfigs(t)
+	testCases := []struct {
+		name string
+		end  func(e *EapServer, req *EapPacket, state []byte)
+	}{
+		{"failure", func(e *EapServer, req *EapPacket, state []byte) {
+			eapExchange(t, e, &EapPacket{Code: EapCodeResponse, Identifier: req.Identifier, Type: 200}, state)
+		}},
+		{"expiry", func(e *EapServer, req *EapPacket, state []byte) {
+			e.mu.Lock()
+			e.sessions[string(state)].expires = time.Now()
+			e.mu.Unlock()
+			time.Sleep(time.Millisecond)
+			eapExchange(t, e, &EapPacket{Code: EapCodeResponse, Identifier: req.Identifier, Type: EapTypeTLS, Data: []byte{0}}, state)
+		}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewEapServer(NewEapTLS(serverConfig))
+			_, req, state := eapExchange(t, e, &EapPacket{Code: EapCodeResponse, Identifier: 1, Type: EapTypeIdentity, Data: []byte("alice")}, nil)
+			peer := NewEapPeerTLS(clientConfig)
+			data, err := peer.Process(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the ClientHello starts the handshake goroutine
+			_, req, state = eapExchange(t, e, &EapPacket{Code: EapCodeResponse, Identifier: req.Identifier, Type: EapTypeTLS, Data: data}, state)
+			e.mu.Lock()
+			tunnel := e.sessions[string(state)].Data.(*eapTLSTunnel)
+			e.mu.Unlock()
+
+			tc.end(e, req, state)
+			for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+				if done, _ := tunnel.result(); done {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatal("the handshake goroutine outlived the conversation")
+				}
+			}
+		})
+	}
+}
+
+func TestEapTLSFragments(t *testing.T) {
+	tunnel := newEapTLSTunnel(newEapTLSConn(), nil, 4, 0)
+	tunnel.conn.out = []byte("0123456789")
+
+	var got [][]byte
+	for b := tunnel.flush(); b != nil; {
+		got = append(got, b)
+		_, b, _ = tunnel.receive([]byte{0})
+	}
+	want := [][]byte{
+		{eapTLSFlagLength | eapTLSFlagMore, 0, 0, 0, 10, '0', '1', '2', '3'},
+		{eapTLSFlagMore, '4', '5', '6', '7'},
+		{0, '8', '9'},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("fragments = %q; want %q", got, want)
+	}
+	for i := range want {
+		if !bytes.Equal(got[i], want[i]) {
+			t.Errorf("fragment %d = %q; want %q", i, got[i], want[i])
+		}
+	}
+
+	// reassembly acknowledges each fragment but the last
+	for i, fragment := range want {
+		msg, reply, err := tunnel.receive(fragment)
+		if err != nil {
+			t.Fatalf("receive(%q) failed: %v", fragment, err)
+		}
+		if i < len(want)-1 && (msg != nil || !bytes.Equal(reply, []byte{0})) {
+			t.Errorf("receive(%q) = %q, %q; want an acknowledgement", fragment, msg, reply)
+		}
+		if i == len(want)-1 && string(msg) != "0123456789" {
+			t.Errorf("reassembled message = %q", msg)
+		}
+	}
+
+	for _, bad := range [][]byte{nil, {eapTLSFlagLength, 0, 0}, {eapTLSFlagLength, 0, 0, 0, 5, 'a'}} {
+		if _, _, err := tunnel.receive(bad); err == nil {
+			t.Errorf("receive(%x) succeeded", bad)
+		}
+	}
+}
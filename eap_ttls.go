@@ -0,0 +1,375 @@
+package radius
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Diameter AVP flags of the EAP-TTLS phase 2 attributes (RFC 5281 §10.1).
+const (
+	ttlsAVPFlagVendor    = 0x80
+	ttlsAVPFlagMandatory = 0x40
+)
+
+// TtlsAVP is an attribute of the EAP-TTLS tunnel, in Diameter AVP format
+// (RFC 5281 §10). RADIUS attributes keep their type as Code.
+type TtlsAVP struct {
+	Code      uint32
+	Mandatory bool
+	// Vendor is the vendor ID, 0 for standard attributes.
+	Vendor VendorID
+	Data   []byte
+}
+
+// EncodeTtlsAVPs encodes AVPs, each padded to a multiple of 4 bytes.
+func EncodeTtlsAVPs(avps []TtlsAVP) []byte {
+	var b []byte
+	for _, a := range avps {
+		n := 8 + len(a.Data)
+		if a.Vendor != 0 {
+			n += 4
+		}
+		h := make([]byte, 8, n+3)
+		binary.BigEndian.PutUint32(h[0:4], a.Code)
+		binary.BigEndian.PutUint32(h[4:8], uint32(n))
+		if a.Mandatory {
+			h[4] |= ttlsAVPFlagMandatory
+		}
+		if a.Vendor != 0 {
+			h[4] |= ttlsAVPFlagVendor
+			h = binary.BigEndian.AppendUint32(h, uint32(a.Vendor))
+		}
+		h = append(h, a.Data...)
+		for len(h)%4 != 0 {
+			h = append(h, 0)
+		}
+		b = append(b, h...)
+	}
+	return b
+}
+
+// DecodeTtlsAVPs decodes the AVPs of EAP-TTLS application data.
+func DecodeTtlsAVPs(b []byte) ([]TtlsAVP, error) {
+	var avps []TtlsAVP
+	for len(b) > 0 {
+		if len(b) < 8 {
+			return nil, errors.New("truncated EAP-TTLS AVP header")
+		}
+		a := TtlsAVP{
+			Code:      binary.BigEndian.Uint32(b[0:4]),
+			Mandatory: b[4]&ttlsAVPFlagMandatory != 0,
+		}
+		n := int(binary.BigEndian.Uint32(b[4:8]) & 0xffffff)
+		header := 8
+		if b[4]&ttlsAVPFlagVendor != 0 {
+			header = 12
+		}
+		if n < header || n > len(b) {
+			return nil, fmt.Errorf("invalid EAP-TTLS AVP length %d", n)
+		}
+		if header == 12 {
+			a.Vendor = VendorID(binary.BigEndian.Uint32(b[8:12]))
+		}
+		a.Data = b[header:n]
+		avps = append(avps, a)
+		if n = (n + 3) &^ 3; n > len(b) {
+			n = len(b)
+		}
+		b = b[n:]
+	}
+	return avps, nil
+}
+
+// EapTTLS is the server side of EAP-TTLSv0 (RFC 5281), an EapMethod.
+//
+// Once the TLS tunnel is established, the peer sends its credentials as
+// AVPs: User-Name with User-Password (PAP) or with MS-CHAP-Challenge and
+// MS-CHAP2-Response (MS-CHAP-V2), the challenge being derived from the TLS
+// keying material. Tunneled EAP is not supported. On success the MSK and
+// EMSK are exported from the TLS keying material.
+type EapTTLS struct {
+	// Config is the server TLS configuration.
+	Config *tls.Config
+	// PAP, when set, checks a cleartext password.
+	PAP func(username, password string) (bool, error)
+	// MSCHAPv2, when set, returns the NT-Hash of the user password for
+	// MS-CHAP-V2. A missing NT-Hash or that of the empty password rejects
+	// the user.
+	MSCHAPv2 MsChapV2LookupFunc
+	// FragmentSize is the largest TLS data sent in one EAP packet.
+	FragmentSize int
+	// HandshakeTimeout bounds the whole handshake.
+	HandshakeTimeout time.Duration
+}
+
+// NewEapTTLS returns an EAP-TTLS method using a copy of config; set PAP or
+// MSCHAPv2 to accept the matching inner authentication.
+func NewEapTTLS(config *tls.Config) *EapTTLS {
+	return &EapTTLS{
+		Config:           config.Clone(),
+		FragmentSize:     DefaultEapTLSFragmentSize,
+		HandshakeTimeout: DefaultEapTLSHandshakeTimeout,
+	}
+}
+
+// eapTTLSState is the per-conversation state of EapTTLS.
+type eapTTLSState struct {
+	t *eapTLSTunnel
+	// solicited is set once the peer was asked for its AVPs.
+	solicited bool
+	// succeeded is set once MS-CHAP2-Success was sent.
+	succeeded bool
+}
+
+// Close stops the tunnel.
+func (st *eapTTLSState) Close() error {
+	return st.t.Close()
+}
+
+func (m *EapTTLS) Type() EapType { return EapTypeTTLS }
+
+func (m *EapTTLS) Start(s *EapSession) ([]byte, error) {
+	t := newEapTLSServerTunnel(m.Config, m.FragmentSize, m.HandshakeTimeout)
+	s.Data = &eapTTLSState{t: t}
+	return t.start(), nil
+}
+
+func (m *EapTTLS) Process(s *EapSession, data []byte) (EapStatus, []byte, error) {
+	st, ok := s.Data.(*eapTTLSState)
+	if !ok {
+		return EapStatusFailure, nil, errors.New("EAP-TTLS conversation not started")
+	}
+	done, msg, out, err := st.t.serverStep(data)
+	if err != nil {
+		return EapStatusFailure, nil, err
+	}
+	if !done {
+		return EapStatusContinue, out, nil
+	}
+	if len(msg) == 0 {
+		switch {
+		case st.succeeded:
+			return m.succeed(s, st)
+		case !st.solicited:
+			// the handshake ended on the peer side: ask for its AVPs
+			st.solicited = true
+			return EapStatusContinue, st.t.ack(), nil
+		}
+		return EapStatusFailure, nil, errors.New("EAP-TTLS peer sent no AVPs")
+	}
+	if st.succeeded {
+		return EapStatusFailure, nil, errors.New("EAP-TTLS data received after MS-CHAP2-Success")
+	}
+
+	app, err := st.t.readApp(msg)
+	if err != nil {
+		return EapStatusFailure, nil, err
+	}
+	avps, err := DecodeTtlsAVPs(app)
+	if err != nil {
+		return EapStatusFailure, nil, err
+	}
+	inner, err := ttlsPacket(avps)
+	if err != nil {
+		return EapStatusFailure, nil, err
+	}
+
+	switch {
+	case inner.HasAVP(AttrUserPassword) && m.PAP != nil:
+		username := inner.GetUsername()
+		password := bytes.TrimRight(inner.GetAVP(AttrUserPassword).Value, "\x00")
+		ok, err := m.PAP(username, string(password))
+		if err != nil || !ok {
+			return EapStatusFailure, nil, err
+		}
+		s.InnerIdentity = username
+		return m.succeed(s, st)
+	case inner.GetVSA(VendorMicrosoft, AttrMSCHAP2Response) != nil && m.MSCHAPv2 != nil:
+		return m.msChapV2(s, st, inner)
+	}
+	return EapStatusFailure, nil, errors.New("EAP-TTLS inner authentication not supported")
+}
+
+// msChapV2 verifies MS-CHAP-V2 AVPs (RFC 5281 §11.2.4) and sends
+// MS-CHAP2-Success.
+func (m *EapTTLS) msChapV2(s *EapSession, st *eapTTLSState, inner *Packet) (EapStatus, []byte, error) {
+	r, err := inner.GetMSCHAPRequest()
+	if err != nil {
+		return EapStatusFailure, nil, err
+	}
+	challenge, err := ttlsChallenge(st.t)
+	if err != nil {
+		return EapStatusFailure, nil, err
+	}
+	if r.Version != 2 || !bytes.Equal(r.Challenge, challenge[:16]) || r.Ident != challenge[16] {
+		return EapStatusFailure, nil, errors.New("EAP-TTLS MS-CHAP-Challenge does not match the tunnel")
+	}
+	ntHash, err := m.MSCHAPv2(r.UserName)
+	if err != nil {
+		return EapStatusFailure, nil, err
+	}
+	ok, err := r.Verify(ntHash)
+	if err != nil || !ok {
+		return EapStatusFailure, nil, err
+	}
+
+	s.InnerIdentity = r.UserName
+	st.succeeded = true
+	auth := MSCHAPv2AuthenticatorResponse(ntHash, r.NTResponse, r.PeerChallenge, r.Challenge, msChapUserName(r.UserName))
+	success := EncodeTtlsAVPs([]TtlsAVP{{
+		Code:      uint32(AttrMSCHAP2Success),
+		Mandatory: true,
+		Vendor:    VendorMicrosoft,
+		Data:      append([]byte{r.Ident}, auth...),
+	}})
+	if _, err := st.t.tls.Write(success); err != nil {
+		return EapStatusFailure, nil, err
+	}
+	return EapStatusContinue, st.t.flush(), nil
+}
+
+func (m *EapTTLS) succeed(s *EapSession, st *eapTTLSState) (EapStatus, []byte, error) {
+	var err error
+	if s.MSK, s.EMSK, err = st.t.keys("ttls keying material", EapTypeTTLS); err != nil {
+		return EapStatusFailure, nil, err
+	}
+	return EapStatusSuccess, nil, nil
+}
+
+// ttlsChallenge derives the MS-CHAP challenge and identifier of the tunnel
+// (RFC 5281 §11.1).
+func ttlsChallenge(t *eapTLSTunnel) ([]byte, error) {
+	state := t.tls.ConnectionState()
+	return state.ExportKeyingMaterial("ttls challenge", nil, msChapV2ChallengeSize+1)
+}
+
+// ttlsPacket converts AVPs to a packet holding the matching RADIUS
+// attributes. A mandatory AVP which is not understood is an error.
+func ttlsPacket(avps []TtlsAVP) (*Packet, error) {
+	p := new(Packet)
+	for _, a := range avps {
+		switch {
+		case a.Vendor == 0 && (a.Code == uint32(AttrUserName) || a.Code == uint32(AttrUserPassword)):
+			p.AddAVP(AVP{Type: AttributeType(a.Code), Value: a.Data})
+		case a.Vendor == VendorMicrosoft && (a.Code == uint32(AttrMSCHAPChallenge) || a.Code == uint32(AttrMSCHAP2Response)):
+			p.AddVSA(VSA{Vendor: a.Vendor, Type: VendorAttr(a.Code), Value: a.Data})
+		case a.Mandatory:
+			return nil, fmt.Errorf("unsupported mandatory EAP-TTLS AVP %d (vendor %d)", a.Code, a.Vendor)
+		}
+	}
+	return p, nil
+}
+
+// EapPeerTTLS is the peer side of EAP-TTLSv0, an EapPeerMethod,
+// authenticating with PAP or MS-CHAP-V2 in the tunnel.
+type EapPeerTTLS struct {
+	// Config is the client TLS configuration, with the roots and name used
+	// to verify the server.
+	Config   *tls.Config
+	Username string
+	Password string
+	// MSCHAPv2 selects MS-CHAP-V2 instead of PAP.
+	MSCHAPv2 bool
+	// FragmentSize is the largest TLS data sent in one EAP packet.
+	FragmentSize int
+	// MSK and EMSK are exported once the tunnel is established.
+	MSK, EMSK []byte
+
+	t    *eapTLSTunnel
+	sent bool
+	// ms is the MS-CHAP-V2 request sent, to check the server response.
+	ms *MSCHAPRequest
+}
+
+// NewEapPeerTTLS returns an EAP-TTLS peer sending username and password with
+// PAP.
+func NewEapPeerTTLS(config *tls.Config, username, password string) *EapPeerTTLS {
+	return &EapPeerTTLS{Config: config, Username: username, Password: password, FragmentSize: DefaultEapTLSFragmentSize}
+}
+
+func (m *EapPeerTTLS) Type() EapType { return EapTypeTTLS }
+
+func (m *EapPeerTTLS) Process(req *EapPacket) ([]byte, error) {
+	if len(req.Data) > 0 && req.Data[0]&eapTLSFlagStart != 0 {
+		if m.t != nil {
+			m.t.Close()
+		}
+		m.t = newEapTLSClientTunnel(m.Config, m.FragmentSize)
+		m.MSK, m.EMSK, m.sent, m.ms = nil, nil, false, nil
+	}
+	if m.t == nil {
+		return nil, errors.New("EAP-TTLS request before Start")
+	}
+	done, app, out, err := m.t.peerStep(req.Data)
+	if err != nil || !done {
+		return out, err
+	}
+	if m.MSK == nil {
+		if m.MSK, m.EMSK, err = m.t.keys("ttls keying material", EapTypeTTLS); err != nil {
+			return nil, err
+		}
+	}
+	if !m.sent {
+		return m.sendCredentials()
+	}
+	if m.ms == nil || len(app) == 0 {
+		return nil, errors.New("unexpected EAP-TTLS request")
+	}
+	return m.checkSuccess(app)
+}
+
+// sendCredentials sends the AVPs of the inner authentication.
+func (m *EapPeerTTLS) sendCredentials() ([]byte, error) {
+	avps := []TtlsAVP{{Code: uint32(AttrUserName), Mandatory: true, Data: []byte(m.Username)}}
+	if m.MSCHAPv2 {
+		challenge, err := ttlsChallenge(m.t)
+		if err != nil {
+			return nil, err
+		}
+		p := new(Packet)
+		p.AddAVP(AVP{Type: AttrUserName, Value: []byte(m.Username)})
+		if err := p.SetMSCHAPv2FromSecret(challenge[16], m.Password, challenge[:16]); err != nil {
+			return nil, err
+		}
+		if m.ms, err = p.GetMSCHAPRequest(); err != nil {
+			return nil, err
+		}
+		for _, attr := range []VendorAttr{AttrMSCHAPChallenge, AttrMSCHAP2Response} {
+			avps = append(avps, TtlsAVP{Code: uint32(attr), Mandatory: true, Vendor: VendorMicrosoft, Data: p.GetVSA(VendorMicrosoft, attr).Value})
+		}
+	} else {
+		// User-Password is padded to a multiple of 16 bytes
+		password := make([]byte, (len(m.Password)+15)&^15)
+		copy(password, m.Password)
+		avps = append(avps, TtlsAVP{Code: uint32(AttrUserPassword), Mandatory: true, Data: password})
+	}
+	if _, err := m.t.tls.Write(EncodeTtlsAVPs(avps)); err != nil {
+		return nil, err
+	}
+	m.sent = true
+	return m.t.flush(), nil
+}
+
+// checkSuccess verifies the authenticator response of MS-CHAP2-Success and
+// acknowledges it.
+func (m *EapPeerTTLS) checkSuccess(app []byte) ([]byte, error) {
+	avps, err := DecodeTtlsAVPs(app)
+	if err != nil {
+		return nil, err
+	}
+	auth := MSCHAPv2AuthenticatorResponse(MSCHAPv2NTHash(m.Password), m.ms.NTResponse, m.ms.PeerChallenge, m.ms.Challenge, msChapUserName(m.Username))
+	for _, a := range avps {
+		if a.Vendor == VendorMicrosoft && a.Code == uint32(AttrMSCHAP2Success) {
+			if len(a.Data) < 1 || a.Data[0] != m.ms.Ident || string(a.Data[1:]) != auth {
+				return nil, errors.New("invalid EAP-TTLS MS-CHAP2-Success")
+			}
+			m.ms = nil
+			return m.t.ack(), nil
+		}
+	}
+	return nil, errors.New("EAP-TTLS response without MS-CHAP2-Success")
+}
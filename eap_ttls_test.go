@@ -0,0 +1,107 @@
+package radius
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"testing"
+)
+
+func TestEapTTLS(t *testing.T) {
+	serverConfig, clientConfig := eapTLSTestConfigs(t)
+	clientConfig.Certificates = nil
+
+	testCases := []struct {
+		name     string
+		version  uint16
+		mschap   bool
+		user     string
+		password string
+		accept   bool
+	}{
+		{"TLS 1.2 PAP", tls.VersionTLS12, false, "alice", "secret", true},
+		{"TLS 1.3 PAP", tls.VersionTLS13, false, "alice", "secret", true},
+		{"TLS 1.2 MS-CHAP-V2", tls.VersionTLS12, true, "alice", "secret", true},
+		{"TLS 1.3 MS-CHAP-V2", tls.VersionTLS13, true, "alice", "secret", true},
+		{"PAP wrong password", tls.VersionTLS12, false, "alice", "wrong", false},
+		{"MS-CHAP-V2 wrong password", tls.VersionTLS13, true, "alice", "wrong", false},
+		{"MS-CHAP-V2 unknown user", tls.VersionTLS13, true, "mallory", "", false},
+		{"MS-CHAP-V2 empty password", tls.VersionTLS13, true, "eve", "", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewEapTTLS(serverConfig)
+			m.Config.MaxVersion = tc.version
+			m.PAP = func(username, password string) (bool, error) {
+				return username == "alice" && password == "secret", nil
+			}
+			ntHashes := map[string][]byte{"alice": MSCHAPv2NTHash("secret"), "eve": MSCHAPv2NTHash("")}
+			m.MSCHAPv2 = func(username string) ([]byte, error) {
+				return ntHashes[username], nil
+			}
+			e := NewEapServer(m)
+			var inner string
+			e.SetAcceptFunc(func(ctx context.Context, s *EapSession, reply *Packet) bool {
+				inner = s.InnerIdentity
+				return true
+			})
+
+			peer := NewEapPeerTTLS(clientConfig, tc.user, tc.password)
+			peer.MSCHAPv2 = tc.mschap
+			reply := eapPeerConversation(t, e, "anonymous", peer)
+			if !tc.accept {
+				if reply.Code != AccessReject {
+					t.Fatalf("reply = %v; want Access-Reject", reply.Code)
+				}
+				return
+			}
+			if reply.Code != AccessAccept {
+				t.Fatalf("reply = %v; want Access-Accept", reply.Code)
+			}
+			if inner != "alice" {
+				t.Errorf("InnerIdentity = %q; want alice", inner)
+			}
+			vsa := reply.GetVSA(VendorMicrosoft, AttrMSMPPERecvKey)
+			if vsa == nil {
+				t.Fatalf("Access-Accept lacks MS-MPPE-Recv-Key")
+			}
+			key, err := DecryptTunnelPassword(vsa.Value, "secret", reply.Authenticator[:])
+			if err != nil || !bytes.Equal(key, peer.MSK[:32]) {
+				t.Errorf("MS-MPPE-Recv-Key = %x, %v; want the peer MSK %x", key, err, peer.MSK[:32])
+			}
+		})
+	}
+}
+
+func TestTtlsAVPs(t *testing.T) {
+	avps := []TtlsAVP{
+		{Code: uint32(AttrUserName), Mandatory: true, Data: []byte("alice")},
+		{Code: uint32(AttrMSCHAP2Success), Vendor: VendorMicrosoft, Data: []byte{1, 2, 3, 4}},
+	}
+	b := EncodeTtlsAVPs(avps)
+	want := []byte{
+		0, 0, 0, 1, 0x40, 0, 0, 13, 'a', 'l', 'i', 'c', 'e', 0, 0, 0,
+		0, 0, 0, 26, 0x80, 0, 0, 16, 0, 0, 1, 55, 1, 2, 3, 4,
+	}
+	if !bytes.Equal(b, want) {
+		t.Fatalf("EncodeTtlsAVPs = %x; want %x", b, want)
+	}
+	got, err := DecodeTtlsAVPs(b)
+	if err != nil || len(got) != 2 {
+		t.Fatalf("DecodeTtlsAVPs = %v, %v", got, err)
+	}
+	for i := range avps {
+		if got[i].Code != avps[i].Code || got[i].Mandatory != avps[i].Mandatory || got[i].Vendor != avps[i].Vendor || !bytes.Equal(got[i].Data, avps[i].Data) {
+			t.Errorf("AVP %d = %+v; want %+v", i, got[i], avps[i])
+		}
+	}
+
+	for _, bad := range [][]byte{{0, 0, 0, 1, 0}, {0, 0, 0, 1, 0, 0, 0, 20}, {0, 0, 0, 1, 0x80, 0, 0, 8}} {
+		if _, err := DecodeTtlsAVPs(bad); err == nil {
+			t.Errorf("DecodeTtlsAVPs(%x) succeeded", bad)
+		}
+	}
+	if _, err := ttlsPacket([]TtlsAVP{{Code: 79, Mandatory: true}}); err == nil {
+		t.Errorf("unknown mandatory AVP accepted")
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/sergle/radius/v2"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:1812", "RADIUS server address")
+	secret := flag.String("secret", "gopher", "RADIUS shared secret")
+	user := flag.String("user", "a", "EAP identity")
+	pass := flag.String("pass", "a", "Password")
+	method := flag.String("method", "mschapv2", "EAP method: md5 or mschapv2")
+	flag.Parse()
+
+	var peer radius.EapPeerMethod
+	switch *method {
+	case "md5":
+		peer = &radius.EapPeerMD5{Password: *pass}
+	case "mschapv2":
+		peer = radius.NewEapPeerMSCHAPv2(*user, *pass)
+	default:
+		log.Fatalf("Unknown EAP method %q", *method)
+	}
+
+	client := radius.NewRadClient(*addr, *secret)
+	eap := radius.NewEapClient(client, *user, peer)
+
+	log.Printf("Starting EAP-%s conversation with %s...", *method, *addr)
+	reply, err := eap.Authenticate(context.Background())
+	if err != nil {
+		log.Fatalf("EAP conversation failed: %v", err)
+	}
+
+	log.Printf("Received reply:\n%s", reply.String())
+}
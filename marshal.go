@@ -0,0 +1,473 @@
+package radius
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ipType    = reflect.TypeOf(net.IP(nil))
+	ipNetType = reflect.TypeOf(net.IPNet{})
+	timeType  = reflect.TypeOf(time.Time{})
+)
+
+// Marshal adds attributes for the tagged fields of the struct v (or pointer
+// to struct) to p, using the default dictionary. See Dictionary.Marshal.
+func Marshal(v interface{}, p *Packet) error {
+	return GetDefaultDictionary().Marshal(v, p)
+}
+
+// Unmarshal stores the attributes of p in the tagged fields of the struct
+// pointed to by v, using the default dictionary. See Dictionary.Unmarshal.
+func Unmarshal(p *Packet, v interface{}) error {
+	return GetDefaultDictionary().Unmarshal(p, v)
+}
+
+// UnmarshalReply is like Unmarshal for a received reply p, decrypting values
+// with requestAuth. See Dictionary.UnmarshalReply.
+func UnmarshalReply(p *Packet, requestAuth []byte, v interface{}) error {
+	return GetDefaultDictionary().UnmarshalReply(p, requestAuth, v)
+}
+
+// marshalField is a struct field tagged `radius:"path[,omitempty]"`.
+type marshalField struct {
+	index     []int
+	name      string
+	path      string
+	omitEmpty bool
+}
+
+// marshalFields lists the tagged fields of a struct type. Untagged embedded
+// structs are searched for tagged fields too.
+func marshalFields(t reflect.Type, index []int) ([]marshalField, error) {
+	var fields []marshalField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		idx := append(append([]int(nil), index...), i)
+		tag, ok := sf.Tag.Lookup("radius")
+		if !ok {
+			if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+				embedded, err := marshalFields(sf.Type, idx)
+				if err != nil {
+					return nil, err
+				}
+				fields = append(fields, embedded...)
+			}
+			continue
+		}
+		if tag == "-" {
+			continue
+		}
+		if !sf.IsExported() {
+			return nil, fmt.Errorf("field %s: radius tag on unexported field", sf.Name)
+		}
+
+		path, opts, _ := strings.Cut(tag, ",")
+		if path == "" {
+			return nil, fmt.Errorf("field %s: missing attribute in radius tag", sf.Name)
+		}
+		f := marshalField{index: idx, name: sf.Name, path: path}
+		for _, opt := range strings.Split(opts, ",") {
+			switch opt {
+			case "":
+			case "omitempty":
+				f.omitEmpty = true
+			default:
+				return nil, fmt.Errorf("field %s: unknown radius tag option %s", sf.Name, opt)
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// Marshal adds attributes for the fields of the struct v (or pointer to
+// struct) tagged with an attribute path (see ResolveAttribute), like
+//
+//	type Session struct {
+//		User    string    `radius:"User-Name"`
+//		Service string    `radius:"Service-Type"`
+//		Tunnel  uint32    `radius:"Tunnel-Type:1,omitempty"`
+//		Pairs   []string  `radius:"Cisco.Cisco-AVPair,omitempty"`
+//		Address net.IP    `radius:"Framed-IP-Address,omitempty"`
+//		Start   time.Time `radius:"Event-Timestamp,omitempty"`
+//	}
+//
+// Supported field types are strings (encoded like AddAttribute, so VALUE
+// names are accepted), integers (including named enum types), net.IP,
+// net.IPNet for prefix attributes, time.Time for date attributes and []byte
+// for string, octets and EAP-Message attributes. Slices of these add one
+// attribute per element, and nil pointers are skipped. With "omitempty" zero
+// values are skipped as well. Fields tagged "-" and untagged fields are
+// ignored.
+//
+// Attributes are encrypted and tagged as for templates, so the packet Secret
+// and Authenticator should be set first. On error attributes of previous
+// fields have already been added to p.
+func (d *Dictionary) Marshal(v interface{}, p *Packet) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot marshal %T: not a struct", v)
+	}
+	fields, err := marshalFields(rv.Type(), nil)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if err := d.marshalField(p, f, rv.FieldByIndex(f.index)); err != nil {
+			return fmt.Errorf("field %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+func (d *Dictionary) marshalField(p *Packet, f marshalField, fv reflect.Value) error {
+	for fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			return nil
+		}
+		fv = fv.Elem()
+	}
+	if f.omitEmpty && fv.IsZero() {
+		return nil
+	}
+
+	t, err := d.GetPathTemplate(f.path)
+	if err != nil {
+		return err
+	}
+	if !isMultiValued(fv.Type()) {
+		return marshalValue(p, t, fv)
+	}
+	for i := 0; i < fv.Len(); i++ {
+		if err := marshalValue(p, t, fv.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isMultiValued reports whether a field type holds several attributes.
+func isMultiValued(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t != ipType && t.Elem().Kind() != reflect.Uint8
+}
+
+// marshalValue adds the attribute for a single value.
+func marshalValue(p *Packet, t AVPTemplate, v reflect.Value) error {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return errors.New("nil value")
+		}
+		v = v.Elem()
+	}
+
+	var s string
+	switch {
+	case v.Type() == timeType:
+		s = strconv.FormatInt(v.Interface().(time.Time).Unix(), 10)
+	case v.Type() == ipType:
+		s = v.Interface().(net.IP).String()
+	case v.Type() == ipNetType:
+		n := v.Interface().(net.IPNet)
+		s = n.String()
+	default:
+		switch v.Kind() {
+		case reflect.String:
+			s = v.String()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			s = strconv.FormatInt(v.Int(), 10)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			s = strconv.FormatUint(v.Uint(), 10)
+		case reflect.Slice:
+			if v.Type().Elem().Kind() != reflect.Uint8 {
+				return fmt.Errorf("unsupported type %s", v.Type())
+			}
+			if !isRawType(templateDef(t).Type) {
+				return fmt.Errorf("cannot marshal %s into %s attribute", v.Type(), templateDef(t).Type)
+			}
+			s = string(v.Bytes())
+		default:
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+	}
+	return t.AddValue(p, s)
+}
+
+// templateDef returns the attribute definition of a template.
+func templateDef(t AVPTemplate) AttributeDef {
+	switch t := t.(type) {
+	case *AttributeTemplate:
+		return t.def
+	case *VSATemplate:
+		return t.def
+	case *ExtendedTemplate:
+		return t.def
+	}
+	return AttributeDef{}
+}
+
+// isRawType reports whether values of a data type are plain byte strings.
+func isRawType(typeName string) bool {
+	switch typeName {
+	case "string", "octets", "password", "eapmessage":
+		return true
+	}
+	return false
+}
+
+// Unmarshal stores the attributes of p in the fields of the struct pointed
+// to by v tagged with an attribute path, as described for Marshal.
+//
+// String fields receive string and octets values as is and other values in
+// text form, with VALUE names for enumerated attributes. Other fields
+// receive the first value of the attribute, slices receive all of them.
+// Values of concat attributes are joined, and encrypted values are decrypted
+// using the packet Secret and Authenticator. For paths with a tag suffix only
+// values with that tag are used. Fields of absent attributes are left
+// unchanged.
+//
+// Use UnmarshalReply for a received reply, whose Authenticator is the
+// Response Authenticator.
+func (d *Dictionary) Unmarshal(p *Packet, v interface{}) error {
+	return d.unmarshal(p, p.Authenticator[:], v)
+}
+
+// UnmarshalReply is like Unmarshal for a received reply p: encrypted values
+// are decrypted with requestAuth, the Authenticator of the request sent.
+func (d *Dictionary) UnmarshalReply(p *Packet, requestAuth []byte, v interface{}) error {
+	return d.unmarshal(p, requestAuth, v)
+}
+
+func (d *Dictionary) unmarshal(p *Packet, requestAuth []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot unmarshal into %T: not a pointer to struct", v)
+	}
+	rv = rv.Elem()
+	fields, err := marshalFields(rv.Type(), nil)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if err := d.unmarshalField(p, requestAuth, f, rv.FieldByIndex(f.index)); err != nil {
+			return fmt.Errorf("field %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+func (d *Dictionary) unmarshalField(p *Packet, requestAuth []byte, f marshalField, fv reflect.Value) error {
+	d.RLock()
+	def, tag, tagged, err := d.resolveTaggedAttribute(f.path)
+	format := d.getVendorFormat(def.Vendor)
+	d.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	var raws, plains [][]byte
+	for _, raw := range def.packetValues(p, format) {
+		valueTag, plain, err := def.DecodeBytes(p, requestAuth, raw)
+		if err != nil {
+			return err
+		}
+		if tagged && valueTag != tag {
+			continue
+		}
+		raws = append(raws, raw)
+		plains = append(plains, plain)
+	}
+	if len(raws) == 0 {
+		return nil
+	}
+
+	t := fv.Type()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if !isMultiValued(t) {
+		return d.unmarshalValue(p, def, fv, raws[0], plains[0])
+	}
+
+	for fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		fv = fv.Elem()
+	}
+	values := reflect.MakeSlice(t, len(raws), len(raws))
+	for i := range raws {
+		if err := d.unmarshalValue(p, def, values.Index(i), raws[i], plains[i]); err != nil {
+			return err
+		}
+	}
+	fv.Set(values)
+	return nil
+}
+
+// packetValues returns the values of the attribute in p in order. Values of
+// concat attributes are joined into one.
+func (a AttributeDef) packetValues(p *Packet, f VendorFormat) [][]byte {
+	var values [][]byte
+	if a.Extended > 0 {
+		var value []byte
+		p.EachAVP(func(avp AVP) bool {
+			if avp.Type != a.Extended {
+				return true
+			}
+			e, more := decodeExtended(avp)
+			if e == nil || uint32(e.ExtType) != a.ID {
+				return true
+			}
+			// long extended fragments
+			value = append(value, e.Value...)
+			if !more {
+				values = append(values, value)
+				value = nil
+			}
+			return true
+		})
+		if value != nil {
+			values = append(values, value)
+		}
+	} else if a.Vendor == 0 {
+		p.EachAVP(func(avp AVP) bool {
+			if avp.Type == AttributeType(a.ID) {
+				values = append(values, avp.Value)
+			}
+			return true
+		})
+	} else {
+		var value []byte
+		p.EachAVP(func(avp AVP) bool {
+			if avp.Type != AttrVendorSpecific || len(avp.Value) < 4 ||
+				VendorID(binary.BigEndian.Uint32(avp.Value[0:4])) != a.Vendor {
+				return true
+			}
+			vsa, more := decodeVSA(avp, f)
+			if vsa.Type != VendorAttr(a.ID) {
+				return true
+			}
+			// WiMAX continued fragments
+			value = append(value, vsa.Value...)
+			if !more {
+				values = append(values, value)
+				value = nil
+			}
+			return true
+		})
+		if value != nil {
+			values = append(values, value)
+		}
+	}
+	if a.Flags.Concat && len(values) > 1 {
+		return [][]byte{bytes.Join(values, nil)}
+	}
+	return values
+}
+
+// unmarshalValue stores a single value of the attribute in v. raw is the
+// value as received, plain the value without tag and encryption.
+func (d *Dictionary) unmarshalValue(p *Packet, def AttributeDef, v reflect.Value, raw, plain []byte) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.unmarshalValue(p, def, v.Elem(), raw, plain)
+	}
+
+	switch v.Type() {
+	case timeType:
+		if def.Type != "date" || len(plain) != uint32Size {
+			return fmt.Errorf("cannot unmarshal %s value into %s", def.Type, v.Type())
+		}
+		v.Set(reflect.ValueOf(avpDate.Value(p, AVP{Value: plain})))
+		return nil
+	case ipType:
+		if len(plain) != net.IPv4len && len(plain) != net.IPv6len {
+			return fmt.Errorf("cannot unmarshal %s value into %s", def.Type, v.Type())
+		}
+		v.SetBytes(append([]byte(nil), plain...))
+		return nil
+	case ipNetType:
+		var n *net.IPNet
+		if def.Type == "ipv4prefix" || def.Type == "ipv6prefix" {
+			n = attrTypeHandlers[def.Type].Value(p, AVP{Value: plain}).(*net.IPNet)
+		}
+		if n == nil {
+			return fmt.Errorf("cannot unmarshal %s value into %s", def.Type, v.Type())
+		}
+		v.Set(reflect.ValueOf(*n))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		if isRawType(def.Type) {
+			v.SetString(string(plain))
+		} else if def.Flags.Encrypt != EncryptMethodNone {
+			// plain is decrypted already, with the authenticator of the request
+			plainDef := def
+			plainDef.Flags.HasTag = false
+			plainDef.Flags.Encrypt = EncryptMethodNone
+			v.SetString(formatValue(d, p, plainDef, AVP{Type: AttributeType(def.ID), Value: plain}))
+		} else {
+			v.SetString(formatValue(d, p, def, AVP{Type: AttributeType(def.ID), Value: raw}))
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := decodeUint(plain)
+		var i int64
+		switch {
+		case ok && def.Type == "signed" && len(plain) == uint32Size:
+			i = int64(int32(n))
+		case ok && n <= math.MaxInt64:
+			i = int64(n)
+		default:
+			ok = false
+		}
+		if !ok || v.OverflowInt(i) {
+			return fmt.Errorf("cannot unmarshal %s value into %s", def.Type, v.Type())
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := decodeUint(plain)
+		if !ok || v.OverflowUint(n) {
+			return fmt.Errorf("cannot unmarshal %s value into %s", def.Type, v.Type())
+		}
+		v.SetUint(n)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		v.SetBytes(append([]byte(nil), plain...))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// decodeUint decodes a big endian byte, short, integer or integer64 value.
+func decodeUint(b []byte) (uint64, bool) {
+	switch len(b) {
+	case 1:
+		return uint64(b[0]), true
+	case uint16Size:
+		return uint64(binary.BigEndian.Uint16(b)), true
+	case uint32Size:
+		return uint64(binary.BigEndian.Uint32(b)), true
+	case 8:
+		return binary.BigEndian.Uint64(b), true
+	}
+	return 0, false
+}
@@ -0,0 +1,226 @@
+package radius
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type marshalTestService uint32
+
+type marshalTestCommon struct {
+	NASPort *uint32 `radius:"NAS-Port,omitempty"`
+}
+
+type marshalTestRequest struct {
+	marshalTestCommon
+	User       string             `radius:"User-Name"`
+	Password   string             `radius:"User-Password,omitempty"`
+	Service    marshalTestService `radius:"Service-Type"`
+	ServiceStr string             `radius:"Service-Type"`
+	Tunnel     uint32             `radius:"Tunnel-Type:2,omitempty"`
+	TunnelPass []byte             `radius:"Tunnel-Password:2,omitempty"`
+	Address    net.IP             `radius:"Framed-IP-Address,omitempty"`
+	Prefix     net.IPNet          `radius:"Framed-IPv6-Prefix,omitempty"`
+	Timestamp  time.Time          `radius:"Event-Timestamp,omitempty"`
+	Class      [][]byte           `radius:"Class,omitempty"`
+	Pairs      []string           `radius:"Cisco.Cisco-AVPair,omitempty"`
+	Policy     string             `radius:"MS-MPPE-Encryption-Policy,omitempty"`
+	EAP        []byte             `radius:"EAP-Message,omitempty"`
+	Ignored    string             `radius:"-"`
+	Untagged   string
+}
+
+func TestMarshalUnmarshal(t *testing.T) {
+	d := newResolveTestDictionary(t)
+
+	port := uint32(7)
+	in := marshalTestRequest{
+		marshalTestCommon: marshalTestCommon{NASPort: &port},
+		User:              "alice",
+		Password:          "s3cret",
+		Service:           2,
+		ServiceStr:        "Login-User",
+		Tunnel:            3,
+		TunnelPass:        []byte("tunnel"),
+		Address:           net.IPv4(192, 0, 2, 1),
+		Prefix:            net.IPNet{IP: net.ParseIP("2001:db8::"), Mask: net.CIDRMask(32, 128)},
+		Timestamp:         time.Unix(1700000000, 0).UTC(),
+		Class:             [][]byte{{1, 2}, {3}},
+		Pairs:             []string{"shell:priv-lvl=15", "ip:addr-pool=pool1"},
+		Policy:            "Encryption-Required",
+		EAP:               bytes.Repeat([]byte{0xaa}, 300),
+		Ignored:           "x",
+		Untagged:          "y",
+	}
+
+	p := Request(AccessRequest, "secret")
+	if err := d.Marshal(&in, p); err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if avps := len(p.AVPs); avps != 17 {
+		t.Errorf("Marshal added %d attributes; want 17", avps)
+	}
+	if avp := p.GetAVP(AttrTunnelType); avp == nil || !bytes.Equal(avp.Value, []byte{2, 0, 0, 3}) {
+		t.Errorf("Tunnel-Type = %v", avp)
+	}
+
+	buf, err := p.Encode()
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	q, err := DecodeRequest("secret", buf)
+	if err != nil {
+		t.Fatalf("DecodeRequest failed: %v", err)
+	}
+
+	var out marshalTestRequest
+	if err := d.Unmarshal(q, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	in.Ignored, in.Untagged = "", ""
+	// both fields see the two Service-Type attributes, the first one wins
+	in.ServiceStr = "Framed-User"
+	in.Address = in.Address.To4()
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("Unmarshal() = %+v; want %+v", out, in)
+	}
+}
+
+func TestUnmarshalTags(t *testing.T) {
+	d := newResolveTestDictionary(t)
+	p := Request(AccessRequest, "secret")
+	for _, a := range []struct{ path, value string }{
+		{"Tunnel-Type:1", "L2TP"},
+		{"Tunnel-Type:2", "PPTP"},
+		{"Service-Type", "Framed-User"},
+	} {
+		if err := d.AddAttribute(p, a.path, a.value); err != nil {
+			t.Fatalf("AddAttribute(%s) failed: %v", a.path, err)
+		}
+	}
+
+	var v struct {
+		First   string   `radius:"Tunnel-Type:1"`
+		Second  string   `radius:"Tunnel-Type:2"`
+		All     []string `radius:"Tunnel-Type"`
+		Service *int8    `radius:"Service-Type"`
+		Missing *string  `radius:"User-Name"`
+		Keep    string   `radius:"Reply-Message"`
+	}
+	v.Keep = "unchanged"
+	if err := d.Unmarshal(p, &v); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if v.First != "L2TP" || v.Second != "PPTP" {
+		t.Errorf("Tunnel-Type = %s, %s; want L2TP, PPTP", v.First, v.Second)
+	}
+	if !reflect.DeepEqual(v.All, []string{"L2TP", "PPTP"}) {
+		t.Errorf("all Tunnel-Type = %v", v.All)
+	}
+	if v.Service == nil || *v.Service != 2 {
+		t.Errorf("Service-Type = %v; want 2", v.Service)
+	}
+	if v.Missing != nil || v.Keep != "unchanged" {
+		t.Errorf("absent attributes changed fields: %v, %s", v.Missing, v.Keep)
+	}
+}
+
+func TestUnmarshalReply(t *testing.T) {
+	d := newResolveTestDictionary(t)
+	request := Request(AccessRequest, "secret")
+	reply := request.Reply()
+	reply.Code = AccessAccept
+	if err := d.AddAttribute(reply, "Tunnel-Password:1", "tunnel"); err != nil {
+		t.Fatalf("AddAttribute failed: %v", err)
+	}
+	buf, err := reply.Encode()
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	q, err := DecodeReply("secret", buf, request.Authenticator[:])
+	if err != nil {
+		t.Fatalf("DecodeReply failed: %v", err)
+	}
+
+	var v struct {
+		Password string `radius:"Tunnel-Password:1"`
+	}
+	if err := d.UnmarshalReply(q, request.Authenticator[:], &v); err != nil {
+		t.Fatalf("UnmarshalReply failed: %v", err)
+	}
+	if v.Password != "tunnel" {
+		t.Errorf("Tunnel-Password = %q; want tunnel", v.Password)
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	d := newResolveTestDictionary(t)
+
+	testCases := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{"not a struct", "alice", "not a struct"},
+		{"unknown attribute", struct {
+			X string `radius:"No-Such-Attribute"`
+		}{"x"}, "field X"},
+		{"invalid value", struct {
+			Service string `radius:"Service-Type"`
+		}{"Bogus"}, "field Service"},
+		{"unsupported type", struct {
+			Flag bool `radius:"Service-Type"`
+		}{true}, "unsupported type"},
+		{"bytes into integer", struct {
+			Port []byte `radius:"NAS-Port"`
+		}{[]byte{1}}, "cannot marshal"},
+		{"unknown option", struct {
+			User string `radius:"User-Name,sometimes"`
+		}{"alice"}, "unknown radius tag option"},
+		{"missing attribute", struct {
+			User string `radius:",omitempty"`
+		}{"alice"}, "missing attribute"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := d.Marshal(tc.v, Request(AccessRequest, "secret"))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("Marshal() error = %v; want %q", err, tc.want)
+			}
+		})
+	}
+
+	p := Request(AccessRequest, "secret")
+	p.AddAVP(AVP{Type: AttrNASPort, Value: []byte{0, 0, 1, 0}})
+	var small struct {
+		Port uint8 `radius:"NAS-Port"`
+	}
+	if err := d.Unmarshal(p, &small); err == nil {
+		t.Errorf("Unmarshal of 256 into uint8 succeeded")
+	}
+	if err := d.Unmarshal(p, small); err == nil {
+		t.Errorf("Unmarshal into a struct value succeeded")
+	}
+}
+
+func TestMarshalDefaultDictionary(t *testing.T) {
+	type request struct {
+		User string `radius:"User-Name"`
+		Port uint32 `radius:"NAS-Port"`
+	}
+	p := Request(AccessRequest, "secret")
+	if err := Marshal(request{User: "bob", Port: 5}, p); err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var out request
+	if err := Unmarshal(p, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if out.User != "bob" || out.Port != 5 {
+		t.Errorf("Unmarshal() = %+v", out)
+	}
+}
@@ -0,0 +1,41 @@
+package radius
+
+// VendorMicrosoft is the vendor ID of the Microsoft VSAs (RFC 2548).
+const VendorMicrosoft VendorID = 311
+
+// Microsoft vendor attributes (RFC 2548).
+const (
+	AttrMSCHAPResponse         VendorAttr = 1
+	AttrMSCHAPError            VendorAttr = 2
+	AttrMSMPPEEncryptionPolicy VendorAttr = 7
+	AttrMSMPPEEncryptionTypes  VendorAttr = 8
+	AttrMSCHAPChallenge        VendorAttr = 11
+	AttrMSMPPESendKey          VendorAttr = 16
+	AttrMSMPPERecvKey          VendorAttr = 17
+	AttrMSCHAP2Response        VendorAttr = 25
+	AttrMSCHAP2Success         VendorAttr = 26
+)
+
+// SetMPPEKeys adds or replaces MS-MPPE-Send-Key and MS-MPPE-Recv-Key
+// (RFC 2548 §2.4.2, §2.4.3), as sent in an Access-Accept concluding EAP or
+// MS-CHAP authentication.
+//
+// The keys are encrypted with the packet Secret and Authenticator, so p must
+// be a reply created by Packet.Reply and not yet encoded: its Authenticator
+// is still the Request Authenticator. The packet is left unchanged when the
+// keys cannot be encrypted.
+func (p *Packet) SetMPPEKeys(sendKey, recvKey []byte) error {
+	send, err := EncryptTunnelPassword(sendKey, p.Secret, p.Authenticator[:])
+	if err != nil {
+		return err
+	}
+	recv, err := EncryptTunnelPassword(recvKey, p.Secret, p.Authenticator[:])
+	if err != nil {
+		return err
+	}
+	p.DeleteVSAWithFormat(VendorMicrosoft, AttrMSMPPESendKey, DefaultVendorFormat)
+	p.DeleteVSAWithFormat(VendorMicrosoft, AttrMSMPPERecvKey, DefaultVendorFormat)
+	p.AddVSA(VSA{Vendor: VendorMicrosoft, Type: AttrMSMPPESendKey, Value: send})
+	p.AddVSA(VSA{Vendor: VendorMicrosoft, Type: AttrMSMPPERecvKey, Value: recv})
+	return nil
+}
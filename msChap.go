@@ -0,0 +1,180 @@
+package radius
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+)
+
+// Native MS-CHAP (RFC 2548): the NAS runs MS-CHAP with the client and sends
+// its challenge and response in Microsoft VSAs of the Access-Request.
+
+var (
+	ErrNoMSCHAP                     = errors.New("no MS-CHAP attributes")
+	ErrInvalidMSCHAPChallengeLength = errors.New("invalid MS-CHAP-Challenge length")
+	ErrInvalidMSCHAPResponseLength  = errors.New("invalid MS-CHAP response length (must be 50 bytes)")
+)
+
+// msChapResponseSize is the size of MS-CHAP-Response and MS-CHAP2-Response.
+const msChapResponseSize = 50
+
+// MSCHAPRequest is the MS-CHAP exchange carried by an Access-Request: the
+// MS-CHAP-Challenge and either MS-CHAP-Response (version 1, RFC 2433) or
+// MS-CHAP2-Response (version 2, RFC 2759).
+type MSCHAPRequest struct {
+	// Version is 1 or 2.
+	Version int
+	// Ident is the identifier of the response, echoed in the reply.
+	Ident uint8
+	// UserName is the User-Name of the request.
+	UserName   string
+	Challenge  []byte
+	NTResponse []byte
+	// PeerChallenge is the MS-CHAPv2 peer challenge.
+	PeerChallenge []byte
+}
+
+// GetMSCHAPRequest returns the MS-CHAP exchange of an Access-Request. It
+// returns ErrNoMSCHAP when the request carries no MS-CHAP response.
+func (p *Packet) GetMSCHAPRequest() (*MSCHAPRequest, error) {
+	r := &MSCHAPRequest{UserName: p.GetUsername()}
+	var challengeSize int
+	if vsa := p.GetVSA(VendorMicrosoft, AttrMSCHAP2Response); vsa != nil {
+		if len(vsa.Value) != msChapResponseSize {
+			return nil, ErrInvalidMSCHAPResponseLength
+		}
+		r.Version = 2
+		r.Ident = vsa.Value[0]
+		r.PeerChallenge = append([]byte(nil), vsa.Value[2:18]...)
+		r.NTResponse = append([]byte(nil), vsa.Value[26:50]...)
+		challengeSize = msChapV2ChallengeSize
+	} else if vsa := p.GetVSA(VendorMicrosoft, AttrMSCHAPResponse); vsa != nil {
+		if len(vsa.Value) != msChapResponseSize {
+			return nil, ErrInvalidMSCHAPResponseLength
+		}
+		// Flags bit 0 clear means only the LM-Response is valid, which is
+		// not supported.
+		if vsa.Value[1]&1 == 0 {
+			return nil, errors.New("MS-CHAP LAN Manager response not supported")
+		}
+		r.Version = 1
+		r.Ident = vsa.Value[0]
+		r.NTResponse = append([]byte(nil), vsa.Value[26:50]...)
+		challengeSize = 8
+	} else {
+		return nil, ErrNoMSCHAP
+	}
+
+	vsa := p.GetVSA(VendorMicrosoft, AttrMSCHAPChallenge)
+	if vsa == nil || len(vsa.Value) != challengeSize {
+		return nil, ErrInvalidMSCHAPChallengeLength
+	}
+	r.Challenge = append([]byte(nil), vsa.Value...)
+	return r, nil
+}
+
+// Verify compares the NT-Response with the one computed from the NT-Hash of
+// the user password, in constant time. It fails for an NT-Hash that is not
+// 16 bytes long, and with ErrEmptyPassword for the NT-Hash of the empty
+// password.
+func (r *MSCHAPRequest) Verify(ntHash []byte) (bool, error) {
+	if err := checkNTHash(ntHash); err != nil {
+		return false, err
+	}
+	if r.Version == 2 {
+		return msChapV2Verify(r.Challenge, r.PeerChallenge, r.NTResponse, r.UserName, ntHash)
+	}
+	expected, err := msChapChallengeResponse(r.Challenge, ntHash)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(expected, r.NTResponse) == 1, nil
+}
+
+// Accept adds to reply the attributes of a successful MS-CHAPv2
+// authentication: MS-CHAP2-Success with the authenticator response and the
+// MS-MPPE keys (RFC 3079). reply must be created by Packet.Reply. Nothing is
+// added for MS-CHAPv1.
+func (r *MSCHAPRequest) Accept(reply *Packet, ntHash []byte) error {
+	if r.Version != 2 {
+		return nil
+	}
+	auth := MSCHAPv2AuthenticatorResponse(ntHash, r.NTResponse, r.PeerChallenge, r.Challenge, msChapUserName(r.UserName))
+	reply.AddVSA(VSA{
+		Vendor: VendorMicrosoft,
+		Type:   AttrMSCHAP2Success,
+		Value:  append([]byte{r.Ident}, auth...),
+	})
+	masterKey := MSCHAPv2MasterKey(ntHash, r.NTResponse)
+	return reply.SetMPPEKeys(MSCHAPv2AsymmetricStartKey(masterKey, 16, true, true),
+		MSCHAPv2AsymmetricStartKey(masterKey, 16, false, true))
+}
+
+// Reject adds MS-CHAP-Error with code to reply.
+func (r *MSCHAPRequest) Reject(reply *Packet, code MsChapError) {
+	msg := fmt.Sprintf("E=%d R=0", int(code))
+	if r.Version == 2 {
+		f := &MsChapV2Failure{Error: code, Challenge: r.Challenge, Version: 3, Message: code.Error()}
+		msg = f.String()
+	}
+	reply.AddVSA(VSA{
+		Vendor: VendorMicrosoft,
+		Type:   AttrMSCHAPError,
+		Value:  append([]byte{r.Ident}, msg...),
+	})
+}
+
+// MSCHAPv1NTResponse computes the 24-byte MS-CHAPv1 NT-Response to an 8-byte
+// challenge (RFC 2433 §A.5 NtChallengeResponse).
+func MSCHAPv1NTResponse(challenge []byte, password string) ([]byte, error) {
+	if len(challenge) != 8 {
+		return nil, ErrInvalidMSCHAPChallengeLength
+	}
+	return msChapChallengeResponse(challenge, MSCHAPv2NTHash(password))
+}
+
+// SetMSCHAPv1FromSecret adds MS-CHAP-Challenge and MS-CHAP-Response for
+// password, as sent by a NAS. challenge must be 8 bytes long.
+func (p *Packet) SetMSCHAPv1FromSecret(ident uint8, password string, challenge []byte) error {
+	ntResponse, err := MSCHAPv1NTResponse(challenge, password)
+	if err != nil {
+		return err
+	}
+	value := make([]byte, msChapResponseSize)
+	value[0] = ident
+	value[1] = 1 // use the NT-Response
+	copy(value[26:], ntResponse)
+	p.setMSCHAP(challenge, AttrMSCHAPResponse, value)
+	return nil
+}
+
+// SetMSCHAPv2FromSecret adds MS-CHAP-Challenge and MS-CHAP2-Response for
+// the User-Name of the packet and password, as sent by a NAS. challenge must
+// be 16 bytes long.
+func (p *Packet) SetMSCHAPv2FromSecret(ident uint8, password string, challenge []byte) error {
+	if len(challenge) != msChapV2ChallengeSize {
+		return ErrInvalidMSCHAPChallengeLength
+	}
+	value := make([]byte, msChapResponseSize)
+	value[0] = ident
+	peerChallenge := value[2:18]
+	if _, err := rand.Read(peerChallenge); err != nil {
+		return err
+	}
+	ntResponse, err := MSCHAPv2NTResponse(challenge, peerChallenge, msChapUserName(p.GetUsername()), password)
+	if err != nil {
+		return err
+	}
+	copy(value[26:], ntResponse)
+	p.setMSCHAP(challenge, AttrMSCHAP2Response, value)
+	return nil
+}
+
+func (p *Packet) setMSCHAP(challenge []byte, attr VendorAttr, value []byte) {
+	for _, t := range []VendorAttr{AttrMSCHAPChallenge, AttrMSCHAPResponse, AttrMSCHAP2Response} {
+		p.DeleteVSAWithFormat(VendorMicrosoft, t, DefaultVendorFormat)
+	}
+	p.AddVSA(VSA{Vendor: VendorMicrosoft, Type: AttrMSCHAPChallenge, Value: append([]byte(nil), challenge...)})
+	p.AddVSA(VSA{Vendor: VendorMicrosoft, Type: attr, Value: value})
+}
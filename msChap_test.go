@@ -0,0 +1,122 @@
+package radius
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// RFC 2433 B.2
+func TestMSCHAPv1NTResponse(t *testing.T) {
+	got, err := MSCHAPv1NTResponse(mustHex(t, "102DB5DF085D3041"), "MyPw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := mustHex(t, "4E9D3C8F9CFD385D5BF4D3246791956CA4C351AB409A3D61")
+	if !bytes.Equal(got, want) {
+		t.Errorf("NTResponse:\n got  %X\n want %X", got, want)
+	}
+	if _, err := MSCHAPv1NTResponse(make([]byte, 16), "MyPw"); err != ErrInvalidMSCHAPChallengeLength {
+		t.Errorf("MSCHAPv1NTResponse() with a 16 byte challenge error = %v", err)
+	}
+}
+
+func TestMSCHAPRequest(t *testing.T) {
+	testCases := []struct {
+		version   int
+		challenge []byte
+		set       func(p *Packet, password string, challenge []byte) error
+	}{
+		{1, bytes.Repeat([]byte{1}, 8), func(p *Packet, password string, challenge []byte) error {
+			return p.SetMSCHAPv1FromSecret(7, password, challenge)
+		}},
+		{2, bytes.Repeat([]byte{2}, 16), func(p *Packet, password string, challenge []byte) error {
+			return p.SetMSCHAPv2FromSecret(7, password, challenge)
+		}},
+	}
+	ntHash := MSCHAPv2NTHash("secret")
+	for _, tc := range testCases {
+		request := Request(AccessRequest, "radius")
+		request.AddAVP(AVP{Type: AttrUserName, Value: []byte(`CORP\alice`)})
+		if err := tc.set(request, "secret", tc.challenge); err != nil {
+			t.Fatalf("v%d: set failed: %v", tc.version, err)
+		}
+		r, err := request.GetMSCHAPRequest()
+		if err != nil {
+			t.Fatalf("v%d: GetMSCHAPRequest failed: %v", tc.version, err)
+		}
+		if r.Version != tc.version || r.Ident != 7 || r.UserName != `CORP\alice` || !bytes.Equal(r.Challenge, tc.challenge) {
+			t.Errorf("v%d: GetMSCHAPRequest() = %+v", tc.version, r)
+		}
+		if ok, err := r.Verify(ntHash); !ok || err != nil {
+			t.Errorf("v%d: Verify() = %v, %v; want true", tc.version, ok, err)
+		}
+		if ok, _ := r.Verify(MSCHAPv2NTHash("wrong")); ok {
+			t.Errorf("v%d: Verify() accepted the wrong password", tc.version)
+		}
+		if ok, err := r.Verify(ntHash[:8]); ok || err == nil {
+			t.Errorf("v%d: Verify() accepted a short NT-Hash", tc.version)
+		}
+
+		reply := request.Reply()
+		if err := r.Accept(reply, ntHash); err != nil {
+			t.Fatalf("v%d: Accept failed: %v", tc.version, err)
+		}
+		if tc.version == 2 {
+			want := MSCHAPv2AuthenticatorResponse(ntHash, r.NTResponse, r.PeerChallenge, r.Challenge, "alice")
+			if vsa := reply.GetVSA(VendorMicrosoft, AttrMSCHAP2Success); vsa == nil || string(vsa.Value) != "\x07"+want {
+				t.Errorf("MS-CHAP2-Success = %v; want %q", vsa, want)
+			}
+			vsa := reply.GetVSA(VendorMicrosoft, AttrMSMPPESendKey)
+			if vsa == nil {
+				t.Fatalf("reply lacks MS-MPPE-Send-Key")
+			}
+			key, err := DecryptTunnelPassword(vsa.Value, "radius", reply.Authenticator[:])
+			want2 := MSCHAPv2AsymmetricStartKey(MSCHAPv2MasterKey(ntHash, r.NTResponse), 16, true, true)
+			if err != nil || !bytes.Equal(key, want2) {
+				t.Errorf("MS-MPPE-Send-Key = %X, %v; want %X", key, err, want2)
+			}
+		} else if len(reply.AVPs) != 0 {
+			t.Errorf("MS-CHAPv1 Accept() added %d attributes", len(reply.AVPs))
+		}
+
+		reply = request.Reply()
+		r.Reject(reply, MsChapErrorAccountDisabled)
+		vsa := reply.GetVSA(VendorMicrosoft, AttrMSCHAPError)
+		if vsa == nil || vsa.Value[0] != 7 || !strings.HasPrefix(string(vsa.Value[1:]), "E=647 R=0") {
+			t.Errorf("v%d: MS-CHAP-Error = %v", tc.version, vsa)
+		}
+	}
+}
+
+func TestGetMSCHAPRequestErrors(t *testing.T) {
+	ms := func(typ VendorAttr, value []byte) AVP {
+		return VSA{Vendor: VendorMicrosoft, Type: typ, Value: value}.ToAVP()
+	}
+	response := make([]byte, 50)
+	response[1] = 1
+	testCases := []struct {
+		name string
+		avps []AVP
+		want error
+	}{
+		{"none", nil, ErrNoMSCHAP},
+		{"short response", []AVP{ms(AttrMSCHAP2Response, make([]byte, 49))}, ErrInvalidMSCHAPResponseLength},
+		{"missing challenge", []AVP{ms(AttrMSCHAP2Response, response)}, ErrInvalidMSCHAPChallengeLength},
+		{"v1 challenge size", []AVP{ms(AttrMSCHAPChallenge, make([]byte, 16)), ms(AttrMSCHAPResponse, response)}, ErrInvalidMSCHAPChallengeLength},
+	}
+	for _, tc := range testCases {
+		p := Request(AccessRequest, "radius")
+		p.AVPs = tc.avps
+		if _, err := p.GetMSCHAPRequest(); err != tc.want {
+			t.Errorf("%s: GetMSCHAPRequest() error = %v; want %v", tc.name, err, tc.want)
+		}
+	}
+
+	p := Request(AccessRequest, "radius")
+	p.AddAVP(ms(AttrMSCHAPChallenge, make([]byte, 8)))
+	p.AddAVP(ms(AttrMSCHAPResponse, make([]byte, 50)))
+	if _, err := p.GetMSCHAPRequest(); err == nil {
+		t.Errorf("GetMSCHAPRequest() accepted an LM-only response")
+	}
+}
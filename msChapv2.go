@@ -1,12 +1,17 @@
 package radius
 
 import (
+	"bytes"
 	"crypto/des"
 	"crypto/sha1"
+	"crypto/subtle"
 	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/bits"
 	"strconv"
+	"strings"
 )
 
 // ── MSCHAPv2 crypto (RFC 2759) ────────────────────────────────────────────────
@@ -81,12 +86,44 @@ func msChapDESKey(k7 []byte) []byte {
 
 // MSCHAPv2NTHash computes NT-Hash = MD4(UTF-16LE(password)) (RFC 2759 §8.2).
 func MSCHAPv2NTHash(password string) []byte {
+	return md4(msChapUnicode(password))
+}
+
+// emptyNTHash is the NT-Hash of the empty password, known to anyone.
+var emptyNTHash = MSCHAPv2NTHash("")
+
+var errInvalidNTHash = errors.New("invalid NT-Hash length")
+
+// checkPassword fails with ErrEmptyPassword for the empty password returned
+// by lookups missing the user: anyone could answer for it.
+func checkPassword(password string) error {
+	if password == "" {
+		return ErrEmptyPassword
+	}
+	return nil
+}
+
+// checkNTHash is checkPassword for NT-Hashes, also failing for hashes that
+// are not 16 bytes long.
+func checkNTHash(ntHash []byte) error {
+	if len(ntHash) != 16 {
+		return errInvalidNTHash
+	}
+	if subtle.ConstantTimeCompare(ntHash, emptyNTHash) == 1 {
+		return ErrEmptyPassword
+	}
+	return nil
+}
+
+// msChapUnicode encodes a password as the 16-bit little-endian characters
+// hashed by MS-CHAP.
+func msChapUnicode(password string) []byte {
 	runes := []rune(password)
 	b := make([]byte, len(runes)*2)
 	for i, r := range runes {
 		binary.LittleEndian.PutUint16(b[i*2:], uint16(r))
 	}
-	return md4(b)
+	return b
 }
 
 // MSCHAPv2ChallengeHash computes SHA1(peerChallenge||authChallenge||username)[0:8]
@@ -102,10 +139,20 @@ func MSCHAPv2ChallengeHash(peerChallenge, authChallenge []byte, username string)
 // MSCHAPv2NTResponse computes the 24-byte NT-Response (RFC 2759 §8.1).
 // authChallenge is the server-generated challenge; peerChallenge is client-generated.
 func MSCHAPv2NTResponse(authChallenge, peerChallenge []byte, username, password string) ([]byte, error) {
-	challenge := MSCHAPv2ChallengeHash(peerChallenge, authChallenge, username)
-	hash := MSCHAPv2NTHash(password)
+	return MSCHAPv2NTResponseHash(authChallenge, peerChallenge, username, MSCHAPv2NTHash(password))
+}
+
+// MSCHAPv2NTResponseHash computes the NT-Response from the NT-Hash of the
+// password, for servers storing hashes rather than passwords.
+func MSCHAPv2NTResponseHash(authChallenge, peerChallenge []byte, username string, ntHash []byte) ([]byte, error) {
+	return msChapChallengeResponse(MSCHAPv2ChallengeHash(peerChallenge, authChallenge, username), ntHash)
+}
+
+// msChapChallengeResponse encrypts an 8-byte challenge with the NT-Hash
+// (RFC 2759 §8.5 ChallengeResponse).
+func msChapChallengeResponse(challenge, ntHash []byte) ([]byte, error) {
 	padded := make([]byte, 21) // NT-Hash (16 bytes) zero-padded to 21
-	copy(padded, hash)
+	copy(padded, ntHash)
 	out := make([]byte, 24)
 	for i := 0; i < 3; i++ {
 		block, err := des.NewCipher(msChapDESKey(padded[i*7 : i*7+7]))
@@ -117,6 +164,52 @@ func MSCHAPv2NTResponse(authChallenge, peerChallenge []byte, username, password
 	return out, nil
 }
 
+// MSCHAPv2AuthenticatorResponse computes the "S=" authenticator response
+// sent by the server on success (RFC 2759 §8.7).
+func MSCHAPv2AuthenticatorResponse(ntHash, ntResponse, peerChallenge, authChallenge []byte, username string) string {
+	h := sha1.New()
+	h.Write(md4(ntHash))
+	h.Write(ntResponse)
+	h.Write([]byte("Magic server to client signing constant"))
+	digest := h.Sum(nil)
+
+	h.Reset()
+	h.Write(digest)
+	h.Write(MSCHAPv2ChallengeHash(peerChallenge, authChallenge, username))
+	h.Write([]byte("Pad to make it do more than one iteration"))
+	return "S=" + strings.ToUpper(hex.EncodeToString(h.Sum(nil)))
+}
+
+// MSCHAPv2MasterKey computes the 16-byte MPPE master key (RFC 3079 §3.4
+// GetMasterKey).
+func MSCHAPv2MasterKey(ntHash, ntResponse []byte) []byte {
+	h := sha1.New()
+	h.Write(md4(ntHash))
+	h.Write(ntResponse)
+	h.Write([]byte("This is the MPPE Master Key"))
+	return h.Sum(nil)[:16]
+}
+
+// MSCHAPv2AsymmetricStartKey derives the MPPE send or receive start key of
+// the client or server from the master key (RFC 3079 §3.4
+// GetAsymmetricStartKey). keyLen is at most 20.
+func MSCHAPv2AsymmetricStartKey(masterKey []byte, keyLen int, isSend, isServer bool) []byte {
+	const (
+		magic2 = "On the client side, this is the send key; on the server side, it is the receive key."
+		magic3 = "On the client side, this is the receive key; on the server side, it is the send key."
+	)
+	magic := magic2
+	if isSend == isServer {
+		magic = magic3
+	}
+	h := sha1.New()
+	h.Write(masterKey)
+	h.Write(make([]byte, 40))
+	h.Write([]byte(magic))
+	h.Write(bytes.Repeat([]byte{0xf2}, 40))
+	return h.Sum(nil)[:keyLen]
+}
+
 type MsChapV2Packet struct {
 	Eap    *EapPacket //The eap information when decrypting, does not use the data inside
 	OpCode MsChapV2OpCode
@@ -125,6 +218,10 @@ type MsChapV2Packet struct {
 
 func (p *MsChapV2Packet) ToEap() *EapPacket {
 	eap := p.Eap.Copy()
+	if p.isShortResponse() {
+		eap.Data = []byte{byte(p.OpCode)}
+		return eap
+	}
 	eap.Data = make([]byte, len(p.Data)+4)
 	eap.Data[0] = byte(p.OpCode)
 	eap.Data[1] = byte(eap.Identifier)
@@ -137,14 +234,26 @@ func MsChapV2PacketFromEap(eap *EapPacket) (p *MsChapV2Packet, err error) {
 	p = &MsChapV2Packet{
 		Eap: eap,
 	}
-	if len(eap.Data) < 4 {
+	if len(eap.Data) < 1 {
 		return nil, fmt.Errorf("[MsChapV2PacketFromEap] protocol error 1, packet too small")
 	}
 	p.OpCode = MsChapV2OpCode(eap.Data[0])
+	if p.isShortResponse() {
+		return p, nil
+	}
+	if len(eap.Data) < 4 {
+		return nil, fmt.Errorf("[MsChapV2PacketFromEap] protocol error 1, packet too small")
+	}
 	p.Data = append([]byte(nil), eap.Data[4:]...)
 	return p, nil
 }
 
+// isShortResponse reports whether p is a Success-Response or
+// Failure-Response, which consist of the OpCode alone.
+func (p *MsChapV2Packet) isShortResponse() bool {
+	return p.Eap.Code == EapCodeResponse && (p.OpCode == MsChapV2OpCodeSuccess || p.OpCode == MsChapV2OpCodeFailure)
+}
+
 //Does not include eap information
 func (p *MsChapV2Packet) String() string {
 	return fmt.Sprintf("OpCode:%s Data:[%#v]", p.OpCode, p.Data)
@@ -176,3 +285,104 @@ func (c MsChapV2OpCode) String() string {
 		return "unknow MsChapV2OpCode " + strconv.Itoa(int(c))
 	}
 }
+
+// MsChapError is an MS-CHAP failure code, sent as "E=" in Failure packets
+// (RFC 2433 §6, RFC 2759 §6). It implements error, so credential lookups can
+// return it to reject a user with a specific code.
+type MsChapError int
+
+const (
+	MsChapErrorRestrictedLogonHours  MsChapError = 646
+	MsChapErrorAccountDisabled       MsChapError = 647
+	MsChapErrorPasswordExpired       MsChapError = 648
+	MsChapErrorNoDialinPermission    MsChapError = 649
+	MsChapErrorAuthenticationFailure MsChapError = 691
+	MsChapErrorChangingPassword      MsChapError = 709
+)
+
+func (e MsChapError) Error() string {
+	switch e {
+	case MsChapErrorRestrictedLogonHours:
+		return "restricted logon hours"
+	case MsChapErrorAccountDisabled:
+		return "account disabled"
+	case MsChapErrorPasswordExpired:
+		return "password expired"
+	case MsChapErrorNoDialinPermission:
+		return "no dialin permission"
+	case MsChapErrorAuthenticationFailure:
+		return "authentication failure"
+	case MsChapErrorChangingPassword:
+		return "changing password"
+	default:
+		return "MS-CHAP error " + strconv.Itoa(int(e))
+	}
+}
+
+// msChapUserName strips the domain of a "DOMAIN\user" name, as the
+// challenge hash is computed over the user name alone (RFC 2759 §8.2).
+func msChapUserName(name string) string {
+	if i := strings.LastIndexByte(name, '\\'); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// MsChapV2Failure is the message of an MS-CHAPv2 Failure packet (RFC 2759
+// §6): "E=eeeeeeeeee R=r C=cccccccccccccccccccccccccccccccc V=vvvvvvvvvv M=<msg>".
+type MsChapV2Failure struct {
+	Error MsChapError
+	// Retry is set when the peer may retry with a new Response.
+	Retry bool
+	// Challenge is the new authenticator challenge, used by a retry or a
+	// Change-Password.
+	Challenge []byte
+	Version   int
+	Message   string
+}
+
+func (f *MsChapV2Failure) String() string {
+	retry := 0
+	if f.Retry {
+		retry = 1
+	}
+	return fmt.Sprintf("E=%d R=%d C=%X V=%d M=%s", int(f.Error), retry, f.Challenge, f.Version, f.Message)
+}
+
+// ParseMsChapV2Failure parses the message of a Failure packet.
+func ParseMsChapV2Failure(msg string) (*MsChapV2Failure, error) {
+	f := &MsChapV2Failure{}
+	for msg != "" {
+		var field string
+		if strings.HasPrefix(msg, "M=") {
+			field, msg = msg, ""
+		} else if i := strings.IndexByte(msg, ' '); i >= 0 {
+			field, msg = msg[:i], strings.TrimLeft(msg[i:], " ")
+		} else {
+			field, msg = msg, ""
+		}
+		if len(field) < 2 || field[1] != '=' {
+			return nil, fmt.Errorf("invalid MS-CHAPv2 failure field %q", field)
+		}
+		value := field[2:]
+		var err error
+		switch field[0] {
+		case 'E':
+			var code int
+			code, err = strconv.Atoi(value)
+			f.Error = MsChapError(code)
+		case 'R':
+			f.Retry = value == "1"
+		case 'C':
+			f.Challenge, err = hex.DecodeString(value)
+		case 'V':
+			f.Version, err = strconv.Atoi(value)
+		case 'M':
+			f.Message = value
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid MS-CHAPv2 failure field %q: %v", field, err)
+		}
+	}
+	return f, nil
+}
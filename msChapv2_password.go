@@ -0,0 +1,170 @@
+package radius
+
+import (
+	"crypto/des"
+	"crypto/rand"
+	"crypto/rc4"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+)
+
+// msChapPwBlockSize is the size of the encrypted new password: 256 unicode
+// characters and their length (RFC 2759 §8.10).
+const msChapPwBlockSize = 516
+
+// msChapV2ChangePasswordSize is the size of the Change-Password data
+// following the MS-CHAPv2 header: Encrypted-Password, Encrypted-Hash,
+// Peer-Challenge, Reserved, NT-Response and Flags.
+const msChapV2ChangePasswordSize = msChapPwBlockSize + 16 + 16 + 8 + 24 + 2
+
+// MSCHAPv2NewPasswordEncrypted encrypts a new password with the NT-Hash of
+// the old one (RFC 2759 §8.9 NewPasswordEncryptedWithOldNtPasswordHash).
+func MSCHAPv2NewPasswordEncrypted(newPassword string, oldNTHash []byte) ([]byte, error) {
+	pw := msChapUnicode(newPassword)
+	if len(pw) > msChapPwBlockSize-4 {
+		return nil, errors.New("MS-CHAP password too long")
+	}
+	block := make([]byte, msChapPwBlockSize)
+	if _, err := rand.Read(block[:msChapPwBlockSize-4-len(pw)]); err != nil {
+		return nil, err
+	}
+	copy(block[msChapPwBlockSize-4-len(pw):], pw)
+	binary.LittleEndian.PutUint32(block[msChapPwBlockSize-4:], uint32(len(pw)))
+
+	c, err := rc4.NewCipher(oldNTHash)
+	if err != nil {
+		return nil, err
+	}
+	c.XORKeyStream(block, block)
+	return block, nil
+}
+
+// MSCHAPv2DecryptNewPassword decrypts a password encrypted by
+// MSCHAPv2NewPasswordEncrypted.
+func MSCHAPv2DecryptNewPassword(encrypted, oldNTHash []byte) (string, error) {
+	if len(encrypted) != msChapPwBlockSize {
+		return "", fmt.Errorf("invalid MS-CHAP password block length %d", len(encrypted))
+	}
+	c, err := rc4.NewCipher(oldNTHash)
+	if err != nil {
+		return "", err
+	}
+	block := make([]byte, msChapPwBlockSize)
+	c.XORKeyStream(block, encrypted)
+
+	n := binary.LittleEndian.Uint32(block[msChapPwBlockSize-4:])
+	if n > msChapPwBlockSize-4 || n%2 != 0 {
+		return "", errors.New("invalid MS-CHAP password block")
+	}
+	pw := block[msChapPwBlockSize-4-int(n) : msChapPwBlockSize-4]
+	chars := make([]uint16, len(pw)/2)
+	for i := range chars {
+		chars[i] = binary.LittleEndian.Uint16(pw[i*2:])
+	}
+	return string(utf16.Decode(chars)), nil
+}
+
+// MSCHAPv2OldHashEncrypted encrypts the old NT-Hash with the new one
+// (RFC 2759 §8.12 OldNtPasswordHashEncryptedWithNewNtPasswordHash), proving
+// the knowledge of the old password.
+func MSCHAPv2OldHashEncrypted(newNTHash, oldNTHash []byte) ([]byte, error) {
+	out := make([]byte, 16)
+	for i := 0; i < 2; i++ {
+		block, err := des.NewCipher(msChapDESKey(newNTHash[i*7 : i*7+7]))
+		if err != nil {
+			return nil, err
+		}
+		block.Encrypt(out[i*8:], oldNTHash[i*8:])
+	}
+	return out, nil
+}
+
+// NewMsChapV2ChangePassword builds the Change-Password response to a
+// Failure request reporting an expired password (E=648), as sent by a peer.
+func NewMsChapV2ChangePassword(failure *EapPacket, username, oldPassword, newPassword string) (*MsChapV2Packet, error) {
+	p, err := MsChapV2PacketFromEap(failure)
+	if err != nil {
+		return nil, err
+	}
+	if p.OpCode != MsChapV2OpCodeFailure {
+		return nil, fmt.Errorf("MS-CHAPv2 %s is not a Failure", p.OpCode)
+	}
+	f, err := ParseMsChapV2Failure(string(p.Data))
+	if err != nil {
+		return nil, err
+	}
+	if len(f.Challenge) != msChapV2ChallengeSize {
+		return nil, errors.New("MS-CHAPv2 Failure without challenge")
+	}
+
+	oldHash := MSCHAPv2NTHash(oldPassword)
+	newHash := MSCHAPv2NTHash(newPassword)
+	data := make([]byte, msChapV2ChangePasswordSize)
+	encrypted, err := MSCHAPv2NewPasswordEncrypted(newPassword, oldHash)
+	if err != nil {
+		return nil, err
+	}
+	copy(data, encrypted)
+	encryptedHash, err := MSCHAPv2OldHashEncrypted(newHash, oldHash)
+	if err != nil {
+		return nil, err
+	}
+	copy(data[msChapPwBlockSize:], encryptedHash)
+	peerChallenge := data[msChapPwBlockSize+16 : msChapPwBlockSize+32]
+	if _, err := rand.Read(peerChallenge); err != nil {
+		return nil, err
+	}
+	ntResponse, err := MSCHAPv2NTResponseHash(f.Challenge, peerChallenge, msChapUserName(username), newHash)
+	if err != nil {
+		return nil, err
+	}
+	copy(data[msChapPwBlockSize+40:], ntResponse)
+
+	return &MsChapV2Packet{
+		Eap:    &EapPacket{Code: EapCodeResponse, Identifier: failure.Identifier, Type: EapTypeMSCHAPV2},
+		OpCode: MsChapV2OpCodeChangePassword,
+		Data:   data,
+	}, nil
+}
+
+// msChapV2ChangePassword holds the fields of a Change-Password packet
+// (RFC 2759 §7).
+type msChapV2ChangePassword struct {
+	encryptedPassword []byte
+	encryptedHash     []byte
+	peerChallenge     []byte
+	ntResponse        []byte
+}
+
+func parseMsChapV2ChangePassword(data []byte) (*msChapV2ChangePassword, error) {
+	if len(data) != msChapV2ChangePasswordSize {
+		return nil, fmt.Errorf("invalid MS-CHAPv2 Change-Password length %d", len(data))
+	}
+	return &msChapV2ChangePassword{
+		encryptedPassword: data[:msChapPwBlockSize],
+		encryptedHash:     data[msChapPwBlockSize : msChapPwBlockSize+16],
+		peerChallenge:     data[msChapPwBlockSize+16 : msChapPwBlockSize+32],
+		ntResponse:        data[msChapPwBlockSize+40 : msChapPwBlockSize+64],
+	}, nil
+}
+
+// newPassword decrypts the new password and checks that the peer knows the
+// old one. It returns the password and its NT-Hash.
+func (cp *msChapV2ChangePassword) newPassword(oldNTHash []byte) (string, []byte, error) {
+	password, err := MSCHAPv2DecryptNewPassword(cp.encryptedPassword, oldNTHash)
+	if err != nil {
+		return "", nil, err
+	}
+	newHash := MSCHAPv2NTHash(password)
+	expected, err := MSCHAPv2OldHashEncrypted(newHash, oldNTHash)
+	if err != nil {
+		return "", nil, err
+	}
+	if subtle.ConstantTimeCompare(expected, cp.encryptedHash) != 1 {
+		return "", nil, errors.New("MS-CHAP encrypted hash mismatch")
+	}
+	return password, newHash, nil
+}
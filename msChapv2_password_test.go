@@ -0,0 +1,85 @@
+package radius
+
+import (
+	"bytes"
+	"crypto/des"
+	"strings"
+	"testing"
+)
+
+func TestMSCHAPv2NewPasswordEncrypted(t *testing.T) {
+	oldHash := MSCHAPv2NTHash("old")
+	for _, password := range []string{"", "new-password", "Pässwörd€", strings.Repeat("x", 256)} {
+		encrypted, err := MSCHAPv2NewPasswordEncrypted(password, oldHash)
+		if err != nil {
+			t.Fatalf("MSCHAPv2NewPasswordEncrypted(%q) failed: %v", password, err)
+		}
+		if len(encrypted) != 516 {
+			t.Errorf("encrypted length = %d; want 516", len(encrypted))
+		}
+		got, err := MSCHAPv2DecryptNewPassword(encrypted, oldHash)
+		if err != nil || got != password {
+			t.Errorf("MSCHAPv2DecryptNewPassword() = %q, %v; want %q", got, err, password)
+		}
+	}
+
+	if _, err := MSCHAPv2NewPasswordEncrypted(strings.Repeat("x", 257), oldHash); err == nil {
+		t.Errorf("MSCHAPv2NewPasswordEncrypted() accepted a 257 character password")
+	}
+	if _, err := MSCHAPv2DecryptNewPassword(make([]byte, 515), oldHash); err == nil {
+		t.Errorf("MSCHAPv2DecryptNewPassword() accepted a short block")
+	}
+}
+
+func TestMSCHAPv2OldHashEncrypted(t *testing.T) {
+	oldHash := MSCHAPv2NTHash("old")
+	newHash := MSCHAPv2NTHash("new")
+	encrypted, err := MSCHAPv2OldHashEncrypted(newHash, oldHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// each half of the old hash is DES encrypted with 7 bytes of the new hash
+	got := make([]byte, 16)
+	for i := 0; i < 2; i++ {
+		block, _ := des.NewCipher(msChapDESKey(newHash[i*7 : i*7+7]))
+		block.Decrypt(got[i*8:], encrypted[i*8:])
+	}
+	if !bytes.Equal(got, oldHash) {
+		t.Errorf("decrypted hash = %X; want %X", got, oldHash)
+	}
+}
+
+func TestNewMsChapV2ChangePassword(t *testing.T) {
+	f := &MsChapV2Failure{Error: MsChapErrorPasswordExpired, Challenge: bytes.Repeat([]byte{7}, 16), Version: 3, Message: "expired"}
+	failure := (&MsChapV2Packet{
+		Eap:    &EapPacket{Code: EapCodeRequest, Identifier: 9, Type: EapTypeMSCHAPV2},
+		OpCode: MsChapV2OpCodeFailure,
+		Data:   []byte(f.String()),
+	}).ToEap()
+
+	p, err := NewMsChapV2ChangePassword(failure, `CORP\bob`, "old", "new")
+	if err != nil {
+		t.Fatalf("NewMsChapV2ChangePassword failed: %v", err)
+	}
+	if p.OpCode != MsChapV2OpCodeChangePassword || p.Eap.Code != EapCodeResponse || p.Eap.Identifier != 9 {
+		t.Errorf("NewMsChapV2ChangePassword() = %v", p)
+	}
+	cp, err := parseMsChapV2ChangePassword(p.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	password, newHash, err := cp.newPassword(MSCHAPv2NTHash("old"))
+	if err != nil || password != "new" {
+		t.Fatalf("newPassword() = %q, %v", password, err)
+	}
+	if ok, err := msChapV2Verify(f.Challenge, cp.peerChallenge, cp.ntResponse, `CORP\bob`, newHash); !ok || err != nil {
+		t.Errorf("NT-Response does not match the new password")
+	}
+	if _, _, err := cp.newPassword(MSCHAPv2NTHash("other")); err == nil {
+		t.Errorf("newPassword() accepted the wrong old password")
+	}
+
+	if _, err := NewMsChapV2ChangePassword(&EapPacket{Code: EapCodeRequest, Type: EapTypeMSCHAPV2, Data: []byte{3, 9, 0, 4}}, "bob", "old", "new"); err == nil {
+		t.Errorf("NewMsChapV2ChangePassword() accepted a Success request")
+	}
+}
@@ -21,6 +21,7 @@ package radius
 import (
 	"bytes"
 	"encoding/hex"
+	"reflect"
 	"testing"
 )
 
@@ -51,6 +52,30 @@ func TestMSCHAPv2NTHashEmpty(t *testing.T) {
 	}
 }
 
+func TestCheckNTHash(t *testing.T) {
+	if err := checkPassword(""); err != ErrEmptyPassword {
+		t.Errorf("checkPassword(\"\") = %v; want ErrEmptyPassword", err)
+	}
+	if err := checkPassword("secret"); err != nil {
+		t.Errorf("checkPassword(secret) = %v", err)
+	}
+	testCases := []struct {
+		name   string
+		ntHash []byte
+		want   error
+	}{
+		{"valid", MSCHAPv2NTHash("secret"), nil},
+		{"empty password", MSCHAPv2NTHash(""), ErrEmptyPassword},
+		{"missing", nil, errInvalidNTHash},
+		{"short", make([]byte, 15), errInvalidNTHash},
+	}
+	for _, tc := range testCases {
+		if err := checkNTHash(tc.ntHash); err != tc.want {
+			t.Errorf("%s: checkNTHash() = %v; want %v", tc.name, err, tc.want)
+		}
+	}
+}
+
 // RFC 2759 A.3
 func TestMSCHAPv2ChallengeHash(t *testing.T) {
 	peer := mustHex(t, "21402324255E262A28295F2B3A337C7E")
@@ -118,3 +143,69 @@ func TestMSCHAPv2NTResponseDeterministic(t *testing.T) {
 		t.Error("NT-Response is not deterministic")
 	}
 }
+
+// RFC 2759 A.7
+func TestMSCHAPv2AuthenticatorResponse(t *testing.T) {
+	auth := mustHex(t, "5B5D7C7D7B3F2F3E3C2C602132262628")
+	peer := mustHex(t, "21402324255E262A28295F2B3A337C7E")
+	ntResponse := mustHex(t, "82309ECD8D708B5EA08FAA3981CD83544233114A3D85D6DF")
+	got := MSCHAPv2AuthenticatorResponse(MSCHAPv2NTHash("clientPass"), ntResponse, peer, auth, "User")
+	if want := "S=407A5589115FD0D6209F510FE9C04566932CDA56"; got != want {
+		t.Errorf("AuthenticatorResponse:\n got  %s\n want %s", got, want)
+	}
+}
+
+// RFC 3079 §3.5.3
+func TestMSCHAPv2MasterKey(t *testing.T) {
+	ntResponse := mustHex(t, "82309ECD8D708B5EA08FAA3981CD83544233114A3D85D6DF")
+	masterKey := MSCHAPv2MasterKey(MSCHAPv2NTHash("clientPass"), ntResponse)
+	if want := mustHex(t, "FDECE3717A8C838CB388E527AE3CDD31"); !bytes.Equal(masterKey, want) {
+		t.Errorf("MasterKey:\n got  %X\n want %X", masterKey, want)
+	}
+	send := MSCHAPv2AsymmetricStartKey(masterKey, 16, true, true)
+	if want := mustHex(t, "8B7CDC149B993A1BA118CB153F56DCCB"); !bytes.Equal(send, want) {
+		t.Errorf("SendStartKey:\n got  %X\n want %X", send, want)
+	}
+	// the server send key is the client receive key
+	if recv := MSCHAPv2AsymmetricStartKey(masterKey, 16, false, false); !bytes.Equal(recv, send) {
+		t.Errorf("client receive key %X differs from server send key %X", recv, send)
+	}
+}
+
+func TestMsChapV2PacketShortResponse(t *testing.T) {
+	p := &MsChapV2Packet{Eap: &EapPacket{Code: EapCodeResponse, Identifier: 5, Type: EapTypeMSCHAPV2}, OpCode: MsChapV2OpCodeSuccess}
+	eap := p.ToEap()
+	if !bytes.Equal(eap.Data, []byte{byte(MsChapV2OpCodeSuccess)}) {
+		t.Fatalf("ToEap().Data = %x; want 03", eap.Data)
+	}
+	got, err := MsChapV2PacketFromEap(eap)
+	if err != nil || got.OpCode != MsChapV2OpCodeSuccess {
+		t.Errorf("MsChapV2PacketFromEap() = %v, %v", got, err)
+	}
+}
+
+func TestParseMsChapV2Failure(t *testing.T) {
+	msg := "E=648 R=0 C=00112233445566778899AABBCCDDEEFF V=3 M=Password expired, change it"
+	f, err := ParseMsChapV2Failure(msg)
+	if err != nil {
+		t.Fatalf("ParseMsChapV2Failure failed: %v", err)
+	}
+	want := &MsChapV2Failure{
+		Error:     MsChapErrorPasswordExpired,
+		Challenge: mustHex(t, "00112233445566778899AABBCCDDEEFF"),
+		Version:   3,
+		Message:   "Password expired, change it",
+	}
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("ParseMsChapV2Failure() = %+v; want %+v", f, want)
+	}
+	if got := f.String(); got != msg {
+		t.Errorf("String() = %q; want %q", got, msg)
+	}
+
+	for _, bad := range []string{"E=abc", "C=zz", "garbage"} {
+		if _, err := ParseMsChapV2Failure(bad); err == nil {
+			t.Errorf("ParseMsChapV2Failure(%q) succeeded", bad)
+		}
+	}
+}
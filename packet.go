@@ -286,6 +286,20 @@ func (p *Packet) EachAVP(fn func(a AVP) bool) {
 	}
 }
 
+// GetConcatenatedAVP returns the values of all attributes of the given type
+// joined in order, as used for attributes flagged "concat" whose value is
+// split over several consecutive AVPs. It returns nil if none are present.
+func (p *Packet) GetConcatenatedAVP(attrType AttributeType) []byte {
+	var buf []byte
+	p.EachAVP(func(a AVP) bool {
+		if a.Type == attrType {
+			buf = append(buf, a.Value...)
+		}
+		return true
+	})
+	return buf
+}
+
 // SetAVP removes all attributes of the same type and then adds avp.
 func (p *Packet) SetAVP(avp AVP) {
 	p.DeleteOneType(avp.Type)
@@ -298,8 +312,43 @@ func (p *Packet) AddAVP(avp AVP) {
 }
 
 // AddVSA adds a Vendor-Specific Attribute (VSA) to the packet.
+//
+// For vendors with a continuation byte (WiMAX) long values are split over
+// several Vendor-Specific attributes.
 func (p *Packet) AddVSA(vsa VSA) {
-	p.AddAVP(vsa.ToAVP())
+	for _, avp := range vsa.ToAVPs() {
+		p.AddAVP(avp)
+	}
+}
+
+// GetVSA returns the first vendor-specific attribute with the given vendor and
+// type, or nil if not present. WiMAX-style continued fragments are reassembled.
+//
+// The vendor layout is taken from the default dictionary.
+func (p *Packet) GetVSA(vendorID VendorID, attrID VendorAttr) *VSA {
+	return p.GetVSAWithFormat(vendorID, attrID, vendorFormat(vendorID))
+}
+
+// GetVSAWithFormat is like GetVSA for a vendor using the VSA layout f.
+func (p *Packet) GetVSAWithFormat(vendorID VendorID, attrID VendorAttr, f VendorFormat) *VSA {
+	var out *VSA
+	p.EachAVP(func(a AVP) bool {
+		if a.Type != AttrVendorSpecific || len(a.Value) < 4 ||
+			VendorID(binary.BigEndian.Uint32(a.Value[0:4])) != vendorID {
+			return true
+		}
+		vsa, more := decodeVSA(a, f)
+		if vsa.Type != attrID {
+			return true
+		}
+		if out == nil {
+			out = vsa
+		} else {
+			out.Value = append(out.Value, vsa.Value...)
+		}
+		return more
+	})
+	return out
 }
 
 // DeleteAVP removes the specific AVP instance from the packet, if present.
@@ -329,6 +378,89 @@ func (p *Packet) DeleteOneType(attrType AttributeType) {
 	}
 }
 
+// DeleteAllType removes all attributes with the given type from the packet.
+func (p *Packet) DeleteAllType(attrType AttributeType) {
+	n := 0
+	for _, avp := range p.AVPs {
+		if avp.Type != attrType {
+			p.AVPs[n] = avp
+			n++
+		}
+	}
+	p.AVPs = p.AVPs[:n]
+}
+
+// DeleteVSA removes all Vendor-Specific attributes carrying the given vendor
+// attribute, including continued fragments.
+//
+// The vendor layout is taken from the default dictionary.
+func (p *Packet) DeleteVSA(vendorID VendorID, attrID VendorAttr) {
+	p.DeleteVSAWithFormat(vendorID, attrID, vendorFormat(vendorID))
+}
+
+// DeleteVSAWithFormat is like DeleteVSA for a vendor using the VSA layout f.
+func (p *Packet) DeleteVSAWithFormat(vendorID VendorID, attrID VendorAttr, f VendorFormat) {
+	n := 0
+	for _, avp := range p.AVPs {
+		if avp.Type == AttrVendorSpecific && len(avp.Value) >= 4 &&
+			VendorID(binary.BigEndian.Uint32(avp.Value[0:4])) == vendorID {
+			if vsa, _ := decodeVSA(avp, f); vsa.Type == attrID {
+				continue
+			}
+		}
+		p.AVPs[n] = avp
+		n++
+	}
+	p.AVPs = p.AVPs[:n]
+}
+
+// AddExtendedAttr adds an RFC 6929 extended attribute to the packet. Long
+// values of long extended attributes are split over several attributes.
+func (p *Packet) AddExtendedAttr(e ExtendedAttr) {
+	for _, avp := range e.ToAVPs() {
+		p.AddAVP(avp)
+	}
+}
+
+// GetExtendedAttr returns the first extended attribute with the given
+// Extended-Attribute and Extended-Type, or nil if not present. The fragments
+// of long extended attributes are reassembled.
+func (p *Packet) GetExtendedAttr(attrType AttributeType, extType uint8) *ExtendedAttr {
+	var out *ExtendedAttr
+	p.EachAVP(func(a AVP) bool {
+		if a.Type != attrType {
+			return true
+		}
+		e, more := decodeExtended(a)
+		if e == nil || e.ExtType != extType {
+			return true
+		}
+		if out == nil {
+			out = e
+		} else {
+			out.Value = append(out.Value, e.Value...)
+		}
+		return more
+	})
+	return out
+}
+
+// DeleteExtendedAttr removes all attributes carrying the given extended
+// attribute, including the fragments of long extended attributes.
+func (p *Packet) DeleteExtendedAttr(attrType AttributeType, extType uint8) {
+	n := 0
+	for _, avp := range p.AVPs {
+		if avp.Type == attrType {
+			if e, _ := decodeExtended(avp); e != nil && e.ExtType == extType {
+				continue
+			}
+		}
+		p.AVPs[n] = avp
+		n++
+	}
+	p.AVPs = p.AVPs[:n]
+}
+
 // Request constructs a new request packet with a random Identifier.
 //
 // For Access-Request packets, a new request Authenticator is also generated and
@@ -0,0 +1,292 @@
+package radius
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// packetJSON is the JSON form of a Packet.
+type packetJSON struct {
+	Code          string    `json:"code"`
+	Identifier    uint8     `json:"identifier"`
+	Authenticator string    `json:"authenticator"`
+	Client        string    `json:"client,omitempty"`
+	Attributes    []avpJSON `json:"attributes"`
+}
+
+// avpJSON is the JSON form of an attribute. Value holds the typed value
+// (a number for integer types, a string otherwise); Raw holds the value
+// in hex when it has no text form that encodes back to the same bytes.
+type avpJSON struct {
+	Name     string          `json:"name,omitempty"`
+	Type     uint32          `json:"type"`
+	Vendor   string          `json:"vendor,omitempty"`
+	VendorID uint32          `json:"vendor_id,omitempty"`
+	Extended uint8           `json:"extended,omitempty"`
+	Tag      uint8           `json:"tag,omitempty"`
+	Value    json.RawMessage `json:"value,omitempty"`
+	Raw      string          `json:"raw,omitempty"`
+}
+
+// MarshalPacketJSON encodes the code, identifier, authenticator, client
+// address and attributes of p as JSON, like
+//
+//	{
+//	  "code": "AccessRequest",
+//	  "identifier": 1,
+//	  "authenticator": "00112233445566778899aabbccddeeff",
+//	  "attributes": [
+//	    {"name": "User-Name", "type": 1, "value": "alice"},
+//	    {"name": "NAS-Port", "type": 5, "value": 7},
+//	    {"name": "Tunnel-Type", "type": 64, "tag": 1, "value": "L2TP"},
+//	    {"name": "Cisco-AVPair", "type": 1, "vendor": "Cisco", "vendor_id": 9, "value": "shell:priv-lvl=15"},
+//	    {"name": "Operator-NAS-Identifier", "type": 8, "extended": 241, "raw": "0102"},
+//	    {"name": "User-Password", "type": 2, "raw": "8a6c0fd1..."}
+//	  ]
+//	}
+//
+// Enumerated values use VALUE names. Attributes keep their order, one entry
+// per attribute. Values without a text form encoding back to the same bytes
+// (octets, encrypted values, unknown attributes) are kept in hex as "raw",
+// so UnmarshalPacketJSON restores the packet exactly. The Secret is not
+// included.
+func (d *Dictionary) MarshalPacketJSON(p *Packet) ([]byte, error) {
+	out := packetJSON{
+		Code:          packetCodeText(p.Code),
+		Identifier:    p.Identifier,
+		Authenticator: hex.EncodeToString(p.Authenticator[:]),
+		Client:        p.ClientAddr,
+		Attributes:    []avpJSON{},
+	}
+	p.EachAVP(func(a AVP) bool {
+		out.Attributes = append(out.Attributes, d.avpToJSON(a))
+		return true
+	})
+	return json.Marshal(out)
+}
+
+// UnmarshalPacketJSON builds a packet from the JSON produced by
+// MarshalPacketJSON. Attributes are identified by name, falling back to
+// "type" with "vendor_id" or "extended", and values are encoded as by ParseAVP.
+func (d *Dictionary) UnmarshalPacketJSON(b []byte) (*Packet, error) {
+	var in packetJSON
+	if err := json.Unmarshal(b, &in); err != nil {
+		return nil, err
+	}
+	code, err := parsePacketCode(in.Code)
+	if err != nil {
+		return nil, err
+	}
+	p := &Packet{Code: code, Identifier: in.Identifier, ClientAddr: in.Client}
+	auth, err := hex.DecodeString(in.Authenticator)
+	if err != nil || (len(auth) != 0 && len(auth) != len(p.Authenticator)) {
+		return nil, fmt.Errorf("invalid authenticator %s", in.Authenticator)
+	}
+	copy(p.Authenticator[:], auth)
+
+	for i, a := range in.Attributes {
+		decoded, err := d.avpFromJSON(a)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %d: %w", i, err)
+		}
+		p.AVPs = append(p.AVPs, decoded...)
+	}
+	return p, nil
+}
+
+// packetCodeText returns the name of a packet code, or its number when the
+// code is unknown.
+func packetCodeText(code PacketCode) string {
+	if name, ok := packetCodeName[code]; ok {
+		return name
+	}
+	return strconv.Itoa(int(code))
+}
+
+// parsePacketCode reverses packetCodeText.
+func parsePacketCode(s string) (PacketCode, error) {
+	for code, name := range packetCodeName {
+		if name == s {
+			return code, nil
+		}
+	}
+	n, err := strconv.ParseUint(s, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid packet code %s", s)
+	}
+	return PacketCode(n), nil
+}
+
+func (d *Dictionary) avpToJSON(a AVP) avpJSON {
+	out := avpJSON{Type: uint32(a.Type)}
+	value := a.Value
+
+	var def AttributeDef
+	var ok bool
+	if a.Type == AttrVendorSpecific && len(a.Value) >= 4 {
+		vendorID := VendorID(binary.BigEndian.Uint32(a.Value[0:4]))
+		f := d.GetVendorFormat(vendorID)
+		vsa, _ := decodeVSA(a, f)
+		// continued (WiMAX) fragments and malformed VSAs stay raw
+		avps := vsa.ToAVPsWithFormat(f)
+		if len(avps) != 1 || !bytes.Equal(avps[0].Value, a.Value) {
+			out.Raw = hex.EncodeToString(a.Value)
+			return out
+		}
+		out.Type = uint32(vsa.Type)
+		out.Vendor = d.GetVendorName(vendorID)
+		out.VendorID = uint32(vendorID)
+		value = vsa.Value
+		def, ok = d.GetVSAAttributeDefByID(vendorID, vsa.Type)
+	} else if IsExtendedType(a.Type) {
+		e, _ := decodeExtended(a)
+		// long extended fragments and malformed attributes stay raw
+		var avps []AVP
+		if e != nil {
+			avps = e.ToAVPs()
+		}
+		if len(avps) != 1 || !bytes.Equal(avps[0].Value, a.Value) {
+			out.Raw = hex.EncodeToString(a.Value)
+			return out
+		}
+		out.Type = uint32(e.ExtType)
+		out.Extended = uint8(e.Type)
+		value = e.Value
+		def, ok = d.GetExtendedAttributeDefByID(e.Type, e.ExtType)
+	} else {
+		def, ok = d.GetAttributeDefByID(a.Type)
+	}
+	if ok {
+		out.Name = def.Name
+		out.Tag, out.Value, ok = d.jsonValue(def, value)
+	}
+	if !ok {
+		out.Raw = hex.EncodeToString(value)
+	}
+	return out
+}
+
+// jsonValue returns the tag and typed JSON value of an attribute, failing
+// when the text form does not encode back to the same bytes.
+func (d *Dictionary) jsonValue(def AttributeDef, b []byte) (uint8, json.RawMessage, bool) {
+	if def.Type == "password" || def.Flags.Encrypt != EncryptMethodNone || def.handler() == nil {
+		return 0, nil, false
+	}
+	tag, _, err := def.DecodeBytes(nil, nil, b)
+	if err != nil {
+		return 0, nil, false
+	}
+
+	text := formatValue(d, nil, def, AVP{Type: AttributeType(def.ID), Value: b})
+	d.RLock()
+	values := d.valueIDs(def.Vendor, def.Name)
+	d.RUnlock()
+	encoded, err := def.encodeValue(nil, tag, text, values)
+	if err != nil || !bytes.Equal(encoded, b) || !utf8.ValidString(text) {
+		return 0, nil, false
+	}
+
+	if isNumericType(def.Type) && !def.Flags.Array {
+		if _, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return tag, json.RawMessage(text), true
+		}
+		if _, err := strconv.ParseUint(text, 10, 64); err == nil {
+			return tag, json.RawMessage(text), true
+		}
+	}
+	value, err := json.Marshal(text)
+	if err != nil {
+		return 0, nil, false
+	}
+	return tag, value, true
+}
+
+// isNumericType reports whether values of a data type are integers.
+func isNumericType(typeName string) bool {
+	switch typeName {
+	case "integer", "byte", "short", "integer64", "signed":
+		return true
+	}
+	return false
+}
+
+func (d *Dictionary) avpFromJSON(a avpJSON) ([]AVP, error) {
+	vendorID := VendorID(a.VendorID)
+	if a.Vendor != "" {
+		if id := d.GetVendorID(a.Vendor); id != 0 {
+			vendorID = id
+		} else if vendorID == 0 {
+			return nil, fmt.Errorf("vendor %s not found in dictionary", a.Vendor)
+		}
+	}
+
+	if a.Value == nil {
+		b, err := hex.DecodeString(a.Raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid raw value %s", a.Raw)
+		}
+		return jsonAVPs(d, vendorID, AttributeType(a.Extended), a.Type, b)
+	}
+
+	var def AttributeDef
+	var ok bool
+	switch {
+	case vendorID > 0 && a.Name != "":
+		def, ok = d.GetVSAAttributeDef(vendorID, a.Name)
+	case vendorID > 0:
+		def, ok = d.GetVSAAttributeDefByID(vendorID, VendorAttr(a.Type))
+	case a.Name != "":
+		def, ok = d.GetAttributeDef(a.Name)
+	case a.Extended > 0:
+		if a.Type <= 255 {
+			def, ok = d.GetExtendedAttributeDefByID(AttributeType(a.Extended), uint8(a.Type))
+		}
+	default:
+		def, ok = d.GetAttributeDefByID(AttributeType(a.Type))
+	}
+	if !ok {
+		return nil, fmt.Errorf("attribute %s (%d) not found in dictionary", a.Name, a.Type)
+	}
+
+	var text string
+	if err := json.Unmarshal(a.Value, &text); err != nil {
+		var n json.Number
+		if err := json.Unmarshal(a.Value, &n); err != nil {
+			return nil, fmt.Errorf("invalid value %s for attribute %s", a.Value, def.Name)
+		}
+		text = n.String()
+	}
+	d.RLock()
+	values := d.valueIDs(def.Vendor, def.Name)
+	d.RUnlock()
+	b, err := def.encodeValue(nil, a.Tag, text, values)
+	if err != nil {
+		return nil, err
+	}
+	return jsonAVPs(d, def.Vendor, def.Extended, def.ID, b)
+}
+
+// jsonAVPs builds the attributes carrying a decoded value. extended is the
+// Extended-Attribute of extended attributes, 0 otherwise.
+func jsonAVPs(d *Dictionary, vendorID VendorID, extended AttributeType, attrID uint32, b []byte) ([]AVP, error) {
+	if vendorID > 0 {
+		vsa := VSA{Vendor: vendorID, Type: VendorAttr(attrID), Value: b}
+		return vsa.ToAVPsWithFormat(d.GetVendorFormat(vendorID)), nil
+	}
+	if attrID > 255 {
+		return nil, errors.New("invalid attribute type " + strconv.FormatUint(uint64(attrID), 10))
+	}
+	if extended > 0 {
+		if !IsExtendedType(extended) {
+			return nil, errors.New("invalid extended attribute " + strconv.Itoa(int(extended)))
+		}
+		return (ExtendedAttr{Type: extended, ExtType: uint8(attrID), Value: b}).ToAVPs(), nil
+	}
+	return []AVP{{Type: AttributeType(attrID), Value: b}}, nil
+}
@@ -0,0 +1,111 @@
+package radius
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPacketJSON(t *testing.T) {
+	d := newResolveTestDictionary(t)
+
+	p := Request(AccessRequest, "secret")
+	p.Identifier = 42
+	p.ClientAddr = "192.0.2.10:1812"
+	for _, a := range []struct{ path, value string }{
+		{"User-Name", "alice"},
+		{"User-Password", "s3cret"},
+		{"NAS-Port", "7"},
+		{"Service-Type", "Framed-User"},
+		{"Tunnel-Type:1", "L2TP"},
+		{"Framed-IP-Address", "192.0.2.1"},
+		{"Event-Timestamp", "1700000000"},
+		{"Cisco.Cisco-AVPair", "shell:priv-lvl=15"},
+		{"MS-MPPE-Encryption-Policy", "Encryption-Required"},
+		{"Operator-NAS-Identifier", "\x01\x02"},
+		{"Extended-Vendor-Specific-5", strings.Repeat("x", 300)},
+	} {
+		if err := d.AddAttribute(p, a.path, a.value); err != nil {
+			t.Fatalf("AddAttribute(%s) failed: %v", a.path, err)
+		}
+	}
+	p.AddAVP(AVP{Type: AttrEAPMessage, Value: []byte{2, 1, 0, 4}})
+	p.AddAVP(AVP{Type: 250, Value: []byte{0xde, 0xad}})
+	// MS-MPPE-Encryption-Policy with an unknown value
+	p.AddVSA(VSA{Vendor: 311, Type: 7, Value: []byte{0, 0, 0, 9}})
+
+	b, err := d.MarshalPacketJSON(p)
+	if err != nil {
+		t.Fatalf("MarshalPacketJSON failed: %v", err)
+	}
+	for _, want := range []string{
+		`"code":"AccessRequest"`,
+		`"identifier":42`,
+		`"client":"192.0.2.10:1812"`,
+		`{"name":"User-Name","type":1,"value":"alice"}`,
+		`{"name":"NAS-Port","type":5,"value":7}`,
+		`{"name":"Service-Type","type":6,"value":"Framed-User"}`,
+		`{"name":"Tunnel-Type","type":64,"tag":1,"value":"L2TP"}`,
+		`{"name":"Framed-IP-Address","type":8,"value":"192.0.2.1"}`,
+		`{"name":"Event-Timestamp","type":55,"value":"2023-11-14T22:13:20Z"}`,
+		`{"name":"Cisco-AVPair","type":1,"vendor":"Cisco","vendor_id":9,"value":"shell:priv-lvl=15"}`,
+		`{"name":"MS-MPPE-Encryption-Policy","type":7,"vendor":"Microsoft","vendor_id":311,"value":"Encryption-Required"}`,
+		`{"name":"MS-MPPE-Encryption-Policy","type":7,"vendor":"Microsoft","vendor_id":311,"value":9}`,
+		`{"name":"EAP-Message","type":79,"raw":"02010004"}`,
+		`{"type":250,"raw":"dead"}`,
+		`{"name":"Operator-NAS-Identifier","type":8,"extended":241,"raw":"0102"}`,
+		// long extended fragments
+		`{"type":245,"raw":"1a80787878`,
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("MarshalPacketJSON() lacks %s in\n%s", want, b)
+		}
+	}
+	if strings.Contains(string(b), "s3cret") || strings.Contains(string(b), `"secret"`) {
+		t.Errorf("MarshalPacketJSON() leaks the password or secret:\n%s", b)
+	}
+
+	q, err := d.UnmarshalPacketJSON(b)
+	if err != nil {
+		t.Fatalf("UnmarshalPacketJSON failed: %v", err)
+	}
+	if q.Code != p.Code || q.Identifier != p.Identifier || q.Authenticator != p.Authenticator || q.ClientAddr != p.ClientAddr {
+		t.Errorf("UnmarshalPacketJSON() header = %d/%d/%x/%s", q.Code, q.Identifier, q.Authenticator, q.ClientAddr)
+	}
+	if !reflect.DeepEqual(q.AVPs, p.AVPs) {
+		t.Errorf("UnmarshalPacketJSON() attributes = %v; want %v", q.AVPs, p.AVPs)
+	}
+	q.Secret = p.Secret
+	if q.GetPassword() != "s3cret" {
+		t.Errorf("User-Password = %s after round trip", q.GetPassword())
+	}
+}
+
+func TestUnmarshalPacketJSONErrors(t *testing.T) {
+	d := newResolveTestDictionary(t)
+
+	testCases := []string{
+		`not json`,
+		`{"code":"Bogus","attributes":[]}`,
+		`{"code":"AccessRequest","authenticator":"abcd","attributes":[]}`,
+		`{"code":"AccessRequest","attributes":[{"name":"Nope","type":0,"value":"x"}]}`,
+		`{"code":"AccessRequest","attributes":[{"name":"Service-Type","type":6,"value":"Sometimes"}]}`,
+		`{"code":"AccessRequest","attributes":[{"name":"NAS-Port","type":5,"value":true}]}`,
+		`{"code":"AccessRequest","attributes":[{"type":300,"raw":"00"}]}`,
+		`{"code":"AccessRequest","attributes":[{"type":1,"raw":"zz"}]}`,
+		`{"code":"AccessRequest","attributes":[{"name":"X","type":1,"vendor":"Acme","value":"x"}]}`,
+	}
+	for _, tc := range testCases {
+		if _, err := d.UnmarshalPacketJSON([]byte(tc)); err == nil {
+			t.Errorf("UnmarshalPacketJSON(%s) succeeded", tc)
+		}
+	}
+
+	p, err := d.UnmarshalPacketJSON([]byte(`{"code":"200","attributes":[{"name":"NAS-Port","type":0,"value":"7"},{"type":5,"value":8}]}`))
+	if err != nil {
+		t.Fatalf("UnmarshalPacketJSON failed: %v", err)
+	}
+	if p.Code != 200 || len(p.AVPs) != 2 || p.AVPs[0].Type != AttrNASPort || p.AVPs[1].Value[3] != 8 {
+		t.Errorf("UnmarshalPacketJSON() = %+v", p)
+	}
+}
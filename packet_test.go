@@ -242,3 +242,42 @@ func TestPacketEAPGolden(t *testing.T) {
 		t.Errorf("EAP data mismatch: got %s, want cbFiBooRnZsXnZJ3", string(eap.Data))
 	}
 }
+
+func TestDeleteAllType(t *testing.T) {
+	p := Request(AccessRequest, "secret")
+	p.AddAVP(AVP{Type: AttrReplyMessage, Value: []byte("a")})
+	p.AddAVP(AVP{Type: AttrUserName, Value: []byte("alice")})
+	p.AddAVP(AVP{Type: AttrReplyMessage, Value: []byte("b")})
+
+	p.DeleteAllType(AttrReplyMessage)
+	if len(p.AVPs) != 1 || p.AVPs[0].Type != AttrUserName {
+		t.Errorf("DeleteAllType left %v", p.AVPs)
+	}
+}
+
+func TestDeleteVSA(t *testing.T) {
+	wimax := VendorFormat{TypeSize: 1, LengthSize: 1, Continuation: true}
+	p := Request(AccessRequest, "secret")
+	p.AddVSA(VSA{Vendor: 9, Type: 1, Value: []byte("a=b")})
+	p.AddVSA(VSA{Vendor: 9, Type: 2, Value: []byte("keep")})
+	for _, avp := range (VSA{Vendor: 24757, Type: 3, Value: bytes.Repeat([]byte{1}, 300)}).ToAVPsWithFormat(wimax) {
+		p.AddAVP(avp)
+	}
+	p.AddAVP(AVP{Type: AttrUserName, Value: []byte("alice")})
+
+	if vsa := p.GetVSAWithFormat(24757, 3, wimax); vsa == nil || len(vsa.Value) != 300 {
+		t.Fatalf("GetVSAWithFormat() = %v", vsa)
+	}
+
+	p.DeleteVSA(9, 1)
+	p.DeleteVSAWithFormat(24757, 3, wimax)
+	if len(p.AVPs) != 2 {
+		t.Fatalf("got %d AVPs after delete; want 2", len(p.AVPs))
+	}
+	if vsa := p.GetVSA(9, 2); vsa == nil || string(vsa.Value) != "keep" {
+		t.Errorf("GetVSA(9, 2) = %v", vsa)
+	}
+	if p.GetVSA(9, 1) != nil {
+		t.Errorf("DeleteVSA left the attribute")
+	}
+}
@@ -0,0 +1,505 @@
+package radius
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Operators of the attribute list syntax.
+const (
+	pairOpAdd     = "+="
+	pairOpSet     = "="
+	pairOpReplace = ":="
+)
+
+// rawPairPrefix names attributes by number, as in "Attr-26.9.1".
+const rawPairPrefix = "Attr-"
+
+// ParsePairs is like ReadPairs for a string.
+func (d *Dictionary) ParsePairs(p *Packet, s string) error {
+	return d.ReadPairs(p, strings.NewReader(s))
+}
+
+// ReadPairs parses attributes in the FreeRADIUS attribute list syntax, as
+// read by radclient and written to detail files, and adds them to p:
+//
+//	# comment
+//	User-Name = "bob", User-Password = "hello"
+//	Service-Type = Framed-User
+//	Tunnel-Type:1 = L2TP
+//	Cisco-AVPair += "shell:priv-lvl=15"
+//	Class = 0x0102
+//	Attr-26.9.1 = 0x7878
+//	Operator-NAS-Identifier = 0x0102
+//
+// Attributes are paths as accepted by ResolveAttribute, with an optional
+// ":tag". Values are bare words, double quoted strings with backslash
+// escapes (\n, \t, \", \\, \ooo octal) or single quoted strings, and octets
+// values may be given in hex. "+=" adds the attribute, "=" adds it only when
+// p has none yet and ":=" first removes the attribute from p, as in
+// FreeRADIUS. Attributes unknown to the dictionary are
+// written "Attr-N", "Attr-26.Vendor.N" or, for extended attributes,
+// "Attr-241.N" with a hex value. Values are
+// encrypted with the packet Secret and Authenticator, as for templates.
+//
+// Lines are added as they are parsed: on error the attributes of previous
+// lines are already in p.
+func (d *Dictionary) ReadPairs(p *Packet, r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		if err := d.parsePairLine(p, sc.Text()); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	return sc.Err()
+}
+
+const pairSpace = " \t\r"
+
+func (d *Dictionary) parsePairLine(p *Packet, s string) error {
+	for {
+		s = strings.TrimLeft(s, pairSpace)
+		if s == "" || s[0] == '#' {
+			return nil
+		}
+
+		i := pairNameEnd(s)
+		if i == 0 {
+			return fmt.Errorf("missing attribute name in %q", s)
+		}
+		name := s[:i]
+		s = strings.TrimLeft(s[i:], pairSpace)
+
+		var op string
+		for _, o := range []string{pairOpReplace, pairOpAdd, pairOpSet} {
+			if strings.HasPrefix(s, o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return fmt.Errorf("missing operator after %s", name)
+		}
+		s = strings.TrimLeft(s[len(op):], pairSpace)
+
+		value, quoted, rest, err := parsePairValue(s)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if err := d.addPair(p, name, op, value, quoted); err != nil {
+			return err
+		}
+
+		s = strings.TrimLeft(rest, pairSpace)
+		if s == "" || s[0] == '#' {
+			return nil
+		}
+		if s[0] != ',' {
+			return fmt.Errorf("unexpected %q after %s", s, name)
+		}
+		s = s[1:]
+	}
+}
+
+// pairNameEnd returns the length of the attribute name at the start of s.
+func pairNameEnd(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case ' ', '\t', '\r', '=', '+', ',', '"', '\'':
+			return i
+		case ':':
+			if i+1 < len(s) && s[i+1] == '=' {
+				return i
+			}
+		}
+	}
+	return len(s)
+}
+
+// parsePairValue parses the value at the start of s, returning the rest of s.
+func parsePairValue(s string) (value string, quoted bool, rest string, err error) {
+	if s == "" {
+		return "", false, "", errors.New("missing value")
+	}
+	switch s[0] {
+	case '\'':
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", false, "", errors.New("unterminated string")
+		}
+		return s[1 : end+1], true, s[end+2:], nil
+	case '"':
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			c := s[i]
+			switch {
+			case c == '"':
+				return b.String(), true, s[i+1:], nil
+			case c == '\\' && i+1 < len(s):
+				i++
+				switch c = s[i]; c {
+				case 'n':
+					b.WriteByte('\n')
+				case 'r':
+					b.WriteByte('\r')
+				case 't':
+					b.WriteByte('\t')
+				case '0', '1', '2', '3':
+					n, err := strconv.ParseUint(s[i:min(i+3, len(s))], 8, 8)
+					if err != nil {
+						return "", false, "", fmt.Errorf("invalid escape in %s", s)
+					}
+					b.WriteByte(byte(n))
+					i += 2
+				default:
+					b.WriteByte(c)
+				}
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return "", false, "", errors.New("unterminated string")
+	}
+	end := strings.IndexAny(s, pairSpace+",#")
+	if end < 0 {
+		end = len(s)
+	}
+	return s[:end], false, s[end:], nil
+}
+
+// isHexType reports whether bare values of a data type are hex encoded.
+func isHexType(def AttributeDef) bool {
+	return (def.Type == "octets" || def.Type == "eapmessage") && !def.Flags.Array
+}
+
+func (d *Dictionary) addPair(p *Packet, name, op, value string, quoted bool) error {
+	if strings.HasPrefix(name, rawPairPrefix) {
+		return d.addRawPair(p, name, op, value)
+	}
+
+	t, err := d.GetPathTemplate(name)
+	if err != nil {
+		return err
+	}
+	def := templateDef(t)
+	if !quoted && isHexType(def) && strings.HasPrefix(value, "0x") {
+		b, err := hex.DecodeString(value[2:])
+		if err != nil {
+			return fmt.Errorf("invalid hex value for attribute %s: %s", def.Name, value)
+		}
+		value = string(b)
+	}
+	if !applyPairOp(p, op, pairAttr{def.Vendor, def.Extended, def.ID}, d.GetVendorFormat(def.Vendor)) {
+		return nil
+	}
+	return t.AddValue(p, value)
+}
+
+// pairAttr identifies an attribute for the operators: its vendor, 0 for
+// standard attributes, its Extended-Attribute, 0 for attributes which are
+// not extended, and its number.
+type pairAttr struct {
+	vendor   VendorID
+	extended AttributeType
+	attr     uint32
+}
+
+// applyPairOp prepares p for adding the attribute a with op and reports
+// whether to add it: ":=" first removes the attribute and "=" adds it only
+// when it is absent. f is the VSA format of the vendor.
+func applyPairOp(p *Packet, op string, a pairAttr, f VendorFormat) bool {
+	switch op {
+	case pairOpReplace:
+		if a.vendor > 0 {
+			p.DeleteVSAWithFormat(a.vendor, VendorAttr(a.attr), f)
+		} else if a.extended > 0 {
+			p.DeleteExtendedAttr(a.extended, uint8(a.attr))
+		} else {
+			p.DeleteAllType(AttributeType(a.attr))
+		}
+	case pairOpSet:
+		if a.vendor > 0 {
+			return p.GetVSAWithFormat(a.vendor, VendorAttr(a.attr), f) == nil
+		}
+		if a.extended > 0 {
+			return p.GetExtendedAttr(a.extended, uint8(a.attr)) == nil
+		}
+		return !p.HasAVP(AttributeType(a.attr))
+	}
+	return true
+}
+
+// addRawPair adds an "Attr-N", "Attr-26.Vendor.N" or "Attr-241.N" attribute.
+func (d *Dictionary) addRawPair(p *Packet, name, op, value string) error {
+	if !strings.HasPrefix(value, "0x") {
+		return fmt.Errorf("value of %s must be hex", name)
+	}
+	b, err := hex.DecodeString(value[2:])
+	if err != nil {
+		return fmt.Errorf("invalid hex value for attribute %s: %s", name, value)
+	}
+
+	var ids []uint64
+	for _, part := range strings.Split(strings.TrimPrefix(name, rawPairPrefix), ".") {
+		id, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid attribute %s", name)
+		}
+		ids = append(ids, id)
+	}
+
+	switch {
+	case len(ids) == 1 && ids[0] <= 255:
+		if applyPairOp(p, op, pairAttr{attr: uint32(ids[0])}, DefaultVendorFormat) {
+			p.AddAVP(AVP{Type: AttributeType(ids[0]), Value: b})
+		}
+	case len(ids) == 3 && ids[0] == uint64(AttrVendorSpecific):
+		vendorID := VendorID(ids[1])
+		f := d.GetVendorFormat(vendorID)
+		if !applyPairOp(p, op, pairAttr{vendor: vendorID, attr: uint32(ids[2])}, f) {
+			return nil
+		}
+		for _, avp := range (VSA{Vendor: vendorID, Type: VendorAttr(ids[2]), Value: b}).ToAVPsWithFormat(f) {
+			p.AddAVP(avp)
+		}
+	case len(ids) == 2 && ids[0] <= 255 && IsExtendedType(AttributeType(ids[0])) && ids[1] <= 255:
+		extended := AttributeType(ids[0])
+		if applyPairOp(p, op, pairAttr{extended: extended, attr: uint32(ids[1])}, DefaultVendorFormat) {
+			p.AddExtendedAttr(ExtendedAttr{Type: extended, ExtType: uint8(ids[1]), Value: b})
+		}
+	default:
+		return fmt.Errorf("invalid attribute %s", name)
+	}
+	return nil
+}
+
+// WritePairs writes the attributes of p in the FreeRADIUS attribute list
+// syntax read by ReadPairs, one per line:
+//
+//	User-Name = "bob"
+//	User-Password = "hello"
+//	Tunnel-Type:1 = L2TP
+//	Cisco-AVPair = "shell:priv-lvl=15"
+//	Cisco-AVPair += "ip:addr-pool=pool1"
+//	Class = 0x0102
+//
+// Repeated attributes are written with "+=" so that ReadPairs adds them
+// all. Encrypted values are decrypted with the packet Secret and Authenticator.
+// Vendor attributes whose name is also used by another vendor are qualified
+// by the vendor name. The fragments of long extended attributes are joined.
+// Unknown attributes and values invalid for their type are written by number
+// in hex, as "Attr-N = 0x...".
+func (d *Dictionary) WritePairs(w io.Writer, p *Packet) error {
+	written := make(map[pairAttr]bool)
+	var err error
+	write := func(attr pairAttr, attrType AttributeType, name, value string) bool {
+		// read back, "=" would drop the repeats of an attribute
+		op := pairOpSet
+		if written[attr] {
+			op = pairOpAdd
+		}
+		// "Attr-N" is present once any attribute of type N is
+		written[attr] = true
+		written[pairAttr{attr: uint32(attrType)}] = true
+		_, err = io.WriteString(w, name+" "+op+" "+value+"\n")
+		return err == nil
+	}
+
+	// fragments of a long extended attribute, written once complete
+	var fragments []AVP
+	writeFragments := func() bool {
+		avps := fragments
+		fragments = nil
+		if attr, name, value, ok := d.formatExtendedPair(p, avps); ok {
+			return write(attr, avps[0].Type, name, value)
+		}
+		for _, a := range avps {
+			if !write(pairAttr{attr: uint32(a.Type)}, a.Type, rawPairPrefix+strconv.Itoa(int(a.Type)), rawPairValue(a.Value)) {
+				return false
+			}
+		}
+		return true
+	}
+
+	p.EachAVP(func(a AVP) bool {
+		e, more := decodeExtended(a)
+		if len(fragments) > 0 {
+			if first := ToExtendedAttr(fragments[0]); e == nil || e.Type != first.Type || e.ExtType != first.ExtType {
+				// interrupted by another attribute
+				if !writeFragments() {
+					return false
+				}
+			}
+		}
+		if more || len(fragments) > 0 {
+			fragments = append(fragments, a)
+			return more || writeFragments()
+		}
+		attr, name, value := d.formatPair(p, a)
+		return write(attr, a.Type, name, value)
+	})
+	if err == nil && len(fragments) > 0 {
+		writeFragments()
+	}
+	return err
+}
+
+// formatPair returns the attribute a identifies, with its name and value in
+// the attribute list syntax.
+func (d *Dictionary) formatPair(p *Packet, a AVP) (pairAttr, string, string) {
+	if IsExtendedType(a.Type) {
+		if attr, name, value, ok := d.formatExtendedPair(p, []AVP{a}); ok {
+			return attr, name, value
+		}
+		return pairAttr{attr: uint32(a.Type)}, rawPairPrefix + strconv.Itoa(int(a.Type)), rawPairValue(a.Value)
+	}
+	if a.Type != AttrVendorSpecific || len(a.Value) < 4 {
+		attr := pairAttr{attr: uint32(a.Type)}
+		rawName := rawPairPrefix + strconv.Itoa(int(a.Type))
+		def, ok := d.GetAttributeDefByID(a.Type)
+		if !ok {
+			return attr, rawName, rawPairValue(a.Value)
+		}
+		name, value := d.formatDefPair(p, def, rawName, a.Value)
+		return attr, name, value
+	}
+
+	vendorID := VendorID(binary.BigEndian.Uint32(a.Value[0:4]))
+	f := d.GetVendorFormat(vendorID)
+	vsa, _ := decodeVSA(a, f)
+	// continued (WiMAX) fragments and malformed VSAs
+	avps := vsa.ToAVPsWithFormat(f)
+	if len(avps) != 1 || !bytes.Equal(avps[0].Value, a.Value) {
+		return pairAttr{attr: uint32(a.Type)}, rawPairPrefix + strconv.Itoa(int(a.Type)), rawPairValue(a.Value)
+	}
+	attr := pairAttr{vendor: vendorID, attr: uint32(vsa.Type)}
+	rawName := fmt.Sprintf("%s%d.%d.%d", rawPairPrefix, AttrVendorSpecific, vendorID, vsa.Type)
+	def, ok := d.GetVSAAttributeDefByID(vendorID, vsa.Type)
+	if !ok {
+		return attr, rawName, rawPairValue(vsa.Value)
+	}
+	name, value := d.formatDefPair(p, def, rawName, vsa.Value)
+	return attr, name, value
+}
+
+// formatExtendedPair is formatPair for an extended attribute carried by avps,
+// the fragments of a long extended attribute. It fails for malformed
+// attributes and fragments which don't encode back to avps.
+func (d *Dictionary) formatExtendedPair(p *Packet, avps []AVP) (pairAttr, string, string, bool) {
+	e, _ := decodeExtended(avps[0])
+	if e == nil {
+		return pairAttr{}, "", "", false
+	}
+	for _, a := range avps[1:] {
+		e.Value = append(e.Value, ToExtendedAttr(a).Value...)
+	}
+	encoded := e.ToAVPs()
+	if len(encoded) != len(avps) {
+		return pairAttr{}, "", "", false
+	}
+	for i := range avps {
+		if !bytes.Equal(encoded[i].Value, avps[i].Value) {
+			return pairAttr{}, "", "", false
+		}
+	}
+
+	attr := pairAttr{extended: e.Type, attr: uint32(e.ExtType)}
+	rawName := fmt.Sprintf("%s%d.%d", rawPairPrefix, e.Type, e.ExtType)
+	def, ok := d.GetExtendedAttributeDefByID(e.Type, e.ExtType)
+	if !ok {
+		return attr, rawName, rawPairValue(e.Value), true
+	}
+	name, value := d.formatDefPair(p, def, rawName, e.Value)
+	return attr, name, value, true
+}
+
+func rawPairValue(b []byte) string {
+	return "0x" + hex.EncodeToString(b)
+}
+
+func (d *Dictionary) formatDefPair(p *Packet, def AttributeDef, rawName string, b []byte) (string, string) {
+	tag, plain, err := def.DecodeBytes(p, p.Authenticator[:], b)
+	if err != nil {
+		return rawName, rawPairValue(b)
+	}
+	value, ok := d.formatPairValue(def, plain)
+	if !ok {
+		return rawName, rawPairValue(b)
+	}
+
+	name := def.Name
+	if def.Vendor > 0 {
+		if resolved, err := d.ResolveAttribute(name); err != nil || resolved.Vendor != def.Vendor {
+			name = d.GetVendorName(def.Vendor) + "." + name
+		}
+	}
+	if tag > 0 {
+		name += ":" + strconv.Itoa(int(tag))
+	}
+	return name, value
+}
+
+// formatPairValue formats a plain (untagged and decrypted) value, failing
+// when the text does not encode back to the same bytes.
+func (d *Dictionary) formatPairValue(def AttributeDef, plain []byte) (string, bool) {
+	if isHexType(def) {
+		return "0x" + hex.EncodeToString(plain), true
+	}
+
+	plainDef := def
+	plainDef.Flags.HasTag = false
+	plainDef.Flags.Encrypt = EncryptMethodNone
+	if plainDef.Type == "password" {
+		plainDef.Type = "string"
+	}
+	text := formatValue(d, nil, plainDef, AVP{Type: AttributeType(def.ID), Value: plain})
+
+	d.RLock()
+	values := d.valueIDs(def.Vendor, def.Name)
+	d.RUnlock()
+	encoded, err := plainDef.encodeValue(nil, 0, text, values)
+	if err != nil || !bytes.Equal(encoded, plain) {
+		return "", false
+	}
+
+	if plainDef.Type == "string" || text == "" || strings.ContainsAny(text, pairSpace+",#\"'\\") {
+		return quotePairValue(text), true
+	}
+	return text, true
+}
+
+// quotePairValue double quotes s, escaping quotes, backslashes and control
+// characters.
+func quotePairValue(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f || (r == utf8.RuneError && size == 1):
+			fmt.Fprintf(&b, "\\%03o", s[i])
+		default:
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	b.WriteByte('"')
+	return b.String()
+}
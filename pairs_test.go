@@ -0,0 +1,194 @@
+package radius
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const pairsTestInput = `# radclient input
+User-Name = "bob \"the\" builder", User-Password = 'hello'
+Service-Type = Framed-User
+NAS-Port = 1
+NAS-Port := 7	# replaces the previous value
+NAS-Port = 9	# ignored, NAS-Port is present
+Tunnel-Type:1 = L2TP
+Cisco-AVPair += "shell:priv-lvl=15"
+Cisco.Cisco-AVPair += "ip:addr-pool=pool1"
+MS-MPPE-Encryption-Policy = Encryption-Required
+Class = 0x0102ff
+Reply-Message = "line\none\001"
+Attr-250 = 0xdead
+Attr-250 = 0xbeef
+Attr-26.4242.1 = 0x41
+Attr-26.4242.1 = 0x42
+`
+
+func TestReadPairs(t *testing.T) {
+	d := newResolveTestDictionary(t)
+	p := Request(AccessRequest, "secret")
+	if err := d.ParsePairs(p, pairsTestInput); err != nil {
+		t.Fatalf("ParsePairs failed: %v", err)
+	}
+
+	if got := p.GetUsername(); got != `bob "the" builder` {
+		t.Errorf("User-Name = %q", got)
+	}
+	if got := p.GetPassword(); got != "hello" {
+		t.Errorf("User-Password = %q", got)
+	}
+	ports, raw := 0, 0
+	p.EachAVP(func(a AVP) bool {
+		switch a.Type {
+		case AttrNASPort:
+			ports++
+		case 250:
+			raw++
+		}
+		return true
+	})
+	if got := p.GetNASPort(); got != 7 || ports != 1 {
+		t.Errorf("NAS-Port = %d (%d attributes); want a single 7", got, ports)
+	}
+	if avp := p.GetAVP(AttrTunnelType); avp == nil || !bytes.Equal(avp.Value, []byte{1, 0, 0, 3}) {
+		t.Errorf("Tunnel-Type = %v", avp)
+	}
+	if avp := p.GetAVP(25); avp == nil || !bytes.Equal(avp.Value, []byte{1, 2, 0xff}) {
+		t.Errorf("Class = %v", avp)
+	}
+	if avp := p.GetAVP(AttrReplyMessage); avp == nil || string(avp.Value) != "line\none\x01" {
+		t.Errorf("Reply-Message = %v", avp)
+	}
+	if avp := p.GetAVP(250); avp == nil || !bytes.Equal(avp.Value, []byte{0xde, 0xad}) || raw != 1 {
+		t.Errorf("Attr-250 = %v (%d attributes); want a single 0xdead", avp, raw)
+	}
+	if vsa := p.GetVSA(4242, 1); vsa == nil || string(vsa.Value) != "A" {
+		t.Errorf("Attr-26.4242.1 = %+v", vsa)
+	}
+	var pairs []string
+	p.EachAVP(func(a AVP) bool {
+		if vsa := ToVSA(a); a.Type == AttrVendorSpecific && vsa.Vendor == 9 {
+			pairs = append(pairs, string(vsa.Value))
+		}
+		return true
+	})
+	if !reflect.DeepEqual(pairs, []string{"shell:priv-lvl=15", "ip:addr-pool=pool1"}) {
+		t.Errorf("Cisco-AVPair = %q", pairs)
+	}
+}
+
+func TestReadPairsErrors(t *testing.T) {
+	d := newResolveTestDictionary(t)
+
+	testCases := []struct {
+		input string
+		want  string
+	}{
+		{"User-Name", "line 1: missing operator"},
+		{"\nUser-Name =", "line 2: User-Name: missing value"},
+		{`User-Name = "bob`, "unterminated string"},
+		{"User-Name = bob NAS-Port = 1", "unexpected"},
+		{"= bob", "missing attribute name"},
+		{"No-Such-Attribute = 1", "not found"},
+		{"Service-Type = Sometimes", "invalid value"},
+		{"Class = 0xzz", "invalid hex"},
+		{"Attr-300 = 0x00", "invalid attribute"},
+		{"Attr-250 = dead", "must be hex"},
+		{"User-Name ~= bob", "missing operator"},
+	}
+	for _, tc := range testCases {
+		err := d.ParsePairs(Request(AccessRequest, "secret"), tc.input)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("ParsePairs(%q) error = %v; want %q", tc.input, err, tc.want)
+		}
+	}
+}
+
+func TestWritePairs(t *testing.T) {
+	d := newResolveTestDictionary(t)
+	p := Request(AccessRequest, "secret")
+	if err := d.ParsePairs(p, pairsTestInput+"Tunnel-Password:2 = \"tunnel secret\"\n"); err != nil {
+		t.Fatalf("ParsePairs failed: %v", err)
+	}
+	// NAS-Port with an invalid length
+	p.AddAVP(AVP{Type: AttrNASPort, Value: []byte{1}})
+
+	var buf bytes.Buffer
+	if err := d.WritePairs(&buf, p); err != nil {
+		t.Fatalf("WritePairs failed: %v", err)
+	}
+	want := `User-Name = "bob \"the\" builder"
+User-Password = "hello"
+Service-Type = Framed-User
+NAS-Port = 7
+Tunnel-Type:1 = L2TP
+Cisco-AVPair = "shell:priv-lvl=15"
+Cisco-AVPair += "ip:addr-pool=pool1"
+MS-MPPE-Encryption-Policy = Encryption-Required
+Class = 0x0102ff
+Reply-Message = "line\none\001"
+Attr-250 = 0xdead
+Attr-26.4242.1 = 0x41
+Tunnel-Password:2 = "tunnel secret"
+Attr-5 += 0x01
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WritePairs() =\n%s\nwant\n%s", got, want)
+	}
+
+	q := Request(AccessRequest, "secret")
+	q.Authenticator = p.Authenticator
+	if err := d.ParsePairs(q, buf.String()); err != nil {
+		t.Fatalf("ParsePairs of WritePairs output failed: %v", err)
+	}
+	var again bytes.Buffer
+	if err := d.WritePairs(&again, q); err != nil {
+		t.Fatalf("WritePairs failed: %v", err)
+	}
+	if again.String() != want {
+		t.Errorf("WritePairs() after round trip =\n%s", again.String())
+	}
+}
+
+func TestExtendedPairs(t *testing.T) {
+	d := newResolveTestDictionary(t)
+	p := Request(AccessRequest, "secret")
+	long := strings.Repeat("x", 300)
+	input := `Operator-NAS-Identifier = 0x0102
+Extended-Vendor-Specific-5 = "` + long + `"
+Attr-241.200 = 0x41
+Attr-241.200 = 0x42
+Attr-241.201 := 0x43
+`
+	if err := d.ParsePairs(p, input); err != nil {
+		t.Fatalf("ParsePairs failed: %v", err)
+	}
+	want := []AVP{
+		{Type: AttrExtendedAttribute1, Value: []byte{8, 1, 2}},
+	}
+	want = append(want, (ExtendedAttr{Type: AttrExtendedAttribute5, ExtType: 26, Value: []byte(long)}).ToAVPs()...)
+	want = append(want,
+		AVP{Type: AttrExtendedAttribute1, Value: []byte{200, 0x41}},
+		AVP{Type: AttrExtendedAttribute1, Value: []byte{201, 0x43}},
+	)
+	if !reflect.DeepEqual(p.AVPs, want) {
+		t.Fatalf("ParsePairs() = %v; want %v", p.AVPs, want)
+	}
+	// a fragment without its end
+	p.AddAVP(AVP{Type: AttrExtendedAttribute5, Value: []byte{26, extendedMore, 'y'}})
+
+	var buf bytes.Buffer
+	if err := d.WritePairs(&buf, p); err != nil {
+		t.Fatalf("WritePairs failed: %v", err)
+	}
+	wantText := `Operator-NAS-Identifier = 0x0102
+Attr-245.26 = 0x` + strings.Repeat("78", 300) + `
+Attr-241.200 = 0x41
+Attr-241.201 = 0x43
+Attr-245 += 0x1a8079
+`
+	if got := buf.String(); got != wantText {
+		t.Errorf("WritePairs() =\n%s\nwant\n%s", got, wantText)
+	}
+}
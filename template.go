@@ -2,46 +2,176 @@ package radius
 
 import (
 	"fmt"
+	"log"
+	"strconv"
+	"strings"
 )
 
 // AVPTemplate is an interface for both standard attributes and VSAs.
 type AVPTemplate interface {
 	Add(p *Packet, value string)
+	AddValue(p *Packet, value string) error
 }
 
 // AttributeTemplate stores a pre-resolved attribute definition for reuse.
 type AttributeTemplate struct {
 	attrType AttributeType
 	handler  avpDataType
+	def      AttributeDef
+	tag      uint8
+	// VALUE name -> value, for enumerated attributes
+	values map[string]uint32
 }
 
-// Add encodes the value and adds it to the provided packet.
+// Add encodes the value and adds it to the provided packet, logging invalid
+// values.
+//
+// Attributes flagged encrypt=N are obfuscated using the packet Secret and
+// Authenticator, and concat attributes longer than 253 bytes are split over
+// several AVPs. Enumerated attributes accept VALUE names, like "Framed" for
+// Service-Type.
 func (t *AttributeTemplate) Add(p *Packet, value string) {
+	if err := t.AddValue(p, value); err != nil {
+		log.Printf("Failed to encode attribute %s: %s\n", t.def.Name, err)
+	}
+}
+
+// AddValue is like Add but returns an error for invalid values.
+func (t *AttributeTemplate) AddValue(p *Packet, value string) error {
 	if t.attrType == AttrUserPassword {
 		p.AddPassword(value)
-	} else {
-		p.AddAVP(AVP{
-			Type:  t.attrType,
-			Value: t.handler.FromString(value),
-		})
+		return nil
+	}
+	b, err := t.encode(p, value)
+	if err != nil {
+		return err
 	}
+	for _, chunk := range splitConcat(b, t.def.Flags.Concat) {
+		p.AddAVP(AVP{Type: t.attrType, Value: chunk})
+	}
+	return nil
+}
+
+func (t *AttributeTemplate) encode(p *Packet, value string) ([]byte, error) {
+	if t.def.Type == "" {
+		// template built without dictionary definition
+		b := t.handler.FromString(value)
+		if b == nil {
+			return nil, fmt.Errorf("invalid value for attribute %d: %s", t.attrType, value)
+		}
+		return b, nil
+	}
+	return t.def.encodeValue(p, t.tag, value, t.values)
 }
 
 // VSATemplate stores a pre-resolved VSA definition for reuse.
 type VSATemplate struct {
 	vendorID VendorID
 	vsaType  VendorAttr
+	format   VendorFormat
 	handler  avpDataType
+	def      AttributeDef
+	tag      uint8
+	values   map[string]uint32
 }
 
-// Add encodes the value and adds it as a VSA to the provided packet.
+// Add encodes the value and adds it as a VSA to the provided packet, logging
+// invalid values.
+//
+// Encryption, tags, concat and VALUE names are handled as for
+// AttributeTemplate.Add.
 func (t *VSATemplate) Add(p *Packet, value string) {
-	vsa := VSA{
-		Vendor: t.vendorID,
-		Type:   t.vsaType,
-		Value:  t.handler.FromString(value),
+	if err := t.AddValue(p, value); err != nil {
+		log.Printf("Failed to encode attribute %s: %s\n", t.def.Name, err)
+	}
+}
+
+// AddValue is like Add but returns an error for invalid values.
+func (t *VSATemplate) AddValue(p *Packet, value string) error {
+	b, err := t.def.encodeValue(p, t.tag, value, t.values)
+	if err != nil {
+		return err
+	}
+	chunks := [][]byte{b}
+	if t.def.Flags.Concat && !t.format.Continuation {
+		chunks = splitConcat(b, true)
+	}
+	for _, chunk := range chunks {
+		vsa := VSA{
+			Vendor: t.vendorID,
+			Type:   t.vsaType,
+			Value:  chunk,
+		}
+		for _, avp := range vsa.ToAVPsWithFormat(t.format) {
+			p.AddAVP(avp)
+		}
+	}
+	return nil
+}
+
+// ExtendedTemplate stores a pre-resolved RFC 6929 extended attribute
+// definition for reuse.
+type ExtendedTemplate struct {
+	attrType AttributeType
+	extType  uint8
+	def      AttributeDef
+	tag      uint8
+	values   map[string]uint32
+}
+
+// Add encodes the value and adds it as an extended attribute to the provided
+// packet, logging invalid values.
+//
+// Long values of long extended attributes are split over several attributes.
+// Encryption, tags and VALUE names are handled as for AttributeTemplate.Add.
+func (t *ExtendedTemplate) Add(p *Packet, value string) {
+	if err := t.AddValue(p, value); err != nil {
+		log.Printf("Failed to encode attribute %s: %s\n", t.def.Name, err)
+	}
+}
+
+// AddValue is like Add but returns an error for invalid values.
+func (t *ExtendedTemplate) AddValue(p *Packet, value string) error {
+	b, err := t.def.encodeValue(p, t.tag, value, t.values)
+	if err != nil {
+		return err
 	}
-	p.AddVSA(vsa)
+	e := ExtendedAttr{Type: t.attrType, ExtType: t.extType, Value: b}
+	if !IsLongExtendedType(t.attrType) && len(b) > e.maxValueSize() {
+		return fmt.Errorf("value too long for attribute %s", t.def.Name)
+	}
+	p.AddExtendedAttr(e)
+	return nil
+}
+
+// maxAVPValueSize is the largest value of a single attribute.
+const maxAVPValueSize = 253
+
+// splitConcat splits values of concat attributes into 253 byte chunks.
+func splitConcat(b []byte, concat bool) [][]byte {
+	if !concat || len(b) <= maxAVPValueSize {
+		return [][]byte{b}
+	}
+	var chunks [][]byte
+	for len(b) > maxAVPValueSize {
+		chunks = append(chunks, b[:maxAVPValueSize])
+		b = b[maxAVPValueSize:]
+	}
+	return append(chunks, b)
+}
+
+// splitTag splits an optional ":tag" suffix from an attribute name, as in
+// "Tunnel-Type:1".
+func splitTag(name string) (string, uint8, error) {
+	i := strings.LastIndexByte(name, ':')
+	if i < 0 {
+		return name, 0, nil
+	}
+	tag, err := strconv.ParseUint(name[i+1:], 10, 8)
+	if err != nil || tag > maxTag {
+		return name, 0, fmt.Errorf("invalid tag in %s", name)
+	}
+	return name[:i], uint8(tag), nil
 }
 
 // RequestTemplate defines a reusable structure for RADIUS requests.
@@ -66,11 +196,33 @@ func (t *RequestTemplate) Fill(p *Packet, values ...string) {
 	}
 }
 
+// FillValues is like Fill but stops at the first invalid value.
+func (t *RequestTemplate) FillValues(p *Packet, values ...string) error {
+	for i, val := range values {
+		if i < len(t.templates) {
+			if err := t.templates[i].AddValue(p, val); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // GetTemplate creates an AttributeTemplate for the given attribute name.
+//
+// For has_tag attributes the name may carry a tag suffix, e.g. "Tunnel-Type:1".
 func (d *Dictionary) GetTemplate(name string) (*AttributeTemplate, error) {
 	d.RLock()
 	defer d.RUnlock()
 
+	name, tag, err := d.splitAttributeTag(name, func(n string) (AttributeFlags, bool) {
+		_, ok := d.attrID[n]
+		return d.attrFlags[n], ok
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	id, ok := d.attrID[name]
 	if !ok {
 		return nil, fmt.Errorf("attribute %s not found in dictionary", name)
@@ -86,9 +238,13 @@ func (d *Dictionary) GetTemplate(name string) (*AttributeTemplate, error) {
 		return nil, fmt.Errorf("no handler found for type %s", typeName)
 	}
 
+	def, _ := d.getAttributeDef(name)
 	return &AttributeTemplate{
 		attrType: id,
 		handler:  handler,
+		def:      def,
+		tag:      tag,
+		values:   d.valueIDs(0, name),
 	}, nil
 }
 
@@ -102,6 +258,14 @@ func (d *Dictionary) GetVSATemplate(vendorName, attrName string) (*VSATemplate,
 		return nil, fmt.Errorf("vendor %s not found in dictionary", vendorName)
 	}
 
+	attrName, tag, err := d.splitAttributeTag(attrName, func(n string) (AttributeFlags, bool) {
+		_, ok := d.vsaAttrID[vID][n]
+		return d.vsaAttrFlags[vID][n], ok
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	aID, ok := d.vsaAttrID[vID][attrName]
 	if !ok {
 		return nil, fmt.Errorf("VSA attribute %s not found for vendor %s", attrName, vendorName)
@@ -117,20 +281,42 @@ func (d *Dictionary) GetVSATemplate(vendorName, attrName string) (*VSATemplate,
 		return nil, fmt.Errorf("no handler found for type %s", typeName)
 	}
 
+	def, _ := d.getVSAAttributeDef(vID, attrName)
 	return &VSATemplate{
 		vendorID: vID,
 		vsaType:  aID,
+		format:   d.getVendorFormat(vID),
 		handler:  handler,
+		def:      def,
+		tag:      tag,
+		values:   d.valueIDs(vID, attrName),
 	}, nil
 }
 
-// CreateRequestTemplate creates a RequestTemplate for the given packet code and list of attribute names.
+// splitAttributeTag splits a ":tag" suffix from name when the attribute has_tag.
+// lookup returns the flags of an attribute and whether it is defined.
+func (d *Dictionary) splitAttributeTag(name string, lookup func(string) (AttributeFlags, bool)) (string, uint8, error) {
+	if _, ok := lookup(name); ok || !strings.Contains(name, ":") {
+		return name, 0, nil
+	}
+	base, tag, err := splitTag(name)
+	if err != nil {
+		return name, 0, err
+	}
+	if flags, ok := lookup(base); ok && !flags.HasTag {
+		return name, 0, fmt.Errorf("attribute %s does not support tags", base)
+	}
+	return base, tag, nil
+}
+
+// CreateRequestTemplate creates a RequestTemplate for the given packet code and
+// list of attribute names or paths (see GetPathTemplate).
 func (d *Dictionary) CreateRequestTemplate(code PacketCode, names ...string) (*RequestTemplate, error) {
 	t := &RequestTemplate{
 		code: code,
 	}
 	for _, name := range names {
-		tmpl, err := d.GetTemplate(name)
+		tmpl, err := d.GetPathTemplate(name)
 		if err != nil {
 			return nil, err
 		}
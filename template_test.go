@@ -77,3 +77,33 @@ func TestTemplate(t *testing.T) {
 		}
 	})
 }
+
+func TestRequestTemplatePaths(t *testing.T) {
+	dict, err := NewRFCDictionary()
+	if err != nil {
+		t.Fatalf("NewRFCDictionary failed: %v", err)
+	}
+	if err := dict.LoadVendorPack("cisco"); err != nil {
+		t.Fatalf("LoadVendorPack failed: %v", err)
+	}
+
+	rt, err := dict.CreateRequestTemplate(AccessRequest, "Service-Type", "Cisco.Cisco-AVPair")
+	if err != nil {
+		t.Fatalf("CreateRequestTemplate failed: %v", err)
+	}
+
+	p := Request(AccessRequest, "secret")
+	if err := rt.FillValues(p, "Login-User", "shell:priv-lvl=15"); err != nil {
+		t.Fatalf("FillValues failed: %v", err)
+	}
+	if st := p.GetAVP(AttrServiceType); st == nil || !bytes.Equal(st.Value, []byte{0, 0, 0, 1}) {
+		t.Errorf("Service-Type = %v; want Login-User", st)
+	}
+	if vsa := p.GetVSA(9, 1); vsa == nil || string(vsa.Value) != "shell:priv-lvl=15" {
+		t.Errorf("Cisco-AVPair = %v", vsa)
+	}
+
+	if err := rt.FillValues(Request(AccessRequest, "secret"), "Not-A-Service"); err == nil {
+		t.Errorf("FillValues with an unknown value name succeeded")
+	}
+}